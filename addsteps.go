@@ -0,0 +1,61 @@
+package saga
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// InvalidStepError is the validation error of a step passed to AddSteps, Index is its index in the arguments.
+type InvalidStepError struct {
+	Index int
+	Name  string
+	Err   error
+}
+
+func (e *InvalidStepError) Error() string {
+	return fmt.Sprintf("step %d %s: %v", e.Index, e.Name, e.Err)
+}
+
+func (e *InvalidStepError) Unwrap() error {
+	return e.Err
+}
+
+// StepsError is returned by AddSteps with errors of all invalid steps.
+type StepsError []*InvalidStepError
+
+func (e StepsError) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return "invalid steps: " + strings.Join(messages, "; ")
+}
+
+// AddSteps validates all steps and adds them if they are valid, otherwise no step is added and StepsError
+// names every invalid one. Steps are validated as if they were added one by one by AddStep.
+func (saga *Saga) AddSteps(steps ...*Step) error {
+	validated := *saga
+	validated.steps = append([]*Step(nil), saga.steps...)
+	validated.numbers = make(map[string]int, len(saga.numbers)+len(steps))
+	for name, i := range saga.numbers {
+		validated.numbers[name] = i
+	}
+	var errs StepsError
+	for i, step := range steps {
+		if step == nil {
+			errs = append(errs, &InvalidStepError{Index: i, Err: errors.New("step is nil")})
+			continue
+		}
+		if err := validated.AddStep(step); err != nil {
+			errs = append(errs, &InvalidStepError{Index: i, Name: step.Name, Err: err})
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	saga.steps = validated.steps
+	saga.numbers = validated.numbers
+	saga.pivotStep = validated.pivotStep
+	return nil
+}
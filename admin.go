@@ -0,0 +1,428 @@
+package saga
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrUnknownSaga = errors.New("saga is not registered")
+
+// Admin performs operations on executions in the Store. It's shared by admin transports.
+type Admin struct {
+	// FuncsCtx and CompensateFuncsCtx are passed to coordinators started by admin operations
+	FuncsCtx           context.Context
+	CompensateFuncsCtx context.Context
+	// Clock is passed to coordinators and used for time of audit records
+	Clock Clock
+
+	// Authorizer optionally gates operations by identity of the caller, see WithActor
+	Authorizer Authorizer
+	// Audit optionally receives records about allowed and denied operations
+	Audit AuditSink
+
+	store Store
+	// readOnly is set for ReadOnly stores and their decorators, see IsReadOnly
+	readOnly bool
+	// sagas are keyed by tenant and name
+	sagas map[string]*Saga
+}
+
+// NewAdmin creates Admin for executions in store. Sagas are definitions used to
+// continue executions, they are matched to executions by tenant and name.
+func NewAdmin(store Store, sagas ...*Saga) *Admin {
+	a := &Admin{
+		FuncsCtx:           context.Background(),
+		CompensateFuncsCtx: context.Background(),
+		Clock:              SystemClock,
+		store:              store,
+		sagas:              make(map[string]*Saga, len(sagas)),
+	}
+	a.readOnly = IsReadOnly(store)
+	for _, saga := range sagas {
+		a.sagas[sagaKey(saga.TenantID, saga.Name)] = saga
+	}
+	return a
+}
+
+// ForTenant returns Admin that only sees executions of the tenant, see ForTenant.
+func (a *Admin) ForTenant(tenantID string) *Admin {
+	scoped := *a
+	scoped.store = ForTenant(a.store, tenantID)
+	return &scoped
+}
+
+func sagaKey(tenantID, name string) string {
+	return tenantID + "/" + name
+}
+
+// ListExecutions returns page of executions selected by filter, see Store.ListExecutions.
+func (a *Admin) ListExecutions(filter ExecutionFilter, page Page) ([]*Status, string, error) {
+	return a.store.ListExecutions(filter, page)
+}
+
+// GetStatus returns status of the execution, see GetStatus.
+func (a *Admin) GetStatus(executionID string) (*Status, error) {
+	return GetStatus(a.store, executionID)
+}
+
+// GetLogs returns page of logs of the execution, see Store.GetLogsPage.
+func (a *Admin) GetLogs(executionID string, page Page) ([]*Log, string, error) {
+	return a.store.GetLogsPage(executionID, page)
+}
+
+// Watch returns status of the execution and channel of its logs of types, all by default, appended after the call,
+// see Store.Watch. Logs appended while the status is read are both reflected in it and sent to the channel.
+// The channel is closed when ctx is done, the watch lasts until then even if an error is returned.
+func (a *Admin) Watch(ctx context.Context, executionID string, types ...string) (*Status, <-chan *Log, error) {
+	logs := a.store.Watch(ctx, LogFilter{ExecutionID: executionID, Types: types})
+	status, err := GetStatus(a.store, executionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return status, logs, nil
+}
+
+// Resume continues the interrupted execution, see ExecutionCoordinator.Resume.
+func (a *Admin) Resume(ctx context.Context, executionID string) (*Status, error) {
+	return a.execute(ctx, executionID, OperationResume, (*ExecutionCoordinator).Resume)
+}
+
+// RetryStep retries the failed step of the execution, see ExecutionCoordinator.RetryStep.
+func (a *Admin) RetryStep(ctx context.Context, executionID string) (*Status, error) {
+	return a.execute(ctx, executionID, OperationRetry, (*ExecutionCoordinator).RetryStep)
+}
+
+// Approve approves the paused step of the execution, see ExecutionCoordinator.Approve.
+func (a *Admin) Approve(ctx context.Context, executionID string) (*Status, error) {
+	return a.execute(ctx, executionID, OperationApprove, (*ExecutionCoordinator).Approve)
+}
+
+// Compensate aborts the execution, see ExecutionCoordinator.Compensate.
+func (a *Admin) Compensate(ctx context.Context, executionID string) (*Status, error) {
+	return a.execute(ctx, executionID, OperationCompensate, (*ExecutionCoordinator).Compensate)
+}
+
+func (a *Admin) execute(ctx context.Context, executionID string, operation Operation, f func(*ExecutionCoordinator) (*Result, error)) (*Status, error) {
+	if a.readOnly {
+		return nil, ErrReadOnly
+	}
+	status, err := GetStatus(a.store, executionID)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.authorize(ctx, operation, status); err != nil {
+		return nil, err
+	}
+	saga, ok := a.sagas[sagaKey(status.TenantID, status.Name)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownSaga, status.Name)
+	}
+
+	c := NewExecutionCoordinator(a.FuncsCtx, a.CompensateFuncsCtx, saga, a.store, WithClock(a.Clock),
+		WithExecutionID(executionID), WithAttribution(ActorFromContext(ctx), ReasonFromContext(ctx)))
+	_, err = operate(c, f)
+	a.audit(ctx, operation, executionID, true, err)
+	if err != nil {
+		return nil, err
+	}
+	return GetStatus(a.store, executionID)
+}
+
+// operate performs the operation by the coordinator, errors of the Store panicked by it are returned
+// instead, e.g. *ConflictError if another process has appended logs of the execution meanwhile.
+func operate(c *ExecutionCoordinator, f func(*ExecutionCoordinator) (*Result, error)) (result *Result, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			if pErr, ok := p.(error); ok {
+				err = fmt.Errorf("execution %s failed: %w", c.ExecutionID, pErr)
+				return
+			}
+			err = fmt.Errorf("execution %s failed: %v", c.ExecutionID, p)
+		}
+	}()
+	return f(c)
+}
+
+// claimTimer claims the timer of the execution due by now for the lease, see TimerLoop. It returns false
+// and the timer, zero if there is none, if it isn't due or it has been claimed by another process.
+func (a *Admin) claimTimer(executionID string, now time.Time, lease time.Duration) (bool, time.Time, error) {
+	if a.readOnly {
+		return false, time.Time{}, ErrReadOnly
+	}
+	logs, err := a.store.GetAllLogsByExecutionID(executionID)
+	if err != nil {
+		return false, time.Time{}, err
+	}
+	p := foldProgress(nil, logs)
+	if p.completed {
+		return false, time.Time{}, nil
+	}
+	if fireAt := p.timer(); fireAt.IsZero() || fireAt.After(now) {
+		return false, fireAt, nil
+	}
+	claim := &Log{
+		ExecutionID:  executionID,
+		Name:         p.name,
+		TenantID:     p.tenantID,
+		Metadata:     p.metadata,
+		Type:         LogTypeSagaTimerClaimed,
+		Time:         now,
+		StepDuration: lease,
+	}
+	p.apply(claim)
+	claim.State = p.state()
+	err = AppendLogAt(a.store, claim, len(logs))
+	var conflict *ConflictError
+	if errors.As(err, &conflict) {
+		return false, time.Time{}, nil
+	}
+	return err == nil, time.Time{}, err
+}
+
+func (a *Admin) authorize(ctx context.Context, operation Operation, status *Status) error {
+	if a.Authorizer == nil {
+		return nil
+	}
+	if err := a.Authorizer.Authorize(ctx, operation, status); err != nil {
+		a.audit(ctx, operation, status.ExecutionID, false, err)
+		return fmt.Errorf("%w: %v", ErrNotAuthorized, err)
+	}
+	return nil
+}
+
+func (a *Admin) audit(ctx context.Context, operation Operation, executionID string, allowed bool, err error) {
+	if a.Audit == nil {
+		return
+	}
+	record := &AuditRecord{
+		Time:        a.Clock.Now(),
+		Actor:       ActorFromContext(ctx),
+		Operation:   operation,
+		ExecutionID: executionID,
+		Reason:      ReasonFromContext(ctx),
+		Allowed:     allowed,
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	a.Audit.Record(record)
+}
+
+// AdminHandler exposes Admin operations over HTTP:
+//
+//	GET  /executions                  statuses of executions, see parseExecutionFilter for parameters
+//	GET  /executions/{id}             status of the execution
+//	GET  /executions/{id}/logs        logs of the execution
+//	POST /executions/{id}/resume      continues the interrupted execution
+//	POST /executions/{id}/retry       retries the failed step
+//	POST /executions/{id}/compensate  aborts the execution and compensates executed steps
+//	POST /executions/{id}/approve     approves the paused step
+//
+// Lists are paginated with limit and cursor query parameters, cursor of the next
+// page is returned in X-Next-Cursor header.
+//
+// It can be mounted into an existing mux using http.StripPrefix.
+type AdminHandler struct {
+	*Admin
+
+	// TenantFromRequest optionally returns tenant of the request, all operations are
+	// scoped by it then. Error is responded with 403.
+	TenantFromRequest func(r *http.Request) (string, error)
+	// ActorFromRequest optionally returns identity of the caller passed to Authorizer,
+	// e.g. by verifying Authorization header. Error is responded with 401.
+	ActorFromRequest func(r *http.Request) (string, error)
+}
+
+// NewAdminHandler creates handler for executions in store, see NewAdmin.
+func NewAdminHandler(store Store, sagas ...*Saga) *AdminHandler {
+	return &AdminHandler{Admin: NewAdmin(store, sagas...)}
+}
+
+func (h *AdminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if parts[0] != "executions" || len(parts) > 3 {
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown path %s", r.URL.Path))
+		return
+	}
+
+	a, err := scopeByTenant(h.Admin, h.TenantFromRequest, r)
+	if err != nil {
+		writeError(w, http.StatusForbidden, err)
+		return
+	}
+
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		listExecutions(w, r, a)
+	case len(parts) == 2 && r.Method == http.MethodGet:
+		getStatus(w, a, parts[1])
+	case len(parts) == 3 && parts[2] == "logs" && r.Method == http.MethodGet:
+		getLogs(w, r, a, parts[1])
+	case len(parts) == 3 && r.Method == http.MethodPost:
+		ctx := r.Context()
+		if h.ActorFromRequest != nil {
+			actor, err := h.ActorFromRequest(r)
+			if err != nil {
+				writeError(w, http.StatusUnauthorized, err)
+				return
+			}
+			ctx = WithActor(ctx, actor)
+		}
+		if reason := r.URL.Query().Get("reason"); reason != "" {
+			ctx = WithReason(ctx, reason)
+		}
+		execute(ctx, w, a, parts[1], parts[2])
+	default:
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("%s is not allowed for %s", r.Method, r.URL.Path))
+	}
+}
+
+func scopeByTenant(a *Admin, tenantFromRequest func(r *http.Request) (string, error), r *http.Request) (*Admin, error) {
+	if tenantFromRequest == nil {
+		return a, nil
+	}
+	tenantID, err := tenantFromRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	return a.ForTenant(tenantID), nil
+}
+
+func listExecutions(w http.ResponseWriter, r *http.Request, a *Admin) {
+	filter, err := parseExecutionFilter(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	page, err := parsePage(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	statuses, next, err := a.ListExecutions(filter, page)
+	if err != nil {
+		writeError(w, errorStatusCode(err), err)
+		return
+	}
+	w.Header().Set("X-Next-Cursor", next)
+	writeJSON(w, http.StatusOK, statuses)
+}
+
+// parseExecutionFilter reads ExecutionFilter from query parameters name, state (may be repeated),
+// incomplete (true or false) and from, to (RFC 3339 timestamps).
+func parseExecutionFilter(query url.Values) (ExecutionFilter, error) {
+	filter := ExecutionFilter{
+		Name:   query.Get("name"),
+		States: query["state"],
+	}
+	var err error
+	if v := query.Get("incomplete"); v != "" {
+		if filter.Incomplete, err = strconv.ParseBool(v); err != nil {
+			return filter, fmt.Errorf("invalid incomplete: %v", err)
+		}
+	}
+	if v := query.Get("from"); v != "" {
+		if filter.From, err = time.Parse(time.RFC3339, v); err != nil {
+			return filter, fmt.Errorf("invalid from: %v", err)
+		}
+	}
+	if v := query.Get("to"); v != "" {
+		if filter.To, err = time.Parse(time.RFC3339, v); err != nil {
+			return filter, fmt.Errorf("invalid to: %v", err)
+		}
+	}
+	return filter, nil
+}
+
+func getStatus(w http.ResponseWriter, a *Admin, executionID string) {
+	status, err := a.GetStatus(executionID)
+	if err != nil {
+		writeError(w, errorStatusCode(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+func getLogs(w http.ResponseWriter, r *http.Request, a *Admin, executionID string) {
+	page, err := parsePage(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	logs, next, err := a.GetLogs(executionID, page)
+	if err != nil {
+		writeError(w, errorStatusCode(err), err)
+		return
+	}
+	w.Header().Set("X-Next-Cursor", next)
+	writeJSON(w, http.StatusOK, logs)
+}
+
+func execute(ctx context.Context, w http.ResponseWriter, a *Admin, executionID, operation string) {
+	operations := map[string]func(context.Context, string) (*Status, error){
+		"resume":     a.Resume,
+		"retry":      a.RetryStep,
+		"compensate": a.Compensate,
+		"approve":    a.Approve,
+	}
+	f, ok := operations[operation]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown operation %s", operation))
+		return
+	}
+	status, err := f(ctx, executionID)
+	if err != nil {
+		writeError(w, errorStatusCode(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+func parsePage(query url.Values) (Page, error) {
+	page := Page{Cursor: query.Get("cursor")}
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 0 {
+			return page, fmt.Errorf("invalid limit %s", v)
+		}
+		page.Limit = limit
+	}
+	return page, nil
+}
+
+func errorStatusCode(err error) int {
+	switch {
+	case errors.Is(err, ErrNoLogs):
+		return http.StatusNotFound
+	case errors.Is(err, ErrInvalidCursor):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrUnknownSaga):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, ErrNotAuthorized):
+		return http.StatusForbidden
+	case errors.Is(err, ErrReadOnly):
+		return http.StatusMethodNotAllowed
+	case errors.Is(err, ErrExecutionCompleted), errors.Is(err, ErrNothingToRetry), errors.Is(err, ErrNotPaused),
+		errors.Is(err, ErrPastPivot):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
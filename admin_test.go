@@ -0,0 +1,438 @@
+package saga
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func doAdminRequest(t *testing.T, h http.Handler, method, path string, v interface{}) int {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+	if v != nil {
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), v))
+	}
+	return rec.Code
+}
+
+func TestAdminApprovePausedStep(t *testing.T) {
+	s := NewSaga("approval")
+
+	m := &mock{}
+	m2 := &mock{}
+	comp := &mock{}
+	require.NoError(t, s.AddStep(&Step{Name: "first", Func: m.f, CompensateFunc: comp.f}))
+	require.NoError(t, s.AddStep(&Step{Name: "second", Func: m2.f, CompensateFunc: comp.f, Options: &StepOptions{RequireApproval: true}}))
+
+	logStore := New()
+	c := NewCoordinator(context.Background(), context.Background(), s, logStore)
+	require.True(t, c.Play().Paused)
+	require.Equal(t, 1, m.callCounter)
+	require.Equal(t, 0, m2.callCounter)
+
+	h := NewAdminHandler(logStore, s)
+
+	var statuses []*Status
+	require.Equal(t, http.StatusOK, doAdminRequest(t, h, http.MethodGet, "/executions", &statuses))
+	require.Len(t, statuses, 1)
+	require.Equal(t, c.ExecutionID, statuses[0].ExecutionID)
+	require.Equal(t, http.StatusOK, doAdminRequest(t, h, http.MethodGet, "/executions?state=completed", &statuses))
+	require.Empty(t, statuses)
+	require.Equal(t, http.StatusBadRequest, doAdminRequest(t, h, http.MethodGet, "/executions?from=yesterday", nil))
+
+	var status Status
+	require.Equal(t, http.StatusOK, doAdminRequest(t, h, http.MethodGet, "/executions/"+c.ExecutionID, &status))
+	require.Equal(t, "paused", status.State)
+
+	require.Equal(t, http.StatusOK, doAdminRequest(t, h, http.MethodPost, "/executions/"+c.ExecutionID+"/approve", &status))
+	require.Equal(t, "completed", status.State)
+	require.Equal(t, 1, m2.callCounter)
+	require.Equal(t, 0, comp.callCounter)
+
+	require.Equal(t, http.StatusConflict, doAdminRequest(t, h, http.MethodPost, "/executions/"+c.ExecutionID+"/approve", nil))
+	require.Equal(t, http.StatusNotFound, doAdminRequest(t, h, http.MethodGet, "/executions/"+RandString(), nil))
+}
+
+func TestAdminReadOnly(t *testing.T) {
+	s := NewSaga("approval")
+	require.NoError(t, s.AddStep(&Step{Name: "first", Func: (&mock{}).f, CompensateFunc: (&mock{}).f, Options: &StepOptions{RequireApproval: true}}))
+	logStore := New()
+	c := NewCoordinator(context.Background(), context.Background(), s, logStore)
+	require.True(t, c.Play().Paused)
+
+	readOnly := ReadOnly(logStore)
+	require.Equal(t, ErrReadOnly, readOnly.AppendLog(&Log{ExecutionID: c.ExecutionID}))
+	_, err := readOnly.DeleteByMetadata("customer", "c1")
+	require.Equal(t, ErrReadOnly, err)
+
+	h := NewAdminHandler(readOnly, s)
+	var status Status
+	require.Equal(t, http.StatusOK, doAdminRequest(t, h, http.MethodGet, "/executions/"+c.ExecutionID, &status))
+	require.Equal(t, "paused", status.State)
+	require.Equal(t, http.StatusMethodNotAllowed, doAdminRequest(t, h, http.MethodPost, "/executions/"+c.ExecutionID+"/approve", nil))
+	require.Equal(t, http.StatusOK, doAdminRequest(t, h, http.MethodGet, "/executions/"+c.ExecutionID, &status))
+	require.Equal(t, "paused", status.State)
+
+	// decorators of read-only stores are read-only as well
+	decorated := Instrumented(ForTenant(Namespaced(readOnly, "eu"), "t1"), &testMetrics{})
+	require.True(t, IsReadOnly(decorated))
+	require.False(t, IsReadOnly(Instrumented(logStore, &testMetrics{})))
+	_, err = NewAdmin(decorated, s).Approve(context.Background(), c.ExecutionID)
+	require.Equal(t, ErrReadOnly, err)
+}
+
+func TestAdminRetryAndCompensate(t *testing.T) {
+	s := NewSaga("retry")
+
+	m := &mock{}
+	m2 := &mock{err: errors.New("hello")}
+	comp := &mock{}
+	require.NoError(t, s.AddStep(&Step{Name: "first", Func: m.f, CompensateFunc: comp.f}))
+	require.NoError(t, s.AddStep(&Step{Name: "second", Func: m2.f, CompensateFunc: comp.f}))
+
+	logStore := New()
+	h := NewAdminHandler(logStore, s)
+
+	var status Status
+	executionID := appendInterruptedExecution(t, logStore, s)
+	m2.err = nil
+	require.Equal(t, http.StatusOK, doAdminRequest(t, h, http.MethodPost, "/executions/"+executionID+"/retry", &status))
+	require.Equal(t, "completed", status.State)
+	require.Equal(t, 1, m2.callCounter)
+	require.Equal(t, 0, comp.callCounter)
+
+	executionID = appendInterruptedExecution(t, logStore, s)
+	require.Equal(t, http.StatusOK, doAdminRequest(t, h, http.MethodPost, "/executions/"+executionID+"/compensate", &status))
+	require.Equal(t, "compensated", status.State)
+	require.Equal(t, 2, comp.callCounter)
+	require.Equal(t, http.StatusConflict, doAdminRequest(t, h, http.MethodPost, "/executions/"+executionID+"/retry", nil))
+
+	executionID = appendInterruptedExecution(t, logStore, s)
+	require.Equal(t, http.StatusOK, doAdminRequest(t, h, http.MethodPost, "/executions/"+executionID+"/resume", &status))
+	require.Equal(t, "compensated", status.State)
+	require.Equal(t, 4, comp.callCounter)
+
+	var logs []*Log
+	require.Equal(t, http.StatusOK, doAdminRequest(t, h, http.MethodGet, "/executions/"+executionID+"/logs", &logs))
+	require.Equal(t, LogTypeSagaComplete, logs[len(logs)-1].Type)
+}
+
+// appendInterruptedExecution simulates execution interrupted after failure of the second step but before compensation
+func appendInterruptedExecution(t *testing.T, logStore Store, s *Saga) string {
+	executionID := RandString()
+	first, second := 0, 1
+	firstName, secondName, errStr := "first", "second", "hello"
+	require.NoError(t, logStore.AppendLog(&Log{ExecutionID: executionID, Name: s.Name, Type: LogTypeStartSaga}))
+	require.NoError(t, logStore.AppendLog(&Log{ExecutionID: executionID, Name: s.Name, Type: LogTypeSagaStepExec, StepNumber: &first, StepName: &firstName, StepPayload: []byte("[]")}))
+	require.NoError(t, logStore.AppendLog(&Log{ExecutionID: executionID, Name: s.Name, Type: LogTypeSagaStepExec, StepNumber: &second, StepName: &secondName, StepPayload: []byte("[]"), StepError: &errStr}))
+	return executionID
+}
+
+func TestDashboard(t *testing.T) {
+	s := NewSaga("dashboard")
+	require.NoError(t, s.AddStep(&Step{Name: "first", Func: (&mock{}).f, CompensateFunc: (&mock{}).f}))
+	require.NoError(t, s.AddStep(&Step{Name: "second", Func: (&mock{err: errors.New("some error")}).f, CompensateFunc: (&mock{}).f}))
+
+	logStore := New()
+	c := NewCoordinator(context.Background(), context.Background(), s, logStore)
+	c.Play()
+
+	h := NewDashboardHandler(logStore)
+
+	var sections []*dashboardSection
+	require.Equal(t, http.StatusOK, doAdminRequest(t, h, http.MethodGet, "/api/executions", &sections))
+	require.Len(t, sections, 3)
+	require.Empty(t, sections[0].Executions)
+	require.Len(t, sections[2].Executions, 1)
+	require.Equal(t, c.ExecutionID, sections[2].Executions[0].ExecutionID)
+
+	var execution dashboardExecution
+	require.Equal(t, http.StatusOK, doAdminRequest(t, h, http.MethodGet, "/api/executions/"+c.ExecutionID+"/timeline", &execution))
+	require.Equal(t, "compensated", execution.Status.State)
+	require.Len(t, execution.Steps, 2)
+	require.Equal(t, "second", execution.Steps[1].Name)
+	require.Equal(t, "some error", execution.Steps[1].Error)
+	require.NotNil(t, execution.Steps[0].CompensatedAt)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), `href="executions/`+c.ExecutionID+`"`)
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/executions/"+c.ExecutionID, nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), "some error")
+
+	require.Equal(t, http.StatusNotFound, doAdminRequest(t, h, http.MethodGet, "/api/executions/"+RandString()+"/timeline", nil))
+}
+
+func TestHealth(t *testing.T) {
+	logStore := New()
+	require.NoError(t, logStore.AppendLog(&Log{ExecutionID: RandString(), Name: "hello", Type: LogTypeStartSaga}))
+
+	health := NewHealth(logStore)
+	hb := health.Heartbeat("recovery", time.Hour)
+	h := health.Handler()
+
+	var report HealthReport
+	require.Equal(t, http.StatusOK, doAdminRequest(t, h, http.MethodGet, "/readyz", &report))
+	require.True(t, report.Healthy)
+	require.Equal(t, 1, *report.InFlight)
+	require.Equal(t, "ok", report.Checks["store"])
+	require.Equal(t, "ok", report.Checks["heartbeat:recovery"])
+
+	health.AddCheck("broker", func() error { return errors.New("unavailable") })
+	require.Equal(t, http.StatusServiceUnavailable, doAdminRequest(t, h, http.MethodGet, "/readyz", &report))
+	require.Equal(t, "unavailable", report.Checks["broker"])
+	require.Equal(t, http.StatusOK, doAdminRequest(t, h, http.MethodGet, "/livez", nil))
+
+	hb.timeout = -time.Second
+	require.Equal(t, http.StatusServiceUnavailable, doAdminRequest(t, h, http.MethodGet, "/livez", &report))
+	require.False(t, report.Healthy)
+	hb.timeout = time.Hour
+	hb.Beat()
+	require.Equal(t, http.StatusOK, doAdminRequest(t, h, http.MethodGet, "/livez", nil))
+}
+
+func TestAdminTenantFromRequest(t *testing.T) {
+	s := NewSaga("hello")
+	s.TenantID = "first"
+	require.NoError(t, s.AddStep(&Step{Name: "first", Func: (&mock{}).f, CompensateFunc: (&mock{}).f, Options: &StepOptions{RequireApproval: true}}))
+
+	logStore := New()
+	c := NewCoordinator(context.Background(), context.Background(), s, logStore)
+	require.True(t, c.Play().Paused)
+
+	h := NewAdminHandler(logStore, s)
+	h.TenantFromRequest = func(r *http.Request) (string, error) {
+		if tenantID := r.URL.Query().Get("tenant"); tenantID != "" {
+			return tenantID, nil
+		}
+		return "", errors.New("tenant is required")
+	}
+
+	var statuses []*Status
+	require.Equal(t, http.StatusOK, doAdminRequest(t, h, http.MethodGet, "/executions?tenant=second", &statuses))
+	require.Empty(t, statuses)
+	require.Equal(t, http.StatusForbidden, doAdminRequest(t, h, http.MethodGet, "/executions", nil))
+	require.Equal(t, http.StatusNotFound, doAdminRequest(t, h, http.MethodPost, "/executions/"+c.ExecutionID+"/approve?tenant=second", nil))
+
+	var status Status
+	require.Equal(t, http.StatusOK, doAdminRequest(t, h, http.MethodPost, "/executions/"+c.ExecutionID+"/approve?tenant=first", &status))
+	require.Equal(t, "completed", status.State)
+	require.Equal(t, "first", status.TenantID)
+}
+
+type auditRecords []*AuditRecord
+
+func (r *auditRecords) Record(record *AuditRecord) {
+	*r = append(*r, record)
+}
+
+func TestAdminAuthorizer(t *testing.T) {
+	s := NewSaga("hello")
+	require.NoError(t, s.AddStep(&Step{Name: "first", Func: (&mock{}).f, CompensateFunc: (&mock{}).f, Options: &StepOptions{RequireApproval: true}}))
+
+	logStore := New()
+	c := NewCoordinator(context.Background(), context.Background(), s, logStore)
+	require.True(t, c.Play().Paused)
+
+	var records auditRecords
+	h := NewAdminHandler(logStore, s)
+	h.Audit = &records
+	h.Authorizer = AuthorizerFunc(func(ctx context.Context, operation Operation, status *Status) error {
+		if operation == OperationCompensate && ActorFromContext(ctx) != "admin" {
+			return errors.New("only admin can compensate")
+		}
+		return nil
+	})
+	h.ActorFromRequest = func(r *http.Request) (string, error) {
+		if actor := r.URL.Query().Get("actor"); actor != "" {
+			return actor, nil
+		}
+		return "", errors.New("unknown actor")
+	}
+
+	require.Equal(t, http.StatusUnauthorized, doAdminRequest(t, h, http.MethodPost, "/executions/"+c.ExecutionID+"/compensate", nil))
+	require.Equal(t, http.StatusForbidden, doAdminRequest(t, h, http.MethodPost, "/executions/"+c.ExecutionID+"/compensate?actor=john", nil))
+	require.Equal(t, http.StatusOK, doAdminRequest(t, h, http.MethodPost, "/executions/"+c.ExecutionID+"/compensate?actor=admin", nil))
+
+	require.Len(t, records, 2)
+	require.Equal(t, "john", records[0].Actor)
+	require.Equal(t, OperationCompensate, records[0].Operation)
+	require.False(t, records[0].Allowed)
+	require.Equal(t, "only admin can compensate", records[0].Error)
+	require.Equal(t, "admin", records[1].Actor)
+	require.True(t, records[1].Allowed)
+	require.Equal(t, c.ExecutionID, records[1].ExecutionID)
+}
+
+func TestAdminAttribution(t *testing.T) {
+	s := NewSaga("hello")
+	require.NoError(t, s.AddStep(&Step{Name: "first", Func: (&mock{}).f, CompensateFunc: (&mock{}).f, Options: &StepOptions{RequireApproval: true}}))
+
+	logStore := New()
+	c := NewCoordinator(context.Background(), context.Background(), s, logStore)
+	require.True(t, c.Play().Paused)
+
+	var records auditRecords
+	h := NewAdminHandler(logStore, s)
+	h.Audit = &records
+	h.ActorFromRequest = func(r *http.Request) (string, error) { return "john", nil }
+	require.Equal(t, http.StatusOK, doAdminRequest(t, h, http.MethodPost, "/executions/"+c.ExecutionID+"/approve?reason=checked+manually", nil))
+	require.Equal(t, "checked manually", records[0].Reason)
+
+	logs, err := logStore.GetAllLogsByExecutionID(c.ExecutionID)
+	require.NoError(t, err)
+	require.Equal(t, LogTypeSagaOperation, logs[2].Type)
+	require.Equal(t, LogTypeSagaStepApproved, logs[3].Type)
+	var attribution Attribution
+	require.NoError(t, json.Unmarshal(logs[2].StepPayload, &attribution))
+	require.Equal(t, Attribution{Operation: OperationApprove, Actor: "john", Reason: "checked manually"}, attribution)
+
+	// operations that are rejected aren't recorded
+	require.Equal(t, http.StatusConflict, doAdminRequest(t, h, http.MethodPost, "/executions/"+c.ExecutionID+"/approve", nil))
+	logs, err = logStore.GetAllLogsByExecutionID(c.ExecutionID)
+	require.NoError(t, err)
+	require.Equal(t, LogTypeSagaComplete, logs[len(logs)-1].Type)
+}
+
+func TestCallbackHandler(t *testing.T) {
+	s := NewSaga("hello")
+	require.NoError(t, s.AddStep(&Step{Name: "first", Func: (&mock{}).f, CompensateFunc: (&mock{}).f, Options: &StepOptions{RequireApproval: true}}))
+	logStore := New()
+	c := NewCoordinator(context.Background(), context.Background(), s, logStore)
+	require.True(t, c.Play().Paused)
+
+	secret := []byte("shared secret")
+	h := NewCallbackHandler(NewAdmin(logStore, s), secret)
+	now := time.Now()
+	send := func(signedAt time.Time, signature string, body string) int {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.Header.Set(CallbackTimestampHeader, strconv.FormatInt(signedAt.Unix(), 10))
+		req.Header.Set(CallbackSignatureHeader, signature)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	body := `{"executionId":"` + c.ExecutionID + `","reason":"paid"}`
+
+	require.Equal(t, http.StatusUnauthorized, send(now, SignCallback([]byte("other"), now, []byte(body)), body))
+	require.Equal(t, http.StatusUnauthorized, send(now, SignCallback(secret, now, []byte(body)), body+" "))
+	old := now.Add(-time.Hour)
+	require.Equal(t, http.StatusUnauthorized, send(old, SignCallback(secret, old, []byte(body)), body))
+	require.Equal(t, http.StatusOK, send(now, SignCallback(secret, now, []byte(body)), body))
+
+	status, err := GetStatus(logStore, c.ExecutionID)
+	require.NoError(t, err)
+	require.Equal(t, "completed", status.State)
+	require.Equal(t, http.StatusConflict, send(now, SignCallback(secret, now, []byte(body)), body))
+}
+
+func TestCloudEvents(t *testing.T) {
+	received := make(chan *CloudEvent, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "application/cloudevents+json", r.Header.Get("Content-Type"))
+		var event CloudEvent
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&event))
+		received <- &event
+	}))
+	defer server.Close()
+	sink := NewHTTPEventSink(server.URL, server.Client())
+
+	s := NewSaga("order")
+	require.NoError(t, s.AddStep(&Step{Name: "reserve", Func: (&mock{}).f, CompensateFunc: (&mock{}).f}))
+	require.NoError(t, s.AddStep(&Step{Name: "charge", Func: (&mock{err: errors.New("declined")}).f, CompensateFunc: (&mock{}).f}))
+	c := NewCoordinator(context.Background(), context.Background(), s, CloudEvents(New(), "/orders", sink))
+	require.Error(t, c.Play().ExecutionError)
+	sink.Close()
+	close(received)
+
+	var types []string
+	for event := range received {
+		require.Equal(t, "1.0", event.SpecVersion)
+		require.Equal(t, "/orders", event.Source)
+		require.Equal(t, c.ExecutionID, event.Subject)
+		types = append(types, event.Type)
+	}
+	require.Equal(t, []string{EventTypeStarted, EventTypeStepExecuted, EventTypeStepExecuted, EventTypeAborted, EventTypeCompensated}, types)
+
+	stepError := "declined"
+	event := NewCloudEvent("/orders", &Log{Type: LogTypeSagaStepExec, ExecutionID: "1", StepError: &stepError})
+	var data EventData
+	require.NoError(t, json.Unmarshal(event.Data, &data))
+	require.Equal(t, "declined", *data.StepError)
+	require.Nil(t, NewCloudEvent("/orders", &Log{Type: LogTypeSagaStepCompensate}))
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	secret := []byte("secret")
+	var attempts int
+	received := make(chan *Status, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		timestamp, err := strconv.ParseInt(r.Header.Get(CallbackTimestampHeader), 10, 64)
+		require.NoError(t, err)
+		require.Equal(t, SignCallback(secret, time.Unix(timestamp, 0), body), r.Header.Get(CallbackSignatureHeader))
+		if attempts++; attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var status Status
+		require.NoError(t, json.Unmarshal(body, &status))
+		received <- &status
+	}))
+	defer server.Close()
+
+	store := New()
+	notifier := NewWebhookNotifier(store, secret, server.URL)
+	notifier.Retry = RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond}
+	var failed []error
+	notifier.OnError = func(url string, status *Status, err error) { failed = append(failed, err) }
+
+	s := NewSaga("order")
+	require.NoError(t, s.AddStep(&Step{Name: "reserve", Func: (&mock{}).f, CompensateFunc: (&mock{}).f}))
+	require.NoError(t, s.AddStep(&Step{Name: "charge", Func: (&mock{err: errors.New("declined")}).f, CompensateFunc: (&mock{}).f}))
+	c := NewCoordinator(context.Background(), context.Background(), s, CloudEvents(store, "/orders", notifier))
+	require.Error(t, c.Play().ExecutionError)
+	notifier.Wait()
+
+	require.Empty(t, failed)
+	require.Equal(t, 2, attempts)
+	status := <-received
+	require.Equal(t, c.ExecutionID, status.ExecutionID)
+	require.Equal(t, string(StateCompensated), status.State)
+	require.Equal(t, []string{"declined"}, status.Errors)
+}
+
+func TestWebhookAlerter(t *testing.T) {
+	received := make(chan *Alert, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/down" {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		var alert Alert
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&alert))
+		received <- &alert
+	}))
+	defer server.Close()
+
+	WebhookAlerter(server.URL, server.Client(), nil).Alert(&Alert{Kind: AlertDeadLettered, ExecutionID: "1"})
+	require.Equal(t, &Alert{Kind: AlertDeadLettered, ExecutionID: "1"}, <-received)
+
+	var failed error
+	WebhookAlerter(server.URL+"/down", nil, func(alert *Alert, err error) { failed = err }).Alert(&Alert{Kind: AlertDeadLettered})
+	require.Error(t, failed)
+}
@@ -0,0 +1,128 @@
+package saga
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// AlertKind is the reason of an Alert.
+type AlertKind string
+
+const (
+	// AlertCompensationFailed is sent when an attempt of compensation of a step fails
+	AlertCompensationFailed AlertKind = "compensation-failed"
+	// AlertDeadLettered is sent when guaranteed compensations of an execution have failed all attempts
+	AlertDeadLettered AlertKind = "dead-lettered"
+)
+
+// Alert describes an execution that needs attention of operators.
+type Alert struct {
+	Kind        AlertKind `json:"kind"`
+	ExecutionID string    `json:"executionId"`
+	SagaName    string    `json:"sagaName"`
+	TenantID    string    `json:"tenantId,omitempty"`
+	Time        time.Time `json:"time"`
+	StepNumber  *int      `json:"stepNumber,omitempty"`
+	StepName    string    `json:"stepName,omitempty"`
+	// Tags are StepOptions.Tags of the step
+	Tags  map[string]string `json:"tags,omitempty"`
+	Error string            `json:"error,omitempty"`
+}
+
+// Alerter is called on failures of compensations and dead-lettered executions, e.g. to page operators
+// by PagerDuty or Slack. Compensations may run concurrently, see ParallelOrder, so it must be safe for that.
+type Alerter interface {
+	Alert(alert *Alert)
+}
+
+// AlerterFunc is an adapter to use ordinary functions as Alerter.
+type AlerterFunc func(alert *Alert)
+
+func (f AlerterFunc) Alert(alert *Alert) {
+	f(alert)
+}
+
+// WithAlerter sets Alerter called on failures of compensations and dead-lettered executions.
+func WithAlerter(alerter Alerter) Option {
+	return func(c *ExecutionCoordinator) {
+		c.alerter = alerter
+	}
+}
+
+func (c *ExecutionCoordinator) alert(kind AlertKind, step *int, err error) {
+	if c.alerter == nil {
+		return
+	}
+	alert := &Alert{
+		Kind:        kind,
+		ExecutionID: c.ExecutionID,
+		SagaName:    c.saga.Name,
+		TenantID:    c.saga.TenantID,
+		Time:        c.Clock.Now(),
+		StepNumber:  step,
+	}
+	if step != nil {
+		alert.StepName = c.saga.steps[*step].Name
+		if options := c.saga.steps[*step].Options; options != nil {
+			alert.Tags = options.Tags
+		}
+	}
+	if err != nil {
+		alert.Error = err.Error()
+	}
+	c.alerter.Alert(alert)
+}
+
+// WriterAlerter writes alerts to w as lines of text, e.g. to os.Stdout.
+func WriterAlerter(w io.Writer) Alerter {
+	var mu sync.Mutex
+	return AlerterFunc(func(alert *Alert) {
+		line := fmt.Sprintf("%s saga alert %s: saga %s, execution %s", alert.Time.Format(time.RFC3339), alert.Kind, alert.SagaName, alert.ExecutionID)
+		if alert.StepName != "" {
+			line += ", step " + alert.StepName
+		}
+		if alert.Error != "" {
+			line += ": " + alert.Error
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		_, _ = fmt.Fprintln(w, line)
+	})
+}
+
+// WebhookAlerter POSTs alerts as JSON to the URL, e.g. of an incoming webhook of an alerting service.
+// Alerts are sent synchronously, errors of delivery are passed to onError if it isn't nil.
+func WebhookAlerter(url string, client *http.Client, onError func(alert *Alert, err error)) Alerter {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return AlerterFunc(func(alert *Alert) {
+		err := postAlert(client, url, alert)
+		if err != nil && onError != nil {
+			onError(alert, err)
+		}
+	})
+}
+
+func postAlert(client *http.Client, url string, alert *Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("alert webhook %s: unexpected status %s", url, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package saga
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var ErrNotAuthorized = errors.New("not authorized")
+
+// Operation is an admin operation changing an execution.
+type Operation string
+
+const (
+	OperationResume     Operation = "resume"
+	OperationRetry      Operation = "retry"
+	OperationApprove    Operation = "approve"
+	OperationCompensate Operation = "compensate"
+)
+
+// Authorizer decides whether the caller may perform the operation on the execution.
+// The caller is available from ctx, see ActorFromContext.
+type Authorizer interface {
+	Authorize(ctx context.Context, operation Operation, status *Status) error
+}
+
+// AuthorizerFunc is an adapter to use ordinary functions as Authorizer.
+type AuthorizerFunc func(ctx context.Context, operation Operation, status *Status) error
+
+func (f AuthorizerFunc) Authorize(ctx context.Context, operation Operation, status *Status) error {
+	return f(ctx, operation, status)
+}
+
+// Attribution is the payload of LogTypeSagaOperation logs, it records who performed the operation and why.
+type Attribution struct {
+	Operation Operation `json:"operation"`
+	Actor     string    `json:"actor,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+}
+
+// AuditRecord describes an attempt to perform an admin operation.
+type AuditRecord struct {
+	Time        time.Time `json:"time"`
+	Actor       string    `json:"actor"`
+	Operation   Operation `json:"operation"`
+	ExecutionID string    `json:"executionId"`
+	Reason      string    `json:"reason,omitempty"`
+	Allowed     bool      `json:"allowed"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// AuditSink receives records about all admin operations, both allowed and denied.
+type AuditSink interface {
+	Record(record *AuditRecord)
+}
+
+type (
+	actorKey  struct{}
+	reasonKey struct{}
+)
+
+// WithActor returns context with identity of the caller of admin operations.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns identity of the caller set by WithActor, empty if it's unknown.
+func ActorFromContext(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+// WithReason returns context with the reason of admin operations given by the caller.
+func WithReason(ctx context.Context, reason string) context.Context {
+	return context.WithValue(ctx, reasonKey{}, reason)
+}
+
+// ReasonFromContext returns reason set by WithReason, empty if it isn't given.
+func ReasonFromContext(ctx context.Context) string {
+	reason, _ := ctx.Value(reasonKey{}).(string)
+	return reason
+}
@@ -0,0 +1,106 @@
+package awstrigger
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	saga "github.com/itimofeev/go-saga"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSQS struct {
+	messages []sqstypes.Message
+	deleted  []string
+	cancel   context.CancelFunc
+}
+
+func (q *fakeSQS) ReceiveMessage(ctx context.Context, params *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	if len(q.messages) == 0 {
+		q.cancel()
+		return nil, ctx.Err()
+	}
+	out := &sqs.ReceiveMessageOutput{Messages: q.messages}
+	q.messages = nil
+	return out, nil
+}
+
+func (q *fakeSQS) DeleteMessage(ctx context.Context, params *sqs.DeleteMessageInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error) {
+	q.deleted = append(q.deleted, aws.ToString(params.ReceiptHandle))
+	return &sqs.DeleteMessageOutput{}, nil
+}
+
+type fakeSNS struct {
+	mu        sync.Mutex
+	published []*sns.PublishInput
+}
+
+func (t *fakeSNS) Publish(ctx context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.published = append(t.published, params)
+	return &sns.PublishOutput{}, nil
+}
+
+func (t *fakeSNS) publishedInputs() []*sns.PublishInput {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]*sns.PublishInput(nil), t.published...)
+}
+
+func TestQueueConsumerAndTopicPublisher(t *testing.T) {
+	const queueURL = "https://sqs.eu-west-1.amazonaws.com/1/orders"
+	topic := &fakeSNS{}
+	store := saga.CloudEvents(saga.New(), "/orders", NewTopicPublisher(topic, "arn:aws:sns:eu-west-1:1:sagas"))
+
+	s := saga.NewSaga("order")
+	require.NoError(t, s.AddStep(&saga.Step{Name: "reserve", Func: func(ctx context.Context) error {
+		var order string
+		return saga.Input(ctx, &order)
+	}, CompensateFunc: func(context.Context) error { return nil }}))
+	trigger := saga.NewTrigger(store)
+	trigger.Route(queueURL, s, func(msg *saga.TriggerMessage) (interface{}, error) {
+		if len(msg.Value) == 0 {
+			return nil, errors.New("empty order")
+		}
+		return string(msg.Value), nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	queue := &fakeSQS{
+		messages: []sqstypes.Message{
+			{MessageId: aws.String("1"), ReceiptHandle: aws.String("r1"), Body: aws.String("42"),
+				MessageAttributes: map[string]sqstypes.MessageAttributeValue{"trace": {DataType: aws.String("String"), StringValue: aws.String("abc")}}},
+			{MessageId: aws.String("2"), ReceiptHandle: aws.String("r2")},
+		},
+		cancel: cancel,
+	}
+	consumer := NewQueueConsumer(queue, queueURL, trigger)
+	var failed []error
+	consumer.OnError = func(msg sqstypes.Message, err error) { failed = append(failed, err) }
+	require.NoError(t, consumer.Run(ctx))
+
+	require.Equal(t, []string{"r1", "r2"}, queue.deleted)
+	require.Len(t, failed, 1)
+	require.True(t, errors.Is(failed[0], saga.ErrInvalidMessage))
+	msg := consumer.Message(sqstypes.Message{MessageId: aws.String("1"),
+		MessageAttributes: map[string]sqstypes.MessageAttributeValue{"trace": {DataType: aws.String("String"), StringValue: aws.String("abc")}}})
+	require.Equal(t, "sqs/1", msg.ID)
+	require.Equal(t, map[string]string{"trace": "abc"}, msg.Headers)
+
+	// executions are played in the background, events of terminal states are published at their end
+	require.Eventually(t, func() bool { return len(topic.publishedInputs()) == 1 }, time.Second, time.Millisecond)
+	published := topic.publishedInputs()[0]
+	require.Equal(t, saga.EventTypeCompleted, aws.ToString(published.MessageAttributes["type"].StringValue))
+	require.Equal(t, "sqs/1", aws.ToString(published.MessageAttributes["subject"].StringValue))
+	status, err := saga.GetStatus(store, "sqs/1")
+	require.NoError(t, err)
+	require.Equal(t, string(saga.StateCompleted), status.State)
+}
@@ -0,0 +1,78 @@
+package awstrigger
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sns/types"
+	saga "github.com/itimofeev/go-saga"
+)
+
+// SNSClient is the part of *sns.Client used by TopicPublisher.
+type SNSClient interface {
+	Publish(ctx context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error)
+}
+
+// TopicPublisher is saga.EventSink publishing CloudEvents of executions to an SNS topic, use it with saga.CloudEvents:
+//
+//	store = saga.CloudEvents(store, "/orders", awstrigger.NewTopicPublisher(sns.NewFromConfig(cfg), topicARN))
+//
+// Events are published synchronously with the "type" and "subject" message attributes, so subscriptions
+// can filter them, by default only events of terminal states are published.
+type TopicPublisher struct {
+	// Types are types of published events
+	Types []string
+	// Timeout limits each call of Publish
+	Timeout time.Duration
+	// OnError is called with errors of publishing, failed events are dropped
+	OnError func(event *saga.CloudEvent, err error)
+
+	client   SNSClient
+	topicARN string
+}
+
+func NewTopicPublisher(client SNSClient, topicARN string) *TopicPublisher {
+	return &TopicPublisher{
+		Types:    []string{saga.EventTypeCompleted, saga.EventTypeCompensated, saga.EventTypeDeadLettered},
+		Timeout:  10 * time.Second,
+		OnError:  func(*saga.CloudEvent, error) {},
+		client:   client,
+		topicARN: topicARN,
+	}
+}
+
+func (p *TopicPublisher) Emit(event *saga.CloudEvent) {
+	if !p.published(event.Type) {
+		return
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		p.OnError(event, err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), p.Timeout)
+	defer cancel()
+	_, err = p.client.Publish(ctx, &sns.PublishInput{
+		TopicArn: aws.String(p.topicARN),
+		Message:  aws.String(string(body)),
+		MessageAttributes: map[string]types.MessageAttributeValue{
+			"type":    {DataType: aws.String("String"), StringValue: aws.String(event.Type)},
+			"subject": {DataType: aws.String("String"), StringValue: aws.String(event.Subject)},
+		},
+	})
+	if err != nil {
+		p.OnError(event, err)
+	}
+}
+
+func (p *TopicPublisher) published(typ string) bool {
+	for _, t := range p.Types {
+		if t == typ {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+// Package awstrigger starts sagas from AWS SQS messages by saga.Trigger and publishes events of executions
+// to AWS SNS. It's a separate module, so the saga package itself stays free of AWS dependencies.
+package awstrigger
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	saga "github.com/itimofeev/go-saga"
+)
+
+// SQSClient is the part of *sqs.Client used by QueueConsumer.
+type SQSClient interface {
+	ReceiveMessage(ctx context.Context, params *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error)
+	DeleteMessage(ctx context.Context, params *sqs.DeleteMessageInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error)
+}
+
+// QueueConsumer starts executions of sagas from messages of an SQS queue. Messages have the queue URL as their
+// topic, so sagas are routed by it:
+//
+//	trigger.Route(queueURL, orderSaga, decodeOrder)
+//	go awstrigger.NewQueueConsumer(sqs.NewFromConfig(cfg), queueURL, trigger).Run(ctx)
+//
+// A message is deleted after the start of its execution is written to the Store, so starts are at-least-once:
+// messages redelivered after their visibility timeout resume their executions instead of duplicating them.
+type QueueConsumer struct {
+	// MaxMessages is the number of messages received at once, 1 to 10
+	MaxMessages int32
+	// WaitTimeSeconds is the duration of long polling, up to 20
+	WaitTimeSeconds int32
+	// OnError is called with errors of messages. Messages that can't be started, of topics without routes
+	// or rejected by the mapper, are deleted, other failed messages are redelivered after their visibility timeout
+	OnError func(msg types.Message, err error)
+
+	client   SQSClient
+	queueURL string
+	trigger  *saga.Trigger
+}
+
+func NewQueueConsumer(client SQSClient, queueURL string, trigger *saga.Trigger) *QueueConsumer {
+	return &QueueConsumer{
+		MaxMessages:     10,
+		WaitTimeSeconds: 20,
+		OnError:         func(types.Message, error) {},
+		client:          client,
+		queueURL:        queueURL,
+		trigger:         trigger,
+	}
+}
+
+// Run receives messages until ctx is done, it returns errors of receiving messages.
+func (c *QueueConsumer) Run(ctx context.Context) error {
+	for {
+		out, err := c.client.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:              aws.String(c.queueURL),
+			MaxNumberOfMessages:   c.MaxMessages,
+			WaitTimeSeconds:       c.WaitTimeSeconds,
+			MessageAttributeNames: []string{"All"},
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		for _, msg := range out.Messages {
+			c.handle(ctx, msg)
+		}
+	}
+}
+
+func (c *QueueConsumer) handle(ctx context.Context, msg types.Message) {
+	if _, err := c.trigger.Start(c.Message(msg)); err != nil {
+		c.OnError(msg, err)
+		if !errors.Is(err, saga.ErrNoRoute) && !errors.Is(err, saga.ErrInvalidMessage) {
+			return
+		}
+	}
+	_, err := c.client.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+		QueueUrl:      aws.String(c.queueURL),
+		ReceiptHandle: msg.ReceiptHandle,
+	})
+	if err != nil {
+		c.OnError(msg, err)
+	}
+}
+
+// Message converts the SQS message to saga.TriggerMessage, its ID is derived from the message ID,
+// which is the same for redeliveries. String attributes of the message are its headers.
+func (c *QueueConsumer) Message(msg types.Message) *saga.TriggerMessage {
+	headers := make(map[string]string, len(msg.MessageAttributes))
+	for name, attribute := range msg.MessageAttributes {
+		if attribute.StringValue != nil {
+			headers[name] = *attribute.StringValue
+		}
+	}
+	return &saga.TriggerMessage{
+		ID:      "sqs/" + aws.ToString(msg.MessageId),
+		Topic:   c.queueURL,
+		Value:   []byte(aws.ToString(msg.Body)),
+		Headers: headers,
+	}
+}
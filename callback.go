@@ -0,0 +1,111 @@
+package saga
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var ErrInvalidSignature = errors.New("invalid signature")
+
+const (
+	// CallbackTimestampHeader contains unix time in seconds when the callback was signed
+	CallbackTimestampHeader = "X-Saga-Timestamp"
+	// CallbackSignatureHeader contains signature of the callback, see SignCallback
+	CallbackSignatureHeader = "X-Saga-Signature"
+)
+
+// Callback is the body of callbacks accepted by CallbackHandler.
+type Callback struct {
+	ExecutionID string `json:"executionId"`
+	Reason      string `json:"reason,omitempty"`
+}
+
+// CallbackHandler lets external systems approve paused steps (see StepOptions.RequireApproval)
+// by HTTP callbacks signed with the shared secret. Callbacks are POST requests with Callback in
+// the body and CallbackTimestampHeader and CallbackSignatureHeader set, callbacks signed
+// earlier than MaxAge ago are rejected, so they can't be replayed later.
+type CallbackHandler struct {
+	// Actor is recorded as the actor of approvals, see Attribution
+	Actor  string
+	MaxAge time.Duration
+	Clock  Clock
+
+	admin  *Admin
+	secret []byte
+}
+
+func NewCallbackHandler(admin *Admin, secret []byte) *CallbackHandler {
+	checkOK(len(secret) > 0, "secret must not be empty")
+	return &CallbackHandler{
+		Actor:  "callback",
+		MaxAge: 5 * time.Minute,
+		Clock:  SystemClock,
+		admin:  admin,
+		secret: secret,
+	}
+}
+
+// SignCallback returns value of CallbackSignatureHeader for the body signed at timestamp:
+// hex of HMAC-SHA256 of the timestamp in unix seconds, a dot and the body.
+func SignCallback(secret []byte, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10) + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (h *CallbackHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("%s is not allowed for %s", r.Method, r.URL.Path))
+		return
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := h.verify(r.Header, body); err != nil {
+		writeError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	var callback Callback
+	if err := json.Unmarshal(body, &callback); err != nil || callback.ExecutionID == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid callback: %s", body))
+		return
+	}
+	ctx := WithActor(r.Context(), h.Actor)
+	if callback.Reason != "" {
+		ctx = WithReason(ctx, callback.Reason)
+	}
+	status, err := h.admin.Approve(ctx, callback.ExecutionID)
+	if err != nil {
+		writeError(w, errorStatusCode(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+func (h *CallbackHandler) verify(header http.Header, body []byte) error {
+	seconds, err := strconv.ParseInt(header.Get(CallbackTimestampHeader), 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: no timestamp", ErrInvalidSignature)
+	}
+	timestamp := time.Unix(seconds, 0)
+	if age := h.Clock.Now().Sub(timestamp); age > h.MaxAge || age < -h.MaxAge {
+		return fmt.Errorf("%w: callback is signed at %s", ErrInvalidSignature, timestamp.UTC().Format(time.RFC3339))
+	}
+	expected := SignCallback(h.secret, timestamp, body)
+	if !hmac.Equal([]byte(header.Get(CallbackSignatureHeader)), []byte(expected)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package saga
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"sort"
+)
+
+var ErrChainBroken = errors.New("hash chain of logs is broken")
+
+// HashChained returns Store that sets Log.Hash of each appended log to HMAC-SHA256 of the log
+// and the hash of the previous log of the execution, so VerifyChain can detect logs that were
+// changed, removed or reordered after they were appended. Nil key means plain SHA-256, which only
+// detects accidental changes. Logs of an execution must be appended sequentially, as the coordinator does.
+func HashChained(store Store, key []byte) Store {
+	return &chainedStore{Store: store, key: key}
+}
+
+type chainedStore struct {
+	Store
+	key []byte
+}
+
+func (s *chainedStore) AppendLog(log *Log) error {
+	chained, err := s.chain(log)
+	if err != nil {
+		return err
+	}
+	return s.Store.AppendLog(chained)
+}
+
+func (s *chainedStore) AppendLogAt(log *Log, expected int) error {
+	chained, err := s.chain(log)
+	if err != nil {
+		return err
+	}
+	return AppendLogAt(s.Store, chained, expected)
+}
+
+func (s *chainedStore) DeleteTenantByMetadata(tenantID, key, value string) (int, error) {
+	return DeleteTenantByMetadata(s.Store, tenantID, key, value)
+}
+
+func (s *chainedStore) IsReadOnly() bool {
+	return IsReadOnly(s.Store)
+}
+
+// chain returns copy of the log with Hash chaining it to the last log of the execution.
+func (s *chainedStore) chain(log *Log) (*Log, error) {
+	logs, err := s.Store.GetAllLogsByExecutionID(log.ExecutionID)
+	if err != nil && !errors.Is(err, ErrNoLogs) {
+		return nil, err
+	}
+	var prev []byte
+	if len(logs) > 0 {
+		prev = logs[len(logs)-1].Hash
+	}
+	chained := *log
+	chained.Hash = chainHash(s.key, prev, log)
+	return &chained, nil
+}
+
+// VerifyChain checks hash chain of logs of the execution appended by HashChained with the key.
+func VerifyChain(store Store, executionID string, key []byte) error {
+	logs, err := store.GetAllLogsByExecutionID(executionID)
+	if err != nil {
+		return err
+	}
+	var prev []byte
+	for i, l := range logs {
+		if !hmac.Equal(l.Hash, chainHash(key, prev, l)) {
+			return fmt.Errorf("%w: log %d of %s", ErrChainBroken, i, executionID)
+		}
+		prev = l.Hash
+	}
+	return nil
+}
+
+// chainHash hashes all fields of the log except Hash, each one prefixed by its length.
+func chainHash(key, prev []byte, l *Log) []byte {
+	var h hash.Hash
+	if key == nil {
+		h = sha256.New()
+	} else {
+		h = hmac.New(sha256.New, key)
+	}
+	write := func(b []byte) {
+		var size [8]byte
+		binary.BigEndian.PutUint64(size[:], uint64(len(b)))
+		h.Write(size[:])
+		h.Write(b)
+	}
+	writeInt := func(i int64) {
+		var b [8]byte
+		binary.BigEndian.PutUint64(b[:], uint64(i))
+		write(b[:])
+	}
+	writeOptional := func(s *string) {
+		if s == nil {
+			write(nil)
+			return
+		}
+		write(append([]byte{1}, *s...))
+	}
+
+	write(prev)
+	write([]byte(l.ExecutionID))
+	write([]byte(l.Name))
+	write([]byte(l.TenantID))
+	keys := make([]string, 0, len(l.Metadata))
+	for key := range l.Metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		write([]byte(key))
+		write([]byte(l.Metadata[key]))
+	}
+	write([]byte(l.Type))
+	writeInt(l.Time.UnixNano())
+	if l.StepNumber == nil {
+		write(nil)
+	} else {
+		writeInt(int64(*l.StepNumber))
+	}
+	writeOptional(l.StepName)
+	writeOptional(l.StepError)
+	write(l.StepPayload)
+	if l.StepPayloadTruncated {
+		write([]byte{1})
+	} else {
+		write(nil)
+	}
+	write([]byte(l.StepPayloadRef))
+	writeInt(int64(l.StepDuration))
+	// hashes of logs without state are kept as they were before it was recorded
+	if l.State != "" {
+		write([]byte(l.State))
+	}
+	if l.StepTimedOut {
+		write([]byte{1})
+	}
+	return h.Sum(nil)
+}
@@ -0,0 +1,133 @@
+// Package chaos injects errors, delays and panics into steps and compensations of
+// a saga, so it can be verified that compensations actually work under failure.
+//
+//	injector := chaos.New(time.Now().UnixNano(), chaos.Fault{Step: "charge", Probability: 0.3, Err: chaos.ErrInjected})
+//	c := saga.NewCoordinator(ctx, ctx, injector.Wrap(s), store)
+package chaos
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"reflect"
+	"sync"
+	"time"
+
+	saga "github.com/itimofeev/go-saga"
+)
+
+var ErrInjected = errors.New("chaos: injected fault")
+
+// Phase selects funcs of a step affected by Fault.
+type Phase int
+
+const (
+	// PhaseAny affects both the step func and its compensation
+	PhaseAny Phase = iota
+	PhaseExec
+	PhaseCompensate
+)
+
+// Fault describes what is injected and where. When several faults match a call,
+// the first one that fires is applied.
+type Fault struct {
+	// Step is the name of the affected step, any step if empty
+	Step  string
+	Phase Phase
+	// Probability of the fault in [0, 1], zero means always
+	Probability float64
+
+	// Delay is applied before the func is called or Err is returned, it's interrupted when ctx is done
+	Delay time.Duration
+	// Err is returned instead of calling the func
+	Err error
+	// Panic is passed to panic instead of calling the func
+	Panic interface{}
+}
+
+// Injector wraps sagas so their funcs are affected by faults.
+type Injector struct {
+	faults []Fault
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// New creates injector, seed makes probabilistic faults reproducible.
+func New(seed int64, faults ...Fault) *Injector {
+	return &Injector{faults: faults, rand: rand.New(rand.NewSource(seed))}
+}
+
+// Wrap returns a copy of the saga with step funcs and compensations affected by faults.
+func (i *Injector) Wrap(s *saga.Saga) *saga.Saga {
+	wrapped := saga.NewSaga(s.Name)
+	wrapped.TenantID = s.TenantID
+	for _, step := range s.Steps() {
+		copied := *step
+		copied.Func = i.wrapFunc(step.Name, PhaseExec, step.Func)
+		copied.CompensateFunc = i.wrapFunc(step.Name, PhaseCompensate, step.CompensateFunc)
+		// the step is already validated by the original saga
+		_ = wrapped.AddStep(&copied)
+	}
+	return wrapped
+}
+
+func (i *Injector) wrapFunc(stepName string, phase Phase, f interface{}) interface{} {
+	if f == nil {
+		// compensation of a retriable step or of a step with CompensateSaga
+		return nil
+	}
+	funcValue := reflect.ValueOf(f)
+	funcType := funcValue.Type()
+	return reflect.MakeFunc(funcType, func(args []reflect.Value) []reflect.Value {
+		fault := i.pick(stepName, phase)
+		if fault == nil {
+			return funcValue.Call(args)
+		}
+
+		if fault.Delay > 0 {
+			ctx := args[0].Interface().(context.Context)
+			select {
+			case <-time.After(fault.Delay):
+			case <-ctx.Done():
+				return errorResults(funcType, ctx.Err())
+			}
+		}
+		if fault.Panic != nil {
+			panic(fault.Panic)
+		}
+		if fault.Err != nil {
+			return errorResults(funcType, fault.Err)
+		}
+		return funcValue.Call(args)
+	}).Interface()
+}
+
+func (i *Injector) pick(stepName string, phase Phase) *Fault {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for j := range i.faults {
+		fault := &i.faults[j]
+		if fault.Step != "" && fault.Step != stepName {
+			continue
+		}
+		if fault.Phase != PhaseAny && fault.Phase != phase {
+			continue
+		}
+		if fault.Probability > 0 && i.rand.Float64() >= fault.Probability {
+			continue
+		}
+		return fault
+	}
+	return nil
+}
+
+// errorResults returns zero values of func results with err as the last one.
+func errorResults(funcType reflect.Type, err error) []reflect.Value {
+	results := make([]reflect.Value, funcType.NumOut())
+	for j := range results {
+		results[j] = reflect.Zero(funcType.Out(j))
+	}
+	results[len(results)-1] = reflect.ValueOf(&err).Elem()
+	return results
+}
@@ -0,0 +1,62 @@
+package chaos
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	saga "github.com/itimofeev/go-saga"
+	"github.com/itimofeev/go-saga/sagatest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInjectError(t *testing.T) {
+	script := sagatest.NewScript()
+	s := saga.NewSaga("chaos")
+	require.NoError(t, s.AddStep(script.Step("first")))
+	require.NoError(t, s.AddStep(&saga.Step{
+		Name:           "second",
+		Func:           func(context.Context) (string, error) { return "hello", nil },
+		CompensateFunc: func(_ context.Context, s string) error { return nil },
+	}))
+
+	injector := New(1, Fault{Step: "second", Phase: PhaseExec, Err: ErrInjected})
+	store := sagatest.NewStore()
+	c := saga.NewCoordinator(context.Background(), context.Background(), injector.Wrap(s), store)
+	require.Equal(t, ErrInjected, c.Play().ExecutionError)
+
+	sagatest.AssertCalls(t, script, "first", "first.compensate")
+	sagatest.AssertCompensationOrder(t, store, c.ExecutionID, "second", "first")
+}
+
+func TestInjectPanicIntoCompensation(t *testing.T) {
+	script := sagatest.NewScript()
+	script.Func("first").FailAlways(ErrInjected)
+	s := saga.NewSaga("chaos")
+	require.NoError(t, s.AddStep(script.Step("first")))
+
+	injector := New(1, Fault{Phase: PhaseCompensate, Panic: "boom"})
+	c := saga.NewCoordinator(context.Background(), context.Background(), injector.Wrap(s), saga.New())
+	require.PanicsWithValue(t, "boom", func() { c.Play() })
+}
+
+func TestInjectDelayAndProbability(t *testing.T) {
+	script := sagatest.NewScript()
+	s := saga.NewSaga("chaos")
+	require.NoError(t, s.AddStep(script.Step("first")))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	injector := New(1, Fault{Phase: PhaseExec, Delay: time.Hour})
+	c := saga.NewCoordinator(ctx, context.Background(), injector.Wrap(s), saga.New())
+	require.Equal(t, context.Canceled, c.Play().ExecutionError)
+
+	injector = New(1, Fault{Probability: 0.5, Err: ErrInjected})
+	failures := 0
+	for i := 0; i < 100; i++ {
+		if injector.pick("first", PhaseExec) != nil {
+			failures++
+		}
+	}
+	require.InDelta(t, 50, failures, 20)
+}
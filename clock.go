@@ -0,0 +1,43 @@
+package saga
+
+import "time"
+
+// Clock provides current time to the coordinator, it's replaced in tests.
+type Clock interface {
+	Now() time.Time
+}
+
+// TimerClock is a Clock that also schedules funcs, e.g. at timeouts of steps. Coordinators schedule them
+// by time.AfterFunc if their Clock doesn't implement it.
+type TimerClock interface {
+	Clock
+	// AfterFunc calls f once d has passed on the clock, see time.AfterFunc.
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a func scheduled by TimerClock, see time.Timer.
+type Timer interface {
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// SystemClock is Clock returning time.Now.
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+// afterFunc schedules f by the clock if it's TimerClock, by time.AfterFunc otherwise.
+func afterFunc(clock Clock, d time.Duration, f func()) Timer {
+	if timers, ok := clock.(TimerClock); ok {
+		return timers.AfterFunc(d, f)
+	}
+	return time.AfterFunc(d, f)
+}
@@ -0,0 +1,267 @@
+package saga
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Types of CloudEvents emitted for lifecycle of executions, see CloudEvents.
+const (
+	EventTypeStarted      = "io.github.itimofeev.saga.started"
+	EventTypeStepExecuted = "io.github.itimofeev.saga.step.executed"
+	EventTypeAborted      = "io.github.itimofeev.saga.aborted"
+	EventTypeCompensated  = "io.github.itimofeev.saga.compensated"
+	EventTypeCompleted    = "io.github.itimofeev.saga.completed"
+	EventTypeDeadLettered = "io.github.itimofeev.saga.dead-lettered"
+)
+
+// CloudEvent is an event in the structured JSON format of CloudEvents 1.0 (https://cloudevents.io).
+type CloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Subject         string          `json:"subject,omitempty"`
+	Time            time.Time       `json:"time"`
+	DataContentType string          `json:"datacontenttype,omitempty"`
+	Data            json.RawMessage `json:"data,omitempty"`
+}
+
+// EventData is the data of lifecycle events, Subject of the event is the execution ID.
+type EventData struct {
+	ExecutionID string            `json:"executionId"`
+	Name        string            `json:"name"`
+	TenantID    string            `json:"tenantId,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	State       State             `json:"state,omitempty"`
+	StepNumber  *int              `json:"stepNumber,omitempty"`
+	StepName    *string           `json:"stepName,omitempty"`
+	StepError   *string           `json:"stepError,omitempty"`
+	DurationMs  int64             `json:"durationMs,omitempty"`
+}
+
+// EventSink receives emitted events. Emit is called after the log of the event is appended, so it must not
+// block executions for long: failures of delivery are handled by the sink, e.g. by logging them.
+type EventSink interface {
+	Emit(event *CloudEvent)
+}
+
+// EventSinkFunc is an adapter to use ordinary functions as EventSink.
+type EventSinkFunc func(event *CloudEvent)
+
+func (f EventSinkFunc) Emit(event *CloudEvent) {
+	f(event)
+}
+
+// CloudEvents returns Store that emits lifecycle events of executions to the sink, so other systems
+// can subscribe to outcomes of sagas: started, step executed, aborted, compensated, completed and dead-lettered.
+// Source is the source attribute of the events, e.g. URI of the service. Events of logs appended
+// within a transaction are emitted after it's committed.
+func CloudEvents(store Store, source string, sink EventSink) Store {
+	return &eventStore{Store: store, source: source, sink: sink}
+}
+
+type eventStore struct {
+	Store
+	source string
+	sink   EventSink
+}
+
+func (s *eventStore) AppendLog(log *Log) error {
+	if err := s.Store.AppendLog(log); err != nil {
+		return err
+	}
+	s.emit(log)
+	return nil
+}
+
+func (s *eventStore) AppendLogAt(log *Log, expected int) error {
+	if err := AppendLogAt(s.Store, log, expected); err != nil {
+		return err
+	}
+	s.emit(log)
+	return nil
+}
+
+func (s *eventStore) WithinTx(fn func(tx Store) error) error {
+	var appended []*Log
+	err := WithinTx(s.Store, func(tx Store) error {
+		appended = nil
+		return fn(&txEventStore{Store: tx, appended: &appended})
+	})
+	if err != nil {
+		return err
+	}
+	for _, l := range appended {
+		s.emit(l)
+	}
+	return nil
+}
+
+func (s *eventStore) DeleteTenantByMetadata(tenantID, key, value string) (int, error) {
+	return DeleteTenantByMetadata(s.Store, tenantID, key, value)
+}
+
+func (s *eventStore) IsReadOnly() bool {
+	return IsReadOnly(s.Store)
+}
+
+func (s *eventStore) emit(l *Log) {
+	if event := NewCloudEvent(s.source, l); event != nil {
+		s.sink.Emit(event)
+	}
+}
+
+// txEventStore collects logs appended within a transaction.
+type txEventStore struct {
+	Store
+	appended *[]*Log
+}
+
+func (s *txEventStore) AppendLog(log *Log) error {
+	if err := s.Store.AppendLog(log); err != nil {
+		return err
+	}
+	*s.appended = append(*s.appended, log)
+	return nil
+}
+
+func (s *txEventStore) AppendLogAt(log *Log, expected int) error {
+	if err := AppendLogAt(s.Store, log, expected); err != nil {
+		return err
+	}
+	*s.appended = append(*s.appended, log)
+	return nil
+}
+
+// NewCloudEvent returns the lifecycle event of the log, nil if the log isn't a lifecycle event.
+func NewCloudEvent(source string, l *Log) *CloudEvent {
+	var typ string
+	switch l.Type {
+	case LogTypeStartSaga:
+		typ = EventTypeStarted
+	case LogTypeSagaStepExec:
+		typ = EventTypeStepExecuted
+	case LogTypeSagaAbort:
+		typ = EventTypeAborted
+	case LogTypeSagaDeadLettered:
+		typ = EventTypeDeadLettered
+	case LogTypeSagaComplete:
+		typ = EventTypeCompleted
+		if l.State == StateCompensated {
+			typ = EventTypeCompensated
+		}
+	default:
+		return nil
+	}
+	data, err := json.Marshal(&EventData{
+		ExecutionID: l.ExecutionID,
+		Name:        l.Name,
+		TenantID:    l.TenantID,
+		Metadata:    l.Metadata,
+		State:       l.State,
+		StepNumber:  l.StepNumber,
+		StepName:    l.StepName,
+		StepError:   l.StepError,
+		DurationMs:  int64(l.StepDuration / time.Millisecond),
+	})
+	checkErr(err, "json.Marshal(EventData)")
+	return &CloudEvent{
+		SpecVersion:     "1.0",
+		ID:              DefaultIDGenerator.NewID(),
+		Source:          source,
+		Type:            typ,
+		Subject:         l.ExecutionID,
+		Time:            l.Time,
+		DataContentType: "application/json",
+		Data:            data,
+	}
+}
+
+// HTTPEventSink POSTs events in the structured content mode of the HTTP binding of CloudEvents.
+// Events are sent one by one in order of emitting by a background goroutine, so Emit doesn't block.
+type HTTPEventSink struct {
+	url    string
+	client *http.Client
+	// OnError is called with errors of delivery, events that failed are dropped
+	OnError func(event *CloudEvent, err error)
+
+	mu      sync.Mutex
+	pending *sync.Cond
+	queue   []*CloudEvent
+	closed  bool
+	done    chan struct{}
+}
+
+func NewHTTPEventSink(url string, client *http.Client) *HTTPEventSink {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	s := &HTTPEventSink{url: url, client: client, OnError: func(*CloudEvent, error) {}, done: make(chan struct{})}
+	s.pending = sync.NewCond(&s.mu)
+	go s.run()
+	return s
+}
+
+func (s *HTTPEventSink) Emit(event *CloudEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.queue = append(s.queue, event)
+	s.pending.Signal()
+}
+
+// Close sends pending events and stops the sink, events emitted after it are dropped.
+func (s *HTTPEventSink) Close() {
+	s.mu.Lock()
+	s.closed = true
+	s.pending.Signal()
+	s.mu.Unlock()
+	<-s.done
+}
+
+func (s *HTTPEventSink) run() {
+	defer close(s.done)
+	for {
+		s.mu.Lock()
+		for len(s.queue) == 0 && !s.closed {
+			s.pending.Wait()
+		}
+		if len(s.queue) == 0 {
+			s.mu.Unlock()
+			return
+		}
+		event := s.queue[0]
+		s.queue = s.queue[1:]
+		s.mu.Unlock()
+
+		if err := s.send(event); err != nil {
+			s.OnError(event, err)
+		}
+	}
+}
+
+func (s *HTTPEventSink) send(event *CloudEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Post(s.url, "application/cloudevents+json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("event %s: unexpected status %s", event.ID, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,270 @@
+// Command sagactl manages saga executions through the admin API (see saga.AdminHandler). It doesn't access
+// Stores directly, so operations are authorized and audited by the service serving the API.
+//
+// Usage:
+//
+//	sagactl [-addr http://localhost:8080] [-actor NAME] [-token TOKEN] [-reason TEXT] <command> [execution IDs]
+//
+// Actor (default $USER) is sent in X-Saga-Actor header and token (default $SAGACTL_TOKEN)
+// in Authorization header, so the admin API can authorize operations, see AdminHandler.ActorFromRequest.
+// Reason is recorded in logs of executions along with the actor by resume, retry, approve and abort.
+//
+// Commands:
+//
+//	list [-name N] [-state S] [-incomplete] [-from T] [-to T]
+//	                  prints executions selected by saga name, state and start time (RFC 3339)
+//	inspect ID...     prints status and logs of executions
+//	resume ID...      continues interrupted executions
+//	retry ID...       retries failed steps of executions
+//	approve ID...     approves paused steps of executions
+//	abort ID...       aborts executions and compensates executed steps
+//	export [ID...]    prints logs of executions (all if no ID passed) as JSON lines
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func main() {
+	addr := flag.String("addr", "http://localhost:8080", "address of the saga admin API")
+	actor := flag.String("actor", os.Getenv("USER"), "identity of the operator")
+	token := flag.String("token", os.Getenv("SAGACTL_TOKEN"), "bearer token for the admin API")
+	reason := flag.String("reason", "", "reason of the operation recorded in logs of executions")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: sagactl [-addr URL] [-actor NAME] [-token TOKEN] [-reason TEXT] list|inspect|resume|retry|approve|abort|export [execution IDs]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	c := &client{addr: strings.TrimRight(*addr, "/"), actor: *actor, token: *token, reason: *reason, http: &http.Client{Timeout: time.Minute}}
+	if err := c.run(os.Stdout, flag.Arg(0), flag.Args()[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "sagactl:", err)
+		os.Exit(1)
+	}
+}
+
+type client struct {
+	addr   string
+	actor  string
+	token  string
+	reason string
+	http   *http.Client
+}
+
+func (c *client) run(w io.Writer, command string, ids []string) error {
+	switch command {
+	case "list":
+		return c.list(w, ids)
+	case "inspect":
+		return c.forEach(ids, func(id string) error { return c.inspect(w, id) })
+	case "resume", "retry", "approve":
+		return c.forEach(ids, func(id string) error { return c.post(w, id, command) })
+	case "abort":
+		return c.forEach(ids, func(id string) error { return c.post(w, id, "compensate") })
+	case "export":
+		return c.export(w, ids)
+	default:
+		return fmt.Errorf("unknown command %s", command)
+	}
+}
+
+func (c *client) forEach(ids []string, f func(id string) error) error {
+	if len(ids) == 0 {
+		return errors.New("at least one execution ID is required")
+	}
+	for _, id := range ids {
+		if err := f(id); err != nil {
+			return fmt.Errorf("%s: %v", id, err)
+		}
+	}
+	return nil
+}
+
+func (c *client) list(w io.Writer, args []string) error {
+	flags := flag.NewFlagSet("list", flag.ContinueOnError)
+	name := flags.String("name", "", "name of the saga")
+	state := flags.String("state", "", "state of executions, e.g. failed")
+	incomplete := flags.Bool("incomplete", false, "only executions that hasn't completed yet")
+	from := flags.String("from", "", "started not before this time")
+	to := flags.String("to", "", "started before this time")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	query := url.Values{}
+	for key, value := range map[string]string{"name": *name, "state": *state, "from": *from, "to": *to} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	if *incomplete {
+		query.Set("incomplete", "true")
+	}
+
+	statuses, err := c.statuses(query)
+	if err != nil {
+		return err
+	}
+	for _, status := range statuses {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", status.ExecutionID, status.Name, status.State, status.StartedAt)
+	}
+	return nil
+}
+
+type executionStatus struct {
+	ExecutionID string `json:"executionId"`
+	Name        string `json:"name"`
+	State       string `json:"state"`
+	StartedAt   string `json:"startedAt"`
+}
+
+// pageSize is the number of items requested at once, sagactl follows cursors to fetch the rest
+const pageSize = 100
+
+func (c *client) statuses(query url.Values) ([]executionStatus, error) {
+	var res []executionStatus
+	for cursor := ""; ; {
+		var statuses []executionStatus
+		next, err := c.page("/executions", query, cursor, &statuses)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, statuses...)
+		if next == "" {
+			return res, nil
+		}
+		cursor = next
+	}
+}
+
+func (c *client) logs(id string, f func(l json.RawMessage) error) error {
+	for cursor := ""; ; {
+		var logs []json.RawMessage
+		next, err := c.page("/executions/"+id+"/logs", url.Values{}, cursor, &logs)
+		if err != nil {
+			return err
+		}
+		for _, l := range logs {
+			if err := f(l); err != nil {
+				return err
+			}
+		}
+		if next == "" {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+func (c *client) page(path string, query url.Values, cursor string, v interface{}) (string, error) {
+	query.Set("limit", strconv.Itoa(pageSize))
+	query.Set("cursor", cursor)
+	header, err := c.do(http.MethodGet, path+"?"+query.Encode(), v)
+	if err != nil {
+		return "", err
+	}
+	return header.Get("X-Next-Cursor"), nil
+}
+
+func (c *client) inspect(w io.Writer, id string) error {
+	var status map[string]interface{}
+	if _, err := c.do(http.MethodGet, "/executions/"+id, &status); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "%s\t%s\t%s\t%v\n", status["executionId"], status["name"], status["state"], valueOrEmpty(status["errors"]))
+	return c.logs(id, func(raw json.RawMessage) error {
+		var l map[string]interface{}
+		if err := json.Unmarshal(raw, &l); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "  %s\t%-20s\t%v\t%v\n", l["Time"], l["Type"], valueOrEmpty(l["StepName"]), valueOrEmpty(l["StepError"]))
+		return nil
+	})
+}
+
+func (c *client) post(w io.Writer, id, operation string) error {
+	var status map[string]interface{}
+	path := "/executions/" + id + "/" + operation
+	if c.reason != "" {
+		path += "?" + url.Values{"reason": {c.reason}}.Encode()
+	}
+	if _, err := c.do(http.MethodPost, path, &status); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "%s\t%s\n", id, status["state"])
+	return nil
+}
+
+func (c *client) export(w io.Writer, ids []string) error {
+	if len(ids) == 0 {
+		statuses, err := c.statuses(url.Values{})
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			ids = append(ids, status.ExecutionID)
+		}
+	}
+	enc := json.NewEncoder(w)
+	for _, id := range ids {
+		if err := c.logs(id, func(l json.RawMessage) error { return enc.Encode(l) }); err != nil {
+			return fmt.Errorf("%s: %v", id, err)
+		}
+	}
+	return nil
+}
+
+func (c *client) do(method, path string, v interface{}) (http.Header, error) {
+	req, err := http.NewRequest(method, c.addr+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.actor != "" {
+		req.Header.Set("X-Saga-Actor", c.actor)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(body, &apiErr) == nil && apiErr.Error != "" {
+			return nil, errors.New(apiErr.Error)
+		}
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return resp.Header, json.Unmarshal(body, v)
+}
+
+func valueOrEmpty(v interface{}) interface{} {
+	if v == nil {
+		return ""
+	}
+	return v
+}
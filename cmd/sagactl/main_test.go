@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	saga "github.com/itimofeev/go-saga"
+	"github.com/stretchr/testify/require"
+)
+
+func noop(context.Context) error { return nil }
+
+// newClient returns client of the admin API of the store served by httptest, headers are requests seen by the API.
+func newClient(t *testing.T, store saga.Store, sagas ...*saga.Saga) (*client, *httptest.Server, *[]http.Header) {
+	handler := saga.NewAdminHandler(store, sagas...)
+	handler.ActorFromRequest = func(r *http.Request) (string, error) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			return "", errors.New("invalid token")
+		}
+		return r.Header.Get("X-Saga-Actor"), nil
+	}
+	var headers []http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = append(headers, r.Header)
+		handler.ServeHTTP(w, r)
+	}))
+	c := &client{addr: server.URL, actor: "alice", token: "secret", http: server.Client()}
+	return c, server, &headers
+}
+
+func run(c *client, command string, args ...string) (string, error) {
+	var out bytes.Buffer
+	err := c.run(&out, command, args)
+	return out.String(), err
+}
+
+func TestList(t *testing.T) {
+	s := saga.NewSaga("order")
+	require.NoError(t, s.AddStep(&saga.Step{Name: "reserve", Func: noop, CompensateFunc: noop}))
+	paused := saga.NewSaga("refund")
+	require.NoError(t, paused.AddStep(&saga.Step{Name: "refund", Func: noop, CompensateFunc: noop,
+		Options: &saga.StepOptions{RequireApproval: true}}))
+
+	store := saga.New()
+	// more executions than a page, so the cursor is followed
+	for i := 0; i < pageSize+20; i++ {
+		require.NoError(t, saga.NewCoordinator(context.Background(), context.Background(), s, store).Play().ExecutionError)
+	}
+	c := saga.NewCoordinator(context.Background(), context.Background(), paused, store)
+	require.True(t, c.Play().Paused)
+
+	client, server, headers := newClient(t, store, s, paused)
+	defer server.Close()
+	out, err := run(client, "list")
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	require.Len(t, lines, pageSize+21)
+	require.Len(t, *headers, 2)
+
+	out, err = run(client, "list", "-state", "paused", "-name", "refund")
+	require.NoError(t, err)
+	require.Equal(t, []string{c.ExecutionID, "refund", "paused"}, strings.Split(out, "\t")[:3])
+	out, err = run(client, "list", "-incomplete", "-from", "2000-01-01T00:00:00Z")
+	require.NoError(t, err)
+	require.Equal(t, c.ExecutionID, strings.Split(out, "\t")[0])
+
+	_, err = run(client, "list", "-from", "yesterday")
+	require.EqualError(t, err, "invalid from: parsing time \"yesterday\" as \"2006-01-02T15:04:05Z07:00\": cannot parse \"yesterday\" as \"2006\"")
+}
+
+func TestInspectAndExport(t *testing.T) {
+	// more logs than a page, so the cursor is followed
+	s := saga.NewSaga("batch")
+	for i := 0; i < pageSize; i++ {
+		require.NoError(t, s.AddStep(&saga.Step{Name: "item" + strconv.Itoa(i), Func: noop, CompensateFunc: noop}))
+	}
+	store := saga.New()
+	var ids []string
+	for i := 0; i < 2; i++ {
+		c := saga.NewCoordinator(context.Background(), context.Background(), s, store)
+		require.NoError(t, c.Play().ExecutionError)
+		ids = append(ids, c.ExecutionID)
+	}
+	logs, err := store.GetAllLogsByExecutionID(ids[0])
+	require.NoError(t, err)
+	require.True(t, len(logs) > pageSize)
+
+	client, server, _ := newClient(t, store, s)
+	defer server.Close()
+	out, err := run(client, "inspect", ids[0])
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	require.Len(t, lines, len(logs)+1)
+	require.Equal(t, []string{ids[0], "batch", "completed"}, strings.Split(lines[0], "\t")[:3])
+	require.Contains(t, lines[len(lines)-1], saga.LogTypeSagaComplete)
+
+	out, err = run(client, "export", ids[1])
+	require.NoError(t, err)
+	lines = strings.Split(strings.TrimSpace(out), "\n")
+	require.Len(t, lines, len(logs))
+	var l saga.Log
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &l))
+	require.Equal(t, ids[1], l.ExecutionID)
+	require.Equal(t, saga.LogTypeStartSaga, l.Type)
+
+	// all executions are exported without IDs
+	out, err = run(client, "export")
+	require.NoError(t, err)
+	require.Len(t, strings.Split(strings.TrimSpace(out), "\n"), 2*len(logs))
+
+	_, err = run(client, "inspect", "unknown")
+	require.EqualError(t, err, "unknown: no logs found")
+	_, err = run(client, "inspect")
+	require.EqualError(t, err, "at least one execution ID is required")
+	_, err = run(client, "restart", ids[0])
+	require.EqualError(t, err, "unknown command restart")
+}
+
+func TestOperations(t *testing.T) {
+	failures := 1
+	s := saga.NewSaga("order")
+	require.NoError(t, s.AddStep(&saga.Step{Name: "approve", Func: noop, CompensateFunc: noop,
+		Options: &saga.StepOptions{RequireApproval: true}}))
+	require.NoError(t, s.AddStep(&saga.Step{Name: "charge", Func: func(context.Context) error {
+		if failures > 0 {
+			failures--
+			return errors.New("declined")
+		}
+		return nil
+	}, CompensateFunc: noop, Options: &saga.StepOptions{Retry: &saga.RetryPolicy{MaxAttempts: 3, Backoff: time.Minute}}}))
+
+	store := saga.New()
+	play := func() string {
+		c := saga.NewCoordinator(context.Background(), context.Background(), s, store)
+		require.True(t, c.Play().Paused)
+		return c.ExecutionID
+	}
+	client, server, headers := newClient(t, store, s)
+	defer server.Close()
+	client.reason = "ticket 42"
+
+	// the charge fails once, its retry is scheduled
+	approved := play()
+	out, err := run(client, "approve", approved)
+	require.NoError(t, err)
+	require.Equal(t, approved+"\tfailed\n", out)
+	require.Equal(t, "alice", (*headers)[0].Get("X-Saga-Actor"))
+	require.Equal(t, "Bearer secret", (*headers)[0].Get("Authorization"))
+	out, err = run(client, "retry", approved)
+	require.NoError(t, err)
+	require.Equal(t, approved+"\tcompleted\n", out)
+	_, err = run(client, "resume", approved)
+	require.EqualError(t, err, approved+": execution already completed")
+
+	// the reason is recorded along with the actor
+	logs, err := store.GetLogs(approved, []string{saga.LogTypeSagaOperation}, time.Time{}, time.Time{})
+	require.NoError(t, err)
+	var attribution saga.Attribution
+	require.NoError(t, json.Unmarshal(logs[0].StepPayload, &attribution))
+	require.Equal(t, saga.Attribution{Operation: saga.OperationApprove, Actor: "alice", Reason: "ticket 42"}, attribution)
+
+	aborted, resumed := play(), play()
+	out, err = run(client, "abort", aborted)
+	require.NoError(t, err)
+	require.Equal(t, aborted+"\tcompensated\n", out)
+	out, err = run(client, "resume", resumed)
+	require.NoError(t, err)
+	require.Equal(t, resumed+"\tpaused\n", out)
+
+	client.token = "stolen"
+	_, err = run(client, "approve", resumed)
+	require.EqualError(t, err, resumed+": invalid token")
+}
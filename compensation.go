@@ -0,0 +1,358 @@
+package saga
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// CompensationOrder groups steps to compensate into stages: stages are compensated one after another,
+// compensations of steps in the same stage run in parallel. Steps are given by numbers in reverse order
+// of their execution, the result must contain each of them once. Logs of parallel compensations are
+// appended concurrently, so it requires a Store that supports it, e.g. not HashChained.
+type CompensationOrder func(steps []int) [][]int
+
+// ReverseOrder compensates steps one by one in reverse order of execution, it's the default.
+func ReverseOrder(steps []int) [][]int {
+	stages := make([][]int, 0, len(steps))
+	for _, step := range steps {
+		stages = append(stages, []int{step})
+	}
+	return stages
+}
+
+// ParallelOrder compensates all steps in parallel, for sagas with independent undo actions.
+func ParallelOrder(steps []int) [][]int {
+	if len(steps) == 0 {
+		return nil
+	}
+	return [][]int{append([]int(nil), steps...)}
+}
+
+// StagesOrder compensates steps of the saga named in each stage in parallel, stages in the given order.
+// Steps that aren't named are compensated after that in reverse order.
+func StagesOrder(saga *Saga, stages ...[]string) CompensationOrder {
+	numbers := make(map[string]int, len(saga.steps))
+	for _, stage := range stages {
+		for _, name := range stage {
+			step, ok := saga.StepNumber(name)
+			checkOK(ok, fmt.Sprintf("saga %s has no step %s", saga.Name, name))
+			numbers[name] = step
+		}
+	}
+	return func(steps []int) [][]int {
+		pending := make(map[int]bool, len(steps))
+		for _, step := range steps {
+			pending[step] = true
+		}
+		var res [][]int
+		for _, stage := range stages {
+			var numbersOfStage []int
+			for _, name := range stage {
+				if step := numbers[name]; pending[step] {
+					numbersOfStage = append(numbersOfStage, step)
+					delete(pending, step)
+				}
+			}
+			if len(numbersOfStage) > 0 {
+				res = append(res, numbersOfStage)
+			}
+		}
+		for _, step := range steps {
+			if pending[step] {
+				res = append(res, []int{step})
+			}
+		}
+		return res
+	}
+}
+
+// DependencyOrder compensates steps of the saga in parallel except chains of dependencies: a step is compensated
+// after all executed steps that depend on it, e.g. "ship" depends on "reserve" if shipping needs the reservation,
+// so "ship" is compensated before "reserve". Dependencies are given by names of the saga's steps:
+//
+//	s.CompensationOrder = saga.DependencyOrder(s, map[string][]string{"ship": {"reserve", "charge"}})
+//
+// Stages are formed by depth in the chains, so unrelated steps share a stage, see Saga.MaxParallelCompensations.
+func DependencyOrder(saga *Saga, dependsOn map[string][]string) CompensationOrder {
+	// dependents are numbers of steps depending on each step
+	dependents := make(map[int][]int, len(dependsOn))
+	for name, dependencies := range dependsOn {
+		step, ok := saga.StepNumber(name)
+		checkOK(ok, fmt.Sprintf("saga %s has no step %s", saga.Name, name))
+		for _, dependency := range dependencies {
+			number, ok := saga.StepNumber(dependency)
+			checkOK(ok, fmt.Sprintf("saga %s has no step %s", saga.Name, dependency))
+			checkOK(number < step, fmt.Sprintf("step %s must follow its dependency %s", name, dependency))
+			dependents[number] = append(dependents[number], step)
+		}
+	}
+	return func(steps []int) [][]int {
+		pending := make(map[int]bool, len(steps))
+		for _, step := range steps {
+			pending[step] = true
+		}
+		// steps are in reverse order of execution and dependents follow their dependencies,
+		// so the depth of each step's dependents is known when it's reached
+		depths := make(map[int]int, len(steps))
+		var res [][]int
+		for _, step := range steps {
+			depth := 0
+			for _, dependent := range dependents[step] {
+				if pending[dependent] && depths[dependent]+1 > depth {
+					depth = depths[dependent] + 1
+				}
+			}
+			depths[step] = depth
+			if depth == len(res) {
+				res = append(res, nil)
+			}
+			res[depth] = append(res[depth], step)
+		}
+		return res
+	}
+}
+
+// compensateStages compensates steps of the logs in stages of the saga CompensationOrder,
+// it returns numbers of steps whose compensations have failed.
+func (c *ExecutionCoordinator) compensateStages(toCompensateLogs []*Log) []int {
+	logs := make(map[int]*Log, len(toCompensateLogs))
+	steps := make([]int, 0, len(toCompensateLogs))
+	for _, l := range toCompensateLogs {
+		logs[*l.StepNumber] = l
+		steps = append(steps, *l.StepNumber)
+	}
+	order := c.saga.CompensationOrder
+	if order == nil {
+		order = ReverseOrder
+	}
+	stages := order(steps)
+	checkOK(countSteps(stages) == len(steps), "compensation order must contain each step once")
+
+	var failed []int
+	for _, stage := range stages {
+		errs := make([]error, len(stage))
+		if len(stage) == 1 {
+			errs[0] = c.compensateLog(logs[stage[0]])
+		} else {
+			var wg sync.WaitGroup
+			panics := make([]interface{}, len(stage))
+			limit := len(stage)
+			if max := c.saga.MaxParallelCompensations; max > 0 && max < limit {
+				limit = max
+			}
+			running := make(chan struct{}, limit)
+			for i, step := range stage {
+				l, ok := logs[step]
+				checkOK(ok, "compensation order must contain each step once")
+				wg.Add(1)
+				running <- struct{}{}
+				go func(i int, l *Log) {
+					defer wg.Done()
+					defer func() { <-running }()
+					// errors of the Store panic in the caller as with sequential compensation
+					defer func() { panics[i] = recover() }()
+					errs[i] = c.compensateLog(l)
+				}(i, l)
+			}
+			wg.Wait()
+			for _, p := range panics {
+				if p != nil {
+					panic(p)
+				}
+			}
+		}
+		for i, err := range errs {
+			if err != nil {
+				c.compensateErrors = append(c.compensateErrors, err)
+				failed = append(failed, stage[i])
+			}
+		}
+	}
+	return failed
+}
+
+func countSteps(stages [][]int) int {
+	seen := make(map[int]bool)
+	for _, stage := range stages {
+		for _, step := range stage {
+			if seen[step] {
+				return -1
+			}
+			seen[step] = true
+		}
+	}
+	return len(seen)
+}
+
+// compensateLog compensates the step of the exec log and logs the error of the compensation.
+func (c *ExecutionCoordinator) compensateLog(toCompensateLog *Log) error {
+	step := *toCompensateLog.StepNumber
+	var err error
+	if c.saga.steps[step].CompensateSaga != nil {
+		err = c.compensateBySaga(step)
+	} else {
+		err = c.compensateByFunc(toCompensateLog)
+	}
+	if err != nil {
+		errStr := err.Error()
+		c.appendLog(&Log{
+			Type:       LogTypeSagaStepCompensateFailed,
+			StepNumber: &step,
+			StepName:   &c.saga.steps[step].Name,
+			StepError:  &errStr,
+		})
+		c.alert(AlertCompensationFailed, &step, err)
+	}
+	c.afterCompensate(step, err)
+	return err
+}
+
+// compensateByFunc calls Step.CompensateFunc of the step of the exec log with its payload.
+func (c *ExecutionCoordinator) compensateByFunc(toCompensateLog *Log) error {
+	compensateFuncRaw := c.saga.steps[*toCompensateLog.StepNumber].CompensateFunc
+	compensateFunc, ok := directFunc(compensateFuncRaw)
+	if !ok {
+		compensateFunc = c.reflectCompensateFunc(toCompensateLog, compensateFuncRaw)
+	}
+
+	ctx := c.compensateFuncsCtx
+	var timeout time.Duration
+	if options := c.saga.steps[*toCompensateLog.StepNumber].Options; options != nil && options.CompensationTimeout > 0 {
+		timeout = options.CompensationTimeout
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	err := c.compensateStep(ctx, *toCompensateLog.StepNumber, compensateFunc)
+	if err != nil && timeout > 0 && ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("%w after %s: %v", ErrCompensationTimeout, timeout, err)
+	}
+	return err
+}
+
+// reflectCompensateFunc returns func calling the compensate func by reflection with the payload of the exec log.
+func (c *ExecutionCoordinator) reflectCompensateFunc(toCompensateLog *Log, compensateFuncRaw interface{}) func(ctx context.Context) error {
+	compensateFuncValue := getFuncValue(compensateFuncRaw)
+	compensateRuncType := reflect.TypeOf(compensateFuncRaw)
+
+	types := make([]reflect.Type, 0, compensateRuncType.NumIn())
+	for i := 1; i < compensateRuncType.NumIn(); i++ {
+		types = append(types, compensateRuncType.In(i))
+	}
+	var unmarshal []reflect.Value
+	if payload := c.loadPayload(toCompensateLog); payload != nil {
+		var err error
+		unmarshal, err = unmarshalParams(types, payload)
+		checkErr(err, "unmarshalParams()")
+	} else {
+		unmarshal = make([]reflect.Value, 0, len(types))
+		for _, typ := range types {
+			unmarshal = append(unmarshal, reflect.Zero(typ))
+		}
+	}
+	return func(ctx context.Context) error {
+		params := make([]reflect.Value, 0, len(unmarshal)+1)
+		params = append(params, reflect.ValueOf(ctx))
+		params = append(params, unmarshal...)
+		return isReturnError(compensateFuncValue.Call(params))
+	}
+}
+
+// ErrCompensationTimeout wraps errors of compensate funcs returned after StepOptions.CompensationTimeout.
+var ErrCompensationTimeout = errors.New("compensation timed out")
+
+// ErrCompensationPending is the compensation error of a step whose Step.CompensateSaga hasn't completed,
+// e.g. it's delayed by a retry. The saga is resumed by the next attempt of the compensation, see Guaranteed.
+var ErrCompensationPending = errors.New("compensate saga hasn't completed")
+
+// compensateBySaga plays or resumes the Step.CompensateSaga of the step. Its execution ID is derived
+// from the execution ID and the step, so attempts of the compensation resume the same execution.
+func (c *ExecutionCoordinator) compensateBySaga(step int) error {
+	c.appendLog(&Log{
+		Type:       LogTypeSagaStepCompensate,
+		StepNumber: &step,
+		StepName:   &c.saga.steps[step].Name,
+	})
+
+	c.syncLogs()
+	compensateSaga := c.saga.steps[step].CompensateSaga
+	compensateCoordinator := NewExecutionCoordinator(c.compensateFuncsCtx, c.compensateFuncsCtx, compensateSaga, c.logStore,
+		WithExecutionID(fmt.Sprintf("%s/compensate/%d", c.ExecutionID, step)),
+		WithIDGenerator(c.idGenerator),
+		WithMetadata(map[string]string{ParentExecutionMetadata: c.ExecutionID}),
+		WithClock(c.Clock),
+		WithHooks(c.hooks),
+		WithLogger(c.logger),
+		WithAlerter(c.alerter))
+	compensateCoordinator.middleware = c.middleware
+	compensateCoordinator.payloadLimit = c.payloadLimit
+	result := resumeOrPlay(compensateCoordinator)
+	switch {
+	case result.Paused || result.Delayed || result.Deferred || result.DeadLettered:
+		return fmt.Errorf("%w: %s", ErrCompensationPending, compensateSaga.Name)
+	case result.ExecutionError != nil:
+		return fmt.Errorf("compensate saga %s: %w", compensateSaga.Name, result.ExecutionError)
+	}
+	return nil
+}
+
+// CompensationClass is what happens when the compensation of a step fails.
+type CompensationClass int
+
+const (
+	// BestEffort compensation is attempted once: its failure is logged and returned in Result.CompensateErrors,
+	// but the execution is still completed as compensated
+	BestEffort CompensationClass = iota
+	// Guaranteed compensation is retried by StepOptions.CompensationRetry until it succeeds. The time of
+	// the next attempt is written to the Store, the attempt is made by Resume called after it, see Status.FireAt.
+	// After MaxAttempts the execution is dead-lettered: it stays incomplete with state dead-lettered
+	// until Resume or Compensate retries its compensations again
+	Guaranteed
+)
+
+// DefaultCompensationRetry retries guaranteed compensations that have no StepOptions.CompensationRetry.
+var DefaultCompensationRetry = RetryPolicy{MaxAttempts: 10, Backoff: time.Second, MaxBackoff: 5 * time.Minute}
+
+// compensationRetry returns the retry policy of guaranteed compensation of the step, nil if it's best-effort.
+func (step *Step) compensationRetry() *RetryPolicy {
+	if step.Options == nil || step.Options.Compensation != Guaranteed {
+		return nil
+	}
+	if step.Options.CompensationRetry != nil {
+		return step.Options.CompensationRetry
+	}
+	return &DefaultCompensationRetry
+}
+
+// retryCompensations schedules the next attempt of failed guaranteed compensations
+// or dead-letters the execution if one of them has failed all attempts.
+func (c *ExecutionCoordinator) retryCompensations(p *progress, failed []int) {
+	var backoff time.Duration
+	for _, step := range failed {
+		policy := c.saga.steps[step].compensationRetry()
+		if policy == nil {
+			continue
+		}
+		// the progress includes the attempt that has just failed
+		attempts := p.compensateAttempts[step]
+		if policy.MaxAttempts > 0 && attempts >= policy.MaxAttempts {
+			c.appendLog(&Log{Type: LogTypeSagaDeadLettered})
+			c.alert(AlertDeadLettered, &step, fmt.Errorf("compensation failed %d attempts", attempts))
+			return
+		}
+		if b := policy.backoff(attempts); backoff == 0 || b < backoff {
+			backoff = b
+		}
+	}
+	if backoff > 0 {
+		c.delayed = true
+		c.appendLog(&Log{
+			Type:         LogTypeSagaCompensationRetryScheduled,
+			StepDuration: backoff,
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package saga
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrConflict is matched by errors.Is for *ConflictError.
+var ErrConflict = errors.New("conflicting append")
+
+// ConflictError is returned by SequencedStore.AppendLogAt if another writer has appended logs
+// to the execution since the expected sequence, e.g. a second coordinator that believes it owns it.
+type ConflictError struct {
+	ExecutionID string
+	// Expected is the number of logs the writer expected, Actual is the number of logs in the store
+	Expected int
+	Actual   int
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("execution %s has %d logs, but %d were expected", e.ExecutionID, e.Actual, e.Expected)
+}
+
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// SequencedStore is implemented by stores with optimistic concurrency of appends. The coordinator
+// appends logs of executions by AppendLogAt with the number of logs it knows of, so two coordinators
+// of one execution can't interleave their logs: the loser panics with *ConflictError.
+type SequencedStore interface {
+	// AppendLogAt appends the log if the execution has exactly expected logs, zero for a new execution,
+	// otherwise it fails with *ConflictError
+	AppendLogAt(log *Log, expected int) error
+}
+
+// AppendLogAt appends the log at the expected sequence if the store is a SequencedStore,
+// otherwise it appends the log without the check.
+func AppendLogAt(store Store, log *Log, expected int) error {
+	if sequenced, ok := store.(SequencedStore); ok {
+		return sequenced.AppendLogAt(log, expected)
+	}
+	return store.AppendLog(log)
+}
+
+func (s *store) AppendLogAt(log *Log, expected int) error {
+	return s.append([]*Log{log}, []int{expected})
+}
+
+// checkSequence returns *ConflictError if the execution doesn't have the expected number of logs, sh.mu must be locked.
+func (sh *shard) checkSequence(executionID string, expected int) error {
+	if actual := len(sh.m[executionID]); actual != expected {
+		return &ConflictError{ExecutionID: executionID, Expected: expected, Actual: actual}
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package saga
+
+import (
+	"context"
+	"time"
+)
+
+// ContextStore is Store whose methods take context of the call for timeouts, tracing and cancellation.
+// New implementations can implement it and be used as Store by BindContext, existing ones are
+// adapted by WithContext.
+type ContextStore interface {
+	AppendLog(ctx context.Context, log *Log) error
+	GetAllLogsByExecutionID(ctx context.Context, executionID string) ([]*Log, error)
+	GetStepLogsToCompensate(ctx context.Context, executionID string) ([]*Log, error)
+	GetLogs(ctx context.Context, executionID string, types []string, from, to time.Time) ([]*Log, error)
+	GetLogsPage(ctx context.Context, executionID string, page Page) ([]*Log, string, error)
+	ListExecutions(ctx context.Context, filter ExecutionFilter, page Page) ([]*Status, string, error)
+	DeleteByMetadata(ctx context.Context, key, value string) (int, error)
+	Watch(ctx context.Context, filter LogFilter) <-chan *Log
+}
+
+// WithContext adapts Store to ContextStore: calls fail with ctx.Err() if ctx is done, otherwise they are
+// passed to the store, which can't interrupt them.
+func WithContext(store Store) ContextStore {
+	if bound, ok := store.(*boundStore); ok {
+		return bound.store
+	}
+	return &contextStore{store: store}
+}
+
+// BindContext adapts ContextStore to Store whose calls take ctx, e.g. to pass it to NewCoordinator.
+// Logs of compensations are appended with ctx as well, so it shouldn't be canceled when the execution is.
+func BindContext(store ContextStore, ctx context.Context) Store {
+	if adapted, ok := store.(*contextStore); ok {
+		return adapted.store
+	}
+	return &boundStore{store: store, ctx: ctx}
+}
+
+type contextStore struct {
+	store Store
+}
+
+func (s *contextStore) AppendLog(ctx context.Context, log *Log) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.store.AppendLog(log)
+}
+
+func (s *contextStore) GetAllLogsByExecutionID(ctx context.Context, executionID string) ([]*Log, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.store.GetAllLogsByExecutionID(executionID)
+}
+
+func (s *contextStore) GetStepLogsToCompensate(ctx context.Context, executionID string) ([]*Log, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.store.GetStepLogsToCompensate(executionID)
+}
+
+func (s *contextStore) GetLogs(ctx context.Context, executionID string, types []string, from, to time.Time) ([]*Log, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.store.GetLogs(executionID, types, from, to)
+}
+
+func (s *contextStore) GetLogsPage(ctx context.Context, executionID string, page Page) ([]*Log, string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, "", err
+	}
+	return s.store.GetLogsPage(executionID, page)
+}
+
+func (s *contextStore) ListExecutions(ctx context.Context, filter ExecutionFilter, page Page) ([]*Status, string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, "", err
+	}
+	return s.store.ListExecutions(filter, page)
+}
+
+func (s *contextStore) DeleteByMetadata(ctx context.Context, key, value string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return s.store.DeleteByMetadata(key, value)
+}
+
+func (s *contextStore) Watch(ctx context.Context, filter LogFilter) <-chan *Log {
+	return s.store.Watch(ctx, filter)
+}
+
+type boundStore struct {
+	store ContextStore
+	ctx   context.Context
+}
+
+func (s *boundStore) AppendLog(log *Log) error {
+	return s.store.AppendLog(s.ctx, log)
+}
+
+func (s *boundStore) GetAllLogsByExecutionID(executionID string) ([]*Log, error) {
+	return s.store.GetAllLogsByExecutionID(s.ctx, executionID)
+}
+
+func (s *boundStore) GetStepLogsToCompensate(executionID string) ([]*Log, error) {
+	return s.store.GetStepLogsToCompensate(s.ctx, executionID)
+}
+
+func (s *boundStore) GetLogs(executionID string, types []string, from, to time.Time) ([]*Log, error) {
+	return s.store.GetLogs(s.ctx, executionID, types, from, to)
+}
+
+func (s *boundStore) GetLogsPage(executionID string, page Page) ([]*Log, string, error) {
+	return s.store.GetLogsPage(s.ctx, executionID, page)
+}
+
+func (s *boundStore) ListExecutions(filter ExecutionFilter, page Page) ([]*Status, string, error) {
+	return s.store.ListExecutions(s.ctx, filter, page)
+}
+
+func (s *boundStore) DeleteByMetadata(key, value string) (int, error) {
+	return s.store.DeleteByMetadata(s.ctx, key, value)
+}
+
+// Watch merges ctx of the call with the bound one, the channel is closed when either of them is done.
+func (s *boundStore) Watch(ctx context.Context, filter LogFilter) <-chan *Log {
+	merged, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-s.ctx.Done():
+		case <-merged.Done():
+		}
+		cancel()
+	}()
+	return s.store.Watch(merged, filter)
+}
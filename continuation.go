@@ -0,0 +1,158 @@
+package saga
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrNoParent = errors.New("execution isn't a continuation of another one")
+	ErrNoOutput = errors.New("no output of step")
+)
+
+// ParentExecutionMetadata is the metadata key of continuations holding ID of the parent execution, see Saga.OnSuccess.
+const ParentExecutionMetadata = "saga.parentExecutionID"
+
+// continuation is the payload of LogTypeSagaContinued logs.
+type continuation struct {
+	ExecutionID string `json:"executionId"`
+	Name        string `json:"name"`
+}
+
+// OnSuccess makes executions of the saga that complete successfully continue with an execution of next.
+// The continuation is written to the Store along with the completion of the parent and played after it,
+// so it's played by Resume of the parent if the process crashes in between. Steps of next read outputs
+// of the parent by ParentOutput.
+func (saga *Saga) OnSuccess(next *Saga) {
+	saga.onSuccess = next
+}
+
+// OnCompensated makes compensated executions of the saga continue with an execution of next the same way as OnSuccess.
+func (saga *Saga) OnCompensated(next *Saga) {
+	saga.onCompensated = next
+}
+
+// nextSaga returns the saga the completing execution continues with, nil if there is none.
+func (c *ExecutionCoordinator) nextSaga() *Saga {
+	if c.aborted() {
+		return c.saga.onCompensated
+	}
+	return c.saga.onSuccess
+}
+
+// recordContinuation appends the continuation of the completing execution unless it's recorded already,
+// it returns the continuation or nil if the saga has none.
+func (c *ExecutionCoordinator) recordContinuation() *continuation {
+	next := c.nextSaga()
+	if next == nil {
+		return nil
+	}
+	p, err := c.loadProgress()
+	checkErr(err, "c.loadProgress()")
+	if p.continuation != nil {
+		return p.continuation
+	}
+	cont := &continuation{ExecutionID: c.idGenerator.NewID(), Name: next.Name}
+	payload, err := json.Marshal(cont)
+	checkErr(err)
+	c.appendLog(&Log{
+		Type:        LogTypeSagaContinued,
+		StepPayload: payload,
+	})
+	return cont
+}
+
+// startContinuation plays the recorded continuation of the completed execution, or resumes it if it has
+// been started already, so it's started once whichever process does it.
+func (c *ExecutionCoordinator) startContinuation(cont *continuation) *Result {
+	nextCoordinator := NewExecutionCoordinator(c.funcsCtx, c.compensateFuncsCtx, c.nextSaga(), c.logStore,
+		WithExecutionID(cont.ExecutionID),
+		WithIDGenerator(c.idGenerator),
+		WithMetadata(map[string]string{ParentExecutionMetadata: c.ExecutionID}),
+		WithClock(c.Clock),
+		WithHooks(c.hooks),
+		WithLogger(c.logger),
+		WithAlerter(c.alerter))
+	nextCoordinator.middleware = c.middleware
+	nextCoordinator.payloadLimit = c.payloadLimit
+	return resumeOrPlay(nextCoordinator)
+}
+
+// resumeContinuation starts the continuation of the completed execution if a crash has prevented it,
+// it returns the result of the execution then and ErrExecutionCompleted otherwise.
+func (c *ExecutionCoordinator) resumeContinuation(p *progress) (*Result, error) {
+	if p.continuation == nil || c.nextSaga() == nil {
+		return nil, ErrExecutionCompleted
+	}
+	if _, _, err := c.logStore.GetLogsPage(p.continuation.ExecutionID, Page{Limit: 1}); !errors.Is(err, ErrNoLogs) {
+		return nil, ErrExecutionCompleted
+	}
+	c.startContinuation(p.continuation)
+	return GetResult(c.logStore, c.ExecutionID)
+}
+
+// resumeOrPlay resumes the execution of the coordinator, plays it if it hasn't been started
+// and returns its current state if it's completed.
+func resumeOrPlay(c *ExecutionCoordinator) *Result {
+	result, err := c.Resume()
+	switch {
+	case errors.Is(err, ErrNoLogs):
+		return c.Play()
+	case errors.Is(err, ErrExecutionCompleted):
+		status, err := GetStatus(c.logStore, c.ExecutionID)
+		checkErr(err, "GetStatus()")
+		result := duplicateResult(status, c)
+		result.Duplicate = false
+		return result
+	}
+	checkErr(err, "c.Resume()")
+	return result
+}
+
+// ParentOutput unmarshals outputs of the step of the parent execution into out, the outputs are
+// the values returned by the step func except the error. ctx is the context passed to a step of
+// a continuation, see Saga.OnSuccess.
+func ParentOutput(ctx context.Context, step string, out ...interface{}) error {
+	scope, ok := ctx.Value(stepKey{}).(*stepScope)
+	if !ok {
+		return ErrNotInStep
+	}
+	parentID, ok := scope.c.metadata[ParentExecutionMetadata]
+	if !ok {
+		return ErrNoParent
+	}
+	logs, err := scope.c.logStore.GetAllLogsByExecutionID(parentID)
+	if err != nil {
+		return err
+	}
+	for i := len(logs) - 1; i >= 0; i-- {
+		l := logs[i]
+		if l.Type != LogTypeSagaStepExec || l.StepError != nil || l.StepName == nil || *l.StepName != step {
+			continue
+		}
+		payload := l.StepPayload
+		if limit := scope.c.payloadLimit; l.StepPayloadRef != "" && limit != nil && limit.Blobs != nil {
+			if payload, err = limit.Blobs.Get(l.StepPayloadRef); err != nil {
+				return err
+			}
+		} else if l.StepPayloadTruncated || l.StepPayloadRef != "" {
+			return fmt.Errorf("%w %s: payload is over the limit", ErrNoOutput, step)
+		}
+		raw := make([]json.RawMessage, 0, len(out))
+		if err := json.Unmarshal(payload, &raw); err != nil {
+			return err
+		}
+		if len(raw) != len(out) {
+			return fmt.Errorf("step %s has %d outputs, but %d are expected", step, len(raw), len(out))
+		}
+		for i := range raw {
+			if err := json.Unmarshal(raw[i], out[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("%w %s", ErrNoOutput, step)
+}
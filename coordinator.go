@@ -3,102 +3,717 @@ package saga
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
-	"math/rand"
 	"reflect"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
+var (
+	ErrExecutionCompleted = errors.New("execution already completed")
+	ErrNothingToRetry     = errors.New("execution has no failed step to retry")
+	ErrNotPaused          = errors.New("execution is not paused")
+	ErrAbortedManually    = errors.New("execution aborted manually")
+	ErrApprovalTimeout    = errors.New("step wasn't approved in time")
+	ErrPastPivot          = errors.New("execution is past the pivot step and can't be compensated")
+)
+
+// Option configures ExecutionCoordinator.
+type Option func(c *ExecutionCoordinator)
+
+// WithExecutionID sets ID of the execution, e.g. to continue an existing execution.
+func WithExecutionID(executionID string) Option {
+	return func(c *ExecutionCoordinator) {
+		c.ExecutionID = executionID
+	}
+}
+
+// WithMetadata sets metadata written to all logs of the execution, e.g. ID of the customer
+// to delete all their executions by Store.DeleteByMetadata.
+func WithMetadata(metadata map[string]string) Option {
+	return func(c *ExecutionCoordinator) {
+		c.metadata = metadata
+	}
+}
+
+// WithAttribution makes operations on the execution (Resume, RetryStep, Approve and Compensate)
+// record the actor performing them and the reason in a LogTypeSagaOperation log.
+func WithAttribution(actor, reason string) Option {
+	return func(c *ExecutionCoordinator) {
+		c.attribution = &Attribution{Actor: actor, Reason: reason}
+	}
+}
+
+// WithStore replaces the Store passed to NewExecutionCoordinator, e.g. by a decorated one.
+func WithStore(store Store) Option {
+	return func(c *ExecutionCoordinator) {
+		c.logStore = store
+	}
+}
+
+// WithClock sets Clock of the coordinator, SystemClock is used by default.
+func WithClock(clock Clock) Option {
+	return func(c *ExecutionCoordinator) {
+		c.Clock = clock
+	}
+}
+
+// WithIDGenerator sets generator of the execution ID, DefaultIDGenerator is used by default.
+func WithIDGenerator(generator IDGenerator) Option {
+	return func(c *ExecutionCoordinator) {
+		c.idGenerator = generator
+	}
+}
+
+// NewCoordinator returns the coordinator of the execution with the ID if it's given, otherwise of a new
+// execution whose ID is generated. Other settings are set by options of NewExecutionCoordinator.
 func NewCoordinator(funcsCtx, compensateFuncsCtx context.Context, saga *Saga, logStore Store, executionID ...string) *ExecutionCoordinator {
+	var opts []Option
+	if len(executionID) > 0 {
+		opts = append(opts, WithExecutionID(executionID[0]))
+	}
+	return NewExecutionCoordinator(funcsCtx, compensateFuncsCtx, saga, logStore, opts...)
+}
+
+// NewExecutionCoordinator returns the coordinator of an execution configured by options, e.g. WithExecutionID.
+func NewExecutionCoordinator(funcsCtx, compensateFuncsCtx context.Context, saga *Saga, logStore Store, opts ...Option) *ExecutionCoordinator {
 	c := &ExecutionCoordinator{
 		funcsCtx:           funcsCtx,
 		compensateFuncsCtx: compensateFuncsCtx,
 		saga:               saga,
 		logStore:           logStore,
+		Clock:              SystemClock,
+		idGenerator:        DefaultIDGenerator,
+		payloads:           make(map[int][]byte, len(saga.steps)),
+		seq:                -1,
 	}
-	if len(executionID) > 0 {
-		c.ExecutionID = executionID[0]
-	} else {
-		c.ExecutionID = RandString()
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.ExecutionID == "" {
+		c.ExecutionID = c.idGenerator.NewID()
+	}
+	if c.eventBus != nil {
+		c.logStore = CloudEvents(c.logStore, c.eventSource, c.eventBus)
 	}
 	return c
 }
 
 type ExecutionCoordinator struct {
 	ExecutionID string
-
-	aborted          bool
+	// Clock is used for time of logs and durations of steps
+	Clock Clock
+
+	// paused, delayed and deferred are set when the operation parks the execution, executionError and
+	// compensateErrors are errors returned by funcs during the operation, others are derived from progress
+	paused           bool
+	delayed          bool
+	deferred         bool
 	executionError   error
 	compensateErrors []error
+	// payloads are real payloads of steps executed by the coordinator by step number
+	payloads map[int][]byte
+	// payloadMemory is set by WithPayloadMemory, retained is the size of payloads,
+	// releaseOrder are numbers of steps whose payloads can be released in order of their execution
+	payloadMemory int
+	retained      int
+	releaseOrder  []int
+	releasable    map[int]bool
 
 	funcsCtx           context.Context
 	compensateFuncsCtx context.Context
+	// forwardCtx is the context of steps after the pivot if it isn't funcsCtx, see ExecutionHandle.Cancel
+	forwardCtx context.Context
 
 	saga *Saga
 
-	logStore Store
+	logStore    Store
+	idGenerator IDGenerator
+	metadata    map[string]string
+	attribution *Attribution
+	// payloadLimit is set by WithPayloadLimit
+	payloadLimit *PayloadLimit
+	escalator    Escalator
+	alerter      Alerter
+	eventBus     EventBus
+	eventSource  string
+	hooks        Hooks
+	logger       Logger
+	middleware   []Middleware
+	locks        SemanticLocks
+	dedupKey     string
+	dedupWindow  time.Duration
+	// input is set by WithInput
+	input json.RawMessage
+	// onStart is called by Play when the start of the execution is written to the Store, see Trigger
+	onStart func()
+
+	// seqMu guards seq, the number of logs of the execution known to the coordinator, -1 until it's known,
+	// see SequencedStore
+	seqMu sync.Mutex
+	seq   int
+	// progress is the state folded from logs known to the coordinator, guarded by seqMu
+	progress *progress
+	// suppressed are logs that weren't written to the Store, guarded by seqMu, see StepOptions.SuppressLogs
+	suppressed []*Log
+	// buffer are logs waiting for the next sync point, guarded by seqMu, see Saga.LogSync
+	buffer []bufferedLog
+	// writing receives the result of the batch written in the background, guarded by seqMu, see SyncPipelined
+	writing chan error
+	// inTx is set while logStore is a transaction, buffered logs are committed with it, see withinTx
+	inTx bool
+	// onComplete are called by complete, see OnComplete
+	onComplete []func(*Result)
+	// canceled is set by ExecutionHandle.Cancel
+	canceled int32
+	// streams are streams of step results, guarded by seqMu, see StreamStepResults
+	streams []*stepStream
 }
 
 func (c *ExecutionCoordinator) Play() *Result {
-	executionStart := time.Now()
-	checkErr(c.logStore.AppendLog(&Log{
-		ExecutionID: c.ExecutionID,
-		Name:        c.saga.Name,
-		Time:        time.Now(),
+	defer c.finish()
+	executionStart := c.Clock.Now()
+	if duplicate := c.start(); duplicate != nil {
+		return duplicate
+	}
+	return c.playStarted(executionStart)
+}
+
+// start appends the start log of the execution, it returns the result of the duplicate instead, see WithDedupKey.
+func (c *ExecutionCoordinator) start() *Result {
+	c.seq = 0
+	c.progress = newProgress(c.saga)
+	if c.dedupKey != "" {
+		return c.startDedup()
+	}
+	c.appendLog(&Log{
 		Type:        LogTypeStartSaga,
-	}))
+		StepPayload: c.input,
+	})
+	return nil
+}
 
-	for i := 0; i < len(c.saga.steps); i++ {
+// playStarted executes steps of the execution started by start.
+func (c *ExecutionCoordinator) playStarted(executionStart time.Time) *Result {
+	if c.onStart != nil {
+		c.syncLogs()
+		c.onStart()
+	}
+	return c.run(0, executionStart)
+}
+
+// Resume continues an execution from the point recorded in the Store, e.g. after
+// the process running it has crashed or after a paused step has been approved.
+func (c *ExecutionCoordinator) Resume() (*Result, error) {
+	defer c.finish()
+	p, err := c.loadProgress()
+	if err != nil {
+		return nil, err
+	}
+	if p.completed {
+		return c.resumeContinuation(p)
+	}
+	c.attribute(OperationResume)
+	if p.failedStep != nil && !p.aborted && (!p.retryAt.IsZero() || c.pastPivot(*p.failedStep)) {
+		if c.Clock.Now().Before(p.retryAt) {
+			return &Result{ExecutionError: errors.New(p.lastError), Delayed: true, Steps: c.stepResults()}, nil
+		}
+		return c.run(*p.failedStep, p.start), nil
+	}
+	if p.deferred(c.Clock.Now()) {
+		return &Result{ExecutionError: errors.New(p.lastError), Deferred: true, Steps: c.stepResults()}, nil
+	}
+	if p.aborted && c.Clock.Now().Before(p.compensateRetryAt) {
+		return &Result{ExecutionError: errors.New(p.lastError), Delayed: true, Steps: c.stepResults()}, nil
+	}
+	if p.aborted || p.failedStep != nil {
+		c.executionError = errors.New(p.lastError)
+		c.abort()
+		return c.completeAbort(p.start), nil
+	}
+	return c.run(p.nextStep, p.start), nil
+}
+
+// RetryStep executes again the step that failed last and continues the execution
+// if it succeeds. It's only possible while compensation hasn't been started.
+func (c *ExecutionCoordinator) RetryStep() (*Result, error) {
+	defer c.finish()
+	p, err := c.loadProgress()
+	if err != nil {
+		return nil, err
+	}
+	if p.completed {
+		return nil, ErrExecutionCompleted
+	}
+	if p.failedStep == nil || p.aborted {
+		return nil, ErrNothingToRetry
+	}
+	c.attribute(OperationRetry)
+	return c.run(*p.failedStep, p.start), nil
+}
+
+// Approve marks the paused step as approved and continues the execution.
+func (c *ExecutionCoordinator) Approve() (*Result, error) {
+	defer c.finish()
+	p, err := c.loadProgress()
+	if err != nil {
+		return nil, err
+	}
+	if p.completed {
+		return nil, ErrExecutionCompleted
+	}
+	if p.pausedStep == nil {
+		return nil, ErrNotPaused
+	}
+	step := *p.pausedStep
+	c.attribute(OperationApprove)
+	c.appendLog(&Log{
+		Type:       LogTypeSagaStepApproved,
+		StepNumber: &step,
+		StepName:   &c.saga.steps[step].Name,
+	})
+	return c.run(step, p.start), nil
+}
+
+// Compensate aborts an unfinished execution and compensates all executed steps.
+func (c *ExecutionCoordinator) Compensate() (*Result, error) {
+	defer c.finish()
+	p, err := c.loadProgress()
+	if err != nil {
+		return nil, err
+	}
+	if p.completed {
+		return nil, ErrExecutionCompleted
+	}
+	if !p.aborted && c.pastPivot(p.nextStep) {
+		return nil, ErrPastPivot
+	}
+	c.attribute(OperationCompensate)
+	if p.lastError != "" {
+		c.executionError = errors.New(p.lastError)
+	} else {
+		c.executionError = ErrAbortedManually
+	}
+	c.abort()
+	return c.completeAbort(p.start), nil
+}
+
+// attribute records who performs the operation if it's set by WithAttribution.
+func (c *ExecutionCoordinator) attribute(operation Operation) {
+	if c.attribution == nil {
+		return
+	}
+	attribution := *c.attribution
+	attribution.Operation = operation
+	payload, err := json.Marshal(&attribution)
+	checkErr(err)
+	c.appendLog(&Log{
+		Type:        LogTypeSagaOperation,
+		StepPayload: payload,
+	})
+}
+
+func (c *ExecutionCoordinator) loadProgress() (*progress, error) {
+	c.syncLogs()
+	logs, err := c.logStore.GetAllLogsByExecutionID(c.ExecutionID)
+	c.seqMu.Lock()
+	// within a transaction buffered logs aren't synced, they are committed with the transaction
+	for _, b := range c.buffer {
+		logs = append(logs, b.log)
+		err = nil
+	}
+	if err != nil {
+		c.seqMu.Unlock()
+		return nil, err
+	}
+	if c.seq < 0 {
+		c.seq = len(logs)
+	}
+	c.progress = foldProgress(c.saga, logs)
+	if c.metadata == nil {
+		// e.g. continuations resumed after a crash keep their parents, see ParentOutput
+		c.metadata = c.progress.metadata
+	}
+	// results of steps whose logs were suppressed are known only to the coordinator
+	for _, l := range c.suppressed {
+		c.progress.applyStep(l)
+	}
+	c.seqMu.Unlock()
+	return c.progress, nil
+}
+
+func (c *ExecutionCoordinator) run(from int, executionStart time.Time) *Result {
+	for i := from; i < len(c.saga.steps); i++ {
+		if atomic.LoadInt32(&c.canceled) == 1 && !c.pastPivot(i) {
+			c.executionError = ErrCanceled
+			c.abort()
+			return c.completeAbort(executionStart)
+		}
 		c.execStep(i)
+		if c.aborted() {
+			return c.completeAbort(executionStart)
+		}
+		if c.paused || c.delayed {
+			return &Result{ExecutionError: c.executionError, Paused: c.paused, Delayed: c.delayed, Steps: c.stepResults()}
+		}
+		if c.deferred {
+			return &Result{ExecutionError: c.executionError, Deferred: true, Steps: c.stepResults()}
+		}
 	}
 
-	checkErr(c.logStore.AppendLog(&Log{
-		ExecutionID:  c.ExecutionID,
-		Name:         c.saga.Name,
-		Time:         time.Now(),
+	return c.complete(executionStart)
+}
+
+func (c *ExecutionCoordinator) complete(executionStart time.Time) *Result {
+	c.syncLogs()
+	data := c.dataSnapshot()
+	var snapshot []byte
+	if data != nil {
+		var err error
+		snapshot, err = json.Marshal(data)
+		checkErr(err)
+	}
+	result := &Result{
+		ExecutionError:   c.executionError,
+		CompensateErrors: c.compensateErrors,
+		Steps:            c.stepResults(),
+		Data:             data,
+	}
+	c.finalize(result)
+	completeLog := &Log{
 		Type:         LogTypeSagaComplete,
-		StepDuration: time.Since(executionStart),
-	}))
-	return &Result{ExecutionError: c.executionError, CompensateErrors: c.compensateErrors}
+		StepDuration: c.Clock.Now().Sub(executionStart),
+		StepPayload:  snapshot,
+	}
+	// the continuation is recorded along with the completion and started after it
+	var cont *continuation
+	if c.nextSaga() != nil {
+		c.withinTx(func() {
+			cont = c.recordContinuation()
+			c.appendLog(completeLog)
+		})
+	} else {
+		c.appendLog(completeLog)
+	}
+	c.syncLogs()
+	if c.locks != nil {
+		checkErr(c.locks.Release(c.ExecutionID), "c.locks.Release()")
+	}
+	if cont != nil {
+		result.ContinuationID = cont.ExecutionID
+		result.Continuation = c.startContinuation(cont)
+	}
+	for _, f := range c.onComplete {
+		f(result)
+	}
+	return result
+}
+
+// OnComplete registers f to be called with the result when the coordinator completes the execution,
+// whether it has succeeded or has been compensated. The execution is completed once, by the coordinator
+// that writes its final log, so f isn't called for parked executions or for duplicates, see WithDedupKey.
+func (c *ExecutionCoordinator) OnComplete(f func(*Result)) {
+	c.onComplete = append(c.onComplete, f)
+}
+
+// completeAbort completes the aborted execution unless its compensation is parked, see Guaranteed.
+func (c *ExecutionCoordinator) completeAbort(executionStart time.Time) *Result {
+	if c.delayed || c.deadLettered() {
+		result := &Result{
+			ExecutionError:   c.executionError,
+			CompensateErrors: c.compensateErrors,
+			Delayed:          c.delayed,
+			DeadLettered:     c.deadLettered(),
+			Steps:            c.stepResults(),
+		}
+		if c.deadLettered() {
+			c.finalize(result)
+		}
+		return result
+	}
+	return c.complete(executionStart)
 }
 
 func (c *ExecutionCoordinator) execStep(i int) {
-	if c.aborted {
+	if c.aborted() {
+		return
+	}
+	if c.skipStep(i) {
 		return
 	}
-	start := time.Now()
+	if c.waitApproval(i) {
+		return
+	}
+	if c.waitDelay(i) || c.waitWindow(i) {
+		return
+	}
+	start := c.Clock.Now()
 	f := c.saga.steps[i].Func
 
-	params := []reflect.Value{reflect.ValueOf(c.funcsCtx)}
-	resp := getFuncValue(f).Call(params)
-	err := isReturnError(resp)
+	ctx, timer := c.startStepTimer(i)
+	ctx = context.WithValue(ctx, stepKey{}, &stepScope{c: c, step: i})
+	c.beforeStep(i)
+	var resp []reflect.Value
+	var err error
+	if direct, ok := directFunc(f); ok {
+		err = c.call(ctx, i, false, direct)
+	} else {
+		resp, err = c.invoke(ctx, i, false, getFuncValue(f), []reflect.Value{reflect.ValueOf(ctx)})
+	}
+	err = timer.stop(err)
 
-	marshaledResp, marshalErr := marshalResp(resp[:len(resp)-1])
+	options := c.saga.steps[i].Options
+	marshaledResp, marshalErr := marshalResp(redact(resp, options != nil && options.Sensitive))
+	checkErr(marshalErr)
+	// compensations executed by this coordinator receive real values even if they are redacted in the Store
+	realResp, marshalErr := marshalResp(resp)
 	checkErr(marshalErr)
 
 	stepLog := &Log{
-		ExecutionID:  c.ExecutionID,
-		Name:         c.saga.Name,
-		Time:         time.Now(),
 		Type:         LogTypeSagaStepExec,
 		StepNumber:   &i,
 		StepName:     &c.saga.steps[i].Name,
 		StepPayload:  marshaledResp,
-		StepDuration: time.Since(start),
+		StepDuration: c.Clock.Now().Sub(start),
+	}
+	if limitErr := c.limitPayload(i, stepLog); limitErr != nil && err == nil {
+		err = limitErr
 	}
+	suppressed := err == nil && options != nil && options.SuppressLogs && random.Float64() >= options.LogSampleRate
+	c.retainPayload(i, realResp, !suppressed && storedAsIs(stepLog, marshaledResp, realResp))
 
 	if err != nil {
 		errStr := err.Error()
 		stepLog.StepError = &errStr
+		stepLog.StepTimedOut = timer.expired()
 	}
 
-	checkErr(c.logStore.AppendLog(stepLog))
-	stepLog.StepDuration = time.Since(start)
-	if err != nil {
-		c.executionError = err
+	if err == nil {
+		if suppressed {
+			c.suppressLog(stepLog)
+		} else {
+			c.appendLog(stepLog)
+			c.syncStep()
+		}
+		c.afterStep(i, nil)
+		return
+	}
+	c.executionError = err
+	// the failed exec log is appended along with the retry or the deferral, so Resume never compensates
+	// a step that had to be retried
+	retried, deferred := false, false
+	critical := options != nil && options.Critical
+	c.withinTx(func() {
+		c.appendLog(stepLog)
+		if critical {
+			return
+		}
+		if retried = c.scheduleRetry(i); retried {
+			return
+		}
+		if grace := c.saga.CompensationGrace; grace > 0 {
+			c.deferCompensation(i, grace)
+			deferred = true
+		}
+	})
+	c.syncStep()
+	c.afterStep(i, err)
+	if !retried && !deferred {
+		c.abort()
+	}
+}
+
+// deferCompensation parks the failed execution for the grace period instead of compensating it.
+func (c *ExecutionCoordinator) deferCompensation(i int, grace time.Duration) {
+	c.deferred = true
+	c.appendLog(&Log{
+		Type:         LogTypeSagaCompensationDeferred,
+		StepNumber:   &i,
+		StepName:     &c.saga.steps[i].Name,
+		StepDuration: grace,
+	})
+}
+
+// scheduleRetry schedules the next attempt of the failed step if its RetryPolicy allows it,
+// it returns true if the execution is parked until the attempt.
+// Steps after the pivot are retried until they succeed.
+func (c *ExecutionCoordinator) scheduleRetry(i int) bool {
+	var policy *RetryPolicy
+	if options := c.saga.steps[i].Options; options != nil {
+		policy = options.Retry
+	}
+	forward := c.pastPivot(i)
+	if policy == nil && forward {
+		policy = &DefaultForwardRetry
+	}
+	if policy == nil {
+		return false
+	}
+	p, err := c.loadProgress()
+	checkErr(err, "c.loadProgress()")
+	attempts := p.attempts[i]
+	if !forward && policy.MaxAttempts > 0 && attempts >= policy.MaxAttempts {
+		return false
+	}
+	c.delayed = true
+	c.appendLog(&Log{
+		Type:         LogTypeSagaStepRetryScheduled,
+		StepNumber:   &i,
+		StepName:     &c.saga.steps[i].Name,
+		StepDuration: policy.backoff(attempts),
+	})
+	return true
+}
+
+// stepCtx returns the context of step i.
+func (c *ExecutionCoordinator) stepCtx(i int) context.Context {
+	if c.forwardCtx != nil && c.pastPivot(i) {
+		return c.forwardCtx
+	}
+	return c.funcsCtx
+}
+
+// pastPivot returns true if step i is after the pivot, so the execution can't be compensated.
+func (c *ExecutionCoordinator) pastPivot(i int) bool {
+	pivot := c.saga.pivot()
+	return pivot >= 0 && i > pivot
+}
+
+// waitApproval pauses the execution before the step until it's approved, it returns true if the
+// execution is paused. The execution is aborted if the approval deadline has passed.
+func (c *ExecutionCoordinator) waitApproval(i int) bool {
+	options := c.saga.steps[i].Options
+	if options == nil || !options.RequireApproval {
+		return false
+	}
+	p, err := c.loadProgress()
+	checkErr(err, "c.loadProgress()")
+	if p.approved[i] {
+		return false
+	}
+	if p.pausedStep == nil || *p.pausedStep != i {
+		c.pause(i, options.ApprovalTimeout)
+		return true
+	}
+	if !p.pausedUntil.IsZero() && !c.Clock.Now().Before(p.pausedUntil) && !c.pastPivot(i) {
+		c.executionError = ErrApprovalTimeout
 		c.abort()
+		return true
+	}
+	c.paused = true
+	return true
+}
+
+// skipStep records the step as skipped if its StepOptions.Condition returns false, it returns true then.
+func (c *ExecutionCoordinator) skipStep(i int) bool {
+	options := c.saga.steps[i].Options
+	if options == nil || options.Condition == nil {
+		return false
+	}
+	ctx := context.WithValue(c.stepCtx(i), stepKey{}, &stepScope{c: c, step: i})
+	if options.Condition(ctx) {
+		return false
+	}
+	c.appendLog(&Log{
+		Type:       LogTypeSagaStepSkipped,
+		StepNumber: &i,
+		StepName:   &c.saga.steps[i].Name,
+	})
+	return true
+}
+
+// waitDelay starts the delay of the step or checks that it's over, it returns true if
+// the execution is parked until the end of the delay.
+func (c *ExecutionCoordinator) waitDelay(i int) bool {
+	options := c.saga.steps[i].Options
+	if options == nil || options.Delay <= 0 {
+		return false
+	}
+	p, err := c.loadProgress()
+	checkErr(err, "c.loadProgress()")
+	if p.attempts[i] > 0 {
+		// the step is retried, the delay is over already
+		return false
+	}
+	if fireAt, ok := p.delays[i]; ok {
+		c.delayed = c.Clock.Now().Before(fireAt)
+		return c.delayed
+	}
+	c.delayed = true
+	c.appendLog(&Log{
+		Type:         LogTypeSagaStepDelayed,
+		StepNumber:   &i,
+		StepName:     &c.saga.steps[i].Name,
+		StepDuration: options.Delay,
+	})
+	return true
+}
+
+func (c *ExecutionCoordinator) pause(i int, timeout time.Duration) {
+	c.paused = true
+	c.appendLog(&Log{
+		Type:         LogTypeSagaStepPaused,
+		StepNumber:   &i,
+		StepName:     &c.saga.steps[i].Name,
+		StepDuration: timeout,
+	})
+}
+
+// appendLog fills fields common for all logs of the execution and appends it to the Store.
+func (c *ExecutionCoordinator) appendLog(l *Log) {
+	c.stampLog(l)
+	c.seqMu.Lock()
+	defer c.seqMu.Unlock()
+	if c.progress != nil {
+		c.progress.apply(l)
+		l.State = c.progress.state()
 	}
+	if c.buffered() {
+		c.buffer = append(c.buffer, bufferedLog{log: l, expected: c.seq})
+		if c.seq >= 0 {
+			c.seq++
+		}
+		c.streamStepResult(l)
+		return
+	}
+	if c.seq < 0 {
+		checkErr(c.logStore.AppendLog(l))
+		c.streamStepResult(l)
+		return
+	}
+	err := AppendLogAt(c.logStore, l, c.seq)
+	var conflict *ConflictError
+	if errors.As(err, &conflict) {
+		panic(conflict)
+	}
+	checkErr(err)
+	c.seq++
+	c.streamStepResult(l)
+}
+
+// suppressLog applies the log to the state known to the coordinator without writing it to the Store,
+// see StepOptions.SuppressLogs.
+func (c *ExecutionCoordinator) suppressLog(l *Log) {
+	c.stampLog(l)
+	c.seqMu.Lock()
+	defer c.seqMu.Unlock()
+	if c.progress != nil {
+		c.progress.apply(l)
+		l.State = c.progress.state()
+	}
+	c.streamStepResult(l)
+	c.suppressed = append(c.suppressed, l)
+}
+
+func (c *ExecutionCoordinator) stampLog(l *Log) {
+	l.ExecutionID = c.ExecutionID
+	l.Name = c.saga.Name
+	l.TenantID = c.saga.TenantID
+	l.Metadata = c.metadata
+	l.Time = c.Clock.Now()
 }
 
 func marshalResp(resp []reflect.Value) ([]byte, error) {
@@ -111,41 +726,42 @@ func marshalResp(resp []reflect.Value) ([]byte, error) {
 }
 
 func (c *ExecutionCoordinator) abort() {
-	toCompensateLogs, err := c.logStore.GetStepLogsToCompensate(c.ExecutionID)
-	checkErr(err, "c.logStore.GetAllLogsByExecutionID(c.ExecutionID)")
+	p, err := c.loadProgress()
+	checkErr(err, "c.loadProgress()")
+
+	// a retried step has several exec logs, only the latest one indexed by the progress is compensated,
+	// failed best-effort compensations aren't attempted again
+	stepLogs := p.compensations()
+	toCompensateLogs := make([]*Log, 0, len(stepLogs))
+	for _, stepLog := range stepLogs {
+		step := *stepLog.StepNumber
+		if p.compensated[step] || c.saga.steps[step].CompensateFunc == nil && c.saga.steps[step].CompensateSaga == nil {
+			continue
+		}
+		if p.compensateAttempts[step] > 0 && c.saga.steps[step].compensationRetry() == nil {
+			continue
+		}
+		if !c.compensateTimedOut(stepLog) {
+			continue
+		}
+		toCompensateLogs = append(toCompensateLogs, stepLog)
+	}
 
 	stepsToCompensate := len(toCompensateLogs)
-	checkErr(c.logStore.AppendLog(&Log{
-		ExecutionID: c.ExecutionID,
-		Name:        c.saga.Name,
-		Time:        time.Now(),
-		Type:        LogTypeSagaAbort,
-		StepNumber:  &stepsToCompensate,
-	}))
-
-	c.aborted = true
-	for i := 0; i < stepsToCompensate; i++ {
-		toCompensateLog := toCompensateLogs[i]
-
-		compensateFuncRaw := c.saga.steps[*toCompensateLog.StepNumber].CompensateFunc
-		compensateFuncValue := getFuncValue(compensateFuncRaw)
-		compensateRuncType := reflect.TypeOf(compensateFuncRaw)
-
-		types := make([]reflect.Type, 0, compensateRuncType.NumIn())
-		for i := 1; i < compensateRuncType.NumIn(); i++ {
-			types = append(types, compensateRuncType.In(i))
+	if !p.aborted {
+		abortLog := &Log{
+			Type:       LogTypeSagaAbort,
+			StepNumber: &stepsToCompensate,
 		}
-		unmarshal, err := unmarshalParams(types, toCompensateLog.StepPayload)
-		checkErr(err, "unmarshalParams()")
-
-		params := make([]reflect.Value, 0)
-		params = append(params, reflect.ValueOf(c.compensateFuncsCtx))
-		params = append(params, unmarshal...)
-
-		if err := c.compensateStep(*toCompensateLog.StepNumber, params, compensateFuncValue); err != nil {
-			c.compensateErrors = append(c.compensateErrors, err)
+		if p.failedStep == nil && c.executionError != nil {
+			reason := c.executionError.Error()
+			abortLog.StepError = &reason
 		}
+		c.appendLog(abortLog)
 	}
+
+	failed := c.compensateStages(toCompensateLogs)
+	c.retryCompensations(p, failed)
 }
 
 func unmarshalParams(types []reflect.Type, payload []byte) ([]reflect.Value, error) {
@@ -171,21 +787,14 @@ func unmarshalParams(types []reflect.Type, payload []byte) ([]reflect.Value, err
 	return res, nil
 }
 
-func (c *ExecutionCoordinator) compensateStep(i int, params []reflect.Value, compensateFunc reflect.Value) error {
-	checkErr(c.logStore.AppendLog(&Log{
-		ExecutionID: c.ExecutionID,
-		Name:        c.saga.Name,
-		Time:        time.Now(),
-		Type:        LogTypeSagaStepCompensate,
-		StepNumber:  &i,
-		StepName:    &c.saga.steps[i].Name,
-	}))
+func (c *ExecutionCoordinator) compensateStep(ctx context.Context, i int, compensateFunc func(ctx context.Context) error) error {
+	c.appendLog(&Log{
+		Type:       LogTypeSagaStepCompensate,
+		StepNumber: &i,
+		StepName:   &c.saga.steps[i].Name,
+	})
 
-	res := compensateFunc.Call(params)
-	if err := isReturnError(res); err != nil {
-		return err
-	}
-	return nil
+	return c.call(ctx, i, true, compensateFunc)
 }
 
 func isReturnError(result []reflect.Value) error {
@@ -215,12 +824,14 @@ func checkOK(ok bool, msg ...string) {
 	}
 }
 
-// RandString simply generates random string of length n
+// RandString simply generates random string of length 10.
+//
+// Deprecated: it isn't unique enough for execution IDs, use IDGenerator instead.
 func RandString() string {
 	var letters = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
 	b := make([]rune, 10)
 	for i := range b {
-		b[i] = letters[rand.Intn(len(letters))]
+		b[i] = letters[random.Intn(len(letters))]
 	}
 	return string(b)
 }
@@ -0,0 +1,200 @@
+package saga
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// dashboardLimit is the max number of executions shown in each section of the dashboard
+const dashboardLimit = 50
+
+// DashboardHandler shows in-flight, failed and compensated executions with per-step
+// timelines. Pages link to each other with relative paths, so the handler has to be
+// mounted at a path ending with slash, e.g.
+//
+//	mux.Handle("/saga/", http.StripPrefix("/saga", NewDashboardHandler(store)))
+//
+// Besides HTML pages it serves the same data as JSON:
+//
+//	GET /api/executions                summary of executions by section
+//	GET /api/executions/{id}/timeline  per-step timeline of the execution
+type DashboardHandler struct {
+	// TenantFromRequest optionally returns tenant of the request, only executions
+	// of the tenant are shown then. Error is responded with 403.
+	TenantFromRequest func(r *http.Request) (string, error)
+
+	store Store
+}
+
+func NewDashboardHandler(store Store) *DashboardHandler {
+	return &DashboardHandler{store: store}
+}
+
+type dashboardSection struct {
+	Title      string    `json:"title"`
+	Executions []*Status `json:"executions"`
+}
+
+// TimelineStep is a summary of a single step of an execution.
+type TimelineStep struct {
+	Number        int           `json:"number"`
+	Name          string        `json:"name"`
+	Attempts      int           `json:"attempts"`
+	ExecutedAt    time.Time     `json:"executedAt"`
+	Duration      time.Duration `json:"duration"`
+	Error         string        `json:"error,omitempty"`
+	PausedAt      *time.Time    `json:"pausedAt,omitempty"`
+	CompensatedAt *time.Time    `json:"compensatedAt,omitempty"`
+}
+
+type dashboardExecution struct {
+	Status *Status         `json:"status"`
+	Steps  []*TimelineStep `json:"steps"`
+}
+
+func (h *DashboardHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("%s is not allowed for %s", r.Method, r.URL.Path))
+		return
+	}
+
+	store := h.store
+	if h.TenantFromRequest != nil {
+		tenantID, err := h.TenantFromRequest(r)
+		if err != nil {
+			writeError(w, http.StatusForbidden, err)
+			return
+		}
+		store = ForTenant(store, tenantID)
+	}
+
+	path := strings.Trim(r.URL.Path, "/")
+	api := strings.HasPrefix(path, "api/")
+	parts := strings.Split(strings.TrimPrefix(path, "api/"), "/")
+
+	switch {
+	case path == "" || api && len(parts) == 1 && parts[0] == "executions":
+		sections, err := dashboardSections(store)
+		h.render(w, api, dashboardIndexTemplate, sections, err)
+	case !api && len(parts) == 2 && parts[0] == "executions",
+		api && len(parts) == 3 && parts[0] == "executions" && parts[2] == "timeline":
+		execution, err := dashboardTimeline(store, parts[1])
+		h.render(w, api, dashboardExecutionTemplate, execution, err)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func dashboardSections(store Store) ([]*dashboardSection, error) {
+	sections := []*dashboardSection{{Title: "In flight"}, {Title: "Failed"}, {Title: "Compensated"}}
+	states := [][]string{
+		{"running", "paused", "delayed", "compensating"},
+		{"failed", "dead-lettered"},
+		{"compensated"},
+	}
+	for i, section := range sections {
+		statuses, _, err := store.ListExecutions(ExecutionFilter{States: states[i]}, Page{Limit: dashboardLimit})
+		if err != nil {
+			return nil, err
+		}
+		section.Executions = statuses
+	}
+	return sections, nil
+}
+
+func dashboardTimeline(store Store, executionID string) (*dashboardExecution, error) {
+	logs, err := store.GetAllLogsByExecutionID(executionID)
+	if err != nil {
+		return nil, err
+	}
+	if len(logs) == 0 {
+		return nil, ErrNoLogs
+	}
+	return &dashboardExecution{
+		Status: newStatus(executionID, foldProgress(nil, logs)),
+		Steps:  timeline(logs),
+	}, nil
+}
+
+// timeline groups logs of an execution by steps in order of their first execution.
+func timeline(logs []*Log) []*TimelineStep {
+	var steps []*TimelineStep
+	byNumber := make(map[int]*TimelineStep)
+	for _, l := range logs {
+		if l.StepNumber == nil || l.Type == LogTypeSagaAbort {
+			continue
+		}
+		step, ok := byNumber[*l.StepNumber]
+		if !ok {
+			step = &TimelineStep{Number: *l.StepNumber}
+			if l.StepName != nil {
+				step.Name = *l.StepName
+			}
+			byNumber[*l.StepNumber] = step
+			steps = append(steps, step)
+		}
+
+		logTime := l.Time
+		switch l.Type {
+		case LogTypeSagaStepExec:
+			step.Attempts++
+			step.ExecutedAt = l.Time
+			step.Duration = l.StepDuration
+			step.Error = ""
+			if l.StepError != nil {
+				step.Error = *l.StepError
+			}
+		case LogTypeSagaStepPaused:
+			step.PausedAt = &logTime
+		case LogTypeSagaStepCompensate:
+			step.CompensatedAt = &logTime
+		}
+	}
+	return steps
+}
+
+func (h *DashboardHandler) render(w http.ResponseWriter, api bool, tmpl *template.Template, data interface{}, err error) {
+	switch {
+	case err != nil:
+		writeError(w, errorStatusCode(err), err)
+	case api:
+		writeJSON(w, http.StatusOK, data)
+	default:
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_ = tmpl.Execute(w, data)
+	}
+}
+
+const dashboardStyle = `<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+.error { color: #b00; }
+</style>`
+
+var dashboardIndexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Sagas</title>` + dashboardStyle + `</head><body>
+{{range .}}<h2>{{.Title}} ({{len .Executions}})</h2>
+<table><tr><th>Execution</th><th>Saga</th><th>State</th><th>Started</th><th>Updated</th><th>Errors</th></tr>
+{{range .Executions}}<tr>
+<td><a href="executions/{{.ExecutionID}}">{{.ExecutionID}}</a></td><td>{{.Name}}</td><td>{{.State}}</td>
+<td>{{.StartedAt.Format "2006-01-02 15:04:05"}}</td><td>{{.UpdatedAt.Format "2006-01-02 15:04:05"}}</td>
+<td class="error">{{range .Errors}}{{.}}<br>{{end}}</td>
+</tr>{{end}}</table>
+{{end}}</body></html>`))
+
+var dashboardExecutionTemplate = template.Must(template.New("execution").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>{{.Status.ExecutionID}}</title>` + dashboardStyle + `</head><body>
+<p><a href="../">All executions</a></p>
+<h2>{{.Status.Name}} {{.Status.ExecutionID}}: {{.Status.State}}</h2>
+<table><tr><th>#</th><th>Step</th><th>Attempts</th><th>Executed</th><th>Duration</th><th>Error</th><th>Paused</th><th>Compensated</th></tr>
+{{range .Steps}}<tr>
+<td>{{.Number}}</td><td>{{.Name}}</td><td>{{.Attempts}}</td>
+<td>{{if .Attempts}}{{.ExecutedAt.Format "15:04:05.000"}}{{end}}</td><td>{{if .Attempts}}{{.Duration}}{{end}}</td>
+<td class="error">{{.Error}}</td>
+<td>{{with .PausedAt}}{{.Format "15:04:05.000"}}{{end}}</td><td>{{with .CompensatedAt}}{{.Format "15:04:05.000"}}{{end}}</td>
+</tr>{{end}}</table>
+</body></html>`))
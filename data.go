@@ -0,0 +1,101 @@
+package saga
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrNoData  = errors.New("no data set for key")
+	ErrNoInput = errors.New("execution has no input")
+)
+
+// WithInput sets the input of the execution: it's written to the Store with the start of the execution,
+// so steps read it by Input after Resume too. It panics if the input can't be marshaled to JSON.
+func WithInput(input interface{}) Option {
+	raw, err := json.Marshal(input)
+	checkErr(err, "json.Marshal(input)")
+	return func(c *ExecutionCoordinator) {
+		c.input = raw
+	}
+}
+
+// Input unmarshals the input of the execution set by WithInput into out. ctx is the context passed to a step.
+func Input(ctx context.Context, out interface{}) error {
+	scope, ok := ctx.Value(stepKey{}).(*stepScope)
+	if !ok {
+		return ErrNotInStep
+	}
+	c := scope.c
+	c.seqMu.Lock()
+	var raw json.RawMessage
+	if c.progress != nil {
+		raw = c.progress.input
+	}
+	c.seqMu.Unlock()
+	if len(raw) == 0 {
+		return ErrNoInput
+	}
+	return json.Unmarshal(raw, out)
+}
+
+// dataEntry is the payload of LogTypeSagaDataSet logs.
+type dataEntry struct {
+	Key   string          `json:"key"`
+	Value json.RawMessage `json:"value"`
+}
+
+// SetData sets the value of the key in the data bag of the execution shared by its steps, e.g. for outputs
+// computed by the saga. Values are written to the Store, so they survive Resume, and the final snapshot is
+// returned in Result.Data. ctx is the context passed to a step.
+func SetData(ctx context.Context, key string, value interface{}) error {
+	scope, ok := ctx.Value(stepKey{}).(*stepScope)
+	if !ok {
+		return ErrNotInStep
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(&dataEntry{Key: key, Value: raw})
+	if err != nil {
+		return err
+	}
+	c := scope.c
+	c.appendLog(&Log{
+		Type:        LogTypeSagaDataSet,
+		StepNumber:  &scope.step,
+		StepName:    &c.saga.steps[scope.step].Name,
+		StepPayload: payload,
+	})
+	return nil
+}
+
+// GetData unmarshals the value of the key set by SetData into out. ctx is the context passed to a step.
+func GetData(ctx context.Context, key string, out interface{}) error {
+	scope, ok := ctx.Value(stepKey{}).(*stepScope)
+	if !ok {
+		return ErrNotInStep
+	}
+	raw, ok := scope.c.dataSnapshot()[key]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNoData, key)
+	}
+	return json.Unmarshal(raw, out)
+}
+
+// dataSnapshot returns a copy of the data bag known to the coordinator.
+func (c *ExecutionCoordinator) dataSnapshot() map[string]json.RawMessage {
+	c.seqMu.Lock()
+	defer c.seqMu.Unlock()
+	if c.progress == nil || len(c.progress.data) == 0 {
+		return nil
+	}
+	data := make(map[string]json.RawMessage, len(c.progress.data))
+	for key, value := range c.progress.data {
+		data[key] = value
+	}
+	return data
+}
@@ -0,0 +1,118 @@
+package saga
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// Frame is the state of an execution at one of its logs.
+type Frame struct {
+	// Index is the number of the log among logs of the execution
+	Index int
+	Log   *Log
+	// Status is the state of the execution right after the log
+	Status *Status
+	// Payloads are payloads of the latest executions of steps by step number, compensations receive them
+	Payloads map[int]json.RawMessage
+	// PayloadFrom is the index of the exec log whose payload is passed to the compensation, set for compensate logs
+	PayloadFrom *int
+	// CompensateArgs are params the compensation received without context, set for compensate logs if the saga is known
+	CompensateArgs []interface{}
+	// DecodeError is set if payload can't be decoded into params of the compensation
+	DecodeError string
+}
+
+// Debugger steps through logs of a stored execution forward and backward, reconstructing
+// its state at each log.
+type Debugger struct {
+	executionID string
+	logs        []*Log
+	saga        *Saga
+	// position is the index of the current log, -1 before the first one
+	position int
+}
+
+// NewDebugger loads logs of the execution. Saga is optional, it's used to decode params of compensations.
+func NewDebugger(store Store, executionID string, saga *Saga) (*Debugger, error) {
+	logs, err := store.GetAllLogsByExecutionID(executionID)
+	if err != nil {
+		return nil, err
+	}
+	if len(logs) == 0 {
+		return nil, ErrNoLogs
+	}
+	return &Debugger{executionID: executionID, logs: logs, saga: saga, position: -1}, nil
+}
+
+// Len returns the number of logs of the execution.
+func (d *Debugger) Len() int {
+	return len(d.logs)
+}
+
+// Next moves to the next log, it returns nil after the last one.
+func (d *Debugger) Next() *Frame {
+	return d.Seek(d.position + 1)
+}
+
+// Prev moves to the previous log, it returns nil before the first one.
+func (d *Debugger) Prev() *Frame {
+	return d.Seek(d.position - 1)
+}
+
+// Seek moves to the log with the index, it returns nil if there is no such log.
+func (d *Debugger) Seek(index int) *Frame {
+	if index < 0 {
+		d.position = -1
+		return nil
+	}
+	if index >= len(d.logs) {
+		d.position = len(d.logs)
+		return nil
+	}
+	d.position = index
+	return d.frame(index)
+}
+
+func (d *Debugger) frame(index int) *Frame {
+	logs := d.logs[:index+1]
+	f := &Frame{
+		Index:    index,
+		Log:      d.logs[index],
+		Status:   newStatus(d.executionID, foldProgress(nil, logs)),
+		Payloads: make(map[int]json.RawMessage),
+	}
+	latest := make(map[int]int)
+	for i, l := range logs {
+		if l.Type == LogTypeSagaStepExec {
+			f.Payloads[*l.StepNumber] = l.StepPayload
+			latest[*l.StepNumber] = i
+		}
+	}
+	if f.Log.Type != LogTypeSagaStepCompensate {
+		return f
+	}
+
+	step := *f.Log.StepNumber
+	from, ok := latest[step]
+	if !ok {
+		return f
+	}
+	f.PayloadFrom = &from
+	if d.saga == nil || step >= len(d.saga.steps) || d.saga.steps[step].CompensateFunc == nil {
+		return f
+	}
+	compensateType := reflect.TypeOf(d.saga.steps[step].CompensateFunc)
+	types := make([]reflect.Type, 0, compensateType.NumIn())
+	for i := 1; i < compensateType.NumIn(); i++ {
+		types = append(types, compensateType.In(i))
+	}
+	values, err := decodePayload(types, d.logs[from].StepPayload)
+	if err != nil {
+		f.DecodeError = err.Error()
+		return f
+	}
+	for _, v := range values {
+		f.CompensateArgs = append(f.CompensateArgs, v.Interface())
+	}
+	return f
+}
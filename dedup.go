@@ -0,0 +1,144 @@
+package saga
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DedupKeyMetadata is the metadata key keeping dedup keys of executions, see WithDedupKey.
+const DedupKeyMetadata = "saga.dedupKey"
+
+// WithDedupKey sets business key of the execution, e.g. "order-123-refund". Play attaches to an execution
+// of the same saga with the key started within the window instead of starting a duplicate, see Result.Duplicate.
+//
+// IDs of executions with dedup keys are derived from the saga, the key and the window the execution is started
+// in, so duplicates are found by their IDs without listing executions. Plays in the same process are deduplicated
+// reliably, Plays in different processes too if the Store is a SequencedStore: the loser of the race for the ID
+// attaches to the winner. With other stores they may race, so steps still have to be idempotent if they can't.
+func WithDedupKey(key string, window time.Duration) Option {
+	checkOK(key != "", "dedup key must not be empty")
+	checkOK(window > 0, "dedup window must be positive")
+	return func(c *ExecutionCoordinator) {
+		c.dedupKey = key
+		c.dedupWindow = window
+	}
+}
+
+// dedupLocks are locks of dedup keys held by Plays in the process, entries are removed when they are unlocked.
+var dedupLocks = struct {
+	sync.Mutex
+	m map[string]*dedupLock
+}{m: make(map[string]*dedupLock)}
+
+type dedupLock struct {
+	sync.Mutex
+	refs int
+}
+
+// lockDedupKey serializes checks of the dedup key and starts of its executions in the process,
+// it returns func unlocking the key.
+func lockDedupKey(key string) func() {
+	dedupLocks.Lock()
+	l, ok := dedupLocks.m[key]
+	if !ok {
+		l = &dedupLock{}
+		dedupLocks.m[key] = l
+	}
+	l.refs++
+	dedupLocks.Unlock()
+	l.Lock()
+	return func() {
+		l.Unlock()
+		dedupLocks.Lock()
+		if l.refs--; l.refs == 0 {
+			delete(dedupLocks.m, key)
+		}
+		dedupLocks.Unlock()
+	}
+}
+
+// dedupID returns the ID of the execution with the key started in the window with the number.
+func dedupID(key string, window int64) string {
+	h := sha256.New()
+	_, _ = h.Write([]byte(key))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(strconv.FormatInt(window, 10)))
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+// startDedup starts the execution unless there is a duplicate, it returns result of the duplicate then.
+// The coordinator ExecutionID is set to the ID of the started execution or of the duplicate.
+func (c *ExecutionCoordinator) startDedup() *Result {
+	key := strings.Join([]string{c.saga.TenantID, c.saga.Name, c.dedupKey}, "\x00")
+	defer lockDedupKey(key)()
+	now := c.Clock.Now()
+	window := now.UnixNano() / int64(c.dedupWindow)
+
+	// the execution of the previous window is a duplicate if it was started less than the window ago
+	if status := c.dedupStatus(dedupID(key, window-1)); status != nil && !status.StartedAt.Before(now.Add(-c.dedupWindow)) {
+		return duplicateResult(status, c)
+	}
+	c.ExecutionID = dedupID(key, window)
+	if status := c.dedupStatus(c.ExecutionID); status != nil {
+		return duplicateResult(status, c)
+	}
+
+	metadata := make(map[string]string, len(c.metadata)+1)
+	for key, value := range c.metadata {
+		metadata[key] = value
+	}
+	metadata[DedupKeyMetadata] = c.dedupKey
+	c.metadata = metadata
+	// the start log is appended directly, so the conflict with a Play in another process is known
+	start := &Log{
+		Type:        LogTypeStartSaga,
+		StepPayload: c.input,
+	}
+	c.stampLog(start)
+	c.seqMu.Lock()
+	defer c.seqMu.Unlock()
+	c.progress.apply(start)
+	start.State = c.progress.state()
+	err := AppendLogAt(c.logStore, start, 0)
+	var conflict *ConflictError
+	if errors.As(err, &conflict) {
+		c.progress = newProgress(c.saga)
+		status, err := GetStatus(c.logStore, c.ExecutionID)
+		checkErr(err, "GetStatus()")
+		return duplicateResult(status, c)
+	}
+	checkErr(err, "AppendLogAt()")
+	c.seq = 1
+	return nil
+}
+
+// dedupStatus returns the status of the execution, nil if it hasn't been started.
+func (c *ExecutionCoordinator) dedupStatus(executionID string) *Status {
+	status, err := GetStatus(c.logStore, executionID)
+	if errors.Is(err, ErrNoLogs) {
+		return nil
+	}
+	checkErr(err, "GetStatus()")
+	return status
+}
+
+func duplicateResult(status *Status, c *ExecutionCoordinator) *Result {
+	c.ExecutionID = status.ExecutionID
+	result := &Result{
+		Duplicate: true,
+		Paused:    status.State == string(StatePaused),
+		Delayed:   status.State == string(StateDelayed),
+	}
+	if len(status.Errors) > 0 && (status.State == "failed" || status.State == "compensating" || status.State == "dead-lettered" || status.State == "compensated") {
+		result.ExecutionError = errors.New(status.Errors[len(status.Errors)-1])
+	}
+	logs, err := c.logStore.GetAllLogsByExecutionID(status.ExecutionID)
+	checkErr(err, "c.logStore.GetAllLogsByExecutionID()")
+	result.Steps = foldProgress(c.saga, logs).steps
+	return result
+}
@@ -0,0 +1,46 @@
+package saga
+
+// SetDefaults sets options applied to steps added after the call unless the steps set them: Timeout and
+// SoftTimeout, Retry, Compensation and CompensationRetry, CompensationTimeout and Tags, whose keys set
+// by steps win. Defaults that don't apply to a step are skipped, e.g. Retry of a critical step or
+// CompensationTimeout of a step without CompensateFunc.
+func (saga *Saga) SetDefaults(defaults StepOptions) {
+	saga.defaults = &defaults
+}
+
+// withDefaults returns copy of the step with defaults of the saga applied, the step itself if there are none.
+func (saga *Saga) withDefaults(step *Step) *Step {
+	if saga.defaults == nil {
+		return step
+	}
+	defaults := saga.defaults
+	var options StepOptions
+	if step.Options != nil {
+		options = *step.Options
+	}
+	if options.Timeout == 0 && options.SoftTimeout == 0 {
+		options.Timeout, options.SoftTimeout = defaults.Timeout, defaults.SoftTimeout
+	}
+	if options.Retry == nil && !options.Critical {
+		options.Retry = defaults.Retry
+	}
+	if options.Compensation == BestEffort && options.CompensationRetry == nil {
+		options.Compensation, options.CompensationRetry = defaults.Compensation, defaults.CompensationRetry
+	}
+	if options.CompensationTimeout == 0 && step.CompensateFunc != nil {
+		options.CompensationTimeout = defaults.CompensationTimeout
+	}
+	if len(defaults.Tags) > 0 {
+		tags := make(map[string]string, len(defaults.Tags)+len(options.Tags))
+		for key, value := range defaults.Tags {
+			tags[key] = value
+		}
+		for key, value := range options.Tags {
+			tags[key] = value
+		}
+		options.Tags = tags
+	}
+	withDefaults := *step
+	withDefaults.Options = &options
+	return &withDefaults
+}
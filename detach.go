@@ -0,0 +1,35 @@
+package saga
+
+import (
+	"context"
+	"time"
+)
+
+// WithCompensationContext replaces the context of compensations passed to NewCoordinator.
+func WithCompensationContext(ctx context.Context) Option {
+	return func(c *ExecutionCoordinator) {
+		c.compensateFuncsCtx = ctx
+	}
+}
+
+// WithDetachedCompensation makes compensations run with the context of steps detached from its cancellation
+// and deadline, so the execution is still compensated when the context of steps is canceled or expired.
+// Values of the context, e.g. tracing spans, are kept.
+func WithDetachedCompensation() Option {
+	return func(c *ExecutionCoordinator) {
+		c.compensateFuncsCtx = detachedContext{c.funcsCtx}
+	}
+}
+
+// detachedContext keeps values of the parent, but is never canceled.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+
+func (detachedContext) Done() <-chan struct{} { return nil }
+
+func (detachedContext) Err() error { return nil }
+
+func (c detachedContext) Value(key interface{}) interface{} { return c.parent.Value(key) }
@@ -0,0 +1,288 @@
+package saga
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// KeyProvider provides data keys for envelope encryption, e.g. by a KMS. Each data key is stored
+// encrypted along with the data it encrypts, only the KeyProvider can decrypt it.
+type KeyProvider interface {
+	// GenerateDataKey returns a new data key and its encrypted form
+	GenerateDataKey() (key, encryptedKey []byte, err error)
+	DecryptDataKey(encryptedKey []byte) ([]byte, error)
+}
+
+// Encrypted returns Store that encrypts payloads and errors of logs with AES-GCM before appending them
+// to the store and decrypts them on reads. They are bound to their logs by the execution ID, the type
+// and the step of the log as additional data, so they can't be moved to other logs. Other fields of logs
+// are stored as is.
+func Encrypted(store Store, keys KeyProvider) Store {
+	return &encryptedStore{store: store, keys: keys}
+}
+
+// encryptedPayload is stored instead of payload, so it stays valid JSON, and instead of the error.
+type encryptedPayload struct {
+	Key   []byte `json:"key"`
+	Nonce []byte `json:"nonce"`
+	Data  []byte `json:"data"`
+}
+
+// additionalData binds the encrypted field of the log to the log.
+func additionalData(l *Log, field string) []byte {
+	step := ""
+	if l.StepNumber != nil {
+		step = strconv.Itoa(*l.StepNumber)
+	}
+	return []byte(strings.Join([]string{l.ExecutionID, l.Type, step, field}, "\x00"))
+}
+
+type encryptedStore struct {
+	store Store
+	keys  KeyProvider
+}
+
+func (s *encryptedStore) AppendLog(log *Log) error {
+	encrypted, err := s.encrypt(log)
+	if err != nil {
+		return err
+	}
+	return s.store.AppendLog(encrypted)
+}
+
+func (s *encryptedStore) AppendLogAt(log *Log, expected int) error {
+	encrypted, err := s.encrypt(log)
+	if err != nil {
+		return err
+	}
+	return AppendLogAt(s.store, encrypted, expected)
+}
+
+// encrypt returns copy of the log with encrypted payload and error, the log isn't changed.
+func (s *encryptedStore) encrypt(log *Log) (*Log, error) {
+	if len(log.StepPayload) == 0 && log.StepError == nil {
+		return log, nil
+	}
+	key, encryptedKey, err := s.keys.GenerateDataKey()
+	if err != nil {
+		return nil, fmt.Errorf("can't generate data key: %w", err)
+	}
+	sealField := func(plaintext []byte, field string) ([]byte, error) {
+		nonce, data, err := seal(key, plaintext, additionalData(log, field))
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(&encryptedPayload{Key: encryptedKey, Nonce: nonce, Data: data})
+	}
+	encrypted := *log
+	if len(log.StepPayload) > 0 {
+		if encrypted.StepPayload, err = sealField(log.StepPayload, "payload"); err != nil {
+			return nil, err
+		}
+	}
+	if log.StepError != nil {
+		stepErr, err := sealField([]byte(*log.StepError), "error")
+		if err != nil {
+			return nil, err
+		}
+		sealed := string(stepErr)
+		encrypted.StepError = &sealed
+	}
+	return &encrypted, nil
+}
+
+func (s *encryptedStore) GetAllLogsByExecutionID(executionID string) ([]*Log, error) {
+	logs, err := s.store.GetAllLogsByExecutionID(executionID)
+	if err != nil {
+		return nil, err
+	}
+	return s.decrypt(logs)
+}
+
+func (s *encryptedStore) GetStepLogsToCompensate(executionID string) ([]*Log, error) {
+	logs, err := s.store.GetStepLogsToCompensate(executionID)
+	if err != nil {
+		return nil, err
+	}
+	return s.decrypt(logs)
+}
+
+func (s *encryptedStore) GetLogs(executionID string, types []string, from, to time.Time) ([]*Log, error) {
+	logs, err := s.store.GetLogs(executionID, types, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return s.decrypt(logs)
+}
+
+func (s *encryptedStore) GetLogsPage(executionID string, page Page) ([]*Log, string, error) {
+	logs, next, err := s.store.GetLogsPage(executionID, page)
+	if err != nil {
+		return nil, "", err
+	}
+	logs, err = s.decrypt(logs)
+	return logs, next, err
+}
+
+// ListExecutions returns statuses with decrypted errors, statuses of executions with errors are folded
+// from their decrypted logs.
+func (s *encryptedStore) ListExecutions(filter ExecutionFilter, page Page) ([]*Status, string, error) {
+	statuses, next, err := s.store.ListExecutions(filter, page)
+	if err != nil {
+		return nil, "", err
+	}
+	for i, status := range statuses {
+		if len(status.Errors) == 0 {
+			continue
+		}
+		if statuses[i], err = GetStatus(s, status.ExecutionID); err != nil {
+			return nil, "", err
+		}
+	}
+	return statuses, next, nil
+}
+
+func (s *encryptedStore) DeleteByMetadata(key, value string) (int, error) {
+	return s.store.DeleteByMetadata(key, value)
+}
+
+func (s *encryptedStore) DeleteTenantByMetadata(tenantID, key, value string) (int, error) {
+	return DeleteTenantByMetadata(s.store, tenantID, key, value)
+}
+
+func (s *encryptedStore) IsReadOnly() bool {
+	return IsReadOnly(s.store)
+}
+
+// Watch sends logs with decrypted payloads, logs whose payloads can't be decrypted are skipped.
+func (s *encryptedStore) Watch(ctx context.Context, filter LogFilter) <-chan *Log {
+	return mapLogs(ctx, s.store.Watch(ctx, filter), func(l *Log) *Log {
+		logs, err := s.decrypt([]*Log{l})
+		if err != nil {
+			return nil
+		}
+		return logs[0]
+	})
+}
+
+// decrypt returns copies of logs with decrypted payloads and errors, logs of the store aren't changed.
+func (s *encryptedStore) decrypt(logs []*Log) ([]*Log, error) {
+	res := make([]*Log, 0, len(logs))
+	for _, l := range logs {
+		if len(l.StepPayload) == 0 && l.StepError == nil {
+			res = append(res, l)
+			continue
+		}
+		decrypted := *l
+		if len(l.StepPayload) > 0 {
+			var payload encryptedPayload
+			if err := json.Unmarshal(l.StepPayload, &payload); err != nil {
+				return nil, fmt.Errorf("payload of %s isn't encrypted: %w", l.ExecutionID, err)
+			}
+			data, err := s.open(l, &payload, "payload")
+			if err != nil {
+				return nil, err
+			}
+			decrypted.StepPayload = data
+		}
+		if l.StepError != nil {
+			var stepErr encryptedPayload
+			if err := json.Unmarshal([]byte(*l.StepError), &stepErr); err != nil {
+				return nil, fmt.Errorf("error of %s isn't encrypted: %w", l.ExecutionID, err)
+			}
+			data, err := s.open(l, &stepErr, "error")
+			if err != nil {
+				return nil, err
+			}
+			opened := string(data)
+			decrypted.StepError = &opened
+		}
+		res = append(res, &decrypted)
+	}
+	return res, nil
+}
+
+// open decrypts the field of the log.
+func (s *encryptedStore) open(l *Log, payload *encryptedPayload, field string) ([]byte, error) {
+	key, err := s.keys.DecryptDataKey(payload.Key)
+	if err != nil {
+		return nil, fmt.Errorf("can't decrypt data key: %w", err)
+	}
+	return open(key, payload.Nonce, payload.Data, additionalData(l, field))
+}
+
+// NewAESKeyProvider returns KeyProvider that encrypts data keys by the master key with AES-GCM.
+// The master key must be 16, 24 or 32 bytes long.
+func NewAESKeyProvider(masterKey []byte) (KeyProvider, error) {
+	if _, err := aes.NewCipher(masterKey); err != nil {
+		return nil, err
+	}
+	return &aesKeyProvider{masterKey: masterKey}, nil
+}
+
+type aesKeyProvider struct {
+	masterKey []byte
+}
+
+func (p *aesKeyProvider) GenerateDataKey() ([]byte, []byte, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, nil, err
+	}
+	nonce, encrypted, err := seal(p.masterKey, key, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, append(nonce, encrypted...), nil
+}
+
+func (p *aesKeyProvider) DecryptDataKey(encryptedKey []byte) ([]byte, error) {
+	gcm, err := newGCM(p.masterKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(encryptedKey) < gcm.NonceSize() {
+		return nil, fmt.Errorf("encrypted key is too short")
+	}
+	return open(p.masterKey, encryptedKey[:gcm.NonceSize()], encryptedKey[gcm.NonceSize():], nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func seal(key, plaintext, additionalData []byte) (nonce, ciphertext []byte, err error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce = make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, nil, err
+	}
+	return nonce, gcm.Seal(nil, nonce, plaintext, additionalData), nil
+}
+
+func open(key, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, additionalData)
+	if err != nil {
+		return nil, fmt.Errorf("can't decrypt payload: %w", err)
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,74 @@
+package saga
+
+import "sync"
+
+// EventBus routes lifecycle events of executions to subscribers, e.g. transports such as HTTPEventSink,
+// metrics and webhooks such as WebhookNotifier, so each of them doesn't need its own hook in the engine.
+// It's EventSink itself, events are published to it by CloudEvents or WithEventBus.
+type EventBus interface {
+	EventSink
+	// Subscribe adds the sink receiving events of types, all by default, and returns func removing it
+	Subscribe(sink EventSink, types ...string) (unsubscribe func())
+}
+
+// NewEventBus returns the in-process EventBus. Events are delivered synchronously in order of emitting
+// to subscribers in order of subscription, so subscribers must not block for long, see EventSink.
+func NewEventBus() EventBus {
+	return &eventBus{}
+}
+
+// WithEventBus publishes lifecycle events of logs appended by the coordinator, and coordinators of its
+// compensating sagas and continuations, to the bus, see CloudEvents. Source is the source attribute of the events.
+func WithEventBus(bus EventBus, source string) Option {
+	return func(c *ExecutionCoordinator) {
+		c.eventBus = bus
+		c.eventSource = source
+	}
+}
+
+type eventBus struct {
+	mu sync.RWMutex
+	// subscribers are copied on write, so Emit iterates them without the lock
+	subscribers []*subscriber
+}
+
+type subscriber struct {
+	sink  EventSink
+	types map[string]bool
+}
+
+func (b *eventBus) Emit(event *CloudEvent) {
+	b.mu.RLock()
+	subscribers := b.subscribers
+	b.mu.RUnlock()
+	for _, s := range subscribers {
+		if len(s.types) == 0 || s.types[event.Type] {
+			s.sink.Emit(event)
+		}
+	}
+}
+
+func (b *eventBus) Subscribe(sink EventSink, types ...string) func() {
+	s := &subscriber{sink: sink, types: make(map[string]bool, len(types))}
+	for _, t := range types {
+		s.types[t] = true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers = append(b.subscribers[:len(b.subscribers):len(b.subscribers)], s)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			subscribers := make([]*subscriber, 0, len(b.subscribers))
+			for _, other := range b.subscribers {
+				if other != s {
+					subscribers = append(subscribers, other)
+				}
+			}
+			b.subscribers = subscribers
+		})
+	}
+}
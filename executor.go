@@ -0,0 +1,39 @@
+package saga
+
+import "context"
+
+// Executor is a step implemented by a type, e.g. a struct holding its dependencies, it can be Step.Func.
+// Steps whose Func is func(context.Context) error, including Executors, are called directly
+// instead of by reflection, so they cost less in performance-sensitive sagas.
+type Executor interface {
+	Execute(ctx context.Context) error
+}
+
+// Compensator is a compensation implemented by a type, it can be Step.CompensateFunc of a step without outputs.
+// Like Executor, compensate funcs func(context.Context) error are called directly.
+type Compensator interface {
+	Compensate(ctx context.Context) error
+}
+
+// bindMethods returns the step whose Executor and Compensator are replaced by their methods.
+func bindMethods(step *Step) *Step {
+	executor, isExecutor := step.Func.(Executor)
+	compensator, isCompensator := step.CompensateFunc.(Compensator)
+	if !isExecutor && !isCompensator {
+		return step
+	}
+	bound := *step
+	if isExecutor {
+		bound.Func = executor.Execute
+	}
+	if isCompensator {
+		bound.CompensateFunc = compensator.Compensate
+	}
+	return &bound
+}
+
+// directFunc returns fn if it's called without reflection.
+func directFunc(fn interface{}) (func(context.Context) error, bool) {
+	direct, ok := fn.(func(context.Context) error)
+	return direct, ok
+}
@@ -0,0 +1,103 @@
+package saga
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var ErrExecutionExists = errors.New("execution already exists")
+
+// Export writes logs of executions selected by filter to w, e.g. to move them to another environment
+// or to back them up before destructive maintenance. It returns the number of exported executions.
+//
+// The format is JSON Lines: each line is a Log as encoded by encoding/json, the same as logs served
+// by the admin API and printed by sagactl export. Logs of an execution are written together in order
+// of appending, executions follow in order of Store.ListExecutions.
+func Export(store Store, filter ExecutionFilter, w io.Writer) (int, error) {
+	enc := json.NewEncoder(w)
+	return exportLogs(store, filter, func(l *Log, seq int) error {
+		return enc.Encode(l)
+	})
+}
+
+// exportLogs calls write with logs of executions selected by filter and their sequence numbers in the execution,
+// it returns the number of exported executions.
+func exportLogs(store Store, filter ExecutionFilter, write func(l *Log, seq int) error) (int, error) {
+	page := Page{Limit: 100}
+	exported := 0
+	for {
+		statuses, next, err := store.ListExecutions(filter, page)
+		if err != nil {
+			return exported, err
+		}
+		for _, status := range statuses {
+			logs, err := store.GetAllLogsByExecutionID(status.ExecutionID)
+			if err != nil {
+				return exported, fmt.Errorf("%s: %w", status.ExecutionID, err)
+			}
+			for seq, l := range logs {
+				if err := write(l, seq); err != nil {
+					return exported, err
+				}
+			}
+			exported++
+		}
+		if next == "" {
+			return exported, nil
+		}
+		page.Cursor = next
+	}
+}
+
+// Import appends logs exported by Export to the store and returns the number of imported executions.
+// It fails with ErrExecutionExists before appending logs of an execution the store already has.
+// Logs are appended as is, so hashes of HashChained logs stay valid if they are imported into
+// the underlying store.
+func Import(store Store, r io.Reader) (int, error) {
+	dec := json.NewDecoder(bufio.NewReader(r))
+	return importLogs(store, func() (*Log, error) {
+		var l Log
+		if err := dec.Decode(&l); err == io.EOF {
+			return nil, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("invalid log: %w", err)
+		}
+		return &l, nil
+	})
+}
+
+// importLogs appends logs returned by next until it returns nil and returns the number of imported executions.
+func importLogs(store Store, next func() (*Log, error)) (int, error) {
+	imported := 0
+	current, seq := "", 0
+	for {
+		l, err := next()
+		if err != nil {
+			return imported, err
+		}
+		if l == nil {
+			return imported, nil
+		}
+		if l.ExecutionID == "" {
+			return imported, errors.New("invalid log: no execution ID")
+		}
+		if l.ExecutionID != current {
+			logs, _, err := store.GetLogsPage(l.ExecutionID, Page{Limit: 1})
+			if err != nil && !errors.Is(err, ErrNoLogs) {
+				return imported, err
+			}
+			if len(logs) > 0 {
+				return imported, fmt.Errorf("%w: %s", ErrExecutionExists, l.ExecutionID)
+			}
+			current, seq = l.ExecutionID, 0
+			imported++
+		}
+		if err := AppendLogAt(store, l, seq); err != nil {
+			return imported, fmt.Errorf("%s: %w", l.ExecutionID, err)
+		}
+		seq++
+	}
+}
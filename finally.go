@@ -0,0 +1,33 @@
+package saga
+
+import "context"
+
+// Finally sets fn run once per execution after it has succeeded, has been compensated or has been dead-lettered,
+// e.g. to release resources or notify upstream systems. It's run with the context of compensations before
+// the execution is completed, so it's run again by Resume if the process crashes in between. Its outcome is
+// written to the Store as a LogTypeSagaFinalized log, its error is returned in Result.FinallyError.
+func (saga *Saga) Finally(fn func(ctx context.Context, result *Result) error) {
+	saga.finally = fn
+}
+
+// finalize runs the finalizer of the saga unless it has already been run for the execution.
+func (c *ExecutionCoordinator) finalize(result *Result) {
+	if c.saga.finally == nil || c.finalized() {
+		return
+	}
+	c.syncLogs()
+	err := c.saga.finally(c.compensateFuncsCtx, result)
+	l := &Log{Type: LogTypeSagaFinalized}
+	if err != nil {
+		errStr := err.Error()
+		l.StepError = &errStr
+		result.FinallyError = err
+	}
+	c.appendLog(l)
+}
+
+func (c *ExecutionCoordinator) finalized() bool {
+	c.seqMu.Lock()
+	defer c.seqMu.Unlock()
+	return c.progress != nil && c.progress.finalized
+}
@@ -0,0 +1,898 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: admin.proto
+
+// Admin operations on saga executions, the same as provided by saga.AdminHandler over HTTP.
+
+package grpcadmin
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	durationpb "google.golang.org/protobuf/types/known/durationpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type ListExecutionsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	States        []string               `protobuf:"bytes,2,rep,name=states,proto3" json:"states,omitempty"`
+	Incomplete    bool                   `protobuf:"varint,3,opt,name=incomplete,proto3" json:"incomplete,omitempty"`
+	From          *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=from,proto3" json:"from,omitempty"`
+	To            *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=to,proto3" json:"to,omitempty"`
+	Limit         int32                  `protobuf:"varint,6,opt,name=limit,proto3" json:"limit,omitempty"`
+	Cursor        string                 `protobuf:"bytes,7,opt,name=cursor,proto3" json:"cursor,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListExecutionsRequest) Reset() {
+	*x = ListExecutionsRequest{}
+	mi := &file_admin_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListExecutionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListExecutionsRequest) ProtoMessage() {}
+
+func (x *ListExecutionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListExecutionsRequest.ProtoReflect.Descriptor instead.
+func (*ListExecutionsRequest) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *ListExecutionsRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ListExecutionsRequest) GetStates() []string {
+	if x != nil {
+		return x.States
+	}
+	return nil
+}
+
+func (x *ListExecutionsRequest) GetIncomplete() bool {
+	if x != nil {
+		return x.Incomplete
+	}
+	return false
+}
+
+func (x *ListExecutionsRequest) GetFrom() *timestamppb.Timestamp {
+	if x != nil {
+		return x.From
+	}
+	return nil
+}
+
+func (x *ListExecutionsRequest) GetTo() *timestamppb.Timestamp {
+	if x != nil {
+		return x.To
+	}
+	return nil
+}
+
+func (x *ListExecutionsRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *ListExecutionsRequest) GetCursor() string {
+	if x != nil {
+		return x.Cursor
+	}
+	return ""
+}
+
+type ListExecutionsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Executions    []*ExecutionStatus     `protobuf:"bytes,1,rep,name=executions,proto3" json:"executions,omitempty"`
+	NextCursor    string                 `protobuf:"bytes,2,opt,name=next_cursor,json=nextCursor,proto3" json:"next_cursor,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListExecutionsResponse) Reset() {
+	*x = ListExecutionsResponse{}
+	mi := &file_admin_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListExecutionsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListExecutionsResponse) ProtoMessage() {}
+
+func (x *ListExecutionsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListExecutionsResponse.ProtoReflect.Descriptor instead.
+func (*ListExecutionsResponse) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ListExecutionsResponse) GetExecutions() []*ExecutionStatus {
+	if x != nil {
+		return x.Executions
+	}
+	return nil
+}
+
+func (x *ListExecutionsResponse) GetNextCursor() string {
+	if x != nil {
+		return x.NextCursor
+	}
+	return ""
+}
+
+type GetExecutionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ExecutionId   string                 `protobuf:"bytes,1,opt,name=execution_id,json=executionId,proto3" json:"execution_id,omitempty"`
+	Limit         int32                  `protobuf:"varint,2,opt,name=limit,proto3" json:"limit,omitempty"`
+	Cursor        string                 `protobuf:"bytes,3,opt,name=cursor,proto3" json:"cursor,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetExecutionRequest) Reset() {
+	*x = GetExecutionRequest{}
+	mi := &file_admin_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetExecutionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetExecutionRequest) ProtoMessage() {}
+
+func (x *GetExecutionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetExecutionRequest.ProtoReflect.Descriptor instead.
+func (*GetExecutionRequest) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *GetExecutionRequest) GetExecutionId() string {
+	if x != nil {
+		return x.ExecutionId
+	}
+	return ""
+}
+
+func (x *GetExecutionRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *GetExecutionRequest) GetCursor() string {
+	if x != nil {
+		return x.Cursor
+	}
+	return ""
+}
+
+type GetExecutionResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Status        *ExecutionStatus       `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	Logs          []*Log                 `protobuf:"bytes,2,rep,name=logs,proto3" json:"logs,omitempty"`
+	NextCursor    string                 `protobuf:"bytes,3,opt,name=next_cursor,json=nextCursor,proto3" json:"next_cursor,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetExecutionResponse) Reset() {
+	*x = GetExecutionResponse{}
+	mi := &file_admin_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetExecutionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetExecutionResponse) ProtoMessage() {}
+
+func (x *GetExecutionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetExecutionResponse.ProtoReflect.Descriptor instead.
+func (*GetExecutionResponse) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *GetExecutionResponse) GetStatus() *ExecutionStatus {
+	if x != nil {
+		return x.Status
+	}
+	return nil
+}
+
+func (x *GetExecutionResponse) GetLogs() []*Log {
+	if x != nil {
+		return x.Logs
+	}
+	return nil
+}
+
+func (x *GetExecutionResponse) GetNextCursor() string {
+	if x != nil {
+		return x.NextCursor
+	}
+	return ""
+}
+
+type ExecutionRequest struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	ExecutionId string                 `protobuf:"bytes,1,opt,name=execution_id,json=executionId,proto3" json:"execution_id,omitempty"`
+	// reason of the operation recorded along with the actor
+	Reason        string `protobuf:"bytes,2,opt,name=reason,proto3" json:"reason,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExecutionRequest) Reset() {
+	*x = ExecutionRequest{}
+	mi := &file_admin_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExecutionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExecutionRequest) ProtoMessage() {}
+
+func (x *ExecutionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExecutionRequest.ProtoReflect.Descriptor instead.
+func (*ExecutionRequest) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ExecutionRequest) GetExecutionId() string {
+	if x != nil {
+		return x.ExecutionId
+	}
+	return ""
+}
+
+func (x *ExecutionRequest) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+type WatchExecutionRequest struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	ExecutionId string                 `protobuf:"bytes,1,opt,name=execution_id,json=executionId,proto3" json:"execution_id,omitempty"`
+	// types of sent logs, e.g. SagaStepExec, all by default
+	Types         []string `protobuf:"bytes,2,rep,name=types,proto3" json:"types,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchExecutionRequest) Reset() {
+	*x = WatchExecutionRequest{}
+	mi := &file_admin_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchExecutionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchExecutionRequest) ProtoMessage() {}
+
+func (x *WatchExecutionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchExecutionRequest.ProtoReflect.Descriptor instead.
+func (*WatchExecutionRequest) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *WatchExecutionRequest) GetExecutionId() string {
+	if x != nil {
+		return x.ExecutionId
+	}
+	return ""
+}
+
+func (x *WatchExecutionRequest) GetTypes() []string {
+	if x != nil {
+		return x.Types
+	}
+	return nil
+}
+
+// ExecutionEvent is either the status of the execution at the start of the watch or a log appended after it.
+type ExecutionEvent struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Event:
+	//
+	//	*ExecutionEvent_Status
+	//	*ExecutionEvent_Log
+	Event         isExecutionEvent_Event `protobuf_oneof:"event"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExecutionEvent) Reset() {
+	*x = ExecutionEvent{}
+	mi := &file_admin_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExecutionEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExecutionEvent) ProtoMessage() {}
+
+func (x *ExecutionEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExecutionEvent.ProtoReflect.Descriptor instead.
+func (*ExecutionEvent) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ExecutionEvent) GetEvent() isExecutionEvent_Event {
+	if x != nil {
+		return x.Event
+	}
+	return nil
+}
+
+func (x *ExecutionEvent) GetStatus() *ExecutionStatus {
+	if x != nil {
+		if x, ok := x.Event.(*ExecutionEvent_Status); ok {
+			return x.Status
+		}
+	}
+	return nil
+}
+
+func (x *ExecutionEvent) GetLog() *Log {
+	if x != nil {
+		if x, ok := x.Event.(*ExecutionEvent_Log); ok {
+			return x.Log
+		}
+	}
+	return nil
+}
+
+type isExecutionEvent_Event interface {
+	isExecutionEvent_Event()
+}
+
+type ExecutionEvent_Status struct {
+	Status *ExecutionStatus `protobuf:"bytes,1,opt,name=status,proto3,oneof"`
+}
+
+type ExecutionEvent_Log struct {
+	Log *Log `protobuf:"bytes,2,opt,name=log,proto3,oneof"`
+}
+
+func (*ExecutionEvent_Status) isExecutionEvent_Event() {}
+
+func (*ExecutionEvent_Log) isExecutionEvent_Event() {}
+
+type ExecutionStatus struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ExecutionId   string                 `protobuf:"bytes,1,opt,name=execution_id,json=executionId,proto3" json:"execution_id,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	State         string                 `protobuf:"bytes,3,opt,name=state,proto3" json:"state,omitempty"`
+	CurrentStep   *int32                 `protobuf:"varint,4,opt,name=current_step,json=currentStep,proto3,oneof" json:"current_step,omitempty"`
+	Attempts      map[int32]int32        `protobuf:"bytes,5,rep,name=attempts,proto3" json:"attempts,omitempty" protobuf_key:"varint,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	Errors        []string               `protobuf:"bytes,6,rep,name=errors,proto3" json:"errors,omitempty"`
+	StartedAt     *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=started_at,json=startedAt,proto3" json:"started_at,omitempty"`
+	UpdatedAt     *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	CompletedAt   *timestamppb.Timestamp `protobuf:"bytes,9,opt,name=completed_at,json=completedAt,proto3" json:"completed_at,omitempty"`
+	TenantId      string                 `protobuf:"bytes,10,opt,name=tenant_id,json=tenantId,proto3" json:"tenant_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExecutionStatus) Reset() {
+	*x = ExecutionStatus{}
+	mi := &file_admin_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExecutionStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExecutionStatus) ProtoMessage() {}
+
+func (x *ExecutionStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExecutionStatus.ProtoReflect.Descriptor instead.
+func (*ExecutionStatus) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ExecutionStatus) GetExecutionId() string {
+	if x != nil {
+		return x.ExecutionId
+	}
+	return ""
+}
+
+func (x *ExecutionStatus) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ExecutionStatus) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+func (x *ExecutionStatus) GetCurrentStep() int32 {
+	if x != nil && x.CurrentStep != nil {
+		return *x.CurrentStep
+	}
+	return 0
+}
+
+func (x *ExecutionStatus) GetAttempts() map[int32]int32 {
+	if x != nil {
+		return x.Attempts
+	}
+	return nil
+}
+
+func (x *ExecutionStatus) GetErrors() []string {
+	if x != nil {
+		return x.Errors
+	}
+	return nil
+}
+
+func (x *ExecutionStatus) GetStartedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.StartedAt
+	}
+	return nil
+}
+
+func (x *ExecutionStatus) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+func (x *ExecutionStatus) GetCompletedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CompletedAt
+	}
+	return nil
+}
+
+func (x *ExecutionStatus) GetTenantId() string {
+	if x != nil {
+		return x.TenantId
+	}
+	return ""
+}
+
+type Log struct {
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	ExecutionId  string                 `protobuf:"bytes,1,opt,name=execution_id,json=executionId,proto3" json:"execution_id,omitempty"`
+	Name         string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Type         string                 `protobuf:"bytes,3,opt,name=type,proto3" json:"type,omitempty"`
+	Time         *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=time,proto3" json:"time,omitempty"`
+	StepNumber   *int32                 `protobuf:"varint,5,opt,name=step_number,json=stepNumber,proto3,oneof" json:"step_number,omitempty"`
+	StepName     *string                `protobuf:"bytes,6,opt,name=step_name,json=stepName,proto3,oneof" json:"step_name,omitempty"`
+	StepError    *string                `protobuf:"bytes,7,opt,name=step_error,json=stepError,proto3,oneof" json:"step_error,omitempty"`
+	StepPayload  []byte                 `protobuf:"bytes,8,opt,name=step_payload,json=stepPayload,proto3" json:"step_payload,omitempty"`
+	StepDuration *durationpb.Duration   `protobuf:"bytes,9,opt,name=step_duration,json=stepDuration,proto3" json:"step_duration,omitempty"`
+	TenantId     string                 `protobuf:"bytes,10,opt,name=tenant_id,json=tenantId,proto3" json:"tenant_id,omitempty"`
+	// state the log has moved the execution to
+	State         string `protobuf:"bytes,11,opt,name=state,proto3" json:"state,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Log) Reset() {
+	*x = Log{}
+	mi := &file_admin_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Log) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Log) ProtoMessage() {}
+
+func (x *Log) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Log.ProtoReflect.Descriptor instead.
+func (*Log) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Log) GetExecutionId() string {
+	if x != nil {
+		return x.ExecutionId
+	}
+	return ""
+}
+
+func (x *Log) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Log) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *Log) GetTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+func (x *Log) GetStepNumber() int32 {
+	if x != nil && x.StepNumber != nil {
+		return *x.StepNumber
+	}
+	return 0
+}
+
+func (x *Log) GetStepName() string {
+	if x != nil && x.StepName != nil {
+		return *x.StepName
+	}
+	return ""
+}
+
+func (x *Log) GetStepError() string {
+	if x != nil && x.StepError != nil {
+		return *x.StepError
+	}
+	return ""
+}
+
+func (x *Log) GetStepPayload() []byte {
+	if x != nil {
+		return x.StepPayload
+	}
+	return nil
+}
+
+func (x *Log) GetStepDuration() *durationpb.Duration {
+	if x != nil {
+		return x.StepDuration
+	}
+	return nil
+}
+
+func (x *Log) GetTenantId() string {
+	if x != nil {
+		return x.TenantId
+	}
+	return ""
+}
+
+func (x *Log) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+var File_admin_proto protoreflect.FileDescriptor
+
+const file_admin_proto_rawDesc = "" +
+	"\n" +
+	"\vadmin.proto\x12\rsaga.admin.v1\x1a\x1egoogle/protobuf/duration.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"\xed\x01\n" +
+	"\x15ListExecutionsRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x16\n" +
+	"\x06states\x18\x02 \x03(\tR\x06states\x12\x1e\n" +
+	"\n" +
+	"incomplete\x18\x03 \x01(\bR\n" +
+	"incomplete\x12.\n" +
+	"\x04from\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\x04from\x12*\n" +
+	"\x02to\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\x02to\x12\x14\n" +
+	"\x05limit\x18\x06 \x01(\x05R\x05limit\x12\x16\n" +
+	"\x06cursor\x18\a \x01(\tR\x06cursor\"y\n" +
+	"\x16ListExecutionsResponse\x12>\n" +
+	"\n" +
+	"executions\x18\x01 \x03(\v2\x1e.saga.admin.v1.ExecutionStatusR\n" +
+	"executions\x12\x1f\n" +
+	"\vnext_cursor\x18\x02 \x01(\tR\n" +
+	"nextCursor\"f\n" +
+	"\x13GetExecutionRequest\x12!\n" +
+	"\fexecution_id\x18\x01 \x01(\tR\vexecutionId\x12\x14\n" +
+	"\x05limit\x18\x02 \x01(\x05R\x05limit\x12\x16\n" +
+	"\x06cursor\x18\x03 \x01(\tR\x06cursor\"\x97\x01\n" +
+	"\x14GetExecutionResponse\x126\n" +
+	"\x06status\x18\x01 \x01(\v2\x1e.saga.admin.v1.ExecutionStatusR\x06status\x12&\n" +
+	"\x04logs\x18\x02 \x03(\v2\x12.saga.admin.v1.LogR\x04logs\x12\x1f\n" +
+	"\vnext_cursor\x18\x03 \x01(\tR\n" +
+	"nextCursor\"M\n" +
+	"\x10ExecutionRequest\x12!\n" +
+	"\fexecution_id\x18\x01 \x01(\tR\vexecutionId\x12\x16\n" +
+	"\x06reason\x18\x02 \x01(\tR\x06reason\"P\n" +
+	"\x15WatchExecutionRequest\x12!\n" +
+	"\fexecution_id\x18\x01 \x01(\tR\vexecutionId\x12\x14\n" +
+	"\x05types\x18\x02 \x03(\tR\x05types\"{\n" +
+	"\x0eExecutionEvent\x128\n" +
+	"\x06status\x18\x01 \x01(\v2\x1e.saga.admin.v1.ExecutionStatusH\x00R\x06status\x12&\n" +
+	"\x03log\x18\x02 \x01(\v2\x12.saga.admin.v1.LogH\x00R\x03logB\a\n" +
+	"\x05event\"\x88\x04\n" +
+	"\x0fExecutionStatus\x12!\n" +
+	"\fexecution_id\x18\x01 \x01(\tR\vexecutionId\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x14\n" +
+	"\x05state\x18\x03 \x01(\tR\x05state\x12&\n" +
+	"\fcurrent_step\x18\x04 \x01(\x05H\x00R\vcurrentStep\x88\x01\x01\x12H\n" +
+	"\battempts\x18\x05 \x03(\v2,.saga.admin.v1.ExecutionStatus.AttemptsEntryR\battempts\x12\x16\n" +
+	"\x06errors\x18\x06 \x03(\tR\x06errors\x129\n" +
+	"\n" +
+	"started_at\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\tstartedAt\x129\n" +
+	"\n" +
+	"updated_at\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\x12=\n" +
+	"\fcompleted_at\x18\t \x01(\v2\x1a.google.protobuf.TimestampR\vcompletedAt\x12\x1b\n" +
+	"\ttenant_id\x18\n" +
+	" \x01(\tR\btenantId\x1a;\n" +
+	"\rAttemptsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\x05R\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x05R\x05value:\x028\x01B\x0f\n" +
+	"\r_current_step\"\xaf\x03\n" +
+	"\x03Log\x12!\n" +
+	"\fexecution_id\x18\x01 \x01(\tR\vexecutionId\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x12\n" +
+	"\x04type\x18\x03 \x01(\tR\x04type\x12.\n" +
+	"\x04time\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\x04time\x12$\n" +
+	"\vstep_number\x18\x05 \x01(\x05H\x00R\n" +
+	"stepNumber\x88\x01\x01\x12 \n" +
+	"\tstep_name\x18\x06 \x01(\tH\x01R\bstepName\x88\x01\x01\x12\"\n" +
+	"\n" +
+	"step_error\x18\a \x01(\tH\x02R\tstepError\x88\x01\x01\x12!\n" +
+	"\fstep_payload\x18\b \x01(\fR\vstepPayload\x12>\n" +
+	"\rstep_duration\x18\t \x01(\v2\x19.google.protobuf.DurationR\fstepDuration\x12\x1b\n" +
+	"\ttenant_id\x18\n" +
+	" \x01(\tR\btenantId\x12\x14\n" +
+	"\x05state\x18\v \x01(\tR\x05stateB\x0e\n" +
+	"\f_step_numberB\f\n" +
+	"\n" +
+	"_step_nameB\r\n" +
+	"\v_step_error2\xe4\x04\n" +
+	"\fAdminService\x12]\n" +
+	"\x0eListExecutions\x12$.saga.admin.v1.ListExecutionsRequest\x1a%.saga.admin.v1.ListExecutionsResponse\x12W\n" +
+	"\fGetExecution\x12\".saga.admin.v1.GetExecutionRequest\x1a#.saga.admin.v1.GetExecutionResponse\x12R\n" +
+	"\x0fResumeExecution\x12\x1f.saga.admin.v1.ExecutionRequest\x1a\x1e.saga.admin.v1.ExecutionStatus\x12L\n" +
+	"\tRetryStep\x12\x1f.saga.admin.v1.ExecutionRequest\x1a\x1e.saga.admin.v1.ExecutionStatus\x12N\n" +
+	"\vApproveStep\x12\x1f.saga.admin.v1.ExecutionRequest\x1a\x1e.saga.admin.v1.ExecutionStatus\x12Q\n" +
+	"\x0eAbortExecution\x12\x1f.saga.admin.v1.ExecutionRequest\x1a\x1e.saga.admin.v1.ExecutionStatus\x12W\n" +
+	"\x0eWatchExecution\x12$.saga.admin.v1.WatchExecutionRequest\x1a\x1d.saga.admin.v1.ExecutionEvent0\x01B(Z&github.com/itimofeev/go-saga/grpcadminb\x06proto3"
+
+var (
+	file_admin_proto_rawDescOnce sync.Once
+	file_admin_proto_rawDescData []byte
+)
+
+func file_admin_proto_rawDescGZIP() []byte {
+	file_admin_proto_rawDescOnce.Do(func() {
+		file_admin_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_admin_proto_rawDesc), len(file_admin_proto_rawDesc)))
+	})
+	return file_admin_proto_rawDescData
+}
+
+var file_admin_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
+var file_admin_proto_goTypes = []any{
+	(*ListExecutionsRequest)(nil),  // 0: saga.admin.v1.ListExecutionsRequest
+	(*ListExecutionsResponse)(nil), // 1: saga.admin.v1.ListExecutionsResponse
+	(*GetExecutionRequest)(nil),    // 2: saga.admin.v1.GetExecutionRequest
+	(*GetExecutionResponse)(nil),   // 3: saga.admin.v1.GetExecutionResponse
+	(*ExecutionRequest)(nil),       // 4: saga.admin.v1.ExecutionRequest
+	(*WatchExecutionRequest)(nil),  // 5: saga.admin.v1.WatchExecutionRequest
+	(*ExecutionEvent)(nil),         // 6: saga.admin.v1.ExecutionEvent
+	(*ExecutionStatus)(nil),        // 7: saga.admin.v1.ExecutionStatus
+	(*Log)(nil),                    // 8: saga.admin.v1.Log
+	nil,                            // 9: saga.admin.v1.ExecutionStatus.AttemptsEntry
+	(*timestamppb.Timestamp)(nil),  // 10: google.protobuf.Timestamp
+	(*durationpb.Duration)(nil),    // 11: google.protobuf.Duration
+}
+var file_admin_proto_depIdxs = []int32{
+	10, // 0: saga.admin.v1.ListExecutionsRequest.from:type_name -> google.protobuf.Timestamp
+	10, // 1: saga.admin.v1.ListExecutionsRequest.to:type_name -> google.protobuf.Timestamp
+	7,  // 2: saga.admin.v1.ListExecutionsResponse.executions:type_name -> saga.admin.v1.ExecutionStatus
+	7,  // 3: saga.admin.v1.GetExecutionResponse.status:type_name -> saga.admin.v1.ExecutionStatus
+	8,  // 4: saga.admin.v1.GetExecutionResponse.logs:type_name -> saga.admin.v1.Log
+	7,  // 5: saga.admin.v1.ExecutionEvent.status:type_name -> saga.admin.v1.ExecutionStatus
+	8,  // 6: saga.admin.v1.ExecutionEvent.log:type_name -> saga.admin.v1.Log
+	9,  // 7: saga.admin.v1.ExecutionStatus.attempts:type_name -> saga.admin.v1.ExecutionStatus.AttemptsEntry
+	10, // 8: saga.admin.v1.ExecutionStatus.started_at:type_name -> google.protobuf.Timestamp
+	10, // 9: saga.admin.v1.ExecutionStatus.updated_at:type_name -> google.protobuf.Timestamp
+	10, // 10: saga.admin.v1.ExecutionStatus.completed_at:type_name -> google.protobuf.Timestamp
+	10, // 11: saga.admin.v1.Log.time:type_name -> google.protobuf.Timestamp
+	11, // 12: saga.admin.v1.Log.step_duration:type_name -> google.protobuf.Duration
+	0,  // 13: saga.admin.v1.AdminService.ListExecutions:input_type -> saga.admin.v1.ListExecutionsRequest
+	2,  // 14: saga.admin.v1.AdminService.GetExecution:input_type -> saga.admin.v1.GetExecutionRequest
+	4,  // 15: saga.admin.v1.AdminService.ResumeExecution:input_type -> saga.admin.v1.ExecutionRequest
+	4,  // 16: saga.admin.v1.AdminService.RetryStep:input_type -> saga.admin.v1.ExecutionRequest
+	4,  // 17: saga.admin.v1.AdminService.ApproveStep:input_type -> saga.admin.v1.ExecutionRequest
+	4,  // 18: saga.admin.v1.AdminService.AbortExecution:input_type -> saga.admin.v1.ExecutionRequest
+	5,  // 19: saga.admin.v1.AdminService.WatchExecution:input_type -> saga.admin.v1.WatchExecutionRequest
+	1,  // 20: saga.admin.v1.AdminService.ListExecutions:output_type -> saga.admin.v1.ListExecutionsResponse
+	3,  // 21: saga.admin.v1.AdminService.GetExecution:output_type -> saga.admin.v1.GetExecutionResponse
+	7,  // 22: saga.admin.v1.AdminService.ResumeExecution:output_type -> saga.admin.v1.ExecutionStatus
+	7,  // 23: saga.admin.v1.AdminService.RetryStep:output_type -> saga.admin.v1.ExecutionStatus
+	7,  // 24: saga.admin.v1.AdminService.ApproveStep:output_type -> saga.admin.v1.ExecutionStatus
+	7,  // 25: saga.admin.v1.AdminService.AbortExecution:output_type -> saga.admin.v1.ExecutionStatus
+	6,  // 26: saga.admin.v1.AdminService.WatchExecution:output_type -> saga.admin.v1.ExecutionEvent
+	20, // [20:27] is the sub-list for method output_type
+	13, // [13:20] is the sub-list for method input_type
+	13, // [13:13] is the sub-list for extension type_name
+	13, // [13:13] is the sub-list for extension extendee
+	0,  // [0:13] is the sub-list for field type_name
+}
+
+func init() { file_admin_proto_init() }
+func file_admin_proto_init() {
+	if File_admin_proto != nil {
+		return
+	}
+	file_admin_proto_msgTypes[6].OneofWrappers = []any{
+		(*ExecutionEvent_Status)(nil),
+		(*ExecutionEvent_Log)(nil),
+	}
+	file_admin_proto_msgTypes[7].OneofWrappers = []any{}
+	file_admin_proto_msgTypes[8].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_admin_proto_rawDesc), len(file_admin_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   10,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_admin_proto_goTypes,
+		DependencyIndexes: file_admin_proto_depIdxs,
+		MessageInfos:      file_admin_proto_msgTypes,
+	}.Build()
+	File_admin_proto = out.File
+	file_admin_proto_goTypes = nil
+	file_admin_proto_depIdxs = nil
+}
@@ -0,0 +1,21 @@
+module github.com/itimofeev/go-saga/grpcadmin
+
+go 1.23
+
+require (
+	github.com/itimofeev/go-saga v0.0.0
+	github.com/stretchr/testify v1.3.0
+	google.golang.org/grpc v1.68.0
+	google.golang.org/protobuf v1.36.11
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/net v0.29.0 // indirect
+	golang.org/x/sys v0.25.0 // indirect
+	golang.org/x/text v0.18.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20240903143218-8af14fe29dc1 // indirect
+)
+
+replace github.com/itimofeev/go-saga => ../
@@ -0,0 +1,263 @@
+// Package grpcadmin exposes saga.Admin operations as a gRPC service, see admin.proto.
+// It's a separate module, so the saga package itself stays free of gRPC dependencies.
+package grpcadmin
+
+//go:generate buf generate
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	saga "github.com/itimofeev/go-saga"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Server implements AdminServiceServer on top of saga.Admin. Register it with
+//
+//	grpcadmin.RegisterAdminServiceServer(grpcServer, grpcadmin.NewServer(saga.NewAdmin(store, sagas...)))
+type Server struct {
+	UnimplementedAdminServiceServer
+
+	// TenantFromContext optionally returns tenant of the call, e.g. from metadata,
+	// all operations are scoped by it then. Error is returned as PermissionDenied.
+	TenantFromContext func(ctx context.Context) (string, error)
+	// ActorFromContext optionally returns identity of the caller passed to saga.Authorizer,
+	// e.g. from verified credentials in metadata. Error is returned as Unauthenticated.
+	ActorFromContext func(ctx context.Context) (string, error)
+
+	admin *saga.Admin
+}
+
+func NewServer(admin *saga.Admin) *Server {
+	return &Server{admin: admin}
+}
+
+func (s *Server) scoped(ctx context.Context) (*saga.Admin, error) {
+	if s.TenantFromContext == nil {
+		return s.admin, nil
+	}
+	tenantID, err := s.TenantFromContext(ctx)
+	if err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+	return s.admin.ForTenant(tenantID), nil
+}
+
+func (s *Server) ListExecutions(ctx context.Context, req *ListExecutionsRequest) (*ListExecutionsResponse, error) {
+	admin, err := s.scoped(ctx)
+	if err != nil {
+		return nil, err
+	}
+	filter := saga.ExecutionFilter{
+		Name:       req.GetName(),
+		States:     req.GetStates(),
+		Incomplete: req.GetIncomplete(),
+	}
+	if req.From != nil {
+		filter.From = req.From.AsTime()
+	}
+	if req.To != nil {
+		filter.To = req.To.AsTime()
+	}
+
+	statuses, next, err := admin.ListExecutions(filter, saga.Page{Limit: int(req.GetLimit()), Cursor: req.GetCursor()})
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	resp := &ListExecutionsResponse{NextCursor: next}
+	for _, st := range statuses {
+		resp.Executions = append(resp.Executions, toExecutionStatus(st))
+	}
+	return resp, nil
+}
+
+func (s *Server) GetExecution(ctx context.Context, req *GetExecutionRequest) (*GetExecutionResponse, error) {
+	admin, err := s.scoped(ctx)
+	if err != nil {
+		return nil, err
+	}
+	st, err := admin.GetStatus(req.GetExecutionId())
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	logs, next, err := admin.GetLogs(req.GetExecutionId(), saga.Page{Limit: int(req.GetLimit()), Cursor: req.GetCursor()})
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+
+	resp := &GetExecutionResponse{Status: toExecutionStatus(st), NextCursor: next}
+	for _, l := range logs {
+		resp.Logs = append(resp.Logs, toLog(l))
+	}
+	return resp, nil
+}
+
+func (s *Server) ResumeExecution(ctx context.Context, req *ExecutionRequest) (*ExecutionStatus, error) {
+	admin, err := s.scoped(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.execute(ctx, admin.Resume, req)
+}
+
+func (s *Server) RetryStep(ctx context.Context, req *ExecutionRequest) (*ExecutionStatus, error) {
+	admin, err := s.scoped(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.execute(ctx, admin.RetryStep, req)
+}
+
+func (s *Server) ApproveStep(ctx context.Context, req *ExecutionRequest) (*ExecutionStatus, error) {
+	admin, err := s.scoped(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.execute(ctx, admin.Approve, req)
+}
+
+func (s *Server) AbortExecution(ctx context.Context, req *ExecutionRequest) (*ExecutionStatus, error) {
+	admin, err := s.scoped(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.execute(ctx, admin.Compensate, req)
+}
+
+// WatchExecution sends status of the execution and then its logs until the execution is completed
+// or the client cancels the call. Logs appended while the status is read may be both reflected in it and sent.
+func (s *Server) WatchExecution(req *WatchExecutionRequest, stream AdminService_WatchExecutionServer) error {
+	admin, err := s.scoped(stream.Context())
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+	types := req.GetTypes()
+	if len(types) > 0 {
+		// completion ends the stream even if its logs aren't sent
+		types = append(append([]string(nil), types...), saga.LogTypeSagaComplete)
+	}
+	st, logs, err := admin.Watch(ctx, req.GetExecutionId(), types...)
+	if err != nil {
+		return toStatusError(err)
+	}
+	if err := stream.Send(&ExecutionEvent{Event: &ExecutionEvent_Status{Status: toExecutionStatus(st)}}); err != nil {
+		return err
+	}
+	if st.CompletedAt != nil {
+		return nil
+	}
+	for l := range logs {
+		if l.Type != saga.LogTypeSagaComplete || watched(req.GetTypes(), l.Type) {
+			if err := stream.Send(&ExecutionEvent{Event: &ExecutionEvent_Log{Log: toLog(l)}}); err != nil {
+				return err
+			}
+		}
+		if l.Type == saga.LogTypeSagaComplete {
+			return nil
+		}
+	}
+	return status.FromContextError(stream.Context().Err()).Err()
+}
+
+func watched(types []string, typ string) bool {
+	if len(types) == 0 {
+		return true
+	}
+	for _, t := range types {
+		if t == typ {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) execute(ctx context.Context, operation func(ctx context.Context, executionID string) (*saga.Status, error), req *ExecutionRequest) (*ExecutionStatus, error) {
+	if s.ActorFromContext != nil {
+		actor, err := s.ActorFromContext(ctx)
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+		ctx = saga.WithActor(ctx, actor)
+	}
+	if req.GetReason() != "" {
+		ctx = saga.WithReason(ctx, req.GetReason())
+	}
+	st, err := operation(ctx, req.GetExecutionId())
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	return toExecutionStatus(st), nil
+}
+
+func toStatusError(err error) error {
+	switch {
+	case errors.Is(err, saga.ErrNoLogs):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, saga.ErrNotAuthorized), errors.Is(err, saga.ErrReadOnly):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, saga.ErrInvalidCursor):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, saga.ErrExecutionCompleted), errors.Is(err, saga.ErrNothingToRetry),
+		errors.Is(err, saga.ErrNotPaused), errors.Is(err, saga.ErrUnknownSaga), errors.Is(err, saga.ErrPastPivot):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+func toExecutionStatus(st *saga.Status) *ExecutionStatus {
+	res := &ExecutionStatus{
+		ExecutionId: st.ExecutionID,
+		Name:        st.Name,
+		TenantId:    st.TenantID,
+		State:       st.State,
+		Attempts:    make(map[int32]int32, len(st.Attempts)),
+		Errors:      st.Errors,
+		StartedAt:   toTimestamp(st.StartedAt),
+		UpdatedAt:   toTimestamp(st.UpdatedAt),
+	}
+	if st.CurrentStep != nil {
+		step := int32(*st.CurrentStep)
+		res.CurrentStep = &step
+	}
+	for step, attempts := range st.Attempts {
+		res.Attempts[int32(step)] = int32(attempts)
+	}
+	if st.CompletedAt != nil {
+		res.CompletedAt = toTimestamp(*st.CompletedAt)
+	}
+	return res
+}
+
+func toLog(l *saga.Log) *Log {
+	res := &Log{
+		ExecutionId:  l.ExecutionID,
+		Name:         l.Name,
+		TenantId:     l.TenantID,
+		Type:         l.Type,
+		State:        string(l.State),
+		Time:         toTimestamp(l.Time),
+		StepName:     l.StepName,
+		StepError:    l.StepError,
+		StepPayload:  l.StepPayload,
+		StepDuration: durationpb.New(l.StepDuration),
+	}
+	if l.StepNumber != nil {
+		step := int32(*l.StepNumber)
+		res.StepNumber = &step
+	}
+	return res
+}
+
+func toTimestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
@@ -0,0 +1,88 @@
+package grpcadmin
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	saga "github.com/itimofeev/go-saga"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestServer(t *testing.T) {
+	s := saga.NewSaga("grpc")
+	noop := func(context.Context) error { return nil }
+	require.NoError(t, s.AddStep(&saga.Step{Name: "first", Func: noop, CompensateFunc: noop}))
+	require.NoError(t, s.AddStep(&saga.Step{Name: "second", Func: noop, CompensateFunc: noop, Options: &saga.StepOptions{RequireApproval: true}}))
+
+	store := saga.New()
+	c := saga.NewCoordinator(context.Background(), context.Background(), s, store)
+	require.True(t, c.Play().Paused)
+
+	listener := bufconn.Listen(1 << 20)
+	grpcServer := grpc.NewServer()
+	RegisterAdminServiceServer(grpcServer, NewServer(saga.NewAdmin(store, s)))
+	go func() { _ = grpcServer.Serve(listener) }()
+	defer grpcServer.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return listener.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	client := NewAdminServiceClient(conn)
+	ctx := context.Background()
+
+	list, err := client.ListExecutions(ctx, &ListExecutionsRequest{States: []string{"paused"}})
+	require.NoError(t, err)
+	require.Len(t, list.Executions, 1)
+	require.Equal(t, c.ExecutionID, list.Executions[0].ExecutionId)
+
+	execution, err := client.GetExecution(ctx, &GetExecutionRequest{ExecutionId: c.ExecutionID, Limit: 2})
+	require.NoError(t, err)
+	require.Equal(t, "paused", execution.Status.State)
+	require.Len(t, execution.Logs, 2)
+	require.Equal(t, saga.LogTypeStartSaga, execution.Logs[0].Type)
+	require.NotEmpty(t, execution.NextCursor)
+
+	watch, err := client.WatchExecution(ctx, &WatchExecutionRequest{ExecutionId: c.ExecutionID, Types: []string{saga.LogTypeSagaStepExec}})
+	require.NoError(t, err)
+	event, err := watch.Recv()
+	require.NoError(t, err)
+	require.Equal(t, "paused", event.GetStatus().GetState())
+
+	approved, err := client.ApproveStep(ctx, &ExecutionRequest{ExecutionId: c.ExecutionID})
+	require.NoError(t, err)
+	require.Equal(t, "completed", approved.State)
+	require.NotNil(t, approved.CompletedAt)
+	event, err = watch.Recv()
+	require.NoError(t, err)
+	require.Equal(t, saga.LogTypeSagaStepExec, event.GetLog().GetType())
+	require.Equal(t, "second", event.GetLog().GetStepName())
+	_, err = watch.Recv()
+	require.Equal(t, io.EOF, err)
+	watch, err = client.WatchExecution(ctx, &WatchExecutionRequest{ExecutionId: c.ExecutionID})
+	require.NoError(t, err)
+	event, err = watch.Recv()
+	require.NoError(t, err)
+	require.Equal(t, "completed", event.GetStatus().GetState())
+	_, err = watch.Recv()
+	require.Equal(t, io.EOF, err)
+	watch, err = client.WatchExecution(ctx, &WatchExecutionRequest{ExecutionId: "unknown"})
+	require.NoError(t, err)
+	_, err = watch.Recv()
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = client.AbortExecution(ctx, &ExecutionRequest{ExecutionId: c.ExecutionID})
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+	_, err = client.RetryStep(ctx, &ExecutionRequest{ExecutionId: "unknown"})
+	require.Equal(t, codes.NotFound, status.Code(err))
+	require.Equal(t, codes.Internal, status.Code(toStatusError(errors.New("hello"))))
+}
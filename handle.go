@@ -0,0 +1,91 @@
+package saga
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+)
+
+var ErrCanceled = errors.New("execution canceled")
+
+// ExecutionHandle is an execution played in the background by PlayAsync.
+type ExecutionHandle struct {
+	ExecutionID string
+
+	c      *ExecutionCoordinator
+	store  Store
+	cancel context.CancelFunc
+	done   chan struct{}
+	result *Result
+}
+
+// PlayAsync plays the execution in a new goroutine, so callers don't have to block for its full duration.
+// Errors of the Store are returned as the execution error the same way as by Runner. An execution with
+// a dedup key is started or attached to its duplicate before PlayAsync returns, so ExecutionID of
+// the handle is the ID of the execution that is played, see WithDedupKey.
+func (c *ExecutionCoordinator) PlayAsync() *ExecutionHandle {
+	if c.dedupKey == "" {
+		return c.async(c.Play)
+	}
+	executionStart := c.Clock.Now()
+	var duplicate *Result
+	failed := recoverResult(c, func() *Result {
+		duplicate = c.start()
+		return nil
+	})
+	return c.async(func() *Result {
+		defer c.finish()
+		if failed != nil {
+			return failed
+		}
+		if duplicate != nil {
+			return duplicate
+		}
+		return c.playStarted(executionStart)
+	})
+}
+
+// async runs f in a new goroutine with steps before the pivot canceled by ExecutionHandle.Cancel.
+func (c *ExecutionCoordinator) async(f func() *Result) *ExecutionHandle {
+	ctx, cancel := context.WithCancel(c.funcsCtx)
+	if c.forwardCtx == nil {
+		c.forwardCtx = c.funcsCtx
+	}
+	c.funcsCtx = ctx
+	h := &ExecutionHandle{
+		ExecutionID: c.ExecutionID,
+		c:           c,
+		store:       c.logStore,
+		cancel:      cancel,
+		done:        make(chan struct{}),
+	}
+	go func() {
+		defer close(h.done)
+		defer cancel()
+		h.result = recoverResult(c, f)
+	}()
+	return h
+}
+
+// Wait returns the result of the execution when Play returns, or ctx.Err() if ctx is done earlier.
+func (h *ExecutionHandle) Wait(ctx context.Context) (*Result, error) {
+	select {
+	case <-h.done:
+		return h.result, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Status returns the status of the execution from the Store.
+func (h *ExecutionHandle) Status() (*Status, error) {
+	return GetStatus(h.store, h.ExecutionID)
+}
+
+// Cancel cancels context of the running step and aborts the execution with ErrCanceled before the next one,
+// executed steps are compensated. Steps after the pivot aren't canceled, their context isn't canceled by Cancel
+// and they are retried until they succeed.
+func (h *ExecutionHandle) Cancel() {
+	atomic.StoreInt32(&h.c.canceled, 1)
+	h.cancel()
+}
@@ -0,0 +1,127 @@
+package saga
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Injector provides dependencies of struct handlers by type, see Saga.AddStruct.
+type Injector struct {
+	deps []reflect.Value
+}
+
+// NewInjector returns Injector providing the dependencies.
+func NewInjector(deps ...interface{}) *Injector {
+	injector := &Injector{}
+	for _, dep := range deps {
+		injector.Provide(dep)
+	}
+	return injector
+}
+
+// Provide adds the dependency, it's injected into fields of its type or of interfaces it implements.
+func (i *Injector) Provide(dep interface{}) {
+	checkOK(dep != nil, "dependency must not be nil")
+	i.deps = append(i.deps, reflect.ValueOf(dep))
+}
+
+// Inject sets exported fields of the struct pointed by target tagged by `inject:""` to the dependencies:
+// the one of the exact type of the field if it's provided, otherwise the first one assignable to it.
+func (i *Injector) Inject(target interface{}) error {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("target must be a pointer to struct, but it's %T", target)
+	}
+	v = v.Elem()
+	for f := 0; f < v.NumField(); f++ {
+		field := v.Type().Field(f)
+		if _, ok := field.Tag.Lookup("inject"); !ok {
+			continue
+		}
+		if field.PkgPath != "" {
+			return fmt.Errorf("field %s must be exported to be injected", field.Name)
+		}
+		dep, ok := i.lookup(field.Type)
+		if !ok {
+			return fmt.Errorf("no dependency of type %s for field %s", field.Type, field.Name)
+		}
+		v.Field(f).Set(dep)
+	}
+	return nil
+}
+
+func (i *Injector) lookup(typ reflect.Type) (reflect.Value, bool) {
+	for _, dep := range i.deps {
+		if dep.Type() == typ {
+			return dep, true
+		}
+	}
+	for _, dep := range i.deps {
+		if dep.Type().AssignableTo(typ) {
+			return dep, true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// AddStruct adds methods of the handler, a pointer to struct, as steps, so large services don't wire
+// closures by hand. Names of step methods in order of execution are listed in the `saga` tag of a blank field,
+// the compensation of each step is its method prefixed by Compensate and its options are returned by its method
+// suffixed by Options if there are such methods:
+//
+//	type Order struct {
+//		_        struct{}       `saga:"Reserve,Charge"`
+//		Payments PaymentsClient `inject:""`
+//	}
+//
+//	func (o *Order) Charge(ctx context.Context) (string, error)
+//	func (o *Order) CompensateCharge(ctx context.Context, chargeID string) error
+//
+// Fields tagged by `inject:""` are set by the injector first, it may be nil if there are none.
+// Steps are validated and added by AddSteps.
+func (saga *Saga) AddStruct(handler interface{}, injector *Injector) error {
+	v := reflect.ValueOf(handler)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("handler must be a pointer to struct, but it's %T", handler)
+	}
+	if injector == nil {
+		injector = NewInjector()
+	}
+	if err := injector.Inject(handler); err != nil {
+		return err
+	}
+
+	var names []string
+	structType := v.Elem().Type()
+	for f := 0; f < structType.NumField(); f++ {
+		if tag, ok := structType.Field(f).Tag.Lookup("saga"); ok && structType.Field(f).Name == "_" {
+			names = append(names, strings.Split(tag, ",")...)
+		}
+	}
+	if len(names) == 0 {
+		return errors.New("handler must list its steps in the saga tag of a blank field")
+	}
+	steps := make([]*Step, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		method := v.MethodByName(name)
+		if !method.IsValid() {
+			return fmt.Errorf("handler %T has no method %s", handler, name)
+		}
+		step := &Step{Name: name, Func: method.Interface()}
+		if compensate := v.MethodByName("Compensate" + name); compensate.IsValid() {
+			step.CompensateFunc = compensate.Interface()
+		}
+		if options := v.MethodByName(name + "Options"); options.IsValid() {
+			f, ok := options.Interface().(func() *StepOptions)
+			if !ok {
+				return fmt.Errorf("method %sOptions of handler %T must return *StepOptions", name, handler)
+			}
+			step.Options = f()
+		}
+		steps = append(steps, step)
+	}
+	return saga.AddSteps(steps...)
+}
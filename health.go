@@ -0,0 +1,181 @@
+package saga
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Pinger is implemented by stores that can check connectivity to their backend.
+// Stores that don't implement it are checked by listing a single execution.
+type Pinger interface {
+	Ping() error
+}
+
+// HealthCheck returns error if the checked component isn't healthy.
+type HealthCheck func() error
+
+// Health reports state of the Store, background workers and custom checks.
+// Its Handler fits liveness and readiness probes of Kubernetes.
+type Health struct {
+	// Clock is used for time of heartbeats, it must be set before registering them
+	Clock Clock
+
+	store Store
+
+	mu         sync.Mutex
+	checks     map[string]HealthCheck
+	heartbeats map[string]*Heartbeat
+}
+
+func NewHealth(store Store) *Health {
+	return &Health{
+		Clock:      SystemClock,
+		store:      store,
+		checks:     make(map[string]HealthCheck),
+		heartbeats: make(map[string]*Heartbeat),
+	}
+}
+
+// AddCheck registers check that must pass for the service to be ready.
+func (h *Health) AddCheck(name string, check HealthCheck) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checks[name] = check
+}
+
+// Heartbeat registers background worker that is considered alive while it calls
+// Beat at least once per timeout.
+func (h *Health) Heartbeat(name string, timeout time.Duration) *Heartbeat {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	hb := &Heartbeat{clock: h.Clock, timeout: timeout, last: h.Clock.Now()}
+	h.heartbeats[name] = hb
+	return hb
+}
+
+type Heartbeat struct {
+	clock   Clock
+	timeout time.Duration
+
+	mu   sync.Mutex
+	last time.Time
+}
+
+func (hb *Heartbeat) Beat() {
+	hb.mu.Lock()
+	defer hb.mu.Unlock()
+	hb.last = hb.clock.Now()
+}
+
+func (hb *Heartbeat) check() error {
+	hb.mu.Lock()
+	defer hb.mu.Unlock()
+	if since := hb.clock.Now().Sub(hb.last); since > hb.timeout {
+		return fmt.Errorf("no heartbeat for %s", since.Round(time.Millisecond))
+	}
+	return nil
+}
+
+// HealthReport is the result of health checks, Checks contains error for failed
+// checks and "ok" for passed ones.
+type HealthReport struct {
+	Healthy  bool              `json:"healthy"`
+	Checks   map[string]string `json:"checks"`
+	InFlight *int              `json:"inFlight,omitempty"`
+}
+
+// Liveness reports state of registered heartbeats.
+func (h *Health) Liveness() *HealthReport {
+	h.mu.Lock()
+	checks := make(map[string]HealthCheck, len(h.heartbeats))
+	for name, hb := range h.heartbeats {
+		checks["heartbeat:"+name] = hb.check
+	}
+	h.mu.Unlock()
+	return runChecks(checks)
+}
+
+// Readiness reports state of heartbeats, the Store and custom checks, and the
+// number of incomplete executions if the Store is available.
+func (h *Health) Readiness() *HealthReport {
+	h.mu.Lock()
+	checks := make(map[string]HealthCheck, len(h.checks)+len(h.heartbeats)+1)
+	for name, check := range h.checks {
+		checks[name] = check
+	}
+	for name, hb := range h.heartbeats {
+		checks["heartbeat:"+name] = hb.check
+	}
+	h.mu.Unlock()
+
+	var inFlight *int
+	checks["store"] = func() error {
+		if pinger, ok := h.store.(Pinger); ok {
+			if err := pinger.Ping(); err != nil {
+				return err
+			}
+		}
+		count, err := h.countInFlight()
+		if err != nil {
+			return err
+		}
+		inFlight = &count
+		return nil
+	}
+
+	report := runChecks(checks)
+	report.InFlight = inFlight
+	return report
+}
+
+func (h *Health) countInFlight() (int, error) {
+	count := 0
+	page := Page{Limit: 1000}
+	for {
+		statuses, next, err := h.store.ListExecutions(ExecutionFilter{Incomplete: true}, page)
+		if err != nil {
+			return 0, err
+		}
+		count += len(statuses)
+		if next == "" {
+			return count, nil
+		}
+		page.Cursor = next
+	}
+}
+
+func runChecks(checks map[string]HealthCheck) *HealthReport {
+	report := &HealthReport{Healthy: true, Checks: make(map[string]string, len(checks))}
+	for name, check := range checks {
+		if err := check(); err != nil {
+			report.Healthy = false
+			report.Checks[name] = err.Error()
+		} else {
+			report.Checks[name] = "ok"
+		}
+	}
+	return report
+}
+
+// Handler serves liveness report at /livez and readiness report at /readyz,
+// responding with 503 if any check fails.
+func (h *Health) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/livez", func(w http.ResponseWriter, r *http.Request) {
+		writeHealthReport(w, h.Liveness())
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		writeHealthReport(w, h.Readiness())
+	})
+	return mux
+}
+
+func writeHealthReport(w http.ResponseWriter, report *HealthReport) {
+	code := http.StatusOK
+	if !report.Healthy {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, report)
+}
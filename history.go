@@ -0,0 +1,147 @@
+package saga
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// HistoryFormat and HistoryVersion identify the interchange format of histories of executions, see ExportHistory.
+// The version is incremented on incompatible changes, new optional fields don't change it.
+const (
+	HistoryFormat  = "go-saga/history"
+	HistoryVersion = 1
+)
+
+var ErrUnsupportedHistory = errors.New("unsupported history format")
+
+// HistoryHeader is the first line of a history.
+type HistoryHeader struct {
+	Format     string    `json:"format"`
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exportedAt"`
+}
+
+// HistoryRecord is a line of a history: an event of an execution, i.e. one of its logs. Unlike Log, its encoding
+// is stable across versions of the package, so histories can be fed into analytics or other orchestration systems.
+type HistoryRecord struct {
+	ExecutionID string `json:"executionId"`
+	// Sequence is the number of the record in the execution starting from zero
+	Sequence int               `json:"sequence"`
+	Saga     string            `json:"saga"`
+	TenantID string            `json:"tenantId,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// Type is the type of the log, e.g. StartSaga or SagaStepExec, see LogTypeStartSaga and others
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+	// State is the state the event has moved the execution to, see State
+	State      string  `json:"state,omitempty"`
+	StepNumber *int    `json:"stepNumber,omitempty"`
+	StepName   *string `json:"stepName,omitempty"`
+	Error      *string `json:"error,omitempty"`
+	// Payload is the payload of the event if it's JSON, e.g. outputs of the step as an array,
+	// other payloads, e.g. encrypted ones, are PayloadBase64
+	Payload          json.RawMessage `json:"payload,omitempty"`
+	PayloadBase64    []byte          `json:"payloadBase64,omitempty"`
+	PayloadTruncated bool            `json:"payloadTruncated,omitempty"`
+	PayloadRef       string          `json:"payloadRef,omitempty"`
+	DurationNs       int64           `json:"durationNs,omitempty"`
+	TimedOut         bool            `json:"timedOut,omitempty"`
+	// Hash is the hash of HashChained logs
+	Hash []byte `json:"hash,omitempty"`
+}
+
+// NewHistoryRecord returns the record of the log with the sequence number in its execution.
+func NewHistoryRecord(l *Log, seq int) *HistoryRecord {
+	record := &HistoryRecord{
+		ExecutionID:      l.ExecutionID,
+		Sequence:         seq,
+		Saga:             l.Name,
+		TenantID:         l.TenantID,
+		Metadata:         l.Metadata,
+		Type:             l.Type,
+		Time:             l.Time,
+		State:            string(l.State),
+		StepNumber:       l.StepNumber,
+		StepName:         l.StepName,
+		Error:            l.StepError,
+		PayloadTruncated: l.StepPayloadTruncated,
+		PayloadRef:       l.StepPayloadRef,
+		DurationNs:       int64(l.StepDuration),
+		TimedOut:         l.StepTimedOut,
+		Hash:             l.Hash,
+	}
+	if len(l.StepPayload) > 0 {
+		if !l.StepPayloadTruncated && json.Valid(l.StepPayload) {
+			record.Payload = l.StepPayload
+		} else {
+			record.PayloadBase64 = l.StepPayload
+		}
+	}
+	return record
+}
+
+// Log returns the log of the record.
+func (r *HistoryRecord) Log() *Log {
+	l := &Log{
+		ExecutionID:          r.ExecutionID,
+		Name:                 r.Saga,
+		TenantID:             r.TenantID,
+		Metadata:             r.Metadata,
+		Type:                 r.Type,
+		Time:                 r.Time,
+		State:                State(r.State),
+		StepNumber:           r.StepNumber,
+		StepName:             r.StepName,
+		StepError:            r.Error,
+		StepPayload:          r.PayloadBase64,
+		StepPayloadTruncated: r.PayloadTruncated,
+		StepPayloadRef:       r.PayloadRef,
+		StepDuration:         time.Duration(r.DurationNs),
+		StepTimedOut:         r.TimedOut,
+		Hash:                 r.Hash,
+	}
+	if len(r.Payload) > 0 {
+		l.StepPayload = r.Payload
+	}
+	return l
+}
+
+// ExportHistory writes histories of executions selected by filter to w in the interchange format and returns
+// the number of exported executions. The format is JSON Lines: the first line is HistoryHeader, each next one
+// is HistoryRecord. Records of an execution are written together in order of their sequence numbers.
+func ExportHistory(store Store, filter ExecutionFilter, w io.Writer) (int, error) {
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(&HistoryHeader{Format: HistoryFormat, Version: HistoryVersion, ExportedAt: time.Now().UTC()}); err != nil {
+		return 0, err
+	}
+	return exportLogs(store, filter, func(l *Log, seq int) error {
+		return enc.Encode(NewHistoryRecord(l, seq))
+	})
+}
+
+// ImportHistory appends histories written by ExportHistory to the store and returns the number of imported
+// executions. It fails with ErrUnsupportedHistory for other formats and newer versions, and with
+// ErrExecutionExists before appending records of an execution the store already has.
+func ImportHistory(store Store, r io.Reader) (int, error) {
+	dec := json.NewDecoder(bufio.NewReader(r))
+	var header HistoryHeader
+	if err := dec.Decode(&header); err != nil {
+		return 0, fmt.Errorf("%w: invalid header: %v", ErrUnsupportedHistory, err)
+	}
+	if header.Format != HistoryFormat || header.Version < 1 || header.Version > HistoryVersion {
+		return 0, fmt.Errorf("%w: %s version %d", ErrUnsupportedHistory, header.Format, header.Version)
+	}
+	return importLogs(store, func() (*Log, error) {
+		var record HistoryRecord
+		if err := dec.Decode(&record); err == io.EOF {
+			return nil, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("invalid record: %w", err)
+		}
+		return record.Log(), nil
+	})
+}
@@ -0,0 +1,55 @@
+package saga
+
+// Hooks are called by the coordinator around steps and compensations, e.g. for tracing or business metrics.
+// Nil hooks are skipped. Compensations may run concurrently, see ParallelOrder, so hooks must be safe for that.
+type Hooks struct {
+	// BeforeStep is called before each attempt of a step
+	BeforeStep func(executionID string, step *Step)
+	// AfterStep is called after each attempt of a step with its result, before failures are compensated
+	AfterStep func(executionID string, result StepResult)
+	// AfterCompensate is called after each attempt of compensation of a step with its result
+	AfterCompensate func(executionID string, result StepResult)
+}
+
+// Logger logs failures of steps and compensations, *log.Logger implements it.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// WithHooks sets Hooks called around steps and compensations.
+func WithHooks(hooks Hooks) Option {
+	return func(c *ExecutionCoordinator) {
+		c.hooks = hooks
+	}
+}
+
+// WithLogger sets Logger of failures of steps and compensations, nothing is logged by default.
+func WithLogger(logger Logger) Option {
+	return func(c *ExecutionCoordinator) {
+		c.logger = logger
+	}
+}
+
+func (c *ExecutionCoordinator) beforeStep(i int) {
+	if c.hooks.BeforeStep != nil {
+		c.hooks.BeforeStep(c.ExecutionID, c.saga.steps[i])
+	}
+}
+
+func (c *ExecutionCoordinator) afterStep(i int, err error) {
+	if err != nil && c.logger != nil {
+		c.logger.Printf("saga %s: execution %s: step %s failed: %v", c.saga.Name, c.ExecutionID, c.saga.steps[i].Name, err)
+	}
+	if c.hooks.AfterStep != nil {
+		c.hooks.AfterStep(c.ExecutionID, c.stepResults()[i])
+	}
+}
+
+func (c *ExecutionCoordinator) afterCompensate(i int, err error) {
+	if err != nil && c.logger != nil {
+		c.logger.Printf("saga %s: execution %s: compensation of step %s failed: %v", c.saga.Name, c.ExecutionID, c.saga.steps[i].Name, err)
+	}
+	if c.hooks.AfterCompensate != nil {
+		c.hooks.AfterCompensate(c.ExecutionID, c.stepResults()[i])
+	}
+}
@@ -0,0 +1,123 @@
+package saga
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	mathrand "math/rand"
+	"sync"
+	"time"
+)
+
+// IDGenerator generates IDs of executions, they must be unique across processes.
+type IDGenerator interface {
+	NewID() string
+}
+
+// IDGeneratorFunc is an adapter to use ordinary functions as IDGenerator.
+type IDGeneratorFunc func() string
+
+func (f IDGeneratorFunc) NewID() string {
+	return f()
+}
+
+// DefaultIDGenerator is used when coordinator is created without WithIDGenerator or WithExecutionID.
+var DefaultIDGenerator IDGenerator = UUIDv7Generator{}
+
+// UUIDv7Generator generates time-ordered UUIDs version 7 (RFC 9562) from crypto/rand.
+type UUIDv7Generator struct {
+	// Clock gives the time of IDs, SystemClock is used if it's nil
+	Clock Clock
+}
+
+func (g UUIDv7Generator) NewID() string {
+	var b [16]byte
+	putMillis(b[:6], clockNow(g.Clock))
+	readRandom(b[6:])
+	b[6] = b[6]&0x0f | 0x70 // version 7
+	b[8] = b[8]&0x3f | 0x80 // variant 10
+
+	var buf [36]byte
+	hex.Encode(buf[0:8], b[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], b[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], b[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], b[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], b[10:])
+	return string(buf[:])
+}
+
+// ULIDGenerator generates lexicographically sortable ULIDs (https://github.com/ulid/spec) from crypto/rand.
+type ULIDGenerator struct {
+	// Clock gives the time of IDs, SystemClock is used if it's nil
+	Clock Clock
+}
+
+const crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+func (g ULIDGenerator) NewID() string {
+	var b [16]byte
+	putMillis(b[:6], clockNow(g.Clock))
+	readRandom(b[6:])
+
+	// 128 bits are encoded as 26 characters of 5 bits, the first one has only 3 bits
+	hi := binary.BigEndian.Uint64(b[:8])
+	lo := binary.BigEndian.Uint64(b[8:])
+	var buf [26]byte
+	for i := 25; i >= 0; i-- {
+		buf[i] = crockfordAlphabet[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(buf[:])
+}
+
+func putMillis(b []byte, t time.Time) {
+	ms := uint64(t.UnixNano() / int64(time.Millisecond))
+	for i := 5; i >= 0; i-- {
+		b[i] = byte(ms)
+		ms >>= 8
+	}
+}
+
+func readRandom(b []byte) {
+	_, err := rand.Read(b)
+	checkErr(err, "rand.Read()")
+}
+
+func clockNow(clock Clock) time.Time {
+	if clock == nil {
+		clock = SystemClock
+	}
+	return clock.Now()
+}
+
+// random is the source of non-cryptographic randomness, e.g. of RandString and sampling of logs.
+// Unlike the global source of math/rand it's seeded, so processes don't repeat the same sequence.
+var random = newLockedRand()
+
+type lockedRand struct {
+	mu   sync.Mutex
+	rand *mathrand.Rand
+}
+
+func newLockedRand() *lockedRand {
+	var seed [8]byte
+	readRandom(seed[:])
+	return &lockedRand{rand: mathrand.New(mathrand.NewSource(int64(binary.BigEndian.Uint64(seed[:]))))}
+}
+
+func (r *lockedRand) Intn(n int) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rand.Intn(n)
+}
+
+func (r *lockedRand) Float64() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rand.Float64()
+}
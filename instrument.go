@@ -0,0 +1,115 @@
+package saga
+
+import (
+	"context"
+	"time"
+)
+
+// StoreMetrics receives measurements of calls of Store methods, e.g. to export them to Prometheus.
+type StoreMetrics interface {
+	// ObserveStoreCall is called after each call of the method, e.g. "AppendLog", with its latency, error
+	// and total size of payloads of the appended or returned logs
+	ObserveStoreCall(method string, latency time.Duration, err error, payloadSize int)
+}
+
+// Instrumented returns Store that reports latency, errors and payload sizes of calls of the store to metrics,
+// so operators can see when persistence becomes the bottleneck. It's Transactional if the store is.
+func Instrumented(store Store, metrics StoreMetrics) Store {
+	return &instrumentedStore{store: store, metrics: metrics}
+}
+
+type instrumentedStore struct {
+	store   Store
+	metrics StoreMetrics
+}
+
+func (s *instrumentedStore) observe(method string, start time.Time, err error, logs ...*Log) {
+	size := 0
+	for _, l := range logs {
+		size += len(l.StepPayload)
+	}
+	s.metrics.ObserveStoreCall(method, time.Since(start), err, size)
+}
+
+func (s *instrumentedStore) AppendLog(log *Log) error {
+	start := time.Now()
+	err := s.store.AppendLog(log)
+	s.observe("AppendLog", start, err, log)
+	return err
+}
+
+func (s *instrumentedStore) AppendLogAt(log *Log, expected int) error {
+	start := time.Now()
+	err := AppendLogAt(s.store, log, expected)
+	s.observe("AppendLogAt", start, err, log)
+	return err
+}
+
+func (s *instrumentedStore) GetAllLogsByExecutionID(executionID string) ([]*Log, error) {
+	start := time.Now()
+	logs, err := s.store.GetAllLogsByExecutionID(executionID)
+	s.observe("GetAllLogsByExecutionID", start, err, logs...)
+	return logs, err
+}
+
+func (s *instrumentedStore) GetStepLogsToCompensate(executionID string) ([]*Log, error) {
+	start := time.Now()
+	logs, err := s.store.GetStepLogsToCompensate(executionID)
+	s.observe("GetStepLogsToCompensate", start, err, logs...)
+	return logs, err
+}
+
+func (s *instrumentedStore) GetLogs(executionID string, types []string, from, to time.Time) ([]*Log, error) {
+	start := time.Now()
+	logs, err := s.store.GetLogs(executionID, types, from, to)
+	s.observe("GetLogs", start, err, logs...)
+	return logs, err
+}
+
+func (s *instrumentedStore) GetLogsPage(executionID string, page Page) ([]*Log, string, error) {
+	start := time.Now()
+	logs, next, err := s.store.GetLogsPage(executionID, page)
+	s.observe("GetLogsPage", start, err, logs...)
+	return logs, next, err
+}
+
+func (s *instrumentedStore) ListExecutions(filter ExecutionFilter, page Page) ([]*Status, string, error) {
+	start := time.Now()
+	statuses, next, err := s.store.ListExecutions(filter, page)
+	s.observe("ListExecutions", start, err)
+	return statuses, next, err
+}
+
+func (s *instrumentedStore) DeleteByMetadata(key, value string) (int, error) {
+	start := time.Now()
+	deleted, err := s.store.DeleteByMetadata(key, value)
+	s.observe("DeleteByMetadata", start, err)
+	return deleted, err
+}
+
+func (s *instrumentedStore) DeleteTenantByMetadata(tenantID, key, value string) (int, error) {
+	start := time.Now()
+	deleted, err := DeleteTenantByMetadata(s.store, tenantID, key, value)
+	s.observe("DeleteTenantByMetadata", start, err)
+	return deleted, err
+}
+
+func (s *instrumentedStore) IsReadOnly() bool {
+	return IsReadOnly(s.store)
+}
+
+func (s *instrumentedStore) Watch(ctx context.Context, filter LogFilter) <-chan *Log {
+	start := time.Now()
+	logs := s.store.Watch(ctx, filter)
+	s.observe("Watch", start, nil)
+	return logs
+}
+
+func (s *instrumentedStore) WithinTx(fn func(tx Store) error) error {
+	start := time.Now()
+	err := WithinTx(s.store, func(tx Store) error {
+		return fn(&instrumentedStore{store: tx, metrics: s.metrics})
+	})
+	s.observe("WithinTx", start, err)
+	return err
+}
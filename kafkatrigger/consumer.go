@@ -0,0 +1,92 @@
+// Package kafkatrigger starts sagas from Kafka messages by saga.Trigger.
+// It's a separate module, so the saga package itself stays free of Kafka dependencies.
+package kafkatrigger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	saga "github.com/itimofeev/go-saga"
+	"github.com/segmentio/kafka-go"
+)
+
+// Reader is the part of *kafka.Reader used by Consumer. The reader must belong to a consumer group
+// with offsets committed explicitly, i.e. with zero CommitInterval.
+type Reader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// Consumer starts executions of sagas routed by topics of messages of the reader:
+//
+//	trigger := saga.NewTrigger(store)
+//	trigger.Route("orders", orderSaga, decodeOrder)
+//	reader := kafka.NewReader(kafka.ReaderConfig{Brokers: brokers, GroupID: "sagas", GroupTopics: trigger.Topics()})
+//	err := kafkatrigger.NewConsumer(reader, trigger).Run(ctx)
+//
+// The offset of a message is committed after the start of its execution is written to the Store,
+// so starts are at-least-once: redelivered messages resume their executions instead of duplicating them.
+// Executions are played in the background, so messages of a partition don't wait for each other.
+type Consumer struct {
+	// OnError is called for messages that can't be started, they are committed and skipped:
+	// messages of topics without routes and the ones rejected by the mapper
+	OnError func(msg kafka.Message, err error)
+
+	reader  Reader
+	trigger *saga.Trigger
+}
+
+func NewConsumer(reader Reader, trigger *saga.Trigger) *Consumer {
+	return &Consumer{
+		OnError: func(kafka.Message, error) {},
+		reader:  reader,
+		trigger: trigger,
+	}
+}
+
+// Run consumes messages until ctx is done or an error of the reader or the Store, the message that has failed
+// isn't committed then, so it's redelivered. Run doesn't wait for executions started by it.
+func (c *Consumer) Run(ctx context.Context) error {
+	for {
+		msg, err := c.reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("fetch message: %w", err)
+		}
+		if _, err := c.trigger.Start(Message(msg)); err != nil {
+			if !errors.Is(err, saga.ErrNoRoute) && !errors.Is(err, saga.ErrInvalidMessage) {
+				return fmt.Errorf("start execution of message %s: %w", MessageID(msg), err)
+			}
+			c.OnError(msg, err)
+		}
+		if err := c.reader.CommitMessages(ctx, msg); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("commit message %s: %w", MessageID(msg), err)
+		}
+	}
+}
+
+// MessageID returns ID of executions started by the message: its topic, partition and offset.
+func MessageID(msg kafka.Message) string {
+	return fmt.Sprintf("kafka/%s/%d/%d", msg.Topic, msg.Partition, msg.Offset)
+}
+
+// Message converts the Kafka message to saga.TriggerMessage.
+func Message(msg kafka.Message) *saga.TriggerMessage {
+	headers := make(map[string]string, len(msg.Headers))
+	for _, header := range msg.Headers {
+		headers[header.Key] = string(header.Value)
+	}
+	return &saga.TriggerMessage{
+		ID:      MessageID(msg),
+		Topic:   msg.Topic,
+		Key:     msg.Key,
+		Value:   msg.Value,
+		Headers: headers,
+	}
+}
@@ -0,0 +1,81 @@
+package kafkatrigger
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	saga "github.com/itimofeev/go-saga"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeReader struct {
+	messages  []kafka.Message
+	committed []kafka.Message
+	onCommit  func(msg kafka.Message)
+	cancel    context.CancelFunc
+}
+
+func (r *fakeReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	if len(r.messages) == 0 {
+		r.cancel()
+		return kafka.Message{}, ctx.Err()
+	}
+	msg := r.messages[0]
+	r.messages = r.messages[1:]
+	return msg, nil
+}
+
+func (r *fakeReader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	for _, msg := range msgs {
+		r.onCommit(msg)
+	}
+	r.committed = append(r.committed, msgs...)
+	return nil
+}
+
+func TestConsumer(t *testing.T) {
+	s := saga.NewSaga("order")
+	noop := func(context.Context) error { return nil }
+	require.NoError(t, s.AddStep(&saga.Step{Name: "reserve", Func: noop, CompensateFunc: noop}))
+	store := saga.New()
+	trigger := saga.NewTrigger(store)
+	trigger.Route("orders", s, func(msg *saga.TriggerMessage) (interface{}, error) {
+		if len(msg.Value) == 0 {
+			return nil, errors.New("empty order")
+		}
+		return string(msg.Value), nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reader := &fakeReader{
+		messages: []kafka.Message{
+			{Topic: "orders", Partition: 1, Offset: 10, Value: []byte("42"), Headers: []kafka.Header{{Key: "trace", Value: []byte("abc")}}},
+			{Topic: "orders", Partition: 1, Offset: 11},
+			{Topic: "payments", Partition: 0, Offset: 3},
+		},
+		cancel: cancel,
+	}
+	// offsets are committed after starts of executions are written
+	reader.onCommit = func(msg kafka.Message) {
+		if msg.Offset == 10 {
+			_, err := saga.GetStatus(store, MessageID(msg))
+			require.NoError(t, err)
+		}
+	}
+	consumer := NewConsumer(reader, trigger)
+	var failed []error
+	consumer.OnError = func(msg kafka.Message, err error) { failed = append(failed, err) }
+
+	require.NoError(t, consumer.Run(ctx))
+	require.Len(t, reader.committed, 3)
+	require.Len(t, failed, 2)
+	require.True(t, errors.Is(failed[0], saga.ErrInvalidMessage))
+	require.True(t, errors.Is(failed[1], saga.ErrNoRoute))
+
+	msg := Message(kafka.Message{Topic: "orders", Partition: 1, Offset: 10, Headers: []kafka.Header{{Key: "trace", Value: []byte("abc")}}})
+	require.Equal(t, "kafka/orders/1/10", msg.ID)
+	require.Equal(t, map[string]string{"trace": "abc"}, msg.Headers)
+}
@@ -0,0 +1,198 @@
+// Package loadtest drives executions of generated sagas against a saga.Store and reports
+// latency of appends and throughput, to help sizing the backend of the Store:
+//
+//	report, err := loadtest.Run(ctx, store, loadtest.Config{Executions: 10000, Concurrency: 32, Steps: 5})
+//	fmt.Println(report)
+//
+// It's a tool for manual runs against real backends rather than a test.
+package loadtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	saga "github.com/itimofeev/go-saga"
+)
+
+var errStepFailed = errors.New("step failed by loadtest")
+
+// Config describes the load, zero values are replaced by defaults.
+type Config struct {
+	// Executions is the total number of executions, 1000 by default
+	Executions int
+	// Concurrency is the number of executions run in parallel, 10 by default
+	Concurrency int
+	// Steps is the number of steps of the saga, 3 by default
+	Steps int
+	// PayloadSize is the size of the value returned by each step in bytes
+	PayloadSize int
+	// FailureRate is the share of executions, from 0 to 1, that fail on a random step and are compensated
+	FailureRate float64
+	// Seed of failures, the same seed gives the same failures
+	Seed int64
+}
+
+func (c Config) withDefaults() Config {
+	if c.Executions <= 0 {
+		c.Executions = 1000
+	}
+	if c.Concurrency <= 0 {
+		c.Concurrency = 10
+	}
+	if c.Steps <= 0 {
+		c.Steps = 3
+	}
+	return c
+}
+
+// Report is the result of Run.
+type Report struct {
+	Executions int
+	// Failed is the number of executions failed and compensated by Config.FailureRate
+	Failed int
+	// Errors is the number of executions interrupted by errors of the Store
+	Errors   int
+	Appends  int
+	Duration time.Duration
+	// AppendsPerSecond and ExecutionsPerSecond are measured over Duration
+	AppendsPerSecond    float64
+	ExecutionsPerSecond float64
+	// latencies of AppendLog
+	P50, P90, P99, Max time.Duration
+}
+
+func (r *Report) String() string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "executions: %d (failed %d, errors %d) in %s\n", r.Executions, r.Failed, r.Errors, r.Duration.Round(time.Millisecond))
+	fmt.Fprintf(b, "throughput: %.1f executions/s, %.1f appends/s\n", r.ExecutionsPerSecond, r.AppendsPerSecond)
+	fmt.Fprintf(b, "append latency: p50 %s, p90 %s, p99 %s, max %s", r.P50, r.P90, r.P99, r.Max)
+	return b.String()
+}
+
+// Run runs executions described by cfg against the store until all of them are done or ctx is done.
+// Executions interrupted by errors of the Store are counted in Report.Errors, they aren't retried.
+func Run(ctx context.Context, store saga.Store, cfg Config) (*Report, error) {
+	cfg = cfg.withDefaults()
+	timed := &timedStore{Store: store}
+	payload := strings.Repeat("x", cfg.PayloadSize)
+
+	// failures are decided upfront to keep them independent from scheduling
+	rnd := rand.New(rand.NewSource(cfg.Seed))
+	failOn := make([]int, cfg.Executions)
+	for i := range failOn {
+		failOn[i] = -1
+		if rnd.Float64() < cfg.FailureRate {
+			failOn[i] = rnd.Intn(cfg.Steps)
+		}
+	}
+
+	report := &Report{}
+	var mu sync.Mutex
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	started := time.Now()
+	for w := 0; w < cfg.Concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				failed, err := runExecution(ctx, timed, newSaga(cfg.Steps, failOn[i], payload))
+				mu.Lock()
+				report.Executions++
+				if err != nil {
+					report.Errors++
+				} else if failed {
+					report.Failed++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+loop:
+	for i := 0; i < cfg.Executions; i++ {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break loop
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	report.Duration = time.Since(started)
+	timed.fill(report)
+	return report, ctx.Err()
+}
+
+func newSaga(steps, failOn int, payload string) *saga.Saga {
+	s := saga.NewSaga("loadtest")
+	for i := 0; i < steps; i++ {
+		var err error
+		if i == failOn {
+			err = errStepFailed
+		}
+		// the saga is generated, so steps are valid
+		_ = s.AddStep(&saga.Step{
+			Name:           fmt.Sprintf("step%d", i),
+			Func:           func(context.Context) (string, error) { return payload, err },
+			CompensateFunc: func(context.Context, string) error { return nil },
+		})
+	}
+	return s
+}
+
+// runExecution plays the saga, errors of the Store make the coordinator panic and are recovered here.
+func runExecution(ctx context.Context, store saga.Store, s *saga.Saga) (failed bool, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("execution failed: %v", p)
+		}
+	}()
+	result := saga.NewCoordinator(ctx, ctx, s, store).Play()
+	return result.ExecutionError != nil, nil
+}
+
+// timedStore measures latency of appends.
+type timedStore struct {
+	saga.Store
+
+	mu        sync.Mutex
+	latencies []time.Duration
+}
+
+func (s *timedStore) AppendLog(log *saga.Log) error {
+	start := time.Now()
+	err := s.Store.AppendLog(log)
+	latency := time.Since(start)
+
+	s.mu.Lock()
+	s.latencies = append(s.latencies, latency)
+	s.mu.Unlock()
+	return err
+}
+
+func (s *timedStore) fill(report *Report) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	report.Appends = len(s.latencies)
+	if seconds := report.Duration.Seconds(); seconds > 0 {
+		report.AppendsPerSecond = float64(report.Appends) / seconds
+		report.ExecutionsPerSecond = float64(report.Executions) / seconds
+	}
+	if len(s.latencies) == 0 {
+		return
+	}
+	sort.Slice(s.latencies, func(i, j int) bool { return s.latencies[i] < s.latencies[j] })
+	percentile := func(p float64) time.Duration {
+		return s.latencies[int(p*float64(len(s.latencies)-1))]
+	}
+	report.P50, report.P90, report.P99 = percentile(0.5), percentile(0.9), percentile(0.99)
+	report.Max = s.latencies[len(s.latencies)-1]
+}
@@ -0,0 +1,27 @@
+package loadtest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	saga "github.com/itimofeev/go-saga"
+	"github.com/itimofeev/go-saga/sagatest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	report, err := Run(context.Background(), saga.New(), Config{Executions: 100, Concurrency: 4, FailureRate: 0.5, Seed: 1})
+	require.NoError(t, err)
+	require.Equal(t, 100, report.Executions)
+	require.InDelta(t, 50, report.Failed, 20)
+	require.Zero(t, report.Errors)
+	require.True(t, report.Appends >= 100*5)
+	require.True(t, report.P50 <= report.P99 && report.P99 <= report.Max)
+	require.Contains(t, report.String(), "executions: 100")
+
+	store := sagatest.NewStore().FailAlways(sagatest.MethodAppendLog, errors.New("unavailable"))
+	report, err = Run(context.Background(), store, Config{Executions: 3})
+	require.NoError(t, err)
+	require.Equal(t, 3, report.Errors)
+}
@@ -0,0 +1,111 @@
+package saga
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	ErrNoSemanticLocks = errors.New("no semantic locks, see WithSemanticLocks")
+	ErrResourceLocked  = errors.New("resource is locked by another execution")
+)
+
+// SemanticLocks mark resources, e.g. records of orders, as pending while executions changing them
+// are in progress. It's a countermeasure against isolation anomalies of sagas: readers and other
+// executions see that a record is pending instead of reading an intermediate state.
+type SemanticLocks interface {
+	// Lock marks the resource pending by the execution, it returns ErrResourceLocked if another
+	// execution holds it. Locking a resource held by the same execution succeeds
+	Lock(resource, executionID string) error
+	// Unlock clears the lock of the resource if the execution holds it
+	Unlock(resource, executionID string) error
+	// Holder returns ID of the execution holding the resource, empty if it isn't locked
+	Holder(resource string) (string, error)
+	// Release clears all locks of the execution
+	Release(executionID string) error
+}
+
+// WithSemanticLocks makes the locks available to steps and compensations by LockResource and UnlockResource.
+// Locks of the execution are released when it completes or is compensated.
+func WithSemanticLocks(locks SemanticLocks) Option {
+	return func(c *ExecutionCoordinator) {
+		holder := &lockHolder{locks: locks, c: c}
+		c.funcsCtx = context.WithValue(c.funcsCtx, locksKey{}, holder)
+		c.compensateFuncsCtx = context.WithValue(c.compensateFuncsCtx, locksKey{}, holder)
+		c.locks = locks
+	}
+}
+
+type locksKey struct{}
+
+// lockHolder refers to the coordinator, because its ExecutionID is set after options
+type lockHolder struct {
+	locks SemanticLocks
+	c     *ExecutionCoordinator
+}
+
+// LockResource marks the resource pending by the execution of the step, ctx is the context passed to a step or compensation.
+func LockResource(ctx context.Context, resource string) error {
+	holder, ok := ctx.Value(locksKey{}).(*lockHolder)
+	if !ok {
+		return ErrNoSemanticLocks
+	}
+	return holder.locks.Lock(resource, holder.c.ExecutionID)
+}
+
+// UnlockResource clears the lock of the resource before the execution ends, e.g. in a compensation.
+func UnlockResource(ctx context.Context, resource string) error {
+	holder, ok := ctx.Value(locksKey{}).(*lockHolder)
+	if !ok {
+		return ErrNoSemanticLocks
+	}
+	return holder.locks.Unlock(resource, holder.c.ExecutionID)
+}
+
+// NewMemoryLocks returns SemanticLocks kept in memory, they are lost on restart.
+func NewMemoryLocks() SemanticLocks {
+	return &memoryLocks{holders: make(map[string]string)}
+}
+
+type memoryLocks struct {
+	mu      sync.Mutex
+	holders map[string]string
+}
+
+func (l *memoryLocks) Lock(resource, executionID string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if holder, ok := l.holders[resource]; ok && holder != executionID {
+		return fmt.Errorf("%w: %s is locked by %s", ErrResourceLocked, resource, holder)
+	}
+	l.holders[resource] = executionID
+	return nil
+}
+
+func (l *memoryLocks) Unlock(resource, executionID string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.holders[resource] == executionID {
+		delete(l.holders, resource)
+	}
+	return nil
+}
+
+func (l *memoryLocks) Holder(resource string) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.holders[resource], nil
+}
+
+func (l *memoryLocks) Release(executionID string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for resource, holder := range l.holders {
+		if holder == executionID {
+			delete(l.holders, resource)
+		}
+	}
+	return nil
+}
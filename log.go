@@ -1,30 +1,203 @@
 package saga
 
-import "time"
+import (
+	"context"
+	"strings"
+	"time"
+)
 
-//noinspection ALL
+// noinspection ALL
 const (
 	LogTypeStartSaga          = "StartSaga"
 	LogTypeSagaStepExec       = "SagaStepExec"
 	LogTypeSagaAbort          = "SagaAbort"
 	LogTypeSagaStepCompensate = "SagaStepCompensate"
 	LogTypeSagaComplete       = "SagaComplete"
+	// LogTypeSagaStepPaused pauses the execution before the step, StepDuration is StepOptions.ApprovalTimeout
+	LogTypeSagaStepPaused   = "SagaStepPaused"
+	LogTypeSagaStepApproved = "SagaStepApproved"
+	// LogTypeSagaStepDelayed starts the delay of the step, StepDuration is the delay, see StepOptions.Delay
+	// and StepOptions.Window
+	LogTypeSagaStepDelayed = "SagaStepDelayed"
+	// LogTypeSagaStepRetryScheduled schedules the next attempt of the failed step, StepDuration is the backoff,
+	// see RetryPolicy
+	LogTypeSagaStepRetryScheduled = "SagaStepRetryScheduled"
+	// LogTypeSagaStepSkipped records that the step was skipped by StepOptions.Condition
+	LogTypeSagaStepSkipped = "SagaStepSkipped"
+	// LogTypeSagaCompensationDeferred starts the grace period of the failed step, StepDuration is the period,
+	// see Saga.CompensationGrace
+	LogTypeSagaCompensationDeferred = "SagaCompensationDeferred"
+	// LogTypeSagaStepCompensateFailed records the error of the compensation of the step
+	LogTypeSagaStepCompensateFailed = "SagaStepCompensateFailed"
+	// LogTypeSagaCompensationRetryScheduled schedules the next attempt of failed guaranteed compensations,
+	// StepDuration is the backoff, see Guaranteed
+	LogTypeSagaCompensationRetryScheduled = "SagaCompensationRetryScheduled"
+	// LogTypeSagaDeadLettered parks the execution whose guaranteed compensations have failed all attempts
+	LogTypeSagaDeadLettered = "SagaDeadLettered"
+	// LogTypeSagaVersionRecorded records version of a resource read by the step, see RecordVersion
+	LogTypeSagaVersionRecorded = "SagaVersionRecorded"
+	// LogTypeSagaContinued records the continuation of the execution, see Saga.OnSuccess
+	LogTypeSagaContinued = "SagaContinued"
+	// LogTypeSagaDataSet sets a value in the data bag of the execution, see SetData. The payload of
+	// LogTypeSagaComplete is the final snapshot of the bag
+	LogTypeSagaDataSet = "SagaDataSet"
+	// LogTypeSagaFinalized records that the finalizer of the saga has run, StepError is its error, see Saga.Finally
+	LogTypeSagaFinalized = "SagaFinalized"
+	// LogTypeSagaOperation records an operation performed on the execution, its payload is Attribution
+	LogTypeSagaOperation = "SagaOperation"
+	// LogTypeSagaTimerClaimed claims the due timer of the execution for the TimerLoop resuming it, StepDuration
+	// is the lease, see TimerLoop.Lease
+	LogTypeSagaTimerClaimed = "SagaTimerClaimed"
 )
 
 type Log struct {
-	ExecutionID  string
-	Name         string
-	Type         string
-	Time         time.Time
-	StepNumber   *int
-	StepName     *string
-	StepError    *string
-	StepPayload  []byte
-	StepDuration time.Duration
+	ExecutionID string
+	Name        string
+	TenantID    string
+	// Metadata is set by WithMetadata, e.g. to find executions of a customer
+	Metadata    map[string]string
+	Type        string
+	Time        time.Time
+	StepNumber  *int
+	StepName    *string
+	StepError   *string
+	StepPayload []byte
+	// StepPayloadTruncated and StepPayloadRef are set for payloads over the limit, see PayloadLimit
+	StepPayloadTruncated bool
+	StepPayloadRef       string
+	StepDuration         time.Duration
+	// StepTimedOut is set on exec logs of steps that failed after their StepOptions.Timeout, see TimeoutPolicy
+	StepTimedOut bool
+	// State is the state the log has moved the execution to, empty for logs written outside of coordinators
+	State State
+	// Hash chains the log to the previous log of the execution, see HashChained
+	Hash []byte
 }
 
 type Store interface {
 	AppendLog(log *Log) error
 	GetAllLogsByExecutionID(executionID string) ([]*Log, error)
 	GetStepLogsToCompensate(executionID string) ([]*Log, error)
+	// GetLogs returns logs of the execution of any of the types appended in [from, to) in order of appending,
+	// empty types select all of them and zero time means unbounded
+	GetLogs(executionID string, types []string, from, to time.Time) ([]*Log, error)
+	// GetLogsPage returns page of logs of the execution and cursor of the next page, empty if there are no more logs
+	GetLogsPage(executionID string, page Page) ([]*Log, string, error)
+	// ListExecutions returns page of executions selected by filter and cursor of the next page, empty if there are no more executions
+	ListExecutions(filter ExecutionFilter, page Page) ([]*Status, string, error)
+	// DeleteByMetadata deletes all logs of executions having the metadata and returns the number of deleted executions
+	DeleteByMetadata(key, value string) (int, error)
+	// Watch returns channel of logs selected by filter that are appended after the call, in order of appending.
+	// Slow readers don't block appends, the channel is closed when ctx is done
+	Watch(ctx context.Context, filter LogFilter) <-chan *Log
+}
+
+// LogFilter selects logs in Store.Watch, zero value selects all of them. Stores can use it to implement Store.GetLogs.
+type LogFilter struct {
+	// ExecutionID selects logs of the execution
+	ExecutionID string
+	// Name selects logs of executions of the saga with this name
+	Name string
+	// TenantID selects logs of executions of the tenant
+	TenantID string
+	// Types selects logs of any of these types, e.g. LogTypeSagaAbort
+	Types []string
+	// From and To select logs with Log.Time in [From, To), zero value means unbounded
+	From time.Time
+	To   time.Time
+}
+
+// Match reports whether the filter selects the log.
+func (f LogFilter) Match(l *Log) bool {
+	if f.ExecutionID != "" && f.ExecutionID != l.ExecutionID {
+		return false
+	}
+	if f.Name != "" && f.Name != l.Name {
+		return false
+	}
+	if f.TenantID != "" && f.TenantID != l.TenantID {
+		return false
+	}
+	if !f.From.IsZero() && l.Time.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !l.Time.Before(f.To) {
+		return false
+	}
+	if len(f.Types) == 0 {
+		return true
+	}
+	for _, typ := range f.Types {
+		if typ == l.Type {
+			return true
+		}
+	}
+	return false
+}
+
+// Page limits results of Store reads. Cursor is returned by the previous read, empty for the first page.
+type Page struct {
+	// Limit is the max number of items, zero means no limit
+	Limit  int
+	Cursor string
+}
+
+// ExecutionFilter selects executions in Store.ListExecutions, zero value selects all of them.
+type ExecutionFilter struct {
+	// IDPrefix selects executions with IDs starting with it
+	IDPrefix string
+	// Name selects executions of the saga with this name
+	Name string
+	// TenantID selects executions of the tenant
+	TenantID string
+	// Metadata selects executions having all of these metadata values
+	Metadata map[string]string
+	// States selects executions in any of these states, see Status.State
+	States []string
+	// Incomplete selects only executions that hasn't completed yet
+	Incomplete bool
+	// DueBy selects executions with timers due by this time, see Status.FireAt
+	DueBy time.Time
+	// From and To select executions started in [From, To), zero value means unbounded
+	From time.Time
+	To   time.Time
+}
+
+// Match reports whether the filter selects the execution with that status.
+func (f ExecutionFilter) Match(status *Status) bool {
+	if !strings.HasPrefix(status.ExecutionID, f.IDPrefix) {
+		return false
+	}
+	if f.Name != "" && f.Name != status.Name {
+		return false
+	}
+	if f.TenantID != "" && f.TenantID != status.TenantID {
+		return false
+	}
+	for key, value := range f.Metadata {
+		if v, ok := status.Metadata[key]; !ok || v != value {
+			return false
+		}
+	}
+	if f.Incomplete && status.CompletedAt != nil {
+		return false
+	}
+	if !f.DueBy.IsZero() && (status.FireAt == nil || status.FireAt.After(f.DueBy)) {
+		return false
+	}
+	if !f.From.IsZero() && status.StartedAt.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !status.StartedAt.Before(f.To) {
+		return false
+	}
+	if len(f.States) == 0 {
+		return true
+	}
+	for _, state := range f.States {
+		if state == status.State {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,131 @@
+package saga
+
+import "errors"
+
+// LogSync is when logs of executions of a saga are written to the Store, see Saga.LogSync.
+type LogSync int
+
+const (
+	// SyncEachLog writes each log to the Store when it's appended, it's the default
+	SyncEachLog LogSync = iota
+	// SyncAtSteps buffers consecutive logs and writes them in one batch, within a transaction of Transactional
+	// stores, at sync points: before each call of a step func, compensate func or finalizer, before other
+	// executions are started, when the operation of the coordinator returns and along with the exec log of a
+	// failed step and the decision what's next, in its transaction. Remote stores get fewer writes,
+	// but a crash loses logs since the last sync point, so the step executed last may be executed again by Resume
+	SyncAtSteps
+	// SyncStrict is SyncAtSteps that also syncs as soon as the exec log of a step is appended, so side effects
+	// of the step are committed to the Store before the execution goes on, e.g. before hooks or retries
+	SyncStrict
+	// SyncPipelined is SyncAtSteps that writes the batch in the background while the step func or compensate
+	// func that follows is called, so its latency overlaps with the latency of the Store. The coordinator waits for
+	// the batch before writing the next one and at other sync points. A failed write fails the execution at
+	// the next of them, so the step called meanwhile may be executed again by Resume even without a crash
+	SyncPipelined
+)
+
+// bufferedLog is a log buffered until the next sync point with its expected sequence, -1 for AppendLog.
+type bufferedLog struct {
+	log      *Log
+	expected int
+}
+
+// buffered reports whether logs are buffered until sync points.
+func (c *ExecutionCoordinator) buffered() bool {
+	return c.saga.LogSync != SyncEachLog
+}
+
+// syncStep syncs logs after the exec log of a step if the saga has SyncStrict.
+func (c *ExecutionCoordinator) syncStep() {
+	if c.saga.LogSync == SyncStrict {
+		c.syncLogs()
+	}
+}
+
+// syncCall syncs logs before a call of a step func or a compensate func, in the background if the saga has SyncPipelined.
+func (c *ExecutionCoordinator) syncCall() {
+	if c.saga.LogSync != SyncPipelined {
+		c.syncLogs()
+		return
+	}
+	if c.inTx {
+		return
+	}
+	c.seqMu.Lock()
+	defer c.seqMu.Unlock()
+	c.waitWrite()
+	if len(c.buffer) == 0 {
+		return
+	}
+	buffer := c.buffer
+	c.buffer = nil
+	written := make(chan error, 1)
+	c.writing = written
+	store := c.logStore
+	go func() {
+		written <- writeLogs(store, buffer)
+	}()
+}
+
+// syncLogs writes logs buffered by Saga.LogSync to the Store in one batch, except within transactions
+// that commit them, see withinTx.
+func (c *ExecutionCoordinator) syncLogs() {
+	if c.inTx {
+		return
+	}
+	c.seqMu.Lock()
+	defer c.seqMu.Unlock()
+	c.waitWrite()
+	if len(c.buffer) == 0 {
+		return
+	}
+	buffer := c.buffer
+	c.buffer = nil
+	checkWrite(writeLogs(c.logStore, buffer))
+}
+
+// waitWrite waits for the batch written in the background by syncCall, c.seqMu must be locked.
+func (c *ExecutionCoordinator) waitWrite() {
+	if c.writing == nil {
+		return
+	}
+	err := <-c.writing
+	c.writing = nil
+	checkWrite(err)
+}
+
+// writeLogs writes the buffer to the store in one transaction.
+func writeLogs(store Store, buffer []bufferedLog) error {
+	return WithinTx(store, func(tx Store) error {
+		return appendBuffer(tx, buffer)
+	})
+}
+
+func appendBuffer(tx Store, buffer []bufferedLog) error {
+	for _, b := range buffer {
+		var err error
+		if b.expected < 0 {
+			err = tx.AppendLog(b.log)
+		} else {
+			err = AppendLogAt(tx, b.log, b.expected)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkWrite(err error) {
+	var conflict *ConflictError
+	if errors.As(err, &conflict) {
+		panic(conflict)
+	}
+	checkErr(err, "writeLogs()")
+}
+
+// finish ends an operation of the coordinator: buffered logs are written and streams of step results are closed.
+func (c *ExecutionCoordinator) finish() {
+	c.syncLogs()
+	c.closeStreams()
+}
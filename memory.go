@@ -2,41 +2,313 @@ package saga
 
 import (
 	"errors"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
 )
 
+var (
+	ErrNoLogs        = errors.New("no logs found")
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// shardCount is the number of shards of the in-memory store, executions are spread over them by hash of their IDs.
+const shardCount = 64
+
+// New returns the in-memory Store. Executions are spread over shards with their own locks, so concurrent executions
+// don't contend on the Store. It keeps copies of appended logs and returns copies of them, so callers may change
+// logs they have appended or read, and reads never hold locks while callers use them.
 func New() Store {
-	return &store{
-		m: make(map[string][]*Log),
+	s := &store{watchers: make(map[*watcher]struct{})}
+	for i := range s.shards {
+		s.shards[i] = &shard{m: make(map[string][]*Log)}
 	}
+	return s
 }
 
+// store locks orderMu before locks of shards, and locks of shards in order of their numbers.
 type store struct {
-	m map[string][]*Log
+	shards [shardCount]*shard
+	// order are IDs of executions in order of their first logs, orderMu is locked to add or delete executions
+	orderMu sync.RWMutex
+	order   []string
+	// watchers receive appended logs, see Watch
+	watchMu  sync.RWMutex
+	watchers map[*watcher]struct{}
+}
+
+type shard struct {
+	mu sync.RWMutex
+	m  map[string][]*Log
+}
+
+func (s *store) shard(executionID string) *shard {
+	return s.shards[shardNumber(executionID)]
+}
+
+func shardNumber(executionID string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(executionID))
+	return int(h.Sum32() % shardCount)
+}
+
+// logs returns copies of logs of the execution, false if there are none.
+func (s *store) logs(executionID string) ([]*Log, bool) {
+	sh := s.shard(executionID)
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+	logs, ok := sh.m[executionID]
+	if !ok {
+		return nil, false
+	}
+	copies := make([]*Log, 0, len(logs))
+	for _, l := range logs {
+		copies = append(copies, copyLog(l))
+	}
+	return copies, true
+}
+
+// copyLog returns a deep copy of the log.
+func copyLog(l *Log) *Log {
+	copied := *l
+	if l.StepNumber != nil {
+		step := *l.StepNumber
+		copied.StepNumber = &step
+	}
+	if l.StepName != nil {
+		name := *l.StepName
+		copied.StepName = &name
+	}
+	if l.StepError != nil {
+		stepErr := *l.StepError
+		copied.StepError = &stepErr
+	}
+	if l.Metadata != nil {
+		copied.Metadata = make(map[string]string, len(l.Metadata))
+		for key, value := range l.Metadata {
+			copied.Metadata[key] = value
+		}
+	}
+	copied.StepPayload = append([]byte(nil), l.StepPayload...)
+	copied.Hash = append([]byte(nil), l.Hash...)
+	return &copied
 }
 
 func (s *store) GetAllLogsByExecutionID(executionID string) ([]*Log, error) {
-	res, ok := s.m[executionID]
-	if ok {
-		return res, nil
+	if logs, ok := s.logs(executionID); ok {
+		return logs, nil
 	}
-	return nil, errors.New("no logs found")
+	return nil, ErrNoLogs
 }
 
 func (s *store) GetStepLogsToCompensate(executionID string) ([]*Log, error) {
-	logs, ok := s.m[executionID]
+	logs, ok := s.logs(executionID)
 	if !ok {
-		return nil, errors.New("no logs found")
+		return nil, ErrNoLogs
 	}
+	return stepLogsToCompensate(logs), nil
+}
+
+// stepLogsToCompensate returns exec logs of steps in reverse order.
+func stepLogsToCompensate(logs []*Log) []*Log {
 	var res []*Log
 	for i := len(logs) - 1; i >= 0; i-- {
 		if logs[i].Type == LogTypeSagaStepExec {
 			res = append(res, logs[i])
 		}
 	}
-	return res, nil
+	return res
+}
+
+func (s *store) GetLogs(executionID string, types []string, from, to time.Time) ([]*Log, error) {
+	logs, ok := s.logs(executionID)
+	if !ok {
+		return nil, ErrNoLogs
+	}
+	return filterLogs(logs, LogFilter{Types: types, From: from, To: to}), nil
+}
+
+func filterLogs(logs []*Log, filter LogFilter) []*Log {
+	var res []*Log
+	for _, l := range logs {
+		if filter.Match(l) {
+			res = append(res, l)
+		}
+	}
+	return res
+}
+
+func (s *store) GetLogsPage(executionID string, page Page) ([]*Log, string, error) {
+	logs, ok := s.logs(executionID)
+	if !ok {
+		return nil, "", ErrNoLogs
+	}
+	return logsPage(logs, page)
+}
+
+func logsPage(logs []*Log, page Page) ([]*Log, string, error) {
+	from, err := parseCursor(page.Cursor)
+	if err != nil || from > len(logs) {
+		return nil, "", ErrInvalidCursor
+	}
+
+	to := len(logs)
+	if page.Limit > 0 && from+page.Limit < to {
+		to = from + page.Limit
+	}
+	return logs[from:to], nextCursor(to, len(logs)), nil
+}
+
+func (s *store) ListExecutions(filter ExecutionFilter, page Page) ([]*Status, string, error) {
+	s.orderMu.RLock()
+	defer s.orderMu.RUnlock()
+	return pageExecutions(s.order, s.logs, filter, page)
+}
+
+// pageExecutions returns page of executions in order selected by filter, logs returns logs of an execution.
+func pageExecutions(order []string, logs func(executionID string) ([]*Log, bool), filter ExecutionFilter, page Page) ([]*Status, string, error) {
+	from, err := parseCursor(page.Cursor)
+	if err != nil || from > len(order) {
+		return nil, "", ErrInvalidCursor
+	}
+
+	var res []*Status
+	for i := from; i < len(order); i++ {
+		if page.Limit > 0 && len(res) == page.Limit {
+			return res, nextCursor(i, len(order)), nil
+		}
+		executionLogs, _ := logs(order[i])
+		status := newStatus(order[i], foldProgress(nil, executionLogs))
+		if filter.Match(status) {
+			res = append(res, status)
+		}
+	}
+	return res, "", nil
+}
+
+func (s *store) DeleteByMetadata(key, value string) (int, error) {
+	return s.deleteByMetadata(key, value, func(*progress) bool { return true }), nil
+}
+
+func (s *store) DeleteTenantByMetadata(tenantID, key, value string) (int, error) {
+	return s.deleteByMetadata(key, value, func(p *progress) bool { return p.tenantID == tenantID }), nil
+}
+
+// deleteByMetadata deletes executions having the metadata that match and returns their number.
+func (s *store) deleteByMetadata(key, value string, match func(p *progress) bool) int {
+	s.orderMu.Lock()
+	defer s.orderMu.Unlock()
+	deleted := 0
+	order := s.order[:0]
+	for _, executionID := range s.order {
+		sh := s.shard(executionID)
+		sh.mu.Lock()
+		p := foldProgress(nil, sh.m[executionID])
+		if v, ok := p.metadata[key]; ok && v == value && match(p) {
+			delete(sh.m, executionID)
+			deleted++
+			sh.mu.Unlock()
+			continue
+		}
+		sh.mu.Unlock()
+		order = append(order, executionID)
+	}
+	s.order = order
+	return deleted
+}
+
+// cursors of the in-memory store are offsets, they are stable because logs and executions are only appended
+// and executions are deleted only on requests like DeleteByMetadata
+func parseCursor(cursor string) (int, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	offset, err := strconv.Atoi(cursor)
+	if err != nil || offset < 0 {
+		return 0, ErrInvalidCursor
+	}
+	return offset, nil
+}
+
+func nextCursor(offset, total int) string {
+	if offset >= total {
+		return ""
+	}
+	return strconv.Itoa(offset)
 }
 
 func (s *store) AppendLog(log *Log) error {
-	s.m[log.ExecutionID] = append(s.m[log.ExecutionID], log)
+	return s.append([]*Log{log}, []int{-1})
+}
+
+// append appends logs atomically, expected are their sequences checked before appending, -1 for none.
+// Only shards of the executions are locked, orderMu too if any of them is new.
+func (s *store) append(logs []*Log, expected []int) error {
+	numbers := make([]int, 0, len(logs))
+	for _, log := range logs {
+		numbers = append(numbers, shardNumber(log.ExecutionID))
+	}
+	sort.Ints(numbers)
+	locked := make([]int, 0, len(numbers))
+	for i, number := range numbers {
+		if i == 0 || number != numbers[i-1] {
+			locked = append(locked, number)
+		}
+	}
+
+	// new executions are known under locks of shards, then they are locked again with orderMu first
+	for withOrder := false; ; withOrder = true {
+		if withOrder {
+			s.orderMu.Lock()
+		}
+		for _, number := range locked {
+			s.shards[number].mu.Lock()
+		}
+		err := s.appendLocked(logs, expected, withOrder)
+		for _, number := range locked {
+			s.shards[number].mu.Unlock()
+		}
+		if withOrder {
+			s.orderMu.Unlock()
+		}
+		if err != errNewExecution {
+			return err
+		}
+	}
+}
+
+// errNewExecution is returned by appendLocked if logs start an execution, but orderMu isn't locked.
+var errNewExecution = errors.New("new execution")
+
+func (s *store) appendLocked(logs []*Log, expected []int, withOrder bool) error {
+	// sequences are checked before appending, so logs are appended entirely or not at all
+	appended := make(map[string]int)
+	for i, log := range logs {
+		sh := s.shard(log.ExecutionID)
+		if _, ok := sh.m[log.ExecutionID]; !ok && !withOrder {
+			return errNewExecution
+		}
+		if expected[i] >= 0 {
+			if err := sh.checkSequence(log.ExecutionID, expected[i]-appended[log.ExecutionID]); err != nil {
+				return err
+			}
+		}
+		appended[log.ExecutionID]++
+	}
+	s.watchMu.RLock()
+	defer s.watchMu.RUnlock()
+	for _, log := range logs {
+		sh := s.shard(log.ExecutionID)
+		if _, ok := sh.m[log.ExecutionID]; !ok {
+			s.order = append(s.order, log.ExecutionID)
+		}
+		stored := copyLog(log)
+		sh.m[log.ExecutionID] = append(sh.m[log.ExecutionID], stored)
+		for w := range s.watchers {
+			w.push(copyLog(stored))
+		}
+	}
 	return nil
 }
@@ -0,0 +1,91 @@
+package saga
+
+import (
+	"context"
+	"reflect"
+	"sync"
+)
+
+// Invocation is a call of a step func or a compensate func passed through Middleware.
+type Invocation struct {
+	ExecutionID string
+	StepNumber  int
+	Step        *Step
+	// Compensation is set for calls of compensate funcs
+	Compensation bool
+}
+
+// StepFunc calls the step func or the compensate func of the invocation and returns its error.
+type StepFunc func(ctx context.Context, invocation *Invocation) error
+
+// Middleware wraps calls of all step funcs and compensate funcs of an execution, e.g. for logging, metrics,
+// authorization or fault injection. It may return an error without calling next, outputs of the step are
+// zero values then. It may call next more than once, also concurrently, e.g. for hedging: outputs of the step
+// are those of the first call that succeeded, of the last one if all of them failed.
+type Middleware func(next StepFunc) StepFunc
+
+// Use adds middleware wrapping calls of step funcs and compensate funcs, middleware added first is the outermost.
+func (c *ExecutionCoordinator) Use(middleware Middleware) {
+	c.middleware = append(c.middleware, middleware)
+}
+
+// invoke calls fn with params, whose first one is replaced by ctx, through the middleware of the coordinator.
+// It returns outputs of fn except the error, zero values if the middleware hasn't called it, see Middleware.
+func (c *ExecutionCoordinator) invoke(ctx context.Context, i int, compensation bool, fn reflect.Value, params []reflect.Value) ([]reflect.Value, error) {
+	var (
+		mu        sync.Mutex
+		outputs   []reflect.Value
+		succeeded bool
+	)
+	err := c.call(ctx, i, compensation, func(ctx context.Context) error {
+		// params are copied as the middleware may call next more than once, e.g. concurrently
+		args := append([]reflect.Value{reflect.ValueOf(ctx)}, params[1:]...)
+		res := fn.Call(args)
+		err := isReturnError(res)
+		mu.Lock()
+		defer mu.Unlock()
+		if !succeeded {
+			outputs, succeeded = res[:len(res)-1], err == nil
+		}
+		return err
+	})
+	mu.Lock()
+	defer mu.Unlock()
+	if outputs == nil {
+		fnType := fn.Type()
+		for j := 0; j < fnType.NumOut()-1; j++ {
+			outputs = append(outputs, reflect.Zero(fnType.Out(j)))
+		}
+	}
+	return outputs, err
+}
+
+// call calls fn through the middleware of the coordinator, funcs without outputs are called by it directly, see Executor.
+func (c *ExecutionCoordinator) call(ctx context.Context, i int, compensation bool, fn func(ctx context.Context) error) error {
+	c.syncCall()
+	call := StepFunc(func(ctx context.Context, _ *Invocation) error {
+		return fn(ctx)
+	})
+	for j := len(c.middleware) - 1; j >= 0; j-- {
+		call = c.middleware[j](call)
+	}
+	invocation := &Invocation{ExecutionID: c.ExecutionID, StepNumber: i, Step: c.saga.steps[i], Compensation: compensation}
+	return call(context.WithValue(ctx, invocationKey{}, invocation), invocation)
+}
+
+type invocationKey struct{}
+
+// InvocationFromContext returns the invocation of the step whose context is ctx, e.g. to identify
+// the execution and the step in commands to remote services. ctx is the context passed to a step,
+// a compensation or a StepOptions.Condition.
+func InvocationFromContext(ctx context.Context) (*Invocation, bool) {
+	if invocation, ok := ctx.Value(invocationKey{}).(*Invocation); ok {
+		copied := *invocation
+		return &copied, true
+	}
+	scope, ok := ctx.Value(stepKey{}).(*stepScope)
+	if !ok {
+		return nil, false
+	}
+	return &Invocation{ExecutionID: scope.c.ExecutionID, StepNumber: scope.step, Step: scope.c.saga.steps[scope.step]}, true
+}
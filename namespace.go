@@ -0,0 +1,135 @@
+package saga
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// Namespaced returns Store that keeps executions in the namespace, so several applications can
+// share one backend without collisions of execution IDs. IDs of executions and keys of their
+// metadata are prefixed by the namespace in the store, executions of other namespaces look as if
+// they don't exist and DeleteByMetadata only deletes executions of the namespace.
+func Namespaced(store Store, namespace string) Store {
+	checkOK(namespace != "", "namespace must not be empty")
+	return &namespacedStore{store: store, prefix: namespace + "/"}
+}
+
+type namespacedStore struct {
+	store  Store
+	prefix string
+}
+
+func (s *namespacedStore) AppendLog(log *Log) error {
+	namespaced := *log
+	namespaced.ExecutionID = s.prefix + log.ExecutionID
+	namespaced.Metadata = mapKeys(log.Metadata, func(key string) string { return s.prefix + key })
+	return s.store.AppendLog(&namespaced)
+}
+
+func (s *namespacedStore) AppendLogAt(log *Log, expected int) error {
+	namespaced := *log
+	namespaced.ExecutionID = s.prefix + log.ExecutionID
+	namespaced.Metadata = mapKeys(log.Metadata, func(key string) string { return s.prefix + key })
+	return AppendLogAt(s.store, &namespaced, expected)
+}
+
+func (s *namespacedStore) GetAllLogsByExecutionID(executionID string) ([]*Log, error) {
+	logs, err := s.store.GetAllLogsByExecutionID(s.prefix + executionID)
+	if err != nil {
+		return nil, err
+	}
+	return s.stripLogs(logs), nil
+}
+
+func (s *namespacedStore) GetStepLogsToCompensate(executionID string) ([]*Log, error) {
+	logs, err := s.store.GetStepLogsToCompensate(s.prefix + executionID)
+	if err != nil {
+		return nil, err
+	}
+	return s.stripLogs(logs), nil
+}
+
+func (s *namespacedStore) GetLogs(executionID string, types []string, from, to time.Time) ([]*Log, error) {
+	logs, err := s.store.GetLogs(s.prefix+executionID, types, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return s.stripLogs(logs), nil
+}
+
+func (s *namespacedStore) GetLogsPage(executionID string, page Page) ([]*Log, string, error) {
+	logs, next, err := s.store.GetLogsPage(s.prefix+executionID, page)
+	if err != nil {
+		return nil, "", err
+	}
+	return s.stripLogs(logs), next, nil
+}
+
+func (s *namespacedStore) ListExecutions(filter ExecutionFilter, page Page) ([]*Status, string, error) {
+	filter.IDPrefix = s.prefix + filter.IDPrefix
+	filter.Metadata = mapKeys(filter.Metadata, func(key string) string { return s.prefix + key })
+	statuses, next, err := s.store.ListExecutions(filter, page)
+	if err != nil {
+		return nil, "", err
+	}
+	res := make([]*Status, 0, len(statuses))
+	for _, status := range statuses {
+		stripped := *status
+		stripped.ExecutionID = strings.TrimPrefix(status.ExecutionID, s.prefix)
+		stripped.Metadata = mapKeys(status.Metadata, s.strip)
+		res = append(res, &stripped)
+	}
+	return res, next, nil
+}
+
+func (s *namespacedStore) DeleteByMetadata(key, value string) (int, error) {
+	return s.store.DeleteByMetadata(s.prefix+key, value)
+}
+
+func (s *namespacedStore) DeleteTenantByMetadata(tenantID, key, value string) (int, error) {
+	return DeleteTenantByMetadata(s.store, tenantID, s.prefix+key, value)
+}
+
+func (s *namespacedStore) IsReadOnly() bool {
+	return IsReadOnly(s.store)
+}
+
+func (s *namespacedStore) Watch(ctx context.Context, filter LogFilter) <-chan *Log {
+	if filter.ExecutionID != "" {
+		filter.ExecutionID = s.prefix + filter.ExecutionID
+	}
+	return mapLogs(ctx, s.store.Watch(ctx, filter), func(l *Log) *Log {
+		if !strings.HasPrefix(l.ExecutionID, s.prefix) {
+			return nil
+		}
+		return s.stripLogs([]*Log{l})[0]
+	})
+}
+
+func (s *namespacedStore) strip(key string) string {
+	return strings.TrimPrefix(key, s.prefix)
+}
+
+// stripLogs returns copies of logs without the namespace, logs of the store aren't changed.
+func (s *namespacedStore) stripLogs(logs []*Log) []*Log {
+	res := make([]*Log, 0, len(logs))
+	for _, l := range logs {
+		stripped := *l
+		stripped.ExecutionID = s.strip(l.ExecutionID)
+		stripped.Metadata = mapKeys(l.Metadata, s.strip)
+		res = append(res, &stripped)
+	}
+	return res
+}
+
+func mapKeys(m map[string]string, f func(key string) string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	res := make(map[string]string, len(m))
+	for key, value := range m {
+		res[f(key)] = value
+	}
+	return res
+}
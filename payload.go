@@ -0,0 +1,210 @@
+package saga
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+var (
+	ErrPayloadTooLarge = errors.New("payload is too large")
+	ErrNoBlob          = errors.New("blob not found")
+)
+
+// PayloadPolicy is what happens to step payloads larger than PayloadLimit.MaxSize.
+type PayloadPolicy int
+
+const (
+	// PayloadReject fails the step with ErrPayloadTooLarge and doesn't write its payload
+	PayloadReject PayloadPolicy = iota
+	// PayloadTruncate writes first MaxSize bytes of the payload and sets Log.StepPayloadTruncated
+	PayloadTruncate
+	// PayloadSpill writes the payload to PayloadLimit.Blobs and its key to Log.StepPayloadRef
+	PayloadSpill
+)
+
+// PayloadLimit limits size of step payloads written to the Store.
+//
+// Compensations executed by the same coordinator receive the real values anyway. Compensations
+// after Resume receive zero values instead of rejected or truncated payloads, spilled payloads are
+// read back from Blobs, so coordinators resuming such executions need the same PayloadLimit.
+type PayloadLimit struct {
+	MaxSize int
+	Policy  PayloadPolicy
+	// Blobs is required by PayloadSpill
+	Blobs BlobStore
+}
+
+// WithPayloadLimit limits size of step payloads written to the Store.
+func WithPayloadLimit(limit PayloadLimit) Option {
+	checkOK(limit.MaxSize > 0, "max size of payload must be positive")
+	checkOK(limit.Policy != PayloadSpill || limit.Blobs != nil, "blob store is required to spill payloads")
+	return func(c *ExecutionCoordinator) {
+		c.payloadLimit = &limit
+	}
+}
+
+// WithPayloadMemory limits total size of payloads of steps the coordinator keeps in memory for their compensations,
+// so sagas of thousands of steps don't exhaust it. Past the limit, payloads of steps executed earliest are released
+// and their compensations read them back from the Store, like after Resume. Payloads that aren't written
+// to the Store as they are, i.e. redacted, truncated or rejected ones and those of suppressed logs, are always kept.
+func WithPayloadMemory(maxSize int) Option {
+	checkOK(maxSize > 0, "max size of payloads in memory must be positive")
+	return func(c *ExecutionCoordinator) {
+		c.payloadMemory = maxSize
+	}
+}
+
+// storedAsIs reports whether the real payload of the exec log can be read back from the Store.
+func storedAsIs(l *Log, marshaled, real []byte) bool {
+	return !l.StepPayloadTruncated && (l.StepPayload != nil || l.StepPayloadRef != "") && bytes.Equal(marshaled, real)
+}
+
+// retainPayload keeps the real payload of step i in memory and releases payloads over WithPayloadMemory,
+// releasable is whether the payload can be read back from the Store.
+func (c *ExecutionCoordinator) retainPayload(i int, payload []byte, releasable bool) {
+	c.retained += len(payload) - len(c.payloads[i])
+	c.payloads[i] = payload
+	if c.payloadMemory == 0 {
+		return
+	}
+	if c.releasable == nil {
+		c.releasable = make(map[int]bool)
+	}
+	c.releasable[i] = releasable
+	if releasable {
+		c.releaseOrder = append(c.releaseOrder, i)
+	}
+	// numbers of retried steps may repeat, their payloads are released on the first of them
+	for c.retained > c.payloadMemory && len(c.releaseOrder) > 0 {
+		step := c.releaseOrder[0]
+		c.releaseOrder = c.releaseOrder[1:]
+		if c.releasable[step] {
+			c.retained -= len(c.payloads[step])
+			delete(c.payloads, step)
+			delete(c.releasable, step)
+		}
+	}
+}
+
+// BlobStore keeps payloads out of the Store, e.g. on disk or in S3.
+type BlobStore interface {
+	Put(key string, data []byte) error
+	// Get returns ErrNoBlob if there is no blob with the key
+	Get(key string) ([]byte, error)
+}
+
+// NewMemoryBlobStore returns BlobStore keeping blobs in memory.
+func NewMemoryBlobStore() BlobStore {
+	return &memoryBlobStore{blobs: make(map[string][]byte)}
+}
+
+type memoryBlobStore struct {
+	mu    sync.RWMutex
+	blobs map[string][]byte
+}
+
+func (s *memoryBlobStore) Put(key string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.blobs[key] = append([]byte(nil), data...)
+	return nil
+}
+
+func (s *memoryBlobStore) Get(key string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	data, ok := s.blobs[key]
+	if !ok {
+		return nil, ErrNoBlob
+	}
+	return append([]byte(nil), data...), nil
+}
+
+// NewDirBlobStore returns BlobStore keeping each blob in a file of the directory, it's created if it doesn't exist.
+// Blobs are written to temporary files renamed to their names, so a crash never leaves a partial blob.
+func NewDirBlobStore(dir string) (BlobStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &dirBlobStore{dir: dir}, nil
+}
+
+type dirBlobStore struct {
+	dir string
+}
+
+// path returns the file of the blob, keys are escaped since they contain slashes, see limitPayload.
+func (s *dirBlobStore) path(key string) string {
+	return filepath.Join(s.dir, url.PathEscape(key))
+}
+
+func (s *dirBlobStore) Put(key string, data []byte) error {
+	tmp, err := ioutil.TempFile(s.dir, ".blob-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path(key))
+}
+
+func (s *dirBlobStore) Get(key string) ([]byte, error) {
+	data, err := ioutil.ReadFile(s.path(key))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %s", ErrNoBlob, key)
+	}
+	return data, err
+}
+
+// limitPayload applies PayloadLimit to the log of step i, it returns error if the step has to fail.
+func (c *ExecutionCoordinator) limitPayload(i int, l *Log) error {
+	limit := c.payloadLimit
+	if limit == nil || len(l.StepPayload) <= limit.MaxSize {
+		return nil
+	}
+	switch limit.Policy {
+	case PayloadTruncate:
+		l.StepPayload = l.StepPayload[:limit.MaxSize]
+		l.StepPayloadTruncated = true
+	case PayloadSpill:
+		key := c.ExecutionID + "/" + strconv.Itoa(i) + "/" + c.idGenerator.NewID()
+		checkErr(limit.Blobs.Put(key, l.StepPayload), "limit.Blobs.Put()")
+		l.StepPayload = nil
+		l.StepPayloadRef = key
+	default:
+		size := len(l.StepPayload)
+		l.StepPayload = nil
+		return fmt.Errorf("%w: %d bytes of step %s, max is %d", ErrPayloadTooLarge, size, *l.StepName, limit.MaxSize)
+	}
+	return nil
+}
+
+// loadPayload returns payload of the exec log to pass to the compensation, nil if it isn't available.
+func (c *ExecutionCoordinator) loadPayload(l *Log) []byte {
+	if payload, ok := c.payloads[*l.StepNumber]; ok {
+		return payload
+	}
+	if l.StepPayloadRef != "" {
+		checkOK(c.payloadLimit != nil && c.payloadLimit.Blobs != nil, "blob store is required to load spilled payloads")
+		payload, err := c.payloadLimit.Blobs.Get(l.StepPayloadRef)
+		checkErr(err, "c.payloadLimit.Blobs.Get()")
+		return payload
+	}
+	if l.StepPayloadTruncated || len(l.StepPayload) == 0 {
+		return nil
+	}
+	return l.StepPayload
+}
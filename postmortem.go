@@ -0,0 +1,121 @@
+package saga
+
+import (
+	htmltemplate "html/template"
+	"io"
+	"text/template"
+)
+
+// Postmortem is a human-readable summary of an execution, usually a failed one: what ran, what failed,
+// what was undone and what remains to be undone manually.
+type Postmortem struct {
+	Status *Status
+	// Ran are executed steps in order of their first execution, Failed are those whose last attempt has failed
+	Ran    []*TimelineStep
+	Failed []*TimelineStep
+	// Undone are compensated steps
+	Undone []*TimelineStep
+	// Manual are executed steps of the aborted execution that haven't been compensated
+	Manual []*ManualStep
+}
+
+// ManualStep is a step that has to be undone manually and the reason it hasn't been compensated.
+type ManualStep struct {
+	Step   *TimelineStep
+	Reason string
+}
+
+// NewPostmortem builds the postmortem of the execution from its logs.
+func NewPostmortem(store Store, executionID string) (*Postmortem, error) {
+	logs, err := store.GetAllLogsByExecutionID(executionID)
+	if err != nil {
+		return nil, err
+	}
+	if len(logs) == 0 {
+		return nil, ErrNoLogs
+	}
+	p := foldProgress(nil, logs)
+	// the last error of the compensation of each step, cleared when it succeeds
+	compensateErrors := make(map[int]string)
+	for _, l := range logs {
+		switch {
+		case l.Type == LogTypeSagaStepCompensateFailed && l.StepError != nil:
+			compensateErrors[*l.StepNumber] = *l.StepError
+		case l.Type == LogTypeSagaStepCompensate:
+			delete(compensateErrors, *l.StepNumber)
+		}
+	}
+
+	postmortem := &Postmortem{Status: newStatus(executionID, p)}
+	for _, step := range timeline(logs) {
+		if step.Attempts == 0 {
+			continue
+		}
+		postmortem.Ran = append(postmortem.Ran, step)
+		if step.Error != "" {
+			postmortem.Failed = append(postmortem.Failed, step)
+		}
+		switch {
+		case p.compensated[step.Number]:
+			postmortem.Undone = append(postmortem.Undone, step)
+		case !p.aborted:
+		case compensateErrors[step.Number] != "":
+			postmortem.Manual = append(postmortem.Manual, &ManualStep{Step: step, Reason: "compensation failed: " + compensateErrors[step.Number]})
+		default:
+			postmortem.Manual = append(postmortem.Manual, &ManualStep{Step: step, Reason: "not compensated"})
+		}
+	}
+	return postmortem, nil
+}
+
+// WriteMarkdown writes the postmortem as Markdown, e.g. for incident tickets.
+func (p *Postmortem) WriteMarkdown(w io.Writer) error {
+	return postmortemMarkdownTemplate.Execute(w, p)
+}
+
+// WriteHTML writes the postmortem as an HTML page.
+func (p *Postmortem) WriteHTML(w io.Writer) error {
+	return postmortemHTMLTemplate.Execute(w, p)
+}
+
+var postmortemMarkdownTemplate = template.Must(template.New("postmortem").Parse(`# Postmortem of {{.Status.Name}} {{.Status.ExecutionID}}
+
+State: {{.Status.State}}, started {{.Status.StartedAt.Format "2006-01-02 15:04:05"}}{{with .Status.CompletedAt}}, completed {{.Format "2006-01-02 15:04:05"}}{{end}}
+{{range .Status.Errors}}
+- Error: {{.}}{{end}}
+
+## What ran
+{{range .Ran}}
+- {{.Name}}: {{.Attempts}} attempt(s), last took {{.Duration}}{{end}}
+
+## What failed
+{{range .Failed}}
+- {{.Name}}: {{.Error}}{{else}}
+Nothing.{{end}}
+
+## What was undone
+{{range .Undone}}
+- {{.Name}}{{else}}
+Nothing.{{end}}
+
+## What remains manual
+{{range .Manual}}
+- {{.Step.Name}}: {{.Reason}}{{else}}
+Nothing.{{end}}
+`))
+
+var postmortemHTMLTemplate = htmltemplate.Must(htmltemplate.New("postmortem").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Postmortem of {{.Status.ExecutionID}}</title>` + dashboardStyle + `</head><body>
+<h1>Postmortem of {{.Status.Name}} {{.Status.ExecutionID}}</h1>
+<p>State: {{.Status.State}}, started {{.Status.StartedAt.Format "2006-01-02 15:04:05"}}{{with .Status.CompletedAt}}, completed {{.Format "2006-01-02 15:04:05"}}{{end}}</p>
+{{range .Status.Errors}}<p class="error">{{.}}</p>{{end}}
+<h2>What ran</h2>
+<table><tr><th>Step</th><th>Attempts</th><th>Duration</th></tr>
+{{range .Ran}}<tr><td>{{.Name}}</td><td>{{.Attempts}}</td><td>{{.Duration}}</td></tr>{{end}}</table>
+<h2>What failed</h2>
+<ul>{{range .Failed}}<li>{{.Name}}: <span class="error">{{.Error}}</span></li>{{else}}<li>Nothing.</li>{{end}}</ul>
+<h2>What was undone</h2>
+<ul>{{range .Undone}}<li>{{.Name}}</li>{{else}}<li>Nothing.</li>{{end}}</ul>
+<h2>What remains manual</h2>
+<ul>{{range .Manual}}<li>{{.Step.Name}}: {{.Reason}}</li>{{else}}<li>Nothing.</li>{{end}}</ul>
+</body></html>`))
@@ -0,0 +1,297 @@
+package saga
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// progress is the state of an execution reconstructed from its logs. Coordinators apply their logs to it
+// as they append them, and statuses, results, recovery and replays fold the logs in the Store the same way.
+// Coordinators only keep besides it the outcome of the operation they perform, e.g. whether it has parked
+// the execution and the errors returned by steps.
+type progress struct {
+	name        string
+	tenantID    string
+	metadata    map[string]string
+	start       time.Time
+	end         time.Time
+	updated     time.Time
+	nextStep    int
+	currentStep *int
+	failedStep  *int
+	pausedStep  *int
+	pausedUntil time.Time
+	delayedStep *int
+	fireAt      time.Time
+	delays      map[int]time.Time
+	graceUntil  time.Time
+	retryAt     time.Time
+	// compensateRetryAt is the next attempt of failed guaranteed compensations
+	compensateRetryAt time.Time
+	// claimedUntil is the end of the lease of the timer claimed by a TimerLoop, cleared by the next timer
+	claimedUntil time.Time
+	versions     map[string]string
+	// input is the input of the execution, see WithInput
+	input json.RawMessage
+	// data is the data bag of the execution, see SetData
+	data map[string]json.RawMessage
+	// continuation is the recorded continuation of the execution, see Saga.OnSuccess
+	continuation *continuation
+	lastError    string
+	errors       []string
+	aborted      bool
+	completed    bool
+	deadLettered bool
+	attempts     map[int]int
+	approved     map[int]bool
+	compensated  map[int]bool
+	// compensateAttempts is the number of attempts of compensation of each step
+	compensateAttempts map[int]int
+	// toCompensate indexes the latest exec log of each step by step number, it's the entry compensating
+	// the step, see compensations
+	toCompensate map[int]indexedLog
+	// applied is the number of applied logs
+	applied int
+	// finalized is set when the finalizer of the saga has run, see Saga.Finally
+	finalized    bool
+	finallyError string
+	// compensateErrors are errors of failed compensations in order of occurrence
+	compensateErrors []string
+	// result is the snapshot of the data bag written by the completion, see Result.Data
+	result json.RawMessage
+	// steps are results of steps of the saga, of steps reached by the execution if the saga isn't known,
+	// then reached is set
+	steps   []StepResult
+	reached bool
+}
+
+// foldProgress folds logs of the execution of the saga, nil if it isn't known.
+func foldProgress(saga *Saga, logs []*Log) *progress {
+	p := newProgress(saga)
+	for _, l := range logs {
+		p.apply(l)
+	}
+	return p
+}
+
+func newProgress(saga *Saga) *progress {
+	var steps []StepResult
+	if saga != nil {
+		steps = newStepResults(saga)
+	}
+	return &progress{
+		steps:              steps,
+		reached:            saga == nil,
+		attempts:           make(map[int]int),
+		approved:           make(map[int]bool),
+		compensated:        make(map[int]bool),
+		compensateAttempts: make(map[int]int),
+		toCompensate:       make(map[int]indexedLog),
+		delays:             make(map[int]time.Time),
+		versions:           make(map[string]string),
+		data:               make(map[string]json.RawMessage),
+	}
+}
+
+// apply updates the progress by the next log of the execution.
+func (p *progress) apply(l *Log) {
+	p.name = l.Name
+	p.tenantID = l.TenantID
+	if l.Metadata != nil {
+		p.metadata = l.Metadata
+	}
+	p.updated = l.Time
+	if l.StepNumber != nil && l.Type != LogTypeSagaAbort {
+		step := *l.StepNumber
+		p.currentStep = &step
+	}
+
+	switch l.Type {
+	case LogTypeStartSaga:
+		p.start = l.Time
+		p.input = l.StepPayload
+	case LogTypeSagaStepExec:
+		step := *l.StepNumber
+		p.attempts[step]++
+		p.toCompensate[step] = indexedLog{log: l, position: p.applied}
+		if l.StepError != nil {
+			p.failedStep = &step
+			p.graceUntil, p.retryAt = time.Time{}, time.Time{}
+			p.lastError = *l.StepError
+			p.errors = append(p.errors, *l.StepError)
+			p.nextStep = step
+		} else {
+			p.failedStep = nil
+			p.nextStep = step + 1
+		}
+		p.delayedStep = nil
+	case LogTypeSagaStepSkipped:
+		p.nextStep = *l.StepNumber + 1
+		p.delayedStep, p.pausedStep = nil, nil
+	case LogTypeSagaStepPaused:
+		step := *l.StepNumber
+		p.pausedStep = &step
+		p.pausedUntil = time.Time{}
+		if l.StepDuration > 0 {
+			p.pausedUntil = l.Time.Add(l.StepDuration)
+		}
+		p.claimedUntil = time.Time{}
+	case LogTypeSagaStepDelayed:
+		step := *l.StepNumber
+		p.delayedStep = &step
+		p.fireAt = l.Time.Add(l.StepDuration)
+		p.delays[step] = p.fireAt
+		p.claimedUntil = time.Time{}
+	case LogTypeSagaStepRetryScheduled:
+		p.retryAt = l.Time.Add(l.StepDuration)
+		p.claimedUntil = time.Time{}
+	case LogTypeSagaCompensationDeferred:
+		p.graceUntil = l.Time.Add(l.StepDuration)
+		p.claimedUntil = time.Time{}
+	case LogTypeSagaTimerClaimed:
+		p.claimedUntil = l.Time.Add(l.StepDuration)
+	case LogTypeSagaVersionRecorded:
+		var v recordedVersion
+		if json.Unmarshal(l.StepPayload, &v) == nil {
+			p.versions[v.Resource] = v.Version
+		}
+	case LogTypeSagaDataSet:
+		var entry dataEntry
+		if json.Unmarshal(l.StepPayload, &entry) == nil {
+			p.data[entry.Key] = entry.Value
+		}
+	case LogTypeSagaContinued:
+		var cont continuation
+		if json.Unmarshal(l.StepPayload, &cont) == nil {
+			p.continuation = &cont
+		}
+	case LogTypeSagaStepApproved:
+		p.approved[*l.StepNumber] = true
+		p.pausedStep = nil
+	case LogTypeSagaAbort:
+		p.aborted = true
+		// aborts without a failed step record their reason, e.g. ErrApprovalTimeout
+		if l.StepError != nil {
+			p.lastError = *l.StepError
+			p.errors = append(p.errors, *l.StepError)
+		}
+	case LogTypeSagaStepCompensate:
+		p.compensated[*l.StepNumber] = true
+		p.compensateAttempts[*l.StepNumber]++
+		p.compensateRetryAt = time.Time{}
+		p.deadLettered = false
+	case LogTypeSagaStepCompensateFailed:
+		p.compensated[*l.StepNumber] = false
+		if l.StepError != nil {
+			p.compensateErrors = append(p.compensateErrors, *l.StepError)
+		}
+	case LogTypeSagaCompensationRetryScheduled:
+		p.compensateRetryAt = l.Time.Add(l.StepDuration)
+		p.claimedUntil = time.Time{}
+	case LogTypeSagaDeadLettered:
+		p.deadLettered = true
+	case LogTypeSagaFinalized:
+		p.finalized = true
+		if l.StepError != nil {
+			p.finallyError = *l.StepError
+		}
+	case LogTypeSagaComplete:
+		p.completed = true
+		p.end = l.Time
+		p.result = l.StepPayload
+	}
+	p.applied++
+	p.applyStep(l)
+}
+
+// indexedLog is a log with its position in logs of the execution.
+type indexedLog struct {
+	log      *Log
+	position int
+}
+
+// compensations returns the latest exec log of each executed step in reverse order of execution,
+// like Store.GetStepLogsToCompensate without logs of retried attempts.
+func (p *progress) compensations() []*Log {
+	indexed := make([]indexedLog, 0, len(p.toCompensate))
+	for _, l := range p.toCompensate {
+		indexed = append(indexed, l)
+	}
+	sort.Slice(indexed, func(i, j int) bool { return indexed[i].position > indexed[j].position })
+	logs := make([]*Log, 0, len(indexed))
+	for _, l := range indexed {
+		logs = append(logs, l.log)
+	}
+	return logs
+}
+
+// applyStep updates results of steps by the log.
+func (p *progress) applyStep(l *Log) {
+	if p.reached {
+		if l.StepNumber == nil || l.StepName == nil || l.Type == LogTypeSagaAbort {
+			return
+		}
+		for len(p.steps) <= *l.StepNumber {
+			p.steps = append(p.steps, StepResult{Status: "pending"})
+		}
+		p.steps[*l.StepNumber].Name = *l.StepName
+	}
+	applyStepLog(p.steps, l)
+}
+
+// timer returns the time when the parked execution has to be resumed, zero if there is no timer:
+// end of the delay of a step, deadline of approval, next attempt of a failed step, end of the grace period
+// or next attempt of failed guaranteed compensations. A claimed timer is postponed until its lease ends,
+// so it fires again only if the TimerLoop that has claimed it hasn't parked the execution by then.
+func (p *progress) timer() time.Time {
+	timer := p.dueTimer()
+	if !timer.IsZero() && timer.Before(p.claimedUntil) {
+		return p.claimedUntil
+	}
+	return timer
+}
+
+func (p *progress) dueTimer() time.Time {
+	switch {
+	case p.completed:
+		return time.Time{}
+	case p.aborted:
+		return p.compensateRetryAt
+	case p.delayedStep != nil:
+		return p.fireAt
+	case p.pausedStep != nil:
+		return p.pausedUntil
+	case p.failedStep != nil && !p.retryAt.IsZero():
+		return p.retryAt
+	case p.failedStep != nil:
+		return p.graceUntil
+	}
+	return time.Time{}
+}
+
+// deferred returns true if compensation of the failed execution is deferred at now.
+func (p *progress) deferred(now time.Time) bool {
+	return p.failedStep != nil && !p.aborted && now.Before(p.graceUntil)
+}
+
+func (p *progress) state() State {
+	switch {
+	case p.completed && p.aborted:
+		return StateCompensated
+	case p.completed:
+		return StateCompleted
+	case p.deadLettered:
+		return StateDeadLettered
+	case p.aborted:
+		return StateCompensating
+	case p.failedStep != nil:
+		return StateFailed
+	case p.pausedStep != nil:
+		return StatePaused
+	case p.delayedStep != nil:
+		return StateDelayed
+	default:
+		return StateRunning
+	}
+}
@@ -0,0 +1,295 @@
+package pubsubtrigger
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	saga "github.com/itimofeev/go-saga"
+)
+
+var (
+	ErrNotInStep       = errors.New("command is dispatched outside of a step")
+	ErrUnauthenticated = errors.New("command isn't signed by an accepted secret")
+)
+
+// SignatureAttribute is the attribute of command messages with their signature, see SignCommand.
+const SignatureAttribute = "signature"
+
+// SignCommand returns the value of SignatureAttribute of the command message data signed with the secret.
+func SignCommand(secret, data []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Command is a step executed by a remote Worker.
+type Command struct {
+	// ID correlates the command with its Reply. It's derived from the execution and the step, so workers
+	// can deduplicate retried and redelivered commands by it
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// ExecutionID and Step identify the dispatching step, Compensation is set for its compensation
+	ExecutionID  string `json:"executionId"`
+	Step         string `json:"step"`
+	Compensation bool   `json:"compensation,omitempty"`
+	// Input is the input of the execution, see saga.Input, or the output of the compensated step
+	Input json.RawMessage `json:"input,omitempty"`
+}
+
+// Reply is the result of a Command, Error is empty if it has succeeded.
+type Reply struct {
+	CommandID string          `json:"commandId"`
+	Output    json.RawMessage `json:"output,omitempty"`
+	Error     string          `json:"error,omitempty"`
+}
+
+// Dispatcher publishes commands of steps to the commands topic and receives their replies from a subscription
+// of the replies topic, each process needs its own subscription. Steps wait for replies, so they should have
+// StepOptions.Timeout, replies that arrive after it are dropped.
+type Dispatcher struct {
+	// Secrets resolves the secret named SecretName that signs commands, see Worker.Secrets. It's resolved
+	// for each command, so rotated secrets are used at once. Commands aren't signed if it's nil
+	Secrets    saga.SecretsProvider
+	SecretName string
+
+	commands *pubsub.Topic
+	replies  *pubsub.Subscription
+
+	mu      sync.Mutex
+	pending map[string]chan *Reply
+}
+
+func NewDispatcher(commands *pubsub.Topic, replies *pubsub.Subscription) *Dispatcher {
+	return &Dispatcher{
+		commands: commands,
+		replies:  replies,
+		pending:  make(map[string]chan *Reply),
+	}
+}
+
+// Run receives replies until ctx is done, see pubsub.Subscription.Receive.
+func (d *Dispatcher) Run(ctx context.Context) error {
+	return d.replies.Receive(ctx, func(ctx context.Context, msg *pubsub.Message) {
+		msg.Ack()
+		var reply Reply
+		if json.Unmarshal(msg.Data, &reply) != nil {
+			return
+		}
+		d.mu.Lock()
+		replies, ok := d.pending[reply.CommandID]
+		d.mu.Unlock()
+		if ok {
+			select {
+			case replies <- &reply:
+			default:
+			}
+		}
+	})
+}
+
+// Step returns step func dispatching the command to a worker, it returns the output of the command:
+//
+//	s.AddStep(&saga.Step{Name: "charge", Func: dispatcher.Step("charge"), CompensateFunc: dispatcher.Compensation("refund")})
+func (d *Dispatcher) Step(name string) func(ctx context.Context) (json.RawMessage, error) {
+	return func(ctx context.Context) (json.RawMessage, error) {
+		return d.dispatch(ctx, name)
+	}
+}
+
+// Compensation returns compensate func of steps returned by Step dispatching the command to a worker.
+// The command is dispatched with the output of the step as the input.
+func (d *Dispatcher) Compensation(name string) func(ctx context.Context, output json.RawMessage) error {
+	return func(ctx context.Context, output json.RawMessage) error {
+		invocation, ok := saga.InvocationFromContext(ctx)
+		if !ok {
+			return ErrNotInStep
+		}
+		command := newCommand(invocation, name)
+		command.Input = output
+		_, err := d.send(ctx, command)
+		return err
+	}
+}
+
+// newCommand returns the command of the invocation, its ID identifies the invocation.
+func newCommand(invocation *saga.Invocation, name string) *Command {
+	id := invocation.ExecutionID + "/" + invocation.Step.Name
+	if invocation.Compensation {
+		id += "/compensation"
+	}
+	return &Command{
+		ID:           id,
+		Name:         name,
+		ExecutionID:  invocation.ExecutionID,
+		Step:         invocation.Step.Name,
+		Compensation: invocation.Compensation,
+	}
+}
+
+func (d *Dispatcher) dispatch(ctx context.Context, name string) (json.RawMessage, error) {
+	invocation, ok := saga.InvocationFromContext(ctx)
+	if !ok {
+		return nil, ErrNotInStep
+	}
+	command := newCommand(invocation, name)
+	var input json.RawMessage
+	if err := saga.Input(ctx, &input); err == nil {
+		command.Input = input
+	} else if !errors.Is(err, saga.ErrNoInput) {
+		return nil, err
+	}
+	return d.send(ctx, command)
+}
+
+// send publishes the command and waits for its reply.
+func (d *Dispatcher) send(ctx context.Context, command *Command) (json.RawMessage, error) {
+	data, err := json.Marshal(command)
+	if err != nil {
+		return nil, err
+	}
+	attributes := map[string]string{"command": command.Name}
+	if d.Secrets != nil {
+		secret, err := d.Secrets.Secret(ctx, d.SecretName)
+		if err != nil {
+			return nil, fmt.Errorf("sign command %s: %w", command.Name, err)
+		}
+		attributes[SignatureAttribute] = SignCommand([]byte(secret), data)
+	}
+	replies := make(chan *Reply, 1)
+	d.mu.Lock()
+	d.pending[command.ID] = replies
+	d.mu.Unlock()
+	defer func() {
+		d.mu.Lock()
+		delete(d.pending, command.ID)
+		d.mu.Unlock()
+	}()
+
+	result := d.commands.Publish(ctx, &pubsub.Message{Data: data, Attributes: attributes})
+	if _, err := result.Get(ctx); err != nil {
+		return nil, fmt.Errorf("publish command %s: %w", command.Name, err)
+	}
+	select {
+	case reply := <-replies:
+		if reply.Error != "" {
+			return nil, errors.New(reply.Error)
+		}
+		return reply.Output, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Handler executes a command and returns its output.
+type Handler func(ctx context.Context, command *Command) (json.RawMessage, error)
+
+// Worker executes commands of a subscription of the commands topic by handlers registered for their names
+// and publishes replies to the replies topic. The ack deadline of a command is extended while its handler
+// runs, up to MaxExtension, so long-running steps aren't redelivered to another worker meanwhile.
+type Worker struct {
+	// MaxExtension is the maximum time a command is handled before it's redelivered
+	MaxExtension time.Duration
+	// OnError is called with errors of publishing replies and of resolving secrets, such commands
+	// are redelivered, and with ErrUnauthenticated for commands that are dropped
+	OnError func(command *Command, err error)
+	// Secrets resolves secrets named SecretNames that verify signatures of commands, see Dispatcher.Secrets.
+	// Commands signed by none of them are dropped, so secrets are rotated by accepting both the old and the
+	// new one until dispatchers sign by the new one. Commands aren't verified if it's nil
+	Secrets     saga.SecretsProvider
+	SecretNames []string
+
+	commands *pubsub.Subscription
+	replies  *pubsub.Topic
+
+	mu       sync.RWMutex
+	handlers map[string]Handler
+}
+
+func NewWorker(commands *pubsub.Subscription, replies *pubsub.Topic) *Worker {
+	return &Worker{
+		MaxExtension: time.Hour,
+		OnError:      func(*Command, error) {},
+		commands:     commands,
+		replies:      replies,
+		handlers:     make(map[string]Handler),
+	}
+}
+
+// Handle registers handler of commands with the name.
+func (w *Worker) Handle(name string, handler Handler) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.handlers[name] = handler
+}
+
+// Run executes commands until ctx is done, see pubsub.Subscription.Receive. Commands without handlers
+// are replied with an error.
+func (w *Worker) Run(ctx context.Context) error {
+	w.commands.ReceiveSettings.MaxExtension = w.MaxExtension
+	return w.commands.Receive(ctx, func(ctx context.Context, msg *pubsub.Message) {
+		var command Command
+		if err := json.Unmarshal(msg.Data, &command); err != nil {
+			msg.Ack()
+			return
+		}
+		if err := w.verify(ctx, msg); err != nil {
+			w.OnError(&command, err)
+			if errors.Is(err, ErrUnauthenticated) {
+				msg.Ack()
+			} else {
+				msg.Nack()
+			}
+			return
+		}
+		reply := &Reply{CommandID: command.ID}
+		w.mu.RLock()
+		handler, ok := w.handlers[command.Name]
+		w.mu.RUnlock()
+		if !ok {
+			reply.Error = fmt.Sprintf("no handler for command %s", command.Name)
+		} else if output, err := handler(ctx, &command); err != nil {
+			reply.Error = err.Error()
+		} else {
+			reply.Output = output
+		}
+		data, err := json.Marshal(reply)
+		if err == nil {
+			_, err = w.replies.Publish(ctx, &pubsub.Message{Data: data}).Get(ctx)
+		}
+		if err != nil {
+			w.OnError(&command, err)
+			msg.Nack()
+			return
+		}
+		msg.Ack()
+	})
+}
+
+// verify returns ErrUnauthenticated if the message isn't signed by any of the secrets of the worker.
+func (w *Worker) verify(ctx context.Context, msg *pubsub.Message) error {
+	if w.Secrets == nil {
+		return nil
+	}
+	signature := []byte(msg.Attributes[SignatureAttribute])
+	for _, name := range w.SecretNames {
+		secret, err := w.Secrets.Secret(ctx, name)
+		if errors.Is(err, saga.ErrSecretNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if hmac.Equal(signature, []byte(SignCommand([]byte(secret), msg.Data))) {
+			return nil
+		}
+	}
+	return ErrUnauthenticated
+}
@@ -0,0 +1,122 @@
+package pubsubtrigger
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"cloud.google.com/go/pubsub/pstest"
+	saga "github.com/itimofeev/go-saga"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func newClient(t *testing.T) *pubsub.Client {
+	server := pstest.NewServer()
+	t.Cleanup(func() { _ = server.Close() })
+	conn, err := grpc.Dial(server.Addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	client, err := pubsub.NewClient(context.Background(), "project", option.WithGRPCConn(conn))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+func newSubscription(t *testing.T, client *pubsub.Client, name string) (*pubsub.Topic, *pubsub.Subscription) {
+	ctx := context.Background()
+	topic, err := client.CreateTopic(ctx, name)
+	require.NoError(t, err)
+	sub, err := client.CreateSubscription(ctx, name+"-sub", pubsub.SubscriptionConfig{Topic: topic})
+	require.NoError(t, err)
+	return topic, sub
+}
+
+type order struct {
+	ID string `json:"id"`
+}
+
+func TestSubscriberAndCommands(t *testing.T) {
+	client := newClient(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	commandsTopic, commandsSub := newSubscription(t, client, "commands")
+	repliesTopic, repliesSub := newSubscription(t, client, "replies")
+	dispatcher := NewDispatcher(commandsTopic, repliesSub)
+	dispatcher.Secrets, dispatcher.SecretName = saga.StaticSecrets{"commands-v2": "new"}, "commands-v2"
+	go func() { _ = dispatcher.Run(ctx) }()
+	worker := NewWorker(commandsSub, repliesTopic)
+	// the old secret is still accepted while the new one is rolled out
+	worker.Secrets = saga.StaticSecrets{"commands-v1": "old", "commands-v2": "new"}
+	worker.SecretNames = []string{"commands-v1", "commands-v2", "commands-v3"}
+	rejected := make(chan error, 1)
+	worker.OnError = func(command *Command, err error) { rejected <- err }
+	commandIDs := make(chan string, 1)
+	worker.Handle("charge", func(ctx context.Context, command *Command) (json.RawMessage, error) {
+		commandIDs <- command.ID
+		var input order
+		if err := json.Unmarshal(command.Input, &input); err != nil {
+			return nil, err
+		}
+		return json.RawMessage(`"charged ` + input.ID + `"`), nil
+	})
+	go func() { _ = worker.Run(ctx) }()
+
+	refunded := make(chan string, 1)
+	worker.Handle("refund", func(ctx context.Context, command *Command) (json.RawMessage, error) {
+		refunded <- string(command.Input)
+		return nil, nil
+	})
+	s := saga.NewSaga("order")
+	require.NoError(t, s.AddStep(&saga.Step{Name: "charge", Func: dispatcher.Step("charge"), CompensateFunc: dispatcher.Compensation("refund"),
+		Options: &saga.StepOptions{Timeout: 10 * time.Second}}))
+	require.NoError(t, s.AddStep(&saga.Step{Name: "ship", Func: dispatcher.Step("ship"), CompensateFunc: dispatcher.Compensation("cancel")}))
+
+	ordersTopic, ordersSub := newSubscription(t, client, "orders")
+	store := saga.New()
+	trigger := saga.NewTrigger(store)
+	trigger.Route(ordersSub.ID(), s, func(msg *saga.TriggerMessage) (interface{}, error) {
+		var o order
+		if err := json.Unmarshal(msg.Value, &o); err != nil {
+			return nil, err
+		}
+		return o, nil
+	})
+	subscriber := NewSubscriber(ordersSub, trigger)
+	failed := make(chan error, 1)
+	subscriber.OnError = func(msg *pubsub.Message, err error) { failed <- err }
+	go func() { _ = subscriber.Run(ctx) }()
+
+	id, err := ordersTopic.Publish(ctx, &pubsub.Message{Data: []byte(`{"id":"42"}`)}).Get(ctx)
+	require.NoError(t, err)
+	_, err = ordersTopic.Publish(ctx, &pubsub.Message{Data: []byte(`not json`)}).Get(ctx)
+	require.NoError(t, err)
+	require.True(t, errors.Is(<-failed, saga.ErrInvalidMessage))
+
+	require.Eventually(t, func() bool {
+		status, err := saga.GetStatus(store, "pubsub/"+id)
+		return err == nil && status.State == string(saga.StateCompensated)
+	}, 10*time.Second, 10*time.Millisecond)
+	logs, err := store.GetLogs("pubsub/"+id, []string{saga.LogTypeSagaStepExec}, time.Time{}, time.Time{})
+	require.NoError(t, err)
+	require.JSONEq(t, `["charged 42"]`, string(logs[0].StepPayload))
+	// replies of commands without handlers are errors, the output of the step is the input of its compensation
+	require.Equal(t, "no handler for command ship", *logs[1].StepError)
+	require.Equal(t, `"charged 42"`, <-refunded)
+	require.Equal(t, "pubsub/"+id+"/charge", <-commandIDs)
+
+	// commands without valid signatures aren't handled
+	forged, err := json.Marshal(&Command{ID: "forged", Name: "charge", Input: json.RawMessage(`{"id":"43"}`)})
+	require.NoError(t, err)
+	_, err = commandsTopic.Publish(ctx, &pubsub.Message{Data: forged, Attributes: map[string]string{
+		SignatureAttribute: SignCommand([]byte("guessed"), forged)}}).Get(ctx)
+	require.NoError(t, err)
+	require.True(t, errors.Is(<-rejected, ErrUnauthenticated))
+	require.Empty(t, commandIDs)
+}
@@ -0,0 +1,64 @@
+// Package pubsubtrigger starts sagas from Google Cloud Pub/Sub messages by saga.Trigger and dispatches steps
+// to remote workers as Pub/Sub commands. It's a separate module, so the saga package itself stays free
+// of Pub/Sub dependencies.
+package pubsubtrigger
+
+import (
+	"context"
+	"errors"
+
+	"cloud.google.com/go/pubsub"
+	saga "github.com/itimofeev/go-saga"
+)
+
+// Subscriber starts executions of sagas from messages of a subscription. Messages have the ID of the subscription
+// as their topic, so sagas are routed by it:
+//
+//	trigger.Route("orders-sagas", orderSaga, decodeOrder)
+//	go pubsubtrigger.NewSubscriber(client.Subscription("orders-sagas"), trigger).Run(ctx)
+//
+// A message is acknowledged after the start of its execution is written to the Store, so starts are at-least-once:
+// redelivered messages resume their executions instead of duplicating them.
+type Subscriber struct {
+	// OnError is called with errors of messages. Messages that can't be started, of topics without routes
+	// or rejected by the mapper, are acknowledged, other failed messages are redelivered
+	OnError func(msg *pubsub.Message, err error)
+
+	sub     *pubsub.Subscription
+	trigger *saga.Trigger
+}
+
+func NewSubscriber(sub *pubsub.Subscription, trigger *saga.Trigger) *Subscriber {
+	return &Subscriber{
+		OnError: func(*pubsub.Message, error) {},
+		sub:     sub,
+		trigger: trigger,
+	}
+}
+
+// Run receives messages until ctx is done, see pubsub.Subscription.Receive.
+func (s *Subscriber) Run(ctx context.Context) error {
+	return s.sub.Receive(ctx, func(ctx context.Context, msg *pubsub.Message) {
+		if _, err := s.trigger.Start(Message(s.sub.ID(), msg)); err != nil {
+			s.OnError(msg, err)
+			if !errors.Is(err, saga.ErrNoRoute) && !errors.Is(err, saga.ErrInvalidMessage) {
+				msg.Nack()
+				return
+			}
+		}
+		msg.Ack()
+	})
+}
+
+// Message converts the Pub/Sub message to saga.TriggerMessage of the topic, its ID is derived from
+// the message ID, which is the same for redeliveries. Attributes of the message are its headers
+// and the ordering key is its key.
+func Message(topic string, msg *pubsub.Message) *saga.TriggerMessage {
+	return &saga.TriggerMessage{
+		ID:      "pubsub/" + msg.ID,
+		Topic:   topic,
+		Key:     []byte(msg.OrderingKey),
+		Value:   msg.Data,
+		Headers: msg.Attributes,
+	}
+}
@@ -0,0 +1,44 @@
+package saga
+
+import "errors"
+
+var ErrReadOnly = errors.New("store is read-only")
+
+// ReadOnly returns Store for reporting and dashboard deployments that must never change executions:
+// AppendLog and DeleteByMetadata fail with ErrReadOnly, reads are passed to the store.
+// Admin on top of it, or on top of decorators of it, rejects operations with ErrReadOnly before running them.
+func ReadOnly(store Store) Store {
+	return &readOnlyStore{Store: store}
+}
+
+// ReadOnlyReporter is implemented by stores that report whether they fail writes with ErrReadOnly.
+// Decorators of this package forward it to the stores they wrap.
+type ReadOnlyReporter interface {
+	IsReadOnly() bool
+}
+
+// IsReadOnly returns true if the store is ReadOnly or decorates one, see ReadOnlyReporter.
+func IsReadOnly(store Store) bool {
+	reporter, ok := store.(ReadOnlyReporter)
+	return ok && reporter.IsReadOnly()
+}
+
+type readOnlyStore struct {
+	Store
+}
+
+func (s *readOnlyStore) IsReadOnly() bool {
+	return true
+}
+
+func (s *readOnlyStore) AppendLog(*Log) error {
+	return ErrReadOnly
+}
+
+func (s *readOnlyStore) DeleteByMetadata(string, string) (int, error) {
+	return 0, ErrReadOnly
+}
+
+func (s *readOnlyStore) DeleteTenantByMetadata(string, string, string) (int, error) {
+	return 0, ErrReadOnly
+}
@@ -0,0 +1,149 @@
+package saga
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"reflect"
+	"strings"
+)
+
+// Redacted replaces sensitive strings in payloads written to the Store.
+//
+// Fields of step outputs tagged as `saga:"sensitive"` are written to the Store as Redacted if
+// they are strings and as zero values otherwise, `saga:"sensitive,hash"` writes hex of SHA-256
+// of strings instead, so they can still be correlated. All outputs of a step are redacted this
+// way if its StepOptions.Sensitive is set.
+const Redacted = "[REDACTED]"
+
+const sensitiveTag = "saga"
+
+// redact returns copies of values with sensitive data redacted, all makes all of them sensitive.
+func redact(values []reflect.Value, all bool) []reflect.Value {
+	res := make([]reflect.Value, 0, len(values))
+	for _, v := range values {
+		if all {
+			res = append(res, redactValue(v, false))
+		} else {
+			res = append(res, redactFields(v))
+		}
+	}
+	return res
+}
+
+// redactValue returns value of the same type as v with sensitive data replaced.
+func redactValue(v reflect.Value, hash bool) reflect.Value {
+	if v.Kind() != reflect.String {
+		return reflect.Zero(v.Type())
+	}
+	res := reflect.New(v.Type()).Elem()
+	if hash {
+		sum := sha256.Sum256([]byte(v.String()))
+		res.SetString(hex.EncodeToString(sum[:]))
+	} else {
+		res.SetString(Redacted)
+	}
+	return res
+}
+
+// redactFields returns copy of v with fields tagged as sensitive redacted, v itself is
+// returned if there is nothing to redact.
+func redactFields(v reflect.Value) reflect.Value {
+	if !v.IsValid() || !hasSensitiveFields(v.Type(), make(map[reflect.Type]bool)) {
+		return v
+	}
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		res := reflect.New(v.Type()).Elem()
+		res.Set(redactFields(v.Elem()))
+		return res
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		res := reflect.New(v.Type().Elem())
+		res.Elem().Set(redactFields(v.Elem()))
+		return res
+	case reflect.Slice, reflect.Array:
+		var res reflect.Value
+		if v.Kind() == reflect.Slice {
+			if v.IsNil() {
+				return v
+			}
+			res = reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		} else {
+			res = reflect.New(v.Type()).Elem()
+		}
+		for i := 0; i < v.Len(); i++ {
+			res.Index(i).Set(redactFields(v.Index(i)))
+		}
+		return res
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		res := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			res.SetMapIndex(iter.Key(), redactFields(iter.Value()))
+		}
+		return res
+	case reflect.Struct:
+		res := reflect.New(v.Type()).Elem()
+		res.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+			if sensitive, hash := parseSensitiveTag(field); sensitive {
+				res.Field(i).Set(redactValue(v.Field(i), hash))
+			} else {
+				res.Field(i).Set(redactFields(v.Field(i)))
+			}
+		}
+		return res
+	default:
+		return v
+	}
+}
+
+// hasSensitiveFields reports whether values of the type may contain fields tagged as sensitive.
+func hasSensitiveFields(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+	switch t.Kind() {
+	case reflect.Interface:
+		return true
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		return hasSensitiveFields(t.Elem(), seen)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+			if sensitive, _ := parseSensitiveTag(field); sensitive || hasSensitiveFields(field.Type, seen) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func parseSensitiveTag(field reflect.StructField) (sensitive, hash bool) {
+	parts := strings.Split(field.Tag.Get(sensitiveTag), ",")
+	if parts[0] != "sensitive" {
+		return false, false
+	}
+	for _, option := range parts[1:] {
+		if option == "hash" {
+			hash = true
+		}
+	}
+	return true, hash
+}
@@ -0,0 +1,205 @@
+package saga
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// Publisher publishes events to a broker, e.g. Kafka, SNS or Pub/Sub, see Relay.
+type Publisher interface {
+	Publish(ctx context.Context, event *CloudEvent) error
+}
+
+// PublisherFunc is an adapter to use ordinary functions as Publisher.
+type PublisherFunc func(ctx context.Context, event *CloudEvent) error
+
+func (f PublisherFunc) Publish(ctx context.Context, event *CloudEvent) error {
+	return f(ctx, event)
+}
+
+// Checkpoints keep positions of Relay: the number of logs of each execution that have been relayed.
+// They should be durable, e.g. a table next to the Store, otherwise events are relayed again after restarts.
+type Checkpoints interface {
+	// Checkpoint returns the number of relayed logs of the execution, zero if there are none
+	Checkpoint(executionID string) (int, error)
+	SetCheckpoint(executionID string, relayed int) error
+}
+
+// NewMemoryCheckpoints returns in-memory Checkpoints, e.g. for tests.
+func NewMemoryCheckpoints() Checkpoints {
+	return &memoryCheckpoints{relayed: make(map[string]int)}
+}
+
+type memoryCheckpoints struct {
+	mu      sync.Mutex
+	relayed map[string]int
+}
+
+func (c *memoryCheckpoints) Checkpoint(executionID string) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.relayed[executionID], nil
+}
+
+func (c *memoryCheckpoints) SetCheckpoint(executionID string, relayed int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.relayed[executionID] = relayed
+	return nil
+}
+
+// Relay publishes lifecycle events of executions in the Store to a broker, so the Store itself is the outbox:
+// events are published if and only if their logs are appended, unlike events emitted by CloudEvents, which are
+// lost if the process exits before delivering them.
+//
+// The relay follows the change feed of the Store, see Store.Watch, after catching up with executions selected
+// by Filter. Events of an execution are published one by one in order of its logs, by one of Workers,
+// and its checkpoint is advanced after each of them, so events are published at least once: an event whose
+// checkpoint wasn't saved is published again, with the same ID, which is derived from the position of its log.
+// A failed event is retried after RetryInterval until it's published or ctx is done.
+type Relay struct {
+	// Source is the source attribute of published events
+	Source string
+	// Filter selects executions caught up with by Run, e.g. by From, logs of other executions appended
+	// after the start of Run are relayed too
+	Filter ExecutionFilter
+	// Workers is the number of executions relayed concurrently
+	Workers int
+	// RetryInterval is the delay before retrying a failed event
+	RetryInterval time.Duration
+	// BatchSize is the page size of executions listed to catch up
+	BatchSize int
+	// OnError receives errors of the Store, Checkpoints and Publisher if it's set
+	OnError func(executionID string, err error)
+
+	store       Store
+	publisher   Publisher
+	checkpoints Checkpoints
+}
+
+func NewRelay(store Store, publisher Publisher, checkpoints Checkpoints) *Relay {
+	return &Relay{
+		Workers:       4,
+		RetryInterval: time.Second,
+		BatchSize:     1000,
+		store:         store,
+		publisher:     publisher,
+		checkpoints:   checkpoints,
+	}
+}
+
+// Run relays events until ctx is done.
+func (r *Relay) Run(ctx context.Context) {
+	logs := r.store.Watch(ctx, LogFilter{Name: r.Filter.Name, TenantID: r.Filter.TenantID})
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	queues := make([]chan string, r.Workers)
+	for i := range queues {
+		queues[i] = make(chan string, r.BatchSize)
+		wg.Add(1)
+		go func(queue chan string) {
+			defer wg.Done()
+			for executionID := range queue {
+				r.relay(ctx, executionID)
+			}
+		}(queues[i])
+	}
+	defer func() {
+		for _, queue := range queues {
+			close(queue)
+		}
+	}()
+	// an execution is always relayed by the same worker, so its events aren't reordered
+	enqueue := func(executionID string) bool {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(executionID))
+		select {
+		case queues[h.Sum32()%uint32(len(queues))] <- executionID:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	var cursor string
+	for {
+		statuses, next, err := r.store.ListExecutions(r.Filter, Page{Limit: r.BatchSize, Cursor: cursor})
+		if err != nil {
+			r.onError("", err)
+			if !r.wait(ctx) {
+				return
+			}
+			continue
+		}
+		for _, status := range statuses {
+			if !enqueue(status.ExecutionID) {
+				return
+			}
+		}
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	for l := range logs {
+		if !enqueue(l.ExecutionID) {
+			return
+		}
+	}
+}
+
+// relay publishes events of logs of the execution after its checkpoint.
+func (r *Relay) relay(ctx context.Context, executionID string) {
+	for {
+		err := r.publish(ctx, executionID)
+		if err == nil {
+			return
+		}
+		r.onError(executionID, err)
+		if !r.wait(ctx) {
+			return
+		}
+	}
+}
+
+func (r *Relay) publish(ctx context.Context, executionID string) error {
+	relayed, err := r.checkpoints.Checkpoint(executionID)
+	if err != nil {
+		return err
+	}
+	logs, err := r.store.GetAllLogsByExecutionID(executionID)
+	if err != nil {
+		return err
+	}
+	for seq := relayed; seq < len(logs); seq++ {
+		if event := NewCloudEvent(r.Source, logs[seq]); event != nil {
+			event.ID = fmt.Sprintf("%s/%d", executionID, seq)
+			if err := r.publisher.Publish(ctx, event); err != nil {
+				return fmt.Errorf("publish %s: %w", event.ID, err)
+			}
+		}
+		if err := r.checkpoints.SetCheckpoint(executionID, seq+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Relay) wait(ctx context.Context) bool {
+	select {
+	case <-time.After(r.RetryInterval):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (r *Relay) onError(executionID string, err error) {
+	if r.OnError != nil {
+		r.OnError(executionID, err)
+	}
+}
@@ -0,0 +1,190 @@
+package saga
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+var ErrExecutionInProgress = errors.New("execution is not completed yet")
+
+// ReplayReport compares a completed execution with its replay against the current definition of the saga.
+type ReplayReport struct {
+	Original *Status
+	Replayed *Status
+	// Logs are logs written by the replay
+	Logs []*Log
+	// Divergences describe differences between the original execution and the replay, empty if they are equal
+	Divergences []string
+}
+
+func (r *ReplayReport) Diverged() bool {
+	return len(r.Divergences) > 0
+}
+
+// Replay re-runs a completed execution from the store against the saga in shadow mode
+// to check that the current definition of the saga still handles it the same way.
+//
+// Step funcs aren't called: each step returns what it returned originally in its latest
+// attempt, decoded into current types of its outputs. A step that wasn't executed originally
+// fails with ErrAbortedManually if the execution was aborted and returns zero values otherwise.
+// Compensations aren't called either, but their params are decoded from payloads as usual.
+// Logs of the replay are written to an in-memory Store, the original store is only read.
+func Replay(store Store, executionID string, saga *Saga) (report *ReplayReport, err error) {
+	logs, err := store.GetAllLogsByExecutionID(executionID)
+	if err != nil {
+		return nil, err
+	}
+	if len(logs) == 0 {
+		return nil, ErrNoLogs
+	}
+	original := foldProgress(nil, logs)
+	if !original.completed {
+		return nil, ErrExecutionInProgress
+	}
+
+	r := &replayer{
+		report:  &ReplayReport{Original: newStatus(executionID, original)},
+		aborted: original.aborted,
+		latest:  make(map[int]*Log),
+	}
+	for _, l := range logs {
+		if l.Type == LogTypeSagaStepExec {
+			r.latest[*l.StepNumber] = l
+		}
+	}
+
+	shadow := &Saga{Name: saga.Name, TenantID: saga.TenantID}
+	for i, step := range saga.steps {
+		shadow.addStep(&Step{
+			Name:           step.Name,
+			Func:           r.stubFunc(i, step),
+			CompensateFunc: stubCompensateFunc(step.CompensateFunc),
+		})
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			report, err = nil, fmt.Errorf("replay of %s failed: %v", executionID, p)
+		}
+	}()
+	replayStore := New()
+	c := NewExecutionCoordinator(context.Background(), context.Background(), shadow, replayStore, WithExecutionID(executionID))
+	c.Play()
+
+	replayLogs, err := replayStore.GetAllLogsByExecutionID(executionID)
+	if err != nil {
+		return nil, err
+	}
+	r.report.Logs = replayLogs
+	r.report.Replayed = newStatus(executionID, foldProgress(nil, replayLogs))
+	r.compare(logs, replayLogs)
+	return r.report, nil
+}
+
+type replayer struct {
+	report  *ReplayReport
+	aborted bool
+	// latest are the latest exec logs of steps by step number
+	latest map[int]*Log
+}
+
+func (r *replayer) diverge(format string, args ...interface{}) {
+	r.report.Divergences = append(r.report.Divergences, fmt.Sprintf(format, args...))
+}
+
+func (r *replayer) stubFunc(i int, step *Step) interface{} {
+	funcType := reflect.TypeOf(step.Func)
+	return reflect.MakeFunc(funcType, func([]reflect.Value) []reflect.Value {
+		results := make([]reflect.Value, 0, funcType.NumOut())
+		for j := 0; j < funcType.NumOut(); j++ {
+			results = append(results, reflect.Zero(funcType.Out(j)))
+		}
+		errIndex := len(results) - 1
+
+		l, ok := r.latest[i]
+		if !ok {
+			if r.aborted {
+				results[errIndex] = reflect.ValueOf(&ErrAbortedManually).Elem()
+			} else {
+				r.diverge("step %d %q wasn't executed originally", i, step.Name)
+			}
+			return results
+		}
+		if *l.StepName != step.Name {
+			r.diverge("step %d was %q originally, but now it's %q", i, *l.StepName, step.Name)
+		}
+		if l.StepError != nil {
+			stepErr := errors.New(*l.StepError)
+			results[errIndex] = reflect.ValueOf(&stepErr).Elem()
+		}
+
+		types := make([]reflect.Type, 0, errIndex)
+		for j := 0; j < errIndex; j++ {
+			types = append(types, funcType.Out(j))
+		}
+		values, err := decodePayload(types, l.StepPayload)
+		if err != nil {
+			r.diverge("step %d %q: %v", i, step.Name, err)
+			return results
+		}
+		copy(results, values)
+		if marshaled, err := marshalResp(results[:errIndex]); err == nil && !bytes.Equal(marshaled, l.StepPayload) {
+			r.diverge("payload of step %d %q was %s originally, but now it's %s", i, step.Name, l.StepPayload, marshaled)
+		}
+		return results
+	}).Interface()
+}
+
+// decodePayload decodes payload of a step into values of the types, unlike unmarshalParams it
+// returns error if the payload doesn't match them.
+func decodePayload(types []reflect.Type, payload []byte) ([]reflect.Value, error) {
+	var raws []json.RawMessage
+	if err := json.Unmarshal(payload, &raws); err != nil {
+		return nil, err
+	}
+	if len(raws) != len(types) {
+		return nil, fmt.Errorf("payload has %d values, but %d are expected", len(raws), len(types))
+	}
+	values := make([]reflect.Value, 0, len(types))
+	for i, raw := range raws {
+		value := reflect.New(types[i])
+		if err := json.Unmarshal(raw, value.Interface()); err != nil {
+			return nil, fmt.Errorf("can't decode value %d: %v", i, err)
+		}
+		values = append(values, value.Elem())
+	}
+	return values, nil
+}
+
+func stubCompensateFunc(compensateFunc interface{}) interface{} {
+	if compensateFunc == nil {
+		return nil
+	}
+	return reflect.MakeFunc(reflect.TypeOf(compensateFunc), func([]reflect.Value) []reflect.Value {
+		return []reflect.Value{reflect.Zero(reflect.TypeOf(compensateFunc).Out(0))}
+	}).Interface()
+}
+
+func (r *replayer) compare(originalLogs, replayLogs []*Log) {
+	if original, replayed := r.report.Original.State, r.report.Replayed.State; original != replayed {
+		r.diverge("execution was %s originally, but replay is %s", original, replayed)
+	}
+	original, replayed := compensatedSteps(originalLogs), compensatedSteps(replayLogs)
+	if !reflect.DeepEqual(original, replayed) {
+		r.diverge("steps %v were compensated originally, but in replay %v", original, replayed)
+	}
+}
+
+func compensatedSteps(logs []*Log) []string {
+	var res []string
+	for _, l := range logs {
+		if l.Type == LogTypeSagaStepCompensate {
+			res = append(res, *l.StepName)
+		}
+	}
+	return res
+}
@@ -0,0 +1,202 @@
+package saga
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ReplicatingStore writes to the primary store and asynchronously mirrors appends and deletes to
+// the secondary one, e.g. a store in another region or an analytics sink. Reads are served by
+// the primary, so a slow or unavailable secondary never slows executions down.
+//
+// Mirrored writes wait in memory until Run writes them to the secondary in order of the writes
+// to the primary. A failed write is retried after RetryInterval until it succeeds, so the secondary
+// catches up once it's reachable again, a write whose result was lost may be mirrored twice.
+// Writes still pending when the process exits are lost.
+type ReplicatingStore struct {
+	Store
+	// RetryInterval is the delay before retrying a failed write to the secondary
+	RetryInterval time.Duration
+	// OnError receives errors of writes to the secondary if it's set
+	OnError func(err error)
+	// Clock is used for lag of pending writes
+	Clock Clock
+
+	secondary Store
+	mu        sync.Mutex
+	pending   []*replicatedWrite
+	notify    chan struct{}
+}
+
+// replicatedWrite is a write to mirror, either an appended log or a delete by metadata.
+type replicatedWrite struct {
+	log *Log
+	// tenantID, key and value are of deletes, tenantID is set by DeleteTenantByMetadata
+	tenantID, key, value string
+	time                 time.Time
+}
+
+// ReplicationLag describes writes not mirrored to the secondary yet.
+type ReplicationLag struct {
+	// Pending is the number of writes waiting to be mirrored
+	Pending int
+	// Oldest is the age of the oldest pending write, zero if there are none
+	Oldest time.Duration
+}
+
+func NewReplicatingStore(primary, secondary Store) *ReplicatingStore {
+	return &ReplicatingStore{
+		Store:         primary,
+		RetryInterval: time.Second,
+		Clock:         SystemClock,
+		secondary:     secondary,
+		notify:        make(chan struct{}, 1),
+	}
+}
+
+func (s *ReplicatingStore) AppendLog(log *Log) error {
+	if err := s.Store.AppendLog(log); err != nil {
+		return err
+	}
+	s.mirror(&replicatedWrite{log: log})
+	return nil
+}
+
+func (s *ReplicatingStore) AppendLogAt(log *Log, expected int) error {
+	if err := AppendLogAt(s.Store, log, expected); err != nil {
+		return err
+	}
+	s.mirror(&replicatedWrite{log: log})
+	return nil
+}
+
+func (s *ReplicatingStore) DeleteByMetadata(key, value string) (int, error) {
+	deleted, err := s.Store.DeleteByMetadata(key, value)
+	if err != nil {
+		return 0, err
+	}
+	s.mirror(&replicatedWrite{key: key, value: value})
+	return deleted, nil
+}
+
+func (s *ReplicatingStore) DeleteTenantByMetadata(tenantID, key, value string) (int, error) {
+	deleted, err := DeleteTenantByMetadata(s.Store, tenantID, key, value)
+	if err != nil {
+		return 0, err
+	}
+	s.mirror(&replicatedWrite{tenantID: tenantID, key: key, value: value})
+	return deleted, nil
+}
+
+func (s *ReplicatingStore) IsReadOnly() bool {
+	return IsReadOnly(s.Store)
+}
+
+// WithinTx appends logs within a transaction of the primary, they are mirrored after it's committed.
+func (s *ReplicatingStore) WithinTx(fn func(tx Store) error) error {
+	var appended []*Log
+	err := WithinTx(s.Store, func(tx Store) error {
+		appended = nil
+		return fn(&replicatedTx{Store: tx, appended: &appended})
+	})
+	if err != nil {
+		return err
+	}
+	for _, l := range appended {
+		s.mirror(&replicatedWrite{log: l})
+	}
+	return nil
+}
+
+// replicatedTx collects logs appended within a transaction of the primary.
+type replicatedTx struct {
+	Store
+	appended *[]*Log
+}
+
+func (tx *replicatedTx) AppendLog(log *Log) error {
+	if err := tx.Store.AppendLog(log); err != nil {
+		return err
+	}
+	*tx.appended = append(*tx.appended, log)
+	return nil
+}
+
+func (tx *replicatedTx) AppendLogAt(log *Log, expected int) error {
+	if err := AppendLogAt(tx.Store, log, expected); err != nil {
+		return err
+	}
+	*tx.appended = append(*tx.appended, log)
+	return nil
+}
+
+func (s *ReplicatingStore) mirror(w *replicatedWrite) {
+	w.time = s.Clock.Now()
+	s.mu.Lock()
+	s.pending = append(s.pending, w)
+	s.mu.Unlock()
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Lag returns writes not mirrored to the secondary yet, e.g. to export it as a metric.
+func (s *ReplicatingStore) Lag() ReplicationLag {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lag := ReplicationLag{Pending: len(s.pending)}
+	if len(s.pending) > 0 {
+		lag.Oldest = s.Clock.Now().Sub(s.pending[0].time)
+	}
+	return lag
+}
+
+// Run mirrors pending writes to the secondary until ctx is done.
+func (s *ReplicatingStore) Run(ctx context.Context) {
+	for {
+		s.mu.Lock()
+		var next *replicatedWrite
+		if len(s.pending) > 0 {
+			next = s.pending[0]
+		}
+		s.mu.Unlock()
+
+		if next == nil {
+			select {
+			case <-s.notify:
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+		if err := s.write(next); err != nil {
+			if s.OnError != nil {
+				s.OnError(err)
+			}
+			select {
+			case <-time.After(s.RetryInterval):
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+		s.mu.Lock()
+		s.pending[0] = nil
+		s.pending = s.pending[1:]
+		s.mu.Unlock()
+	}
+}
+
+func (s *ReplicatingStore) write(w *replicatedWrite) error {
+	if w.log != nil {
+		return s.secondary.AppendLog(w.log)
+	}
+	if w.tenantID != "" {
+		_, err := DeleteTenantByMetadata(s.secondary, w.tenantID, w.key, w.value)
+		return err
+	}
+	_, err := s.secondary.DeleteByMetadata(w.key, w.value)
+	return err
+}
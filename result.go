@@ -0,0 +1,56 @@
+package saga
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// GetResult rebuilds the Result of the execution from its logs, e.g. for services that query outcomes after
+// the process running the execution has exited. Steps that haven't been reached are unknown without
+// the saga, so Result.Steps ends with the last reached one.
+func GetResult(store Store, executionID string) (*Result, error) {
+	logs, err := store.GetAllLogsByExecutionID(executionID)
+	if err != nil {
+		return nil, err
+	}
+	if len(logs) == 0 {
+		return nil, ErrNoLogs
+	}
+	p := foldProgress(nil, logs)
+	state := p.state()
+	result := &Result{
+		Paused:       state == StatePaused,
+		Delayed:      state == StateDelayed,
+		DeadLettered: state == StateDeadLettered,
+		Steps:        p.steps,
+	}
+	switch {
+	case state == StateFailed && !p.retryAt.IsZero():
+		result.Delayed = true
+	case state == StateFailed && !p.graceUntil.IsZero():
+		result.Deferred = true
+	case state == StateCompensating && !p.compensateRetryAt.IsZero():
+		result.Delayed = true
+	}
+	if p.lastError != "" && (p.failedStep != nil || p.aborted) {
+		result.ExecutionError = errors.New(p.lastError)
+	}
+	for _, compensateErr := range p.compensateErrors {
+		result.CompensateErrors = append(result.CompensateErrors, errors.New(compensateErr))
+	}
+	if p.finallyError != "" {
+		result.FinallyError = errors.New(p.finallyError)
+	}
+	if len(p.result) > 0 {
+		if err := json.Unmarshal(p.result, &result.Data); err != nil {
+			return nil, err
+		}
+	}
+	if p.continuation != nil {
+		result.ContinuationID = p.continuation.ExecutionID
+		if result.Continuation, err = GetResult(store, p.continuation.ExecutionID); err != nil && err != ErrNoLogs {
+			return nil, err
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,129 @@
+package saga
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// resultJSON is the JSON encoding of Result, errors are encoded by their messages.
+type resultJSON struct {
+	ExecutionError   string                     `json:"executionError,omitempty"`
+	CompensateErrors []string                   `json:"compensateErrors,omitempty"`
+	Paused           bool                       `json:"paused,omitempty"`
+	Delayed          bool                       `json:"delayed,omitempty"`
+	Duplicate        bool                       `json:"duplicate,omitempty"`
+	Deferred         bool                       `json:"deferred,omitempty"`
+	DeadLettered     bool                       `json:"deadLettered,omitempty"`
+	Continuation     *Result                    `json:"continuation,omitempty"`
+	ContinuationID   string                     `json:"continuationId,omitempty"`
+	Steps            []StepResult               `json:"steps,omitempty"`
+	Data             map[string]json.RawMessage `json:"data,omitempty"`
+	FinallyError     string                     `json:"finallyError,omitempty"`
+}
+
+// MarshalJSON encodes the result with stable field names, so it can be returned from HTTP handlers
+// or published as an event. Errors are encoded by their messages.
+func (r *Result) MarshalJSON() ([]byte, error) {
+	v := resultJSON{
+		ExecutionError: errorMessage(r.ExecutionError),
+		Paused:         r.Paused,
+		Delayed:        r.Delayed,
+		Duplicate:      r.Duplicate,
+		Deferred:       r.Deferred,
+		DeadLettered:   r.DeadLettered,
+		Continuation:   r.Continuation,
+		ContinuationID: r.ContinuationID,
+		Steps:          r.Steps,
+		Data:           r.Data,
+		FinallyError:   errorMessage(r.FinallyError),
+	}
+	for _, err := range r.CompensateErrors {
+		v.CompensateErrors = append(v.CompensateErrors, errorMessage(err))
+	}
+	return json.Marshal(&v)
+}
+
+// UnmarshalJSON decodes the result encoded by MarshalJSON, errors are restored as plain errors with their messages.
+func (r *Result) UnmarshalJSON(data []byte) error {
+	var v resultJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*r = Result{
+		ExecutionError: messageError(v.ExecutionError),
+		Paused:         v.Paused,
+		Delayed:        v.Delayed,
+		Duplicate:      v.Duplicate,
+		Deferred:       v.Deferred,
+		DeadLettered:   v.DeadLettered,
+		Continuation:   v.Continuation,
+		ContinuationID: v.ContinuationID,
+		Steps:          v.Steps,
+		Data:           v.Data,
+		FinallyError:   messageError(v.FinallyError),
+	}
+	for _, message := range v.CompensateErrors {
+		r.CompensateErrors = append(r.CompensateErrors, messageError(message))
+	}
+	return nil
+}
+
+type stepResultJSON struct {
+	Name        string `json:"name"`
+	Status      string `json:"status"`
+	Attempts    int    `json:"attempts"`
+	DurationMs  int64  `json:"durationMs"`
+	Error       string `json:"error,omitempty"`
+	Compensated bool   `json:"compensated,omitempty"`
+	// CompensationAttempts and CompensationError are omitted for steps that haven't been compensated
+	CompensationAttempts int    `json:"compensationAttempts,omitempty"`
+	CompensationError    string `json:"compensationError,omitempty"`
+}
+
+// MarshalJSON encodes the result of the step with stable field names, the duration in milliseconds.
+func (r StepResult) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&stepResultJSON{
+		Name:                 r.Name,
+		Status:               r.Status,
+		Attempts:             r.Attempts,
+		DurationMs:           r.Duration.Milliseconds(),
+		Error:                errorMessage(r.Error),
+		Compensated:          r.Compensated,
+		CompensationAttempts: r.CompensationAttempts,
+		CompensationError:    errorMessage(r.CompensationError),
+	})
+}
+
+// UnmarshalJSON decodes the result of the step encoded by MarshalJSON.
+func (r *StepResult) UnmarshalJSON(data []byte) error {
+	var v stepResultJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*r = StepResult{
+		Name:                 v.Name,
+		Status:               v.Status,
+		Attempts:             v.Attempts,
+		Duration:             time.Duration(v.DurationMs) * time.Millisecond,
+		Error:                messageError(v.Error),
+		Compensated:          v.Compensated,
+		CompensationAttempts: v.CompensationAttempts,
+		CompensationError:    messageError(v.CompensationError),
+	}
+	return nil
+}
+
+func errorMessage(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+func messageError(message string) error {
+	if message == "" {
+		return nil
+	}
+	return errors.New(message)
+}
@@ -0,0 +1,235 @@
+package saga
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	ErrRunnerStopped = errors.New("runner is stopped")
+	ErrQueueFull     = errors.New("queue of pending executions is full")
+)
+
+// OverflowPolicy is what Runner.Submit does if the queue already has MaxPending executions.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock blocks Submit until there is space in the queue
+	OverflowBlock OverflowPolicy = iota
+	// OverflowReject finishes the submitted job with ErrQueueFull
+	OverflowReject
+	// OverflowShed finishes the pending job with the lowest priority with ErrQueueFull, the latest
+	// submitted one among equal priorities, which may be the submitted job itself
+	OverflowShed
+)
+
+// Runner plays sagas by a pool of workers. Submitted executions wait in a queue ordered by
+// priority, so urgent executions are played before bulk ones submitted earlier.
+type Runner struct {
+	// FuncsCtx and CompensateFuncsCtx are passed to coordinators of submitted executions
+	FuncsCtx           context.Context
+	CompensateFuncsCtx context.Context
+	// MaxPending limits the number of executions waiting in the queue, zero means no limit,
+	// Overflow is applied to executions submitted over the limit
+	MaxPending int
+	Overflow   OverflowPolicy
+
+	store   Store
+	workers int
+
+	mu       sync.Mutex
+	notEmpty *sync.Cond
+	notFull  *sync.Cond
+	queue    jobQueue
+	seq      uint64
+	stopped  bool
+}
+
+// Job is an execution submitted to Runner.
+type Job struct {
+	ExecutionID string
+	// Priority of the execution, executions with higher priority are played first,
+	// the ones with equal priority are played in order of submission
+	Priority int
+
+	coordinator *ExecutionCoordinator
+	seq         uint64
+	done        chan struct{}
+	result      *Result
+}
+
+// Wait blocks until the execution is played and returns its result.
+// Result.ExecutionError is ErrRunnerStopped if the Runner was stopped before playing it.
+func (j *Job) Wait() *Result {
+	<-j.done
+	return j.result
+}
+
+func (j *Job) finish(result *Result) {
+	j.result = result
+	close(j.done)
+}
+
+func NewRunner(store Store, workers int) *Runner {
+	checkOK(workers > 0, "number of workers must be positive")
+	r := &Runner{
+		FuncsCtx:           context.Background(),
+		CompensateFuncsCtx: context.Background(),
+		store:              store,
+		workers:            workers,
+	}
+	r.notEmpty = sync.NewCond(&r.mu)
+	r.notFull = sync.NewCond(&r.mu)
+	return r
+}
+
+// Submit queues the saga to be played with the priority, opts configure its coordinator.
+// Submit blocks if the queue is full and Overflow is OverflowBlock.
+func (r *Runner) Submit(saga *Saga, priority int, opts ...Option) *Job {
+	c := NewExecutionCoordinator(r.FuncsCtx, r.CompensateFuncsCtx, saga, r.store, opts...)
+	job := &Job{ExecutionID: c.ExecutionID, Priority: priority, coordinator: c, done: make(chan struct{})}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.seq++
+	job.seq = r.seq
+	for r.full() && !r.stopped && r.Overflow == OverflowBlock {
+		r.notFull.Wait()
+	}
+	if r.stopped {
+		job.finish(&Result{ExecutionError: ErrRunnerStopped})
+		return job
+	}
+	if r.full() {
+		if r.Overflow == OverflowReject {
+			job.finish(&Result{ExecutionError: ErrQueueFull})
+			return job
+		}
+		lowest := r.queue.lowest()
+		if r.queue.less(r.queue[lowest], job) {
+			job.finish(&Result{ExecutionError: ErrQueueFull})
+			return job
+		}
+		heap.Remove(&r.queue, lowest).(*Job).finish(&Result{ExecutionError: ErrQueueFull})
+	}
+	heap.Push(&r.queue, job)
+	r.notEmpty.Signal()
+	return job
+}
+
+func (r *Runner) full() bool {
+	return r.MaxPending > 0 && r.queue.Len() >= r.MaxPending
+}
+
+// Pending returns the number of executions waiting in the queue.
+func (r *Runner) Pending() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.queue.Len()
+}
+
+// Run plays submitted executions until ctx is done, then it waits for executions being played
+// and finishes pending ones with ErrRunnerStopped.
+func (r *Runner) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < r.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.work()
+		}()
+	}
+
+	<-ctx.Done()
+	r.mu.Lock()
+	r.stopped = true
+	r.notEmpty.Broadcast()
+	r.notFull.Broadcast()
+	r.mu.Unlock()
+	wg.Wait()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for r.queue.Len() > 0 {
+		heap.Pop(&r.queue).(*Job).finish(&Result{ExecutionError: ErrRunnerStopped})
+	}
+}
+
+func (r *Runner) work() {
+	for {
+		r.mu.Lock()
+		for r.queue.Len() == 0 && !r.stopped {
+			r.notEmpty.Wait()
+		}
+		if r.stopped {
+			r.mu.Unlock()
+			return
+		}
+		job := heap.Pop(&r.queue).(*Job)
+		r.notFull.Signal()
+		r.mu.Unlock()
+
+		job.finish(play(job.coordinator))
+	}
+}
+
+// play plays the execution, errors of the Store are returned as the execution error
+// instead of stopping the worker, e.g. *ConflictError.
+func play(c *ExecutionCoordinator) *Result {
+	return recoverResult(c, c.Play)
+}
+
+// recoverResult returns the result of f or the panic of f as the execution error.
+func recoverResult(c *ExecutionCoordinator, f func() *Result) (result *Result) {
+	defer func() {
+		if p := recover(); p != nil {
+			if err, ok := p.(error); ok {
+				result = &Result{ExecutionError: fmt.Errorf("execution %s failed: %w", c.ExecutionID, err)}
+				return
+			}
+			result = &Result{ExecutionError: fmt.Errorf("execution %s failed: %v", c.ExecutionID, p)}
+		}
+	}()
+	return f()
+}
+
+// jobQueue is a heap of jobs ordered by priority and submission.
+type jobQueue []*Job
+
+func (q jobQueue) Len() int { return len(q) }
+
+func (q jobQueue) Less(i, j int) bool { return q.less(q[i], q[j]) }
+
+// less returns true if a has to be played before b.
+func (q jobQueue) less(a, b *Job) bool {
+	if a.Priority != b.Priority {
+		return a.Priority > b.Priority
+	}
+	return a.seq < b.seq
+}
+
+// lowest returns index of the job that has to be played last.
+func (q jobQueue) lowest() int {
+	lowest := 0
+	for i := range q {
+		if q.less(q[lowest], q[i]) {
+			lowest = i
+		}
+	}
+	return lowest
+}
+
+func (q jobQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *jobQueue) Push(x interface{}) { *q = append(*q, x.(*Job)) }
+
+func (q *jobQueue) Pop() interface{} {
+	old := *q
+	job := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	return job
+}
@@ -2,9 +2,11 @@ package saga
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"reflect"
+	"time"
 )
 
 func NewSaga(name string) *Saga {
@@ -14,6 +16,73 @@ func NewSaga(name string) *Saga {
 }
 
 type StepOptions struct {
+	// RequireApproval pauses execution before the step until it's approved, see ExecutionCoordinator.Approve
+	RequireApproval bool
+	// ApprovalTimeout aborts the paused execution with ErrApprovalTimeout if the step isn't approved
+	// in time. The deadline is written to the Store, the execution is aborted by Resume called after it
+	ApprovalTimeout time.Duration
+	// Sensitive redacts all outputs of the step before writing them to the Store, see Redacted.
+	// Compensations executed by the same coordinator still receive the real values,
+	// compensations after Resume receive redacted ones.
+	Sensitive bool
+	// Delay parks the execution before the step for the duration, the timer is written to the Store.
+	// The step is executed by Resume called after the delay, see Status.FireAt
+	Delay time.Duration
+	// Window restricts when the step may be executed, the execution is parked until the window opens
+	// the same way as by Delay
+	Window *TimeWindow
+	// Timeout cancels context of the step func, error returned by the func after that is wrapped
+	// by ErrStepTimeout. SoftTimeout calls Escalator earlier, see WithEscalator
+	Timeout     time.Duration
+	SoftTimeout time.Duration
+	// OnTimeout governs compensation of the step if it has timed out, Probe is required by VerifyThenCompensate
+	OnTimeout TimeoutPolicy
+	Probe     Probe
+	// Retry retries the failed step after backoff instead of aborting the execution
+	Retry *RetryPolicy
+	// Compensation is the class of the compensation of the step, BestEffort by default.
+	// CompensationRetry retries Guaranteed compensations, DefaultCompensationRetry if it's nil
+	Compensation      CompensationClass
+	CompensationRetry *RetryPolicy
+	// CompensationTimeout cancels context of the compensate func, error returned by the func after that
+	// is wrapped by ErrCompensationTimeout
+	CompensationTimeout time.Duration
+	// NoCompensation marks a step that needs no compensation, e.g. a read, its CompensateFunc must be nil
+	NoCompensation bool
+	// Critical compensates the execution as soon as the step fails, without Retry or Saga.CompensationGrace.
+	// Steps after the pivot can't be critical
+	Critical bool
+	// Condition skips the step if it returns false, it's called before the step with the context of the step,
+	// so it can read the data bag by GetData. Skipped steps are recorded in the Store and aren't compensated
+	Condition func(ctx context.Context) bool
+	// Tags describe the step for Hooks and Escalator, e.g. its owner team
+	Tags map[string]string
+	// SuppressLogs doesn't write exec logs of successful attempts of the step to the Store, except for
+	// the fraction LogSampleRate of them, to reduce load of high-frequency steps. Failures are always written.
+	// It requires NoCompensation, and the step is executed again by Resume if no later log has been written
+	SuppressLogs  bool
+	LogSampleRate float64
+}
+
+// RetryPolicy retries failed steps with exponential backoff. The time of the next attempt is written
+// to the Store, the attempt is made by Resume called after it, see Status.FireAt.
+type RetryPolicy struct {
+	// MaxAttempts limits attempts including the first one, zero means no limit
+	MaxAttempts int
+	// Backoff is the delay before the first retry, it's doubled for each next one up to MaxBackoff if it's set
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+func (p *RetryPolicy) backoff(attempts int) time.Duration {
+	backoff := p.Backoff
+	for i := 1; i < attempts && (p.MaxBackoff <= 0 || backoff < p.MaxBackoff); i++ {
+		backoff *= 2
+	}
+	if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+	return backoff
 }
 
 type Step struct {
@@ -21,36 +90,196 @@ type Step struct {
 	Func           interface{}
 	CompensateFunc interface{}
 	Options        *StepOptions
+	// Pivot marks the point of no return: failures of steps before the pivot and of the pivot itself
+	// are compensated, failures after it are retried forward until the steps succeed, see DefaultForwardRetry,
+	// and approval timeouts after it are ignored. A saga has at most one pivot
+	Pivot bool
+	// Retriable marks a step after the pivot that is guaranteed to succeed eventually, so it's retried
+	// until it does and needs no compensation: its CompensateFunc may be nil
+	Retriable bool
+	// CompensateSaga compensates the step by an execution of the saga instead of CompensateFunc, for undo
+	// procedures of several steps with their own logs and retries. Its steps read outputs of the step
+	// by ParentOutput
+	CompensateSaga *Saga
 }
 
+// DefaultForwardRetry retries failed steps after the pivot that have no RetryPolicy.
+var DefaultForwardRetry = RetryPolicy{Backoff: time.Second, MaxBackoff: 5 * time.Minute}
+
 type Result struct {
 	ExecutionError   error
 	CompensateErrors []error
+	Paused           bool
+	// Delayed is set if the execution is parked until the delay of a step ends or until
+	// the next attempt of a failed step, see RetryPolicy
+	Delayed bool
+	// Duplicate is set if Play attached to an existing execution with the same dedup key instead of
+	// starting a new one, see WithDedupKey. Other fields reflect the current state of that execution
+	Duplicate bool
+	// Deferred is set if the execution has failed, but its compensation is deferred, see Saga.CompensationGrace
+	Deferred bool
+	// Continuation is the result of the continuation played after the execution, see Saga.OnSuccess,
+	// ContinuationID is its execution ID
+	Continuation   *Result
+	ContinuationID string
+	// DeadLettered is set if guaranteed compensations have failed all attempts, see Guaranteed
+	DeadLettered bool
+	// Steps are results of steps of the saga in order of execution, enough to render a postmortem
+	// without reading the Store
+	Steps []StepResult
+	// Data is the snapshot of the data bag of the completed execution, see SetData
+	Data map[string]json.RawMessage
+	// FinallyError is the error of the finalizer of the saga, see Saga.Finally
+	FinallyError error
 }
 
+// Saga is the definition of a saga: its steps and policies. Executions don't change it, their state is kept by
+// ExecutionCoordinator, so one definition is played, resumed and replayed by any number of goroutines at once.
+// It must not be changed by AddStep and the like while it's played.
 type Saga struct {
-	Name  string
+	Name string
+	// TenantID is written to all logs of executions of the saga, see ForTenant
+	TenantID string
+	// CompensationGrace defers compensation of failed executions for the duration, so they can
+	// be rescued by RetryStep. The deadline is written to the Store, compensation is started
+	// by Resume called after it or by Compensate at any time, see Status.FireAt
+	CompensationGrace time.Duration
+	// CompensationOrder orders compensations, ReverseOrder if it's nil
+	CompensationOrder CompensationOrder
+	// LogSync is when logs of its executions are written to the Store, SyncEachLog by default
+	LogSync LogSync
+	// MaxParallelCompensations limits the number of compensations of a stage of CompensationOrder
+	// running at once, zero means no limit
+	MaxParallelCompensations int
+
 	steps []*Step
+	// numbers are numbers of steps by name, pivotStep is the number of the pivot step plus one,
+	// zero if there is none, so lookups don't scan steps of sagas with thousands of them
+	numbers   map[string]int
+	pivotStep int
+	// onSuccess and onCompensated are continuations, see OnSuccess
+	onSuccess     *Saga
+	onCompensated *Saga
+	// defaults are options of steps, see SetDefaults
+	defaults *StepOptions
+	finally  func(ctx context.Context, result *Result) error
 }
 
 func (saga *Saga) AddStep(step *Step) error {
+	step = bindMethods(saga.withDefaults(step))
 	if err := checkStep(step); err != nil {
 		return err
 	}
-	saga.steps = append(saga.steps, step)
+	if step.Pivot && saga.pivot() >= 0 {
+		return fmt.Errorf("saga already has pivot step %s", saga.steps[saga.pivot()].Name)
+	}
+	if step.Retriable && (step.Pivot || saga.pivot() < 0) {
+		return errors.New("retriable step must follow the pivot step")
+	}
+	if step.Options != nil && step.Options.Critical && saga.pivot() >= 0 && !step.Pivot {
+		return errors.New("critical step must not follow the pivot step")
+	}
+	saga.addStep(step)
 	return nil
 }
 
+// addStep appends the step and indexes it.
+func (saga *Saga) addStep(step *Step) {
+	if saga.numbers == nil {
+		saga.numbers = make(map[string]int)
+	}
+	saga.numbers[step.Name] = len(saga.steps)
+	if step.Pivot {
+		saga.pivotStep = len(saga.steps) + 1
+	}
+	saga.steps = append(saga.steps, step)
+}
+
+// StepNumber returns the number of the step with the name, the latest one if names repeat.
+func (saga *Saga) StepNumber(name string) (int, bool) {
+	i, ok := saga.numbers[name]
+	return i, ok
+}
+
+// pivot returns number of the pivot step, -1 if there is no pivot.
+func (saga *Saga) pivot() int {
+	return saga.pivotStep - 1
+}
+
+// Steps returns steps of the saga in order of execution.
+func (saga *Saga) Steps() []*Step {
+	return append([]*Step(nil), saga.steps...)
+}
+
 func checkStep(step *Step) error {
+	if options := step.Options; options != nil {
+		if options.ApprovalTimeout < 0 || options.ApprovalTimeout > 0 && !options.RequireApproval {
+			return errors.New("approval timeout must be positive and requires approval")
+		}
+		if options.Retry != nil && (options.Retry.Backoff <= 0 || options.Retry.MaxAttempts < 0) {
+			return errors.New("retry backoff must be positive")
+		}
+		if retry := options.CompensationRetry; retry != nil && (retry.Backoff <= 0 || retry.MaxAttempts < 0 || options.Compensation != Guaranteed) {
+			return errors.New("compensation retry backoff must be positive and requires guaranteed compensation")
+		}
+		if options.Timeout < 0 || options.SoftTimeout < 0 || options.Timeout > 0 && options.SoftTimeout >= options.Timeout {
+			return errors.New("timeouts must be positive and soft timeout must be less than timeout")
+		}
+		if options.CompensationTimeout < 0 || options.CompensationTimeout > 0 && step.CompensateFunc == nil {
+			return errors.New("compensation timeout must be positive and requires compensate func")
+		}
+		if options.NoCompensation && (step.CompensateFunc != nil || step.CompensateSaga != nil) {
+			return errors.New("step with no compensation must not have compensate func or compensate saga")
+		}
+		if options.Critical && options.Retry != nil {
+			return errors.New("critical step can't be retried")
+		}
+		if options.SuppressLogs && !options.NoCompensation || options.LogSampleRate < 0 || options.LogSampleRate > 1 ||
+			options.LogSampleRate > 0 && !options.SuppressLogs {
+			return errors.New("suppressed logs require no compensation and log sample rate must be between 0 and 1")
+		}
+		for key := range options.Tags {
+			if key == "" {
+				return errors.New("tag keys must not be empty")
+			}
+		}
+		if options.OnTimeout == VerifyThenCompensate && options.Probe == nil {
+			return errors.New("verify then compensate requires probe")
+		}
+		if options.Window != nil {
+			if err := options.Window.check(); err != nil {
+				return err
+			}
+		}
+	}
+
 	funcType := reflect.TypeOf(step.Func)
+	if funcType == nil {
+		return errors.New("func field is not a func, but nil")
+	}
 	if funcType.Kind() != reflect.Func {
 		return fmt.Errorf("func field is not a func, but %s", funcType.Kind())
 	}
+	if reflect.ValueOf(step.Func).IsNil() {
+		return errors.New("func field is a nil func")
+	}
 
+	if step.CompensateFunc != nil && step.CompensateSaga != nil {
+		return errors.New("step must have either compensate func or compensate saga")
+	}
+	// retriable steps need no compensation, compensate sagas need no func
+	noCompensation := step.CompensateFunc == nil &&
+		(step.Retriable || step.CompensateSaga != nil || step.Options != nil && step.Options.NoCompensation)
 	compensateType := reflect.TypeOf(step.CompensateFunc)
-	if compensateType.Kind() != reflect.Func {
+	if !noCompensation && compensateType == nil {
+		return errors.New("func field is not a func, but nil")
+	}
+	if !noCompensation && compensateType.Kind() != reflect.Func {
 		return fmt.Errorf("func field is not a func, but %s", compensateType.Kind())
 	}
+	if !noCompensation && reflect.ValueOf(step.CompensateFunc).IsNil() {
+		return errors.New("compensate func field is a nil func")
+	}
 	if funcType.NumIn() != 1 || funcType.In(0) != reflect.TypeOf((*context.Context)(nil)).Elem() {
 		return errors.New("func must have strictly one parameter context.Context")
 	}
@@ -60,6 +289,9 @@ func checkStep(step *Step) error {
 	if !funcType.Out(funcType.NumOut() - 1).Implements(reflect.TypeOf((*error)(nil)).Elem()) {
 		return errors.New("last out parameter of func must be of type error")
 	}
+	if noCompensation {
+		return nil
+	}
 
 	if compensateType.NumIn() == 0 {
 		return errors.New("compensate must have at least one parameter context.Context")
@@ -1,11 +1,21 @@
 package saga
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
 	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 type mock struct {
@@ -48,7 +58,7 @@ func TestSuccessfullyExecTwoSteps_WithCustomizeExecutionID(t *testing.T) {
 
 	logStore := New()
 	executionID := RandString()
-	c := NewCoordinator(context.Background(), context.Background(), s, logStore, executionID)
+	c := NewExecutionCoordinator(context.Background(), context.Background(), s, logStore, WithExecutionID(executionID))
 	require.Nil(t, c.Play().ExecutionError)
 
 	require.Equal(t, m.callCounter, 1)
@@ -193,14 +203,17 @@ func TestCompensateReturnsError(t *testing.T) {
 
 	logs, err := logStore.GetAllLogsByExecutionID(c.ExecutionID)
 	require.NoError(t, err)
-	require.Len(t, logs, 7)
+	require.Len(t, logs, 9)
 	require.Equal(t, logs[0].Type, LogTypeStartSaga)
 	require.Equal(t, logs[1].Type, LogTypeSagaStepExec)
 	require.Equal(t, logs[2].Type, LogTypeSagaStepExec)
 	require.Equal(t, logs[3].Type, LogTypeSagaAbort)
 	require.Equal(t, logs[4].Type, LogTypeSagaStepCompensate)
-	require.Equal(t, logs[5].Type, LogTypeSagaStepCompensate)
-	require.Equal(t, logs[6].Type, LogTypeSagaComplete)
+	require.Equal(t, logs[5].Type, LogTypeSagaStepCompensateFailed)
+	require.Equal(t, "compensate error 2", *logs[5].StepError)
+	require.Equal(t, logs[6].Type, LogTypeSagaStepCompensate)
+	require.Equal(t, logs[7].Type, LogTypeSagaStepCompensateFailed)
+	require.Equal(t, logs[8].Type, LogTypeSagaComplete)
 
 	_, err = logStore.GetAllLogsByExecutionID(RandString())
 	require.Error(t, err)
@@ -272,3 +285,3353 @@ func TestMarshalResp(t *testing.T) {
 	require.Equal(t, th, resp[2].Interface())
 	require.Equal(t, fourth, resp[3].Interface())
 }
+
+func TestGetStatus(t *testing.T) {
+	s := NewSaga("hello")
+
+	require.NoError(t, s.AddStep(&Step{Name: "first", Func: (&mock{}).f, CompensateFunc: (&mock{}).f}))
+	require.NoError(t, s.AddStep(&Step{Name: "second", Func: (&mock{err: errors.New("some error")}).f, CompensateFunc: (&mock{}).f}))
+
+	logStore := New()
+	c := NewCoordinator(context.Background(), context.Background(), s, logStore)
+	c.Play()
+
+	status, err := GetStatus(logStore, c.ExecutionID)
+	require.NoError(t, err)
+	require.Equal(t, c.ExecutionID, status.ExecutionID)
+	require.Equal(t, "hello", status.Name)
+	require.Equal(t, "compensated", status.State)
+	require.Equal(t, 0, *status.CurrentStep)
+	require.Equal(t, map[int]int{0: 1, 1: 1}, status.Attempts)
+	require.Equal(t, []string{"some error"}, status.Errors)
+	require.NotNil(t, status.CompletedAt)
+	require.False(t, status.StartedAt.After(*status.CompletedAt))
+
+	_, err = GetStatus(logStore, RandString())
+	require.Equal(t, ErrNoLogs, err)
+}
+
+func TestListExecutions(t *testing.T) {
+	s := NewSaga("hello")
+	require.NoError(t, s.AddStep(&Step{Name: "first", Func: (&mock{}).f, CompensateFunc: (&mock{}).f}))
+	failed := NewSaga("failed")
+	require.NoError(t, failed.AddStep(&Step{Name: "first", Func: (&mock{err: errors.New("some error")}).f, CompensateFunc: (&mock{}).f}))
+
+	logStore := New()
+	start := time.Now()
+	c1 := NewCoordinator(context.Background(), context.Background(), s, logStore)
+	c1.Play()
+	c2 := NewCoordinator(context.Background(), context.Background(), failed, logStore)
+	c2.Play()
+	require.NoError(t, logStore.AppendLog(&Log{ExecutionID: "incomplete", Name: s.Name, Type: LogTypeStartSaga, Time: time.Now()}))
+
+	executionIDs := func(filter ExecutionFilter) []string {
+		statuses, next, err := logStore.ListExecutions(filter, Page{})
+		require.NoError(t, err)
+		require.Empty(t, next)
+		var res []string
+		for _, status := range statuses {
+			res = append(res, status.ExecutionID)
+		}
+		return res
+	}
+
+	require.Equal(t, []string{c1.ExecutionID, c2.ExecutionID, "incomplete"}, executionIDs(ExecutionFilter{}))
+	require.Equal(t, []string{c1.ExecutionID, "incomplete"}, executionIDs(ExecutionFilter{Name: "hello"}))
+	require.Equal(t, []string{c2.ExecutionID}, executionIDs(ExecutionFilter{States: []string{"compensated"}}))
+	require.Equal(t, []string{"incomplete"}, executionIDs(ExecutionFilter{Incomplete: true}))
+	require.Empty(t, executionIDs(ExecutionFilter{To: start}))
+	require.Empty(t, executionIDs(ExecutionFilter{From: time.Now()}))
+}
+
+func TestPagination(t *testing.T) {
+	s := NewSaga("hello")
+	require.NoError(t, s.AddStep(&Step{Name: "first", Func: (&mock{}).f, CompensateFunc: (&mock{}).f}))
+
+	logStore := New()
+	var executionIDs []string
+	for i := 0; i < 5; i++ {
+		c := NewCoordinator(context.Background(), context.Background(), s, logStore)
+		c.Play()
+		executionIDs = append(executionIDs, c.ExecutionID)
+	}
+
+	var listed []string
+	page := Page{Limit: 2}
+	for {
+		statuses, next, err := logStore.ListExecutions(ExecutionFilter{}, page)
+		require.NoError(t, err)
+		require.True(t, len(statuses) <= 2)
+		for _, status := range statuses {
+			listed = append(listed, status.ExecutionID)
+		}
+		if next == "" {
+			break
+		}
+		page.Cursor = next
+	}
+	require.Equal(t, executionIDs, listed)
+
+	logs, next, err := logStore.GetLogsPage(executionIDs[0], Page{Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, logs, 2)
+	require.Equal(t, LogTypeStartSaga, logs[0].Type)
+	logs, next, err = logStore.GetLogsPage(executionIDs[0], Page{Limit: 2, Cursor: next})
+	require.NoError(t, err)
+	require.Len(t, logs, 1)
+	require.Equal(t, LogTypeSagaComplete, logs[0].Type)
+	require.Empty(t, next)
+
+	_, _, err = logStore.GetLogsPage(executionIDs[0], Page{Cursor: "hello"})
+	require.Equal(t, ErrInvalidCursor, err)
+	_, _, err = logStore.ListExecutions(ExecutionFilter{}, Page{Cursor: "100"})
+	require.Equal(t, ErrInvalidCursor, err)
+}
+
+func TestForTenant(t *testing.T) {
+	first := NewSaga("hello")
+	first.TenantID = "first"
+	require.NoError(t, first.AddStep(&Step{Name: "first", Func: (&mock{}).f, CompensateFunc: (&mock{}).f}))
+	second := NewSaga("hello")
+	second.TenantID = "second"
+	require.NoError(t, second.AddStep(&Step{Name: "first", Func: (&mock{}).f, CompensateFunc: (&mock{}).f}))
+
+	logStore := New()
+	c1 := NewCoordinator(context.Background(), context.Background(), first, ForTenant(logStore, "first"))
+	c1.Play()
+	c2 := NewCoordinator(context.Background(), context.Background(), second, logStore)
+	c2.Play()
+
+	scoped := ForTenant(logStore, "first")
+	statuses, _, err := scoped.ListExecutions(ExecutionFilter{}, Page{})
+	require.NoError(t, err)
+	require.Len(t, statuses, 1)
+	require.Equal(t, c1.ExecutionID, statuses[0].ExecutionID)
+	require.Equal(t, "first", statuses[0].TenantID)
+
+	statuses, _, err = scoped.ListExecutions(ExecutionFilter{TenantID: "second"}, Page{})
+	require.NoError(t, err)
+	require.Empty(t, statuses)
+
+	logs, err := scoped.GetAllLogsByExecutionID(c1.ExecutionID)
+	require.NoError(t, err)
+	require.Equal(t, "first", logs[0].TenantID)
+	_, err = scoped.GetAllLogsByExecutionID(c2.ExecutionID)
+	require.Equal(t, ErrNoLogs, err)
+	_, _, err = scoped.GetLogsPage(c2.ExecutionID, Page{})
+	require.Equal(t, ErrNoLogs, err)
+	_, err = scoped.GetStepLogsToCompensate(c2.ExecutionID)
+	require.Equal(t, ErrNoLogs, err)
+	require.Equal(t, ErrTenantMismatch, scoped.AppendLog(&Log{ExecutionID: c2.ExecutionID, TenantID: "second"}))
+	// logs of the tenant can't be appended to executions of other tenants by their IDs
+	require.Equal(t, ErrTenantMismatch, scoped.AppendLog(&Log{ExecutionID: c2.ExecutionID, TenantID: "first", Type: LogTypeSagaAbort}))
+	require.Equal(t, ErrTenantMismatch, AppendLogAt(scoped, &Log{ExecutionID: c2.ExecutionID, TenantID: "first", Type: LogTypeSagaAbort}, 4))
+	logs, err = logStore.GetAllLogsByExecutionID(c2.ExecutionID)
+	require.NoError(t, err)
+	require.Equal(t, LogTypeSagaComplete, logs[len(logs)-1].Type)
+	require.NoError(t, scoped.AppendLog(&Log{ExecutionID: "new", TenantID: "first", Type: LogTypeStartSaga}))
+
+	require.Panics(t, func() { ForTenant(logStore, "") })
+}
+
+func TestIDGenerators(t *testing.T) {
+	uuid := UUIDv7Generator{}.NewID()
+	require.Regexp(t, `^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, uuid)
+	require.NotEqual(t, uuid, UUIDv7Generator{}.NewID())
+
+	ulid := ULIDGenerator{}.NewID()
+	require.Regexp(t, `^[0-7][0-9A-HJKMNP-TV-Z]{25}$`, ulid)
+	time.Sleep(2 * time.Millisecond)
+	require.True(t, ulid < ULIDGenerator{}.NewID())
+
+	s := NewSaga("hello")
+	require.NoError(t, s.AddStep(&Step{Name: "first", Func: (&mock{}).f, CompensateFunc: (&mock{}).f}))
+	c := NewExecutionCoordinator(context.Background(), context.Background(), s, New(), WithIDGenerator(IDGeneratorFunc(func() string { return "fixed" })))
+	require.Equal(t, "fixed", c.ExecutionID)
+	require.Len(t, NewCoordinator(context.Background(), context.Background(), s, New()).ExecutionID, 36)
+	require.Equal(t, "order-123", NewCoordinator(context.Background(), context.Background(), s, New(), "order-123").ExecutionID)
+}
+
+func TestReplay(t *testing.T) {
+	store := New()
+	s := NewSaga("order")
+	require.NoError(t, s.AddStep(&Step{
+		Name:           "reserve",
+		Func:           func(context.Context) (string, error) { return "item", nil },
+		CompensateFunc: func(context.Context, string) error { return nil },
+	}))
+	require.NoError(t, s.AddStep(&Step{
+		Name:           "charge",
+		Func:           func(context.Context) error { return errors.New("declined") },
+		CompensateFunc: func(context.Context) error { return nil },
+	}))
+	c := NewCoordinator(context.Background(), context.Background(), s, store)
+	c.Play()
+
+	m := &mock{}
+	same := NewSaga("order")
+	require.NoError(t, same.AddStep(&Step{Name: "reserve", Func: func(context.Context) (string, error) { return "", m.f(nil) }, CompensateFunc: func(context.Context, string) error { return m.f(nil) }}))
+	require.NoError(t, same.AddStep(&Step{Name: "charge", Func: m.f, CompensateFunc: m.f}))
+	report, err := Replay(store, c.ExecutionID, same)
+	require.NoError(t, err)
+	require.False(t, report.Diverged(), report.Divergences)
+	require.Equal(t, "compensated", report.Replayed.State)
+	require.Equal(t, 0, m.callCounter)
+
+	changed := NewSaga("order")
+	require.NoError(t, changed.AddStep(&Step{Name: "reserve", Func: func(context.Context) (int, error) { return 0, nil }, CompensateFunc: func(context.Context, int) error { return nil }}))
+	require.NoError(t, changed.AddStep(&Step{Name: "bill", Func: m.f, CompensateFunc: m.f}))
+	report, err = Replay(store, c.ExecutionID, changed)
+	require.NoError(t, err)
+	require.Len(t, report.Divergences, 3)
+	require.Contains(t, report.Divergences[0], `step 0 "reserve": can't decode value 0`)
+	require.Equal(t, `step 1 was "charge" originally, but now it's "bill"`, report.Divergences[1])
+
+	interrupted := NewCoordinator(context.Background(), context.Background(), s, store)
+	interrupted.appendLog(&Log{Type: LogTypeStartSaga})
+	_, err = Replay(store, interrupted.ExecutionID, s)
+	require.Equal(t, ErrExecutionInProgress, err)
+}
+
+func TestDebugger(t *testing.T) {
+	store := New()
+	s := NewSaga("order")
+	require.NoError(t, s.AddStep(&Step{
+		Name:           "reserve",
+		Func:           func(context.Context) (string, int, error) { return "item", 2, nil },
+		CompensateFunc: func(context.Context, string, int) error { return nil },
+	}))
+	require.NoError(t, s.AddStep(&Step{
+		Name:           "charge",
+		Func:           func(context.Context) error { return errors.New("declined") },
+		CompensateFunc: func(context.Context) error { return nil },
+	}))
+	c := NewCoordinator(context.Background(), context.Background(), s, store)
+	c.Play()
+
+	d, err := NewDebugger(store, c.ExecutionID, s)
+	require.NoError(t, err)
+	require.Equal(t, 7, d.Len())
+	require.Nil(t, d.Prev())
+
+	frame := d.Next()
+	require.Equal(t, LogTypeStartSaga, frame.Log.Type)
+	require.Equal(t, "running", frame.Status.State)
+
+	frame = d.Seek(2)
+	require.Equal(t, "failed", frame.Status.State)
+	require.Equal(t, `["item",2]`, string(frame.Payloads[0]))
+
+	frame = d.Seek(5)
+	require.Equal(t, LogTypeSagaStepCompensate, frame.Log.Type)
+	require.Equal(t, "reserve", *frame.Log.StepName)
+	require.Equal(t, 1, *frame.PayloadFrom)
+	require.Equal(t, []interface{}{"item", 2}, frame.CompensateArgs)
+
+	require.Equal(t, "compensating", d.Prev().Status.State)
+	require.Equal(t, "compensated", d.Seek(6).Status.State)
+	require.Nil(t, d.Next())
+
+	_, err = NewDebugger(store, "unknown", s)
+	require.Equal(t, ErrNoLogs, err)
+}
+
+func TestEncrypted(t *testing.T) {
+	_, err := NewAESKeyProvider([]byte("short"))
+	require.Error(t, err)
+	keys, err := NewAESKeyProvider([]byte("0123456789abcdef0123456789abcdef"))
+	require.NoError(t, err)
+
+	backend := New()
+	store := Encrypted(backend, keys)
+	s := NewSaga("payment")
+	var compensated string
+	require.NoError(t, s.AddStep(&Step{
+		Name:           "charge",
+		Func:           func(context.Context) (string, error) { return "4111 1111 1111 1111", nil },
+		CompensateFunc: func(_ context.Context, card string) error { compensated = card; return nil },
+	}))
+	require.NoError(t, s.AddStep(&Step{Name: "fail", Func: func(context.Context) error { return errors.New("failed") }, CompensateFunc: (&mock{}).f}))
+	c := NewCoordinator(context.Background(), context.Background(), s, store)
+	c.Play()
+	require.Equal(t, "4111 1111 1111 1111", compensated)
+
+	raw, err := backend.GetAllLogsByExecutionID(c.ExecutionID)
+	require.NoError(t, err)
+	require.NotContains(t, string(raw[1].StepPayload), "4111")
+	logs, err := store.GetAllLogsByExecutionID(c.ExecutionID)
+	require.NoError(t, err)
+	require.Equal(t, `["4111 1111 1111 1111"]`, string(logs[1].StepPayload))
+	page, _, err := store.GetLogsPage(c.ExecutionID, Page{Limit: 2})
+	require.NoError(t, err)
+	require.Equal(t, logs[1].StepPayload, page[1].StepPayload)
+
+	// errors are encrypted as well
+	var stepErr *string
+	for i, l := range logs {
+		if l.StepError != nil {
+			stepErr = l.StepError
+			require.NotContains(t, *raw[i].StepError, "failed")
+		}
+	}
+	require.Equal(t, "failed", *stepErr)
+	statuses, _, err := store.ListExecutions(ExecutionFilter{}, Page{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"failed"}, statuses[0].Errors)
+
+	// payloads can't be moved to other logs
+	other := NewCoordinator(context.Background(), context.Background(), s, store)
+	other.Play()
+	otherRaw, err := backend.GetAllLogsByExecutionID(other.ExecutionID)
+	require.NoError(t, err)
+	tampered := New()
+	moved := *raw[1]
+	moved.ExecutionID = other.ExecutionID
+	require.NoError(t, tampered.AppendLog(&moved))
+	_, err = Encrypted(tampered, keys).GetAllLogsByExecutionID(other.ExecutionID)
+	require.EqualError(t, err, "can't decrypt payload: cipher: message authentication failed")
+	copied := *otherRaw[1]
+	copied.StepPayload = raw[1].StepPayload
+	tampered = New()
+	require.NoError(t, tampered.AppendLog(&copied))
+	_, err = Encrypted(tampered, keys).GetAllLogsByExecutionID(other.ExecutionID)
+	require.EqualError(t, err, "can't decrypt payload: cipher: message authentication failed")
+
+	// nor errors replaced by plain text
+	for _, l := range otherRaw {
+		if l.StepError != nil {
+			replaced := *l
+			plain := "declined"
+			replaced.StepError = &plain
+			tampered = New()
+			require.NoError(t, tampered.AppendLog(&replaced))
+		}
+	}
+	_, err = Encrypted(tampered, keys).GetAllLogsByExecutionID(other.ExecutionID)
+	require.EqualError(t, err, "error of "+other.ExecutionID+" isn't encrypted: invalid character 'd' looking for beginning of value")
+
+	otherKeys, err := NewAESKeyProvider([]byte("fedcba9876543210fedcba9876543210"))
+	require.NoError(t, err)
+	_, err = Encrypted(backend, otherKeys).GetAllLogsByExecutionID(c.ExecutionID)
+	require.Error(t, err)
+}
+
+type card struct {
+	Holder string
+	Number string `saga:"sensitive"`
+	CVC    int    `saga:"sensitive"`
+	Email  string `saga:"sensitive,hash"`
+}
+
+func TestSensitive(t *testing.T) {
+	store := New()
+	s := NewSaga("payment")
+	var compensated []interface{}
+	require.NoError(t, s.AddStep(&Step{
+		Name: "charge",
+		Func: func(context.Context) (*card, []card, error) {
+			c := card{Holder: "John", Number: "4111", CVC: 123, Email: "john@example.com"}
+			return &c, []card{c}, nil
+		},
+		CompensateFunc: func(_ context.Context, c *card, cards []card) error {
+			compensated = append(compensated, c, cards)
+			return nil
+		},
+	}))
+	require.NoError(t, s.AddStep(&Step{
+		Name:           "token",
+		Func:           func(context.Context) (string, error) { return "secret", nil },
+		CompensateFunc: func(_ context.Context, token string) error { compensated = append(compensated, token); return nil },
+		Options:        &StepOptions{Sensitive: true},
+	}))
+	require.NoError(t, s.AddStep(&Step{Name: "fail", Func: func(context.Context) error { return errors.New("failed") }, CompensateFunc: (&mock{}).f}))
+	c := NewCoordinator(context.Background(), context.Background(), s, store)
+	c.Play()
+
+	expected := &card{Holder: "John", Number: "4111", CVC: 123, Email: "john@example.com"}
+	require.Equal(t, []interface{}{"secret", expected, []card{*expected}}, compensated)
+
+	logs, err := store.GetAllLogsByExecutionID(c.ExecutionID)
+	require.NoError(t, err)
+	redacted := `{"Holder":"John","Number":"[REDACTED]","CVC":0,"Email":"855f96e983f1f8e8be944692b6f719fd54329826cb62e98015efee8e2e071dd4"}`
+	require.Equal(t, `[`+redacted+`,[`+redacted+`]]`, string(logs[1].StepPayload))
+	require.Equal(t, `["[REDACTED]"]`, string(logs[2].StepPayload))
+}
+
+func TestHashChained(t *testing.T) {
+	key := []byte("audit key")
+	backend := New()
+	store := HashChained(backend, key)
+	s := NewSaga("order")
+	require.NoError(t, s.AddStep(&Step{Name: "first", Func: (&mock{}).f, CompensateFunc: (&mock{}).f}))
+	require.NoError(t, s.AddStep(&Step{Name: "second", Func: (&mock{err: errors.New("failed")}).f, CompensateFunc: (&mock{}).f}))
+	c := NewCoordinator(context.Background(), context.Background(), s, store)
+	c.Play()
+
+	require.NoError(t, VerifyChain(store, c.ExecutionID, key))
+	require.True(t, errors.Is(VerifyChain(store, c.ExecutionID, []byte("other key")), ErrChainBroken))
+
+	logs, err := backend.GetAllLogsByExecutionID(c.ExecutionID)
+	require.NoError(t, err)
+	require.Len(t, logs[0].Hash, 32)
+	// the memory store keeps copies of logs, so they are tampered with in another one
+	stepErr := "changed"
+	logs[2].StepError = &stepErr
+	tampered := New()
+	for _, l := range logs {
+		require.NoError(t, tampered.AppendLog(l))
+	}
+	require.EqualError(t, VerifyChain(tampered, c.ExecutionID, key), "hash chain of logs is broken: log 2 of "+c.ExecutionID)
+
+	plain := HashChained(New(), nil)
+	require.NoError(t, plain.AppendLog(&Log{ExecutionID: "e1", Type: LogTypeStartSaga}))
+	require.NoError(t, plain.AppendLog(&Log{ExecutionID: "e1", Type: LogTypeSagaComplete}))
+	require.NoError(t, VerifyChain(plain, "e1", nil))
+}
+
+func TestDeleteByMetadata(t *testing.T) {
+	backend := New()
+	s := NewSaga("order")
+	require.NoError(t, s.AddStep(&Step{Name: "first", Func: (&mock{}).f, CompensateFunc: (&mock{}).f}))
+	s.TenantID = "t1"
+	c1 := NewExecutionCoordinator(context.Background(), context.Background(), s, backend, WithMetadata(map[string]string{"customer": "c1"}))
+	c1.Play()
+	status, err := GetStatus(backend, c1.ExecutionID)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"customer": "c1"}, status.Metadata)
+
+	other := NewSaga("order")
+	require.NoError(t, other.AddStep(&Step{Name: "first", Func: (&mock{}).f, CompensateFunc: (&mock{}).f}))
+	other.TenantID = "t2"
+	c2 := NewExecutionCoordinator(context.Background(), context.Background(), other, backend, WithMetadata(map[string]string{"customer": "c1"}))
+	c2.Play()
+
+	// executions of other tenants with the same metadata are kept
+	deleted, err := ForTenant(Instrumented(backend, &testMetrics{}), "t1").DeleteByMetadata("customer", "c1")
+	require.NoError(t, err)
+	require.Equal(t, 1, deleted)
+	_, err = GetStatus(backend, c1.ExecutionID)
+	require.Equal(t, ErrNoLogs, err)
+	_, err = GetStatus(backend, c2.ExecutionID)
+	require.NoError(t, err)
+	deleted, err = ForTenant(backend, "t1").DeleteByMetadata("customer", "c2")
+	require.NoError(t, err)
+	require.Zero(t, deleted)
+	_, err = ForTenant(&storeOnly{backend}, "t1").DeleteByMetadata("customer", "c1")
+	require.Equal(t, ErrTenantDeleteUnsupported, err)
+
+	deleted, err = backend.DeleteByMetadata("customer", "c1")
+	require.NoError(t, err)
+	require.Equal(t, 1, deleted)
+	_, err = GetStatus(backend, c2.ExecutionID)
+	require.Equal(t, ErrNoLogs, err)
+}
+
+// storeOnly hides optional interfaces of the store.
+type storeOnly struct {
+	Store
+}
+
+func TestSecrets(t *testing.T) {
+	var secrets []string
+	useSecret := func(ctx context.Context) error {
+		secret, err := Secret(ctx, "api-key")
+		secrets = append(secrets, secret)
+		return err
+	}
+	s := NewSaga("order")
+	require.NoError(t, s.AddStep(&Step{Name: "first", Func: useSecret, CompensateFunc: useSecret}))
+	require.NoError(t, s.AddStep(&Step{Name: "second", Func: (&mock{err: errors.New("failed")}).f, CompensateFunc: (&mock{}).f}))
+
+	c := NewExecutionCoordinator(context.Background(), context.Background(), s, New(), WithSecrets(StaticSecrets{"api-key": "s3cr3t"}))
+	require.Empty(t, c.Play().CompensateErrors)
+	require.Equal(t, []string{"s3cr3t", "s3cr3t"}, secrets)
+
+	_, err := Secret(context.Background(), "api-key")
+	require.Equal(t, ErrNoSecretsProvider, err)
+	_, err = StaticSecrets{}.Secret(context.Background(), "api-key")
+	require.Equal(t, ErrSecretNotFound, err)
+}
+
+func TestPayloadLimit(t *testing.T) {
+	big := strings.Repeat("x", 100)
+	var compensated []string
+	newSaga := func() *Saga {
+		s := NewSaga("big")
+		require.NoError(t, s.AddStep(&Step{
+			Name:           "download",
+			Func:           func(context.Context) (string, error) { return big, nil },
+			CompensateFunc: func(_ context.Context, data string) error { compensated = append(compensated, data); return nil },
+		}))
+		require.NoError(t, s.AddStep(&Step{Name: "approve", Func: (&mock{}).f, CompensateFunc: (&mock{}).f, Options: &StepOptions{RequireApproval: true}}))
+		return s
+	}
+
+	store := New()
+	c := NewExecutionCoordinator(context.Background(), context.Background(), newSaga(), store, WithPayloadLimit(PayloadLimit{MaxSize: 10}))
+	result := c.Play()
+	require.True(t, errors.Is(result.ExecutionError, ErrPayloadTooLarge))
+	require.Equal(t, []string{big}, compensated)
+	logs, err := store.GetAllLogsByExecutionID(c.ExecutionID)
+	require.NoError(t, err)
+	require.Nil(t, logs[1].StepPayload)
+
+	compensated = nil
+	c = NewExecutionCoordinator(context.Background(), context.Background(), newSaga(), store, WithPayloadLimit(PayloadLimit{MaxSize: 10, Policy: PayloadTruncate}))
+	require.True(t, c.Play().Paused)
+	logs, err = store.GetAllLogsByExecutionID(c.ExecutionID)
+	require.NoError(t, err)
+	require.Len(t, logs[1].StepPayload, 10)
+	require.True(t, logs[1].StepPayloadTruncated)
+	_, err = NewExecutionCoordinator(context.Background(), context.Background(), newSaga(), store, WithExecutionID(c.ExecutionID)).Compensate()
+	require.NoError(t, err)
+	require.Equal(t, []string{""}, compensated)
+
+	compensated = nil
+	limit := PayloadLimit{MaxSize: 10, Policy: PayloadSpill, Blobs: NewMemoryBlobStore()}
+	c = NewExecutionCoordinator(context.Background(), context.Background(), newSaga(), store, WithPayloadLimit(limit))
+	require.True(t, c.Play().Paused)
+	logs, err = store.GetAllLogsByExecutionID(c.ExecutionID)
+	require.NoError(t, err)
+	require.Nil(t, logs[1].StepPayload)
+	require.NotEmpty(t, logs[1].StepPayloadRef)
+	_, err = NewExecutionCoordinator(context.Background(), context.Background(), newSaga(), store, WithExecutionID(c.ExecutionID), WithPayloadLimit(limit)).Compensate()
+	require.NoError(t, err)
+	require.Equal(t, []string{big}, compensated)
+
+	require.Panics(t, func() { WithPayloadLimit(PayloadLimit{MaxSize: 10, Policy: PayloadSpill}) })
+}
+
+func TestDirBlobStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blobs")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	blobs, err := NewDirBlobStore(dir)
+	require.NoError(t, err)
+	_, err = blobs.Get("missing/key")
+	require.True(t, errors.Is(err, ErrNoBlob))
+
+	big := strings.Repeat("x", 100)
+	var shipped string
+	shipping := NewSaga("shipping")
+	require.NoError(t, shipping.AddStep(&Step{
+		Name:           "ship",
+		Func:           func(ctx context.Context) error { return ParentOutput(ctx, "pack", &shipped) },
+		CompensateFunc: (&mock{}).f,
+	}))
+	order := NewSaga("order")
+	require.NoError(t, order.AddStep(&Step{
+		Name:           "pack",
+		Func:           func(context.Context) (string, error) { return big, nil },
+		CompensateFunc: func(context.Context, string) error { return nil },
+	}))
+	order.OnSuccess(shipping)
+
+	store := New()
+	c := NewExecutionCoordinator(context.Background(), context.Background(), order, store,
+		WithPayloadLimit(PayloadLimit{MaxSize: 10, Policy: PayloadSpill, Blobs: blobs}))
+	result := c.Play()
+	require.NoError(t, result.ExecutionError)
+	require.NoError(t, result.Continuation.ExecutionError)
+	require.Equal(t, big, shipped)
+	logs, err := store.GetAllLogsByExecutionID(c.ExecutionID)
+	require.NoError(t, err)
+	data, err := blobs.Get(logs[1].StepPayloadRef)
+	require.NoError(t, err)
+	require.Equal(t, `["`+big+`"]`, string(data))
+}
+
+func TestPayloadMemory(t *testing.T) {
+	var compensated []string
+	s := NewSaga("batch")
+	for i := 0; i < 10; i++ {
+		value := strings.Repeat(strconv.Itoa(i), 100)
+		require.NoError(t, s.AddStep(&Step{
+			Name:           "item" + strconv.Itoa(i),
+			Func:           func(context.Context) (string, error) { return value, nil },
+			CompensateFunc: func(_ context.Context, v string) error { compensated = append(compensated, v); return nil },
+			Options:        &StepOptions{Sensitive: i == 0},
+		}))
+	}
+	require.NoError(t, s.AddStep(&Step{Name: "fail", Func: func(context.Context) error { return errors.New("failed") }, CompensateFunc: (&mock{}).f}))
+
+	c := NewExecutionCoordinator(context.Background(), context.Background(), s, New(), WithPayloadMemory(250))
+	c.Play()
+	require.Len(t, compensated, 10)
+	for i, v := range compensated {
+		require.Equal(t, strings.Repeat(strconv.Itoa(9-i), 100), v)
+	}
+	// the redacted payload of the first step is kept with the latest ones
+	require.Len(t, c.payloads, 3)
+	require.Contains(t, c.payloads, 0)
+	require.True(t, c.retained <= 250+len(c.payloads[0]))
+}
+
+type testClock struct{ now time.Time }
+
+func (c *testClock) Now() time.Time { return c.now }
+
+func TestDelayedStep(t *testing.T) {
+	first, reminder := &mock{}, &mock{}
+	s := NewSaga("reminder")
+	require.NoError(t, s.AddStep(&Step{Name: "first", Func: first.f, CompensateFunc: (&mock{}).f}))
+	require.NoError(t, s.AddStep(&Step{Name: "remind", Func: reminder.f, CompensateFunc: (&mock{}).f, Options: &StepOptions{Delay: 24 * time.Hour}}))
+
+	store := New()
+	clock := &testClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c := NewCoordinator(context.Background(), context.Background(), s, store)
+	c.Clock = clock
+	require.True(t, c.Play().Delayed)
+	require.Equal(t, 1, first.callCounter)
+	require.Equal(t, 0, reminder.callCounter)
+	status, err := GetStatus(store, c.ExecutionID)
+	require.NoError(t, err)
+	require.Equal(t, "delayed", status.State)
+	require.Equal(t, clock.now.Add(24*time.Hour), *status.FireAt)
+
+	// a restarted process resumes the execution, the timer isn't started again
+	clock.now = clock.now.Add(time.Hour)
+	resumed := NewExecutionCoordinator(context.Background(), context.Background(), s, store, WithExecutionID(c.ExecutionID))
+	resumed.Clock = clock
+	result, err := resumed.Resume()
+	require.NoError(t, err)
+	require.True(t, result.Delayed)
+	require.Equal(t, 0, reminder.callCounter)
+
+	clock.now = clock.now.Add(23 * time.Hour)
+	resumed = NewExecutionCoordinator(context.Background(), context.Background(), s, store, WithExecutionID(c.ExecutionID))
+	resumed.Clock = clock
+	result, err = resumed.Resume()
+	require.NoError(t, err)
+	require.False(t, result.Delayed)
+	require.Equal(t, 1, reminder.callCounter)
+	status, err = GetStatus(store, c.ExecutionID)
+	require.NoError(t, err)
+	require.Equal(t, "completed", status.State)
+	require.Nil(t, status.FireAt)
+
+	_, err = resumed.Resume()
+	require.Equal(t, ErrExecutionCompleted, err)
+}
+
+func TestParseCron(t *testing.T) {
+	from := time.Date(2020, 1, 31, 10, 17, 30, 0, time.UTC) // Friday
+	for expr, next := range map[string]time.Time{
+		"* * * * *":        time.Date(2020, 1, 31, 10, 18, 0, 0, time.UTC),
+		"*/15 * * * *":     time.Date(2020, 1, 31, 10, 30, 0, 0, time.UTC),
+		"5 2-4 * * *":      time.Date(2020, 2, 1, 2, 5, 0, 0, time.UTC),
+		"0 0 29 2 *":       time.Date(2020, 2, 29, 0, 0, 0, 0, time.UTC),
+		"0 9 * * 1,3":      time.Date(2020, 2, 3, 9, 0, 0, 0, time.UTC),
+		"0 9 * * 7":        time.Date(2020, 2, 2, 9, 0, 0, 0, time.UTC),
+		"0 9 15 * 6":       time.Date(2020, 2, 1, 9, 0, 0, 0, time.UTC),
+		"@monthly":         time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC),
+		"@every 1h30m":     from.Add(90 * time.Minute),
+		" 15 10 31 1 * ":   time.Date(2021, 1, 31, 10, 15, 0, 0, time.UTC),
+		"0 0 30 2 *":       {},
+		"0,30 */6 1-7 * *": time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC),
+		"0 0 */2 * 1":      time.Date(2020, 2, 3, 0, 0, 0, 0, time.UTC), // */2 doesn't restrict days, odd days must be Mondays
+	} {
+		schedule, err := ParseCron(expr)
+		require.NoError(t, err, expr)
+		require.Equal(t, next, schedule.Next(from), expr)
+	}
+
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *", "@every -1m", "@sometimes"} {
+		_, err := ParseCron(expr)
+		require.Error(t, err, expr)
+	}
+}
+
+func TestScheduler(t *testing.T) {
+	for policy, expected := range map[OverlapPolicy][]string{
+		OverlapSkip:           {"completed"},
+		OverlapQueue:          {"completed", "completed", "completed"},
+		OverlapCancelPrevious: {"compensated", "completed"},
+	} {
+		started, release := make(chan struct{}, 10), make(chan struct{})
+		s := NewSaga("reconcile")
+		require.NoError(t, s.AddStep(&Step{
+			Name: "wait",
+			Func: func(ctx context.Context) error {
+				started <- struct{}{}
+				select {
+				case <-release:
+					return nil
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			},
+			CompensateFunc: (&mock{}).f,
+		}))
+
+		store := New()
+		scheduler := NewScheduler(store)
+		scheduler.Clock = &testClock{now: time.Date(2020, 1, 1, 0, 0, 30, 0, time.UTC)}
+		require.NoError(t, scheduler.Add("* * * * *", s, policy))
+		require.Error(t, scheduler.Add("* *", s, policy))
+
+		ctx := context.Background()
+		// nothing is due before the first minute
+		require.Equal(t, time.Date(2020, 1, 1, 0, 1, 0, 0, time.UTC), scheduler.fire(ctx, time.Date(2020, 1, 1, 0, 0, 59, 0, time.UTC)))
+		require.Empty(t, started)
+		scheduler.fire(ctx, time.Date(2020, 1, 1, 0, 1, 0, 0, time.UTC))
+		<-started
+		next := scheduler.fire(ctx, time.Date(2020, 1, 1, 0, 2, 0, 0, time.UTC))
+		require.Equal(t, time.Date(2020, 1, 1, 0, 3, 0, 0, time.UTC), next)
+		if policy == OverlapQueue {
+			scheduler.fire(ctx, time.Date(2020, 1, 1, 0, 3, 0, 0, time.UTC))
+		}
+		close(release)
+		scheduler.wg.Wait()
+
+		require.Len(t, started, len(expected)-1, policy)
+		statuses, _, err := store.ListExecutions(ExecutionFilter{}, Page{})
+		require.NoError(t, err)
+		var states []string
+		for _, status := range statuses {
+			states = append(states, status.State)
+		}
+		require.ElementsMatch(t, expected, states, policy)
+	}
+}
+
+func TestSchedulerRun(t *testing.T) {
+	s := NewSaga("every")
+	calls := make(chan struct{}, 100)
+	require.NoError(t, s.AddStep(&Step{
+		Name:           "tick",
+		Func:           func(ctx context.Context) error { calls <- struct{}{}; return nil },
+		CompensateFunc: (&mock{}).f,
+	}))
+	scheduler := NewScheduler(New())
+	require.NoError(t, scheduler.Add("@every 10ms", s, OverlapSkip))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		scheduler.Run(ctx)
+		close(done)
+	}()
+	<-calls
+	<-calls
+	cancel()
+	<-done
+}
+
+func TestCompensationGrace(t *testing.T) {
+	first, comp, second := &mock{}, &mock{}, &mock{err: errors.New("unavailable")}
+	s := NewSaga("grace")
+	s.CompensationGrace = time.Hour
+	require.NoError(t, s.AddStep(&Step{Name: "first", Func: first.f, CompensateFunc: comp.f}))
+	require.NoError(t, s.AddStep(&Step{Name: "second", Func: second.f, CompensateFunc: comp.f}))
+
+	store := New()
+	clock := &testClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	newCoordinator := func(opts ...Option) *ExecutionCoordinator {
+		c := NewExecutionCoordinator(context.Background(), context.Background(), s, store, opts...)
+		c.Clock = clock
+		return c
+	}
+
+	// the failed execution is rescued by retry during the grace period
+	c := newCoordinator()
+	result := c.Play()
+	require.True(t, result.Deferred)
+	require.EqualError(t, result.ExecutionError, "unavailable")
+	require.Equal(t, 0, comp.callCounter)
+	status, err := GetStatus(store, c.ExecutionID)
+	require.NoError(t, err)
+	require.Equal(t, "failed", status.State)
+	require.Equal(t, clock.now.Add(time.Hour), *status.FireAt)
+
+	clock.now = clock.now.Add(30 * time.Minute)
+	result, err = newCoordinator(WithExecutionID(c.ExecutionID)).Resume()
+	require.NoError(t, err)
+	require.True(t, result.Deferred)
+	require.Equal(t, 0, comp.callCounter)
+
+	second.err = nil
+	result, err = newCoordinator(WithExecutionID(c.ExecutionID)).RetryStep()
+	require.NoError(t, err)
+	require.False(t, result.Deferred)
+	require.NoError(t, result.ExecutionError)
+	require.Equal(t, 0, comp.callCounter)
+	status, err = GetStatus(store, c.ExecutionID)
+	require.NoError(t, err)
+	require.Equal(t, "completed", status.State)
+	require.Nil(t, status.FireAt)
+
+	// the execution is compensated by Resume after the grace period
+	second.err = errors.New("unavailable")
+	c = newCoordinator()
+	require.True(t, c.Play().Deferred)
+	clock.now = clock.now.Add(time.Hour)
+	result, err = newCoordinator(WithExecutionID(c.ExecutionID)).Resume()
+	require.NoError(t, err)
+	require.False(t, result.Deferred)
+	require.EqualError(t, result.ExecutionError, "unavailable")
+	require.Equal(t, 2, comp.callCounter)
+	status, err = GetStatus(store, c.ExecutionID)
+	require.NoError(t, err)
+	require.Equal(t, "compensated", status.State)
+	require.Nil(t, status.FireAt)
+}
+
+func TestRunner(t *testing.T) {
+	var mu sync.Mutex
+	var played []string
+	started, release := make(chan struct{}), make(chan struct{})
+	newSaga := func(name string) *Saga {
+		s := NewSaga(name)
+		require.NoError(t, s.AddStep(&Step{
+			Name: "record",
+			Func: func(ctx context.Context) error {
+				if name == "blocker" {
+					close(started)
+					<-release
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				played = append(played, name)
+				return nil
+			},
+			CompensateFunc: (&mock{}).f,
+		}))
+		return s
+	}
+
+	runner := NewRunner(New(), 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		runner.Run(ctx)
+		close(done)
+	}()
+
+	blocker := runner.Submit(newSaga("blocker"), 0)
+	<-started
+	jobs := []*Job{
+		runner.Submit(newSaga("backfill1"), 0),
+		runner.Submit(newSaga("backfill2"), 0),
+		runner.Submit(newSaga("urgent"), 10, WithExecutionID("urgent-1")),
+		runner.Submit(newSaga("normal"), 5),
+	}
+	require.Equal(t, 4, runner.Pending())
+	require.Equal(t, "urgent-1", jobs[2].ExecutionID)
+	close(release)
+	require.NoError(t, blocker.Wait().ExecutionError)
+	for _, job := range jobs {
+		require.NoError(t, job.Wait().ExecutionError)
+	}
+	require.Equal(t, []string{"blocker", "urgent", "normal", "backfill1", "backfill2"}, played)
+
+	cancel()
+	<-done
+	require.Equal(t, ErrRunnerStopped, runner.Submit(newSaga("late"), 0).Wait().ExecutionError)
+}
+
+func TestApprovalTimeout(t *testing.T) {
+	first, comp, second := &mock{}, &mock{}, &mock{}
+	s := NewSaga("approval")
+	require.NoError(t, s.AddStep(&Step{Name: "first", Func: first.f, CompensateFunc: comp.f}))
+	require.NoError(t, s.AddStep(&Step{Name: "second", Func: second.f, CompensateFunc: comp.f,
+		Options: &StepOptions{RequireApproval: true, ApprovalTimeout: time.Hour}}))
+	require.Error(t, s.AddStep(&Step{Name: "third", Func: second.f, CompensateFunc: comp.f,
+		Options: &StepOptions{ApprovalTimeout: time.Hour}}))
+
+	store := New()
+	clock := &testClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c := NewCoordinator(context.Background(), context.Background(), s, store)
+	c.Clock = clock
+	require.True(t, c.Play().Paused)
+	status, err := GetStatus(store, c.ExecutionID)
+	require.NoError(t, err)
+	require.Equal(t, "paused", status.State)
+	require.Equal(t, clock.now.Add(time.Hour), *status.FireAt)
+
+	clock.now = clock.now.Add(time.Hour)
+	resumed := NewExecutionCoordinator(context.Background(), context.Background(), s, store, WithExecutionID(c.ExecutionID))
+	resumed.Clock = clock
+	result, err := resumed.Resume()
+	require.NoError(t, err)
+	require.Equal(t, ErrApprovalTimeout, result.ExecutionError)
+	require.Equal(t, 0, second.callCounter)
+	require.Equal(t, 1, comp.callCounter)
+	status, err = GetStatus(store, c.ExecutionID)
+	require.NoError(t, err)
+	require.Equal(t, "compensated", status.State)
+	require.Equal(t, []string{ErrApprovalTimeout.Error()}, status.Errors)
+	require.Nil(t, status.FireAt)
+}
+
+func TestRetryPolicy(t *testing.T) {
+	policy := &RetryPolicy{Backoff: time.Second, MaxBackoff: 5 * time.Second}
+	require.Equal(t, time.Second, policy.backoff(1))
+	require.Equal(t, 4*time.Second, policy.backoff(3))
+	require.Equal(t, 5*time.Second, policy.backoff(100))
+
+	flaky, comp := &mock{err: errors.New("unavailable")}, &mock{}
+	s := NewSaga("retry")
+	require.NoError(t, s.AddStep(&Step{Name: "flaky", Func: flaky.f, CompensateFunc: comp.f,
+		Options: &StepOptions{Retry: &RetryPolicy{MaxAttempts: 3, Backoff: time.Minute}}}))
+	require.Error(t, s.AddStep(&Step{Name: "invalid", Func: flaky.f, CompensateFunc: comp.f,
+		Options: &StepOptions{Retry: &RetryPolicy{}}}))
+
+	store := New()
+	clock := &testClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c := NewCoordinator(context.Background(), context.Background(), s, store)
+	c.Clock = clock
+	result := c.Play()
+	require.True(t, result.Delayed)
+	require.EqualError(t, result.ExecutionError, "unavailable")
+	status, err := GetStatus(store, c.ExecutionID)
+	require.NoError(t, err)
+	require.Equal(t, "failed", status.State)
+	require.Equal(t, clock.now.Add(time.Minute), *status.FireAt)
+
+	resume := func() *Result {
+		resumed := NewExecutionCoordinator(context.Background(), context.Background(), s, store, WithExecutionID(c.ExecutionID))
+		resumed.Clock = clock
+		result, err := resumed.Resume()
+		require.NoError(t, err)
+		return result
+	}
+	require.True(t, resume().Delayed)
+	require.Equal(t, 1, flaky.callCounter)
+
+	clock.now = clock.now.Add(time.Minute)
+	require.True(t, resume().Delayed)
+	require.Equal(t, 2, flaky.callCounter)
+	status, err = GetStatus(store, c.ExecutionID)
+	require.NoError(t, err)
+	require.Equal(t, clock.now.Add(2*time.Minute), *status.FireAt)
+
+	// the last attempt fails, so the execution is compensated
+	clock.now = clock.now.Add(2 * time.Minute)
+	result = resume()
+	require.False(t, result.Delayed)
+	require.EqualError(t, result.ExecutionError, "unavailable")
+	require.Equal(t, 3, flaky.callCounter)
+	require.Equal(t, 1, comp.callCounter)
+}
+
+func TestTimerLoop(t *testing.T) {
+	delayed, flaky := &mock{}, &mock{err: errors.New("unavailable")}
+	s := NewSaga("timers")
+	require.NoError(t, s.AddStep(&Step{Name: "delayed", Func: delayed.f, CompensateFunc: (&mock{}).f,
+		Options: &StepOptions{Delay: time.Hour}}))
+	require.NoError(t, s.AddStep(&Step{Name: "flaky", Func: flaky.f, CompensateFunc: (&mock{}).f,
+		Options: &StepOptions{Retry: &RetryPolicy{Backoff: time.Hour}}}))
+
+	store := New()
+	clock := &testClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c := NewCoordinator(context.Background(), context.Background(), s, store)
+	c.Clock = clock
+	require.True(t, c.Play().Delayed)
+
+	admin := NewAdmin(store, s)
+	admin.Clock = clock
+	var errs []error
+	loop := NewTimerLoop(admin)
+	loop.OnError = func(executionID string, err error) { errs = append(errs, err) }
+	require.Equal(t, 0, loop.Fire(context.Background()))
+
+	clock.now = clock.now.Add(time.Hour)
+	require.Equal(t, 1, loop.Fire(context.Background()))
+	require.Equal(t, 1, delayed.callCounter)
+	require.Equal(t, 1, flaky.callCounter)
+
+	flaky.err = nil
+	clock.now = clock.now.Add(time.Hour)
+	require.Equal(t, 1, loop.Fire(context.Background()))
+	require.Equal(t, 2, flaky.callCounter)
+	status, err := GetStatus(store, c.ExecutionID)
+	require.NoError(t, err)
+	require.Equal(t, "completed", status.State)
+	require.Equal(t, 0, loop.Fire(context.Background()))
+	require.Empty(t, errs)
+}
+
+func TestTimerLoopsSharingStore(t *testing.T) {
+	var executed int32
+	s := NewSaga("timers")
+	require.NoError(t, s.AddStep(&Step{Name: "delayed", Func: func(context.Context) error {
+		atomic.AddInt32(&executed, 1)
+		time.Sleep(time.Millisecond)
+		return nil
+	}, CompensateFunc: (&mock{}).f, Options: &StepOptions{Delay: time.Hour}}))
+
+	store := New()
+	clock := &testClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	for i := 0; i < 20; i++ {
+		c := NewExecutionCoordinator(context.Background(), context.Background(), s, store, WithClock(clock))
+		require.True(t, c.Play().Delayed)
+	}
+	clock.now = clock.now.Add(time.Hour)
+
+	var resumed int32
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		admin := NewAdmin(store, s)
+		admin.Clock = clock
+		loop := NewTimerLoop(admin)
+		loop.OnError = func(executionID string, err error) { t.Error(executionID, err) }
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			atomic.AddInt32(&resumed, int32(loop.Fire(context.Background())))
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, int32(20), resumed)
+	require.Equal(t, int32(20), executed)
+	statuses, _, err := store.ListExecutions(ExecutionFilter{Incomplete: true}, Page{})
+	require.NoError(t, err)
+	require.Empty(t, statuses)
+}
+
+func TestAdminRecoversConflicts(t *testing.T) {
+	s := NewSaga("conflicting")
+	var store Store
+	require.NoError(t, s.AddStep(&Step{Name: "step", Func: func(ctx context.Context) error {
+		// another process appends a log of the execution meanwhile
+		invocation, _ := InvocationFromContext(ctx)
+		return store.AppendLog(&Log{ExecutionID: invocation.ExecutionID, Name: "conflicting", Type: LogTypeSagaDataSet})
+	}, CompensateFunc: (&mock{}).f, Options: &StepOptions{RequireApproval: true}}))
+	store = New()
+	c := NewCoordinator(context.Background(), context.Background(), s, store)
+	require.True(t, c.Play().Paused)
+
+	_, err := NewAdmin(store, s).Approve(context.Background(), c.ExecutionID)
+	var conflict *ConflictError
+	require.True(t, errors.As(err, &conflict))
+}
+
+func TestTimeWindow(t *testing.T) {
+	window := &TimeWindow{Start: 2 * time.Hour, End: 4 * time.Hour}
+	at := func(day, hour, minute int) time.Time { return time.Date(2020, 1, day, hour, minute, 0, 0, time.UTC) }
+	require.Equal(t, at(1, 2, 0), window.Next(at(1, 1, 0)))
+	require.Equal(t, at(1, 3, 30), window.Next(at(1, 3, 30)))
+	require.Equal(t, at(2, 2, 0), window.Next(at(1, 4, 0)))
+
+	overnight := &TimeWindow{Start: 22 * time.Hour, End: time.Hour}
+	require.Equal(t, at(2, 0, 30), overnight.Next(at(2, 0, 30)))
+	require.Equal(t, at(2, 22, 0), overnight.Next(at(2, 1, 0)))
+
+	moscow := time.FixedZone("MSK", 3*60*60)
+	local := &TimeWindow{Start: 2 * time.Hour, End: 4 * time.Hour, Location: moscow}
+	require.Equal(t, at(1, 23, 0), local.Next(at(1, 12, 0)))
+
+	require.Error(t, (&TimeWindow{Start: time.Hour, End: time.Hour}).check())
+	require.Error(t, (&TimeWindow{Start: time.Hour, End: 25 * time.Hour}).check())
+}
+
+func TestStepWindow(t *testing.T) {
+	batch := &mock{}
+	s := NewSaga("nightly")
+	require.NoError(t, s.AddStep(&Step{Name: "batch", Func: batch.f, CompensateFunc: (&mock{}).f,
+		Options: &StepOptions{Window: &TimeWindow{Start: 2 * time.Hour, End: 4 * time.Hour}}}))
+	require.Error(t, s.AddStep(&Step{Name: "invalid", Func: batch.f, CompensateFunc: (&mock{}).f,
+		Options: &StepOptions{Window: &TimeWindow{End: 25 * time.Hour}}}))
+
+	store := New()
+	clock := &testClock{now: time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)}
+	c := NewCoordinator(context.Background(), context.Background(), s, store)
+	c.Clock = clock
+	require.True(t, c.Play().Delayed)
+	require.Equal(t, 0, batch.callCounter)
+	opens := time.Date(2020, 1, 2, 2, 0, 0, 0, time.UTC)
+	status, err := GetStatus(store, c.ExecutionID)
+	require.NoError(t, err)
+	require.Equal(t, "delayed", status.State)
+	require.Equal(t, opens, *status.FireAt)
+
+	resume := func() *Result {
+		resumed := NewExecutionCoordinator(context.Background(), context.Background(), s, store, WithExecutionID(c.ExecutionID))
+		resumed.Clock = clock
+		result, err := resumed.Resume()
+		require.NoError(t, err)
+		return result
+	}
+	clock.now = clock.now.Add(time.Hour)
+	require.True(t, resume().Delayed)
+	logs, err := store.GetAllLogsByExecutionID(c.ExecutionID)
+	require.NoError(t, err)
+	require.Len(t, logs, 2)
+
+	// the execution resumed after the window closes waits for the next one
+	clock.now = opens.Add(3 * time.Hour)
+	require.True(t, resume().Delayed)
+	status, err = GetStatus(store, c.ExecutionID)
+	require.NoError(t, err)
+	require.Equal(t, opens.AddDate(0, 0, 1), *status.FireAt)
+
+	clock.now = opens.AddDate(0, 0, 1).Add(time.Minute)
+	require.False(t, resume().Delayed)
+	require.Equal(t, 1, batch.callCounter)
+}
+
+func TestRunnerOverflow(t *testing.T) {
+	newSaga := func() *Saga {
+		s := NewSaga("bulk")
+		require.NoError(t, s.AddStep(&Step{Name: "noop", Func: (&mock{}).f, CompensateFunc: (&mock{}).f}))
+		return s
+	}
+
+	runner := NewRunner(New(), 1)
+	runner.MaxPending = 2
+	runner.Overflow = OverflowReject
+	first, second := runner.Submit(newSaga(), 0), runner.Submit(newSaga(), 0)
+	require.Equal(t, ErrQueueFull, runner.Submit(newSaga(), 10).Wait().ExecutionError)
+
+	runner.Overflow = OverflowShed
+	require.Equal(t, ErrQueueFull, runner.Submit(newSaga(), 0).Wait().ExecutionError)
+	urgent := runner.Submit(newSaga(), 10)
+	require.Equal(t, ErrQueueFull, second.Wait().ExecutionError)
+	require.Equal(t, 2, runner.Pending())
+
+	// blocked submit continues when a worker takes a pending execution
+	runner.Overflow = OverflowBlock
+	submitted := make(chan *Job)
+	go func() { submitted <- runner.Submit(newSaga(), 0) }()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		runner.Run(ctx)
+		close(done)
+	}()
+	require.NoError(t, (<-submitted).Wait().ExecutionError)
+	require.NoError(t, first.Wait().ExecutionError)
+	require.NoError(t, urgent.Wait().ExecutionError)
+	cancel()
+	<-done
+}
+
+func TestStepTimeout(t *testing.T) {
+	slow := func(d time.Duration) func(ctx context.Context) error {
+		return func(ctx context.Context) error {
+			select {
+			case <-time.After(d):
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+	play := func(f func(ctx context.Context) error, escalator Escalator) *Result {
+		s := NewSaga("slow")
+		require.NoError(t, s.AddStep(&Step{Name: "slow", Func: f, CompensateFunc: (&mock{}).f,
+			Options: &StepOptions{SoftTimeout: 10 * time.Millisecond, Timeout: 50 * time.Millisecond}}))
+		var opts []Option
+		if escalator != nil {
+			opts = append(opts, WithEscalator(escalator))
+		}
+		return NewExecutionCoordinator(context.Background(), context.Background(), s, New(), opts...).Play()
+	}
+
+	escalations := make(chan *Escalation, 1)
+	result := play(slow(time.Minute), func(e *Escalation) time.Duration {
+		escalations <- e
+		return 0
+	})
+	require.True(t, errors.Is(result.ExecutionError, ErrStepTimeout), result.ExecutionError)
+	escalation := <-escalations
+	require.Equal(t, "slow", escalation.StepName)
+	require.True(t, escalation.Elapsed >= 10*time.Millisecond)
+	require.False(t, escalation.Deadline.IsZero())
+
+	// the escalator extends the hard timeout
+	result = play(slow(100*time.Millisecond), func(e *Escalation) time.Duration { return time.Minute })
+	require.NoError(t, result.ExecutionError)
+
+	result = play(slow(time.Minute), nil)
+	require.True(t, errors.Is(result.ExecutionError, ErrStepTimeout), result.ExecutionError)
+	require.NoError(t, play(slow(0), nil).ExecutionError)
+
+	require.Error(t, NewSaga("invalid").AddStep(&Step{Name: "slow", Func: slow(0), CompensateFunc: (&mock{}).f,
+		Options: &StepOptions{SoftTimeout: time.Minute, Timeout: time.Second}}))
+}
+
+func TestDedupKey(t *testing.T) {
+	refund := &mock{}
+	s := NewSaga("refund")
+	require.NoError(t, s.AddStep(&Step{Name: "refund", Func: refund.f, CompensateFunc: (&mock{}).f}))
+
+	store := New()
+	clock := &testClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	play := func(key string) (*ExecutionCoordinator, *Result) {
+		c := NewExecutionCoordinator(context.Background(), context.Background(), s, store,
+			WithDedupKey(key, time.Hour), WithMetadata(map[string]string{"customer": "42"}))
+		c.Clock = clock
+		return c, c.Play()
+	}
+
+	first, result := play("order-123-refund")
+	require.False(t, result.Duplicate)
+	second, result := play("order-123-refund")
+	require.True(t, result.Duplicate)
+	require.NoError(t, result.ExecutionError)
+	require.Equal(t, first.ExecutionID, second.ExecutionID)
+	require.Equal(t, 1, refund.callCounter)
+	status, err := GetStatus(store, first.ExecutionID)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"customer": "42", DedupKeyMetadata: "order-123-refund"}, status.Metadata)
+
+	other, result := play("order-456-refund")
+	require.False(t, result.Duplicate)
+	require.NotEqual(t, first.ExecutionID, other.ExecutionID)
+
+	clock.now = clock.now.Add(time.Hour + time.Minute)
+	late, result := play("order-123-refund")
+	require.False(t, result.Duplicate)
+	require.NotEqual(t, first.ExecutionID, late.ExecutionID)
+	require.Equal(t, 3, refund.callCounter)
+
+	refund.err = errors.New("declined")
+	failed, result := play("order-789-refund")
+	require.Error(t, result.ExecutionError)
+	duplicate, result := play("order-789-refund")
+	require.True(t, result.Duplicate)
+	require.EqualError(t, result.ExecutionError, "declined")
+	require.Equal(t, failed.ExecutionID, duplicate.ExecutionID)
+
+	// the execution of the previous window is a duplicate within the window
+	refund.err = nil
+	clock.now = time.Date(2020, 1, 2, 0, 59, 0, 0, time.UTC)
+	previous, _ := play("order-321-refund")
+	clock.now = clock.now.Add(2 * time.Minute)
+	next, result := play("order-321-refund")
+	require.True(t, result.Duplicate)
+	require.Equal(t, previous.ExecutionID, next.ExecutionID)
+
+	// concurrent Plays in the process start one execution
+	refund.callCounter = 0
+	var wg sync.WaitGroup
+	var duplicates int32
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c := NewExecutionCoordinator(context.Background(), context.Background(), s, store, WithDedupKey("order-654-refund", time.Hour))
+			c.Clock = clock
+			if c.Play().Duplicate {
+				atomic.AddInt32(&duplicates, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, int32(9), duplicates)
+	require.Equal(t, 1, refund.callCounter)
+
+	// a Play in another process starting the execution after the lookup wins the conditional append
+	racing := &racingStore{Store: store}
+	c := NewExecutionCoordinator(context.Background(), context.Background(), s, racing, WithDedupKey("order-987-refund", time.Hour))
+	c.Clock = clock
+	result = c.Play()
+	require.True(t, result.Duplicate)
+	require.Equal(t, racing.winner, c.ExecutionID)
+	require.Equal(t, 1, refund.callCounter)
+}
+
+// racingStore starts the execution looked up the second time as if another process did it after the lookup.
+type racingStore struct {
+	Store
+	lookups int
+	winner  string
+}
+
+func (s *racingStore) GetAllLogsByExecutionID(executionID string) ([]*Log, error) {
+	if s.lookups++; s.lookups == 2 {
+		s.winner = executionID
+		if err := s.Store.AppendLog(&Log{ExecutionID: executionID, Name: "refund", Type: LogTypeStartSaga}); err != nil {
+			return nil, err
+		}
+		return nil, ErrNoLogs
+	}
+	return s.Store.GetAllLogsByExecutionID(executionID)
+}
+
+func (s *racingStore) AppendLogAt(l *Log, expected int) error {
+	return AppendLogAt(s.Store, l, expected)
+}
+
+func TestTimerWheel(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	w := newTimerWheel(100*time.Millisecond, start)
+	w.add("soon", start.Add(250*time.Millisecond))
+	w.add("minute", start.Add(time.Minute))
+	w.add("day", start.Add(24*time.Hour))
+	w.add("year", start.AddDate(2, 0, 0))
+	w.add("overdue", start.Add(-time.Second))
+	w.add("moved", start.Add(time.Second))
+	w.add("moved", start.Add(time.Hour))
+	require.Equal(t, 6, w.len())
+
+	require.Equal(t, []string{"overdue"}, w.advance(start.Add(200*time.Millisecond)))
+	require.Equal(t, []string{"soon"}, w.advance(start.Add(300*time.Millisecond)))
+	require.Empty(t, w.advance(start.Add(59*time.Second)))
+	require.Equal(t, []string{"minute"}, w.advance(start.Add(time.Minute)))
+	require.Equal(t, []string{"moved"}, w.advance(start.Add(time.Hour)))
+	require.Empty(t, w.advance(start.Add(23*time.Hour)))
+	require.Equal(t, []string{"day"}, w.advance(start.Add(25*time.Hour)))
+	require.Equal(t, 1, w.len())
+}
+
+func TestPivot(t *testing.T) {
+	reserve, charge, ship, comp := &mock{}, &mock{}, &mock{err: errors.New("courier unavailable")}, &mock{}
+	s := NewSaga("order")
+	require.NoError(t, s.AddStep(&Step{Name: "reserve", Func: reserve.f, CompensateFunc: comp.f}))
+	require.NoError(t, s.AddStep(&Step{Name: "charge", Func: charge.f, CompensateFunc: comp.f, Pivot: true}))
+	require.NoError(t, s.AddStep(&Step{Name: "ship", Func: ship.f, CompensateFunc: comp.f,
+		Options: &StepOptions{Retry: &RetryPolicy{MaxAttempts: 1, Backoff: time.Minute, MaxBackoff: time.Minute}}}))
+	require.Error(t, s.AddStep(&Step{Name: "another", Func: ship.f, CompensateFunc: comp.f, Pivot: true}))
+
+	store := New()
+	clock := &testClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	newCoordinator := func(opts ...Option) *ExecutionCoordinator {
+		c := NewExecutionCoordinator(context.Background(), context.Background(), s, store, opts...)
+		c.Clock = clock
+		return c
+	}
+
+	// failures after the pivot are retried forward despite MaxAttempts
+	c := newCoordinator()
+	require.True(t, c.Play().Delayed)
+	for i := 0; i < 3; i++ {
+		clock.now = clock.now.Add(time.Minute)
+		result, err := newCoordinator(WithExecutionID(c.ExecutionID)).Resume()
+		require.NoError(t, err)
+		require.True(t, result.Delayed)
+	}
+	require.Equal(t, 4, ship.callCounter)
+	require.Equal(t, 0, comp.callCounter)
+	_, err := newCoordinator(WithExecutionID(c.ExecutionID)).Compensate()
+	require.Equal(t, ErrPastPivot, err)
+
+	ship.err = nil
+	clock.now = clock.now.Add(time.Minute)
+	result, err := newCoordinator(WithExecutionID(c.ExecutionID)).Resume()
+	require.NoError(t, err)
+	require.NoError(t, result.ExecutionError)
+	require.Equal(t, 0, comp.callCounter)
+
+	// failure of the pivot itself is compensated
+	charge.err = errors.New("declined")
+	result = newCoordinator().Play()
+	require.EqualError(t, result.ExecutionError, "declined")
+	require.Equal(t, 2, comp.callCounter)
+}
+
+func TestRetriableStep(t *testing.T) {
+	charge, notify, comp := &mock{}, &mock{err: errors.New("smtp unavailable")}, &mock{}
+	s := NewSaga("order")
+	require.Error(t, s.AddStep(&Step{Name: "notify", Func: notify.f, Retriable: true}))
+	require.EqualError(t, s.AddStep(&Step{Name: "charge", Func: charge.f}), "func field is not a func, but nil")
+	require.NoError(t, s.AddStep(&Step{Name: "charge", Func: charge.f, CompensateFunc: comp.f, Pivot: true}))
+	require.NoError(t, s.AddStep(&Step{Name: "notify", Func: notify.f, Retriable: true}))
+
+	store := New()
+	clock := &testClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c := NewCoordinator(context.Background(), context.Background(), s, store)
+	c.Clock = clock
+	require.True(t, c.Play().Delayed)
+	status, err := GetStatus(store, c.ExecutionID)
+	require.NoError(t, err)
+	require.Equal(t, clock.now.Add(DefaultForwardRetry.Backoff), *status.FireAt)
+
+	notify.err = nil
+	clock.now = *status.FireAt
+	resumed := NewExecutionCoordinator(context.Background(), context.Background(), s, store, WithExecutionID(c.ExecutionID))
+	resumed.Clock = clock
+	result, err := resumed.Resume()
+	require.NoError(t, err)
+	require.NoError(t, result.ExecutionError)
+	require.Equal(t, 2, notify.callCounter)
+	require.Equal(t, 0, comp.callCounter)
+}
+
+func TestSemanticLocks(t *testing.T) {
+	locks := NewMemoryLocks()
+	release := make(chan struct{})
+	var holder string
+	newSaga := func(fail error) *Saga {
+		s := NewSaga("order")
+		require.NoError(t, s.AddStep(&Step{
+			Name:           "reserve",
+			Func:           func(ctx context.Context) error { return LockResource(ctx, "order-1") },
+			CompensateFunc: func(ctx context.Context) error { return UnlockResource(ctx, "order-1") },
+		}))
+		require.NoError(t, s.AddStep(&Step{
+			Name: "wait",
+			Func: func(ctx context.Context) error {
+				var err error
+				holder, err = locks.Holder("order-1")
+				require.NoError(t, err)
+				<-release
+				return fail
+			},
+			CompensateFunc: (&mock{}).f,
+		}))
+		return s
+	}
+
+	store := New()
+	done := make(chan *Result)
+	first := NewExecutionCoordinator(context.Background(), context.Background(), newSaga(nil), store, WithSemanticLocks(locks))
+	go func() { done <- first.Play() }()
+
+	// the second execution can't lock the pending order
+	second := NewExecutionCoordinator(context.Background(), context.Background(), newSaga(nil), store, WithSemanticLocks(locks))
+	for {
+		if h, _ := locks.Holder("order-1"); h != "" {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	result := second.Play()
+	require.True(t, errors.Is(result.ExecutionError, ErrResourceLocked), result.ExecutionError)
+
+	close(release)
+	require.NoError(t, (<-done).ExecutionError)
+	require.Equal(t, first.ExecutionID, holder)
+	h, err := locks.Holder("order-1")
+	require.NoError(t, err)
+	require.Empty(t, h)
+
+	// compensated executions release their locks too
+	result = NewExecutionCoordinator(context.Background(), context.Background(), newSaga(errors.New("declined")), store, WithSemanticLocks(locks)).Play()
+	require.EqualError(t, result.ExecutionError, "declined")
+	h, err = locks.Holder("order-1")
+	require.NoError(t, err)
+	require.Empty(t, h)
+
+	require.Equal(t, ErrNoSemanticLocks, LockResource(context.Background(), "order-1"))
+}
+
+func TestVersions(t *testing.T) {
+	version := "v1"
+	comp := &mock{}
+	s := NewSaga("update")
+	require.NoError(t, s.AddStep(&Step{
+		Name:           "read",
+		Func:           func(ctx context.Context) error { return RecordVersion(ctx, "order-1", version) },
+		CompensateFunc: comp.f,
+	}))
+	require.NoError(t, s.AddStep(&Step{
+		Name:           "approve",
+		Func:           (&mock{}).f,
+		CompensateFunc: comp.f,
+		Options:        &StepOptions{RequireApproval: true},
+	}))
+	require.NoError(t, s.AddStep(&Step{
+		Name:           "write",
+		Func:           func(ctx context.Context) error { return VerifyVersion(ctx, "order-1", version) },
+		CompensateFunc: comp.f,
+	}))
+
+	// the version is checked after approval by another coordinator
+	store := New()
+	c := NewCoordinator(context.Background(), context.Background(), s, store)
+	require.True(t, c.Play().Paused)
+	version = "v2"
+	result, err := NewExecutionCoordinator(context.Background(), context.Background(), s, store, WithExecutionID(c.ExecutionID)).Approve()
+	require.NoError(t, err)
+	require.True(t, errors.Is(result.ExecutionError, ErrVersionConflict), result.ExecutionError)
+	require.Equal(t, 3, comp.callCounter)
+
+	require.Equal(t, ErrNotInStep, RecordVersion(context.Background(), "order-1", "v1"))
+}
+
+func TestCompensationOrder(t *testing.T) {
+	var mu sync.Mutex
+	var compensated []string
+	started := make(chan struct{}, 3)
+	release := make(chan struct{})
+	newSaga := func(order func(*Saga) CompensationOrder, block bool) *Saga {
+		s := NewSaga("wide")
+		for _, name := range []string{"a", "b", "c"} {
+			name := name
+			require.NoError(t, s.AddStep(&Step{
+				Name: name,
+				Func: func(context.Context) error { return nil },
+				CompensateFunc: func(context.Context) error {
+					if block {
+						started <- struct{}{}
+						<-release
+					}
+					mu.Lock()
+					defer mu.Unlock()
+					compensated = append(compensated, name)
+					return nil
+				},
+			}))
+		}
+		failed := &mock{err: errors.New("failed")}
+		require.NoError(t, s.AddStep(&Step{Name: "d", Func: failed.f, CompensateFunc: (&mock{}).f}))
+		s.CompensationOrder = order(s)
+		return s
+	}
+
+	// parallel compensations all start before any of them finishes
+	s := newSaga(func(*Saga) CompensationOrder { return ParallelOrder }, true)
+	done := make(chan *Result)
+	go func() { done <- NewCoordinator(context.Background(), context.Background(), s, New()).Play() }()
+	for i := 0; i < 3; i++ {
+		<-started
+	}
+	close(release)
+	result := <-done
+	require.EqualError(t, result.ExecutionError, "failed")
+	require.Empty(t, result.CompensateErrors)
+	require.ElementsMatch(t, []string{"a", "b", "c"}, compensated)
+
+	compensated = nil
+	s = newSaga(func(s *Saga) CompensationOrder { return StagesOrder(s, []string{"a"}) }, false)
+	NewCoordinator(context.Background(), context.Background(), s, New()).Play()
+	require.Equal(t, []string{"a", "c", "b"}, compensated)
+
+	require.Panics(t, func() { StagesOrder(s, []string{"e"}) })
+
+	// steps are compensated after their dependents, unrelated ones in the first stage
+	compensated = nil
+	s = newSaga(func(s *Saga) CompensationOrder { return DependencyOrder(s, map[string][]string{"c": {"a"}}) }, false)
+	require.Equal(t, [][]int{{3, 2, 1}, {0}}, s.CompensationOrder([]int{3, 2, 1, 0}))
+	require.Equal(t, [][]int{{3, 1, 0}}, s.CompensationOrder([]int{3, 1, 0}))
+	NewCoordinator(context.Background(), context.Background(), s, New()).Play()
+	require.Equal(t, "a", compensated[2])
+	require.Panics(t, func() { DependencyOrder(s, map[string][]string{"a": {"c"}}) })
+
+	// the limit of parallel compensations holds the next one until a running one finishes
+	compensated = nil
+	started, release = make(chan struct{}, 3), make(chan struct{})
+	s = newSaga(func(*Saga) CompensationOrder { return ParallelOrder }, true)
+	s.MaxParallelCompensations = 2
+	go func() { done <- NewCoordinator(context.Background(), context.Background(), s, New()).Play() }()
+	<-started
+	<-started
+	select {
+	case <-started:
+		t.Fatal("compensation exceeds the limit")
+	case <-time.After(10 * time.Millisecond):
+	}
+	release <- struct{}{}
+	<-started
+	close(release)
+	require.Empty(t, (<-done).CompensateErrors)
+	require.Len(t, compensated, 3)
+}
+
+func TestGuaranteedCompensation(t *testing.T) {
+	reserve, release := &mock{}, &mock{err: errors.New("unavailable")}
+	charge, refund := &mock{err: errors.New("declined")}, &mock{err: errors.New("unavailable")}
+	s := NewSaga("order")
+	require.Error(t, s.AddStep(&Step{Name: "reserve", Func: reserve.f, CompensateFunc: release.f,
+		Options: &StepOptions{CompensationRetry: &RetryPolicy{Backoff: time.Second}}}))
+	require.NoError(t, s.AddStep(&Step{Name: "reserve", Func: reserve.f, CompensateFunc: release.f,
+		Options: &StepOptions{Compensation: Guaranteed, CompensationRetry: &RetryPolicy{MaxAttempts: 3, Backoff: time.Minute}}}))
+	require.NoError(t, s.AddStep(&Step{Name: "charge", Func: charge.f, CompensateFunc: refund.f}))
+
+	store := New()
+	clock := &testClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	newCoordinator := func(opts ...Option) *ExecutionCoordinator {
+		c := NewExecutionCoordinator(context.Background(), context.Background(), s, store, opts...)
+		c.Clock = clock
+		return c
+	}
+
+	// the best-effort compensation isn't retried, the guaranteed one is retried after backoff
+	c := newCoordinator()
+	result := c.Play()
+	require.True(t, result.Delayed)
+	require.Len(t, result.CompensateErrors, 2)
+	status, err := GetStatus(store, c.ExecutionID)
+	require.NoError(t, err)
+	require.Equal(t, "compensating", status.State)
+	require.Equal(t, clock.now.Add(time.Minute), *status.FireAt)
+
+	result, err = newCoordinator(WithExecutionID(c.ExecutionID)).Resume()
+	require.NoError(t, err)
+	require.True(t, result.Delayed)
+	require.Equal(t, 1, release.callCounter)
+
+	clock.now = clock.now.Add(time.Minute)
+	result, err = newCoordinator(WithExecutionID(c.ExecutionID)).Resume()
+	require.NoError(t, err)
+	require.True(t, result.Delayed)
+	require.Equal(t, 2, release.callCounter)
+	require.Equal(t, 1, refund.callCounter)
+
+	// the execution is dead-lettered after the last attempt
+	clock.now = clock.now.Add(2 * time.Minute)
+	result, err = newCoordinator(WithExecutionID(c.ExecutionID)).Resume()
+	require.NoError(t, err)
+	require.True(t, result.DeadLettered)
+	status, err = GetStatus(store, c.ExecutionID)
+	require.NoError(t, err)
+	require.Equal(t, "dead-lettered", status.State)
+	require.Nil(t, status.FireAt)
+
+	release.err = nil
+	result, err = newCoordinator(WithExecutionID(c.ExecutionID)).Compensate()
+	require.NoError(t, err)
+	require.False(t, result.DeadLettered)
+	require.Empty(t, result.CompensateErrors)
+	require.Equal(t, 4, release.callCounter)
+	require.Equal(t, 1, refund.callCounter)
+	status, err = GetStatus(store, c.ExecutionID)
+	require.NoError(t, err)
+	require.Equal(t, "compensated", status.State)
+}
+
+func TestTimeoutPolicy(t *testing.T) {
+	hang := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	play := func(options *StepOptions) int {
+		comp := &mock{}
+		s := NewSaga("timeout")
+		options.Timeout = 10 * time.Millisecond
+		require.NoError(t, s.AddStep(&Step{Name: "hang", Func: hang, CompensateFunc: comp.f, Options: options}))
+		store := New()
+		c := NewCoordinator(context.Background(), context.Background(), s, store)
+		result := c.Play()
+		require.True(t, errors.Is(result.ExecutionError, ErrStepTimeout), result.ExecutionError)
+		logs, err := store.GetStepLogsToCompensate(c.ExecutionID)
+		require.NoError(t, err)
+		require.True(t, logs[0].StepTimedOut)
+		return comp.callCounter
+	}
+
+	require.Equal(t, 1, play(&StepOptions{}))
+	require.Equal(t, 0, play(&StepOptions{OnTimeout: NeverCompensate}))
+	require.Equal(t, 0, play(&StepOptions{OnTimeout: VerifyThenCompensate, Probe: func(context.Context) (bool, error) { return false, nil }}))
+	require.Equal(t, 1, play(&StepOptions{OnTimeout: VerifyThenCompensate, Probe: func(context.Context) (bool, error) { return true, nil }}))
+
+	// errors looking like timeouts don't make steps timed out
+	comp := &mock{}
+	s := NewSaga("timeout")
+	require.NoError(t, s.AddStep(&Step{Name: "fail", Func: func(context.Context) error { return ErrStepTimeout },
+		CompensateFunc: comp.f, Options: &StepOptions{Timeout: time.Minute, OnTimeout: NeverCompensate}}))
+	store := New()
+	c := NewCoordinator(context.Background(), context.Background(), s, store)
+	require.Equal(t, ErrStepTimeout, c.Play().ExecutionError)
+	require.Equal(t, 1, comp.callCounter)
+	logs, err := store.GetStepLogsToCompensate(c.ExecutionID)
+	require.NoError(t, err)
+	require.False(t, logs[0].StepTimedOut)
+
+	s = NewSaga("timeout")
+	require.EqualError(t, s.AddStep(&Step{Name: "hang", Func: hang, CompensateFunc: (&mock{}).f,
+		Options: &StepOptions{OnTimeout: VerifyThenCompensate}}), "verify then compensate requires probe")
+}
+
+func TestContinuation(t *testing.T) {
+	crash := true
+	var orderID string
+	fulfillment := NewSaga("fulfillment")
+	require.NoError(t, fulfillment.AddStep(&Step{
+		Name: "ship",
+		Func: func(ctx context.Context) error {
+			if crash {
+				crash = false
+				panic("crash")
+			}
+			var amount int
+			return ParentOutput(ctx, "pay", &orderID, &amount)
+		},
+		CompensateFunc: (&mock{}).f,
+	}))
+	refunded := NewSaga("refunded")
+	require.NoError(t, refunded.AddStep(&Step{Name: "notify", Func: (&mock{}).f, CompensateFunc: (&mock{}).f}))
+
+	payErr := errors.New("declined")
+	payment := NewSaga("payment")
+	require.NoError(t, payment.AddStep(&Step{
+		Name: "pay",
+		Func: func(ctx context.Context) (string, int, error) {
+			require.Equal(t, ErrNoParent, ParentOutput(ctx, "pay"))
+			return "order-1", 100, payErr
+		},
+		CompensateFunc: func(context.Context, string, int) error { return nil },
+	}))
+	payment.OnSuccess(fulfillment)
+	payment.OnCompensated(refunded)
+
+	store := New()
+	c := NewCoordinator(context.Background(), context.Background(), payment, store)
+	result := c.Play()
+	require.EqualError(t, result.ExecutionError, "declined")
+	require.NoError(t, result.Continuation.ExecutionError)
+	status, err := GetStatus(store, result.ContinuationID)
+	require.NoError(t, err)
+	require.Equal(t, "refunded", status.Name)
+	require.Equal(t, c.ExecutionID, status.Metadata[ParentExecutionMetadata])
+
+	// the parent is completed before its continuation is started, a crash of the continuation is resumed by itself
+	payErr = nil
+	c = NewCoordinator(context.Background(), context.Background(), payment, store)
+	require.Panics(t, func() { c.Play() })
+	status, err = GetStatus(store, c.ExecutionID)
+	require.NoError(t, err)
+	require.Equal(t, "completed", status.State)
+	result, err = GetResult(store, c.ExecutionID)
+	require.NoError(t, err)
+	status, err = GetStatus(store, result.ContinuationID)
+	require.NoError(t, err)
+	require.Equal(t, "running", status.State)
+	_, err = NewExecutionCoordinator(context.Background(), context.Background(), payment, store, WithExecutionID(c.ExecutionID)).Resume()
+	require.Equal(t, ErrExecutionCompleted, err)
+
+	continued, err := NewExecutionCoordinator(context.Background(), context.Background(), fulfillment, store,
+		WithExecutionID(result.ContinuationID)).Resume()
+	require.NoError(t, err)
+	require.NoError(t, continued.ExecutionError)
+	require.Equal(t, "order-1", orderID)
+
+	// the continuation recorded with the completion is started by Resume of the parent after a crash in between
+	logs, err := store.GetAllLogsByExecutionID(c.ExecutionID)
+	require.NoError(t, err)
+	require.Equal(t, LogTypeSagaContinued, logs[len(logs)-2].Type)
+	crashed := New()
+	for _, l := range logs {
+		require.NoError(t, crashed.AppendLog(l))
+	}
+	orderID = ""
+	result, err = NewExecutionCoordinator(context.Background(), context.Background(), payment, crashed, WithExecutionID(c.ExecutionID)).Resume()
+	require.NoError(t, err)
+	require.NoError(t, result.ExecutionError)
+	require.NoError(t, result.Continuation.ExecutionError)
+	require.Equal(t, "order-1", orderID)
+	status, err = GetStatus(crashed, result.ContinuationID)
+	require.NoError(t, err)
+	require.Equal(t, "fulfillment", status.Name)
+	require.Equal(t, "completed", status.State)
+	_, err = NewExecutionCoordinator(context.Background(), context.Background(), payment, crashed, WithExecutionID(c.ExecutionID)).Resume()
+	require.Equal(t, ErrExecutionCompleted, err)
+}
+
+func TestCompensateSaga(t *testing.T) {
+	var refunded string
+	notify := &mock{err: errors.New("smtp unavailable")}
+	undo := NewSaga("undo")
+	require.NoError(t, undo.AddStep(&Step{
+		Name: "refund",
+		Func: func(ctx context.Context) error {
+			return ParentOutput(ctx, "charge", &refunded)
+		},
+		CompensateFunc: (&mock{}).f,
+	}))
+	require.NoError(t, undo.AddStep(&Step{Name: "notify", Func: notify.f, CompensateFunc: (&mock{}).f,
+		Options: &StepOptions{Retry: &RetryPolicy{Backoff: time.Minute}}}))
+
+	s := NewSaga("order")
+	require.Error(t, s.AddStep(&Step{Name: "charge", Func: (&mock{}).f, CompensateFunc: (&mock{}).f, CompensateSaga: undo}))
+	require.NoError(t, s.AddStep(&Step{
+		Name:           "charge",
+		Func:           func(context.Context) (string, error) { return "payment-1", nil },
+		CompensateSaga: undo,
+		Options:        &StepOptions{Compensation: Guaranteed, CompensationRetry: &RetryPolicy{Backoff: time.Minute}},
+	}))
+	require.NoError(t, s.AddStep(&Step{Name: "ship", Func: (&mock{err: errors.New("no stock")}).f, CompensateFunc: (&mock{}).f}))
+
+	store := New()
+	clock := &testClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c := NewCoordinator(context.Background(), context.Background(), s, store)
+	c.Clock = clock
+	result := c.Play()
+	require.True(t, result.Delayed)
+	require.True(t, errors.Is(result.CompensateErrors[len(result.CompensateErrors)-1], ErrCompensationPending))
+	require.Equal(t, "payment-1", refunded)
+
+	// the next attempt of the compensation resumes the compensate saga
+	notify.err = nil
+	clock.now = clock.now.Add(time.Minute)
+	resumed := NewExecutionCoordinator(context.Background(), context.Background(), s, store, WithExecutionID(c.ExecutionID))
+	resumed.Clock = clock
+	result, err := resumed.Resume()
+	require.NoError(t, err)
+	require.False(t, result.Delayed)
+	require.Empty(t, result.CompensateErrors)
+	require.Equal(t, 2, notify.callCounter)
+
+	status, err := GetStatus(store, c.ExecutionID+"/compensate/0")
+	require.NoError(t, err)
+	require.Equal(t, "completed", status.State)
+	status, err = GetStatus(store, c.ExecutionID)
+	require.NoError(t, err)
+	require.Equal(t, "compensated", status.State)
+}
+
+func TestWithinTx(t *testing.T) {
+	store := New()
+	step := 0
+	require.EqualError(t, WithinTx(store, func(tx Store) error {
+		require.NoError(t, tx.AppendLog(&Log{ExecutionID: "1", Type: LogTypeStartSaga}))
+		return errors.New("rollback")
+	}), "rollback")
+	_, err := store.GetAllLogsByExecutionID("1")
+	require.Equal(t, ErrNoLogs, err)
+
+	require.NoError(t, WithinTx(store, func(tx Store) error {
+		require.NoError(t, tx.AppendLog(&Log{ExecutionID: "1", Type: LogTypeStartSaga}))
+		require.NoError(t, tx.AppendLog(&Log{ExecutionID: "1", Type: LogTypeSagaStepExec, StepNumber: &step}))
+		logs, err := tx.GetAllLogsByExecutionID("1")
+		require.NoError(t, err)
+		require.Len(t, logs, 2)
+		_, err = store.GetAllLogsByExecutionID("1")
+		require.Equal(t, ErrNoLogs, err)
+
+		// all reads include logs of the transaction
+		logs, err = tx.GetStepLogsToCompensate("1")
+		require.NoError(t, err)
+		require.Len(t, logs, 1)
+		logs, err = tx.GetLogs("1", []string{LogTypeStartSaga}, time.Time{}, time.Time{})
+		require.NoError(t, err)
+		require.Len(t, logs, 1)
+		logs, next, err := tx.GetLogsPage("1", Page{Limit: 1})
+		require.NoError(t, err)
+		require.Len(t, logs, 1)
+		logs, _, err = tx.GetLogsPage("1", Page{Cursor: next})
+		require.NoError(t, err)
+		require.Equal(t, LogTypeSagaStepExec, logs[0].Type)
+		statuses, _, err := tx.ListExecutions(ExecutionFilter{}, Page{})
+		require.NoError(t, err)
+		require.Len(t, statuses, 1)
+		require.Equal(t, "1", statuses[0].ExecutionID)
+		return nil
+	}))
+	logs, err := store.GetAllLogsByExecutionID("1")
+	require.NoError(t, err)
+	require.Len(t, logs, 2)
+
+	// nested transactions are part of the outer one
+	require.EqualError(t, WithinTx(store, func(tx Store) error {
+		require.NoError(t, WithinTx(tx, func(nested Store) error {
+			return nested.AppendLog(&Log{ExecutionID: "1", Type: LogTypeSagaComplete})
+		}))
+		logs, err := tx.GetAllLogsByExecutionID("1")
+		require.NoError(t, err)
+		require.Len(t, logs, 3)
+		return errors.New("rollback")
+	}), "rollback")
+	logs, err = store.GetAllLogsByExecutionID("1")
+	require.NoError(t, err)
+	require.Len(t, logs, 2)
+
+	// a failed nested transaction rolls back only its logs
+	require.NoError(t, WithinTx(store, func(tx Store) error {
+		require.NoError(t, tx.AppendLog(&Log{ExecutionID: "1", Type: LogTypeSagaDataSet}))
+		require.EqualError(t, WithinTx(tx, func(nested Store) error {
+			require.NoError(t, nested.AppendLog(&Log{ExecutionID: "1", Type: LogTypeSagaComplete}))
+			return errors.New("rollback")
+		}), "rollback")
+		return nil
+	}))
+	logs, err = store.GetAllLogsByExecutionID("1")
+	require.NoError(t, err)
+	require.Len(t, logs, 3)
+	require.Equal(t, LogTypeSagaDataSet, logs[2].Type)
+}
+
+func TestMemoryStoreConcurrency(t *testing.T) {
+	store := New()
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(executionID string) {
+			defer wg.Done()
+			for seq := 0; seq < 50; seq++ {
+				require.NoError(t, AppendLogAt(store, &Log{ExecutionID: executionID, Type: LogTypeSagaDataSet}, seq))
+			}
+		}(fmt.Sprint(i))
+	}
+	wg.Wait()
+	statuses, _, err := store.ListExecutions(ExecutionFilter{}, Page{})
+	require.NoError(t, err)
+	require.Len(t, statuses, 100)
+
+	// transactions spanning shards are appended entirely or not at all
+	err = WithinTx(store, func(tx Store) error {
+		require.NoError(t, AppendLogAt(tx, &Log{ExecutionID: "1", Type: LogTypeSagaDataSet}, 50))
+		require.NoError(t, AppendLogAt(tx, &Log{ExecutionID: "new", Type: LogTypeStartSaga}, 0))
+		return AppendLogAt(tx, &Log{ExecutionID: "2", Type: LogTypeSagaDataSet}, 49)
+	})
+	var conflict *ConflictError
+	require.True(t, errors.As(err, &conflict))
+	logs, err := store.GetAllLogsByExecutionID("1")
+	require.NoError(t, err)
+	require.Len(t, logs, 50)
+	_, err = store.GetAllLogsByExecutionID("new")
+	require.Equal(t, ErrNoLogs, err)
+}
+
+func TestSharedSaga(t *testing.T) {
+	s := NewSaga("order")
+	require.NoError(t, s.AddStep(&Step{
+		Name:           "reserve",
+		Func:           func(ctx context.Context) (int, error) { return 1, SetData(ctx, "reservation", "r1") },
+		CompensateFunc: func(context.Context, int) error { return nil },
+	}))
+	// funcs of the shared saga are called concurrently, so they count compensations atomically
+	var compensated int32
+	require.NoError(t, s.AddStep(&Step{Name: "charge", Func: func(context.Context) error { return errors.New("declined") },
+		CompensateFunc: func(context.Context) error { atomic.AddInt32(&compensated, 1); return nil }}))
+
+	store := New()
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c := NewCoordinator(context.Background(), context.Background(), s, store)
+			result := c.Play()
+			require.EqualError(t, result.ExecutionError, "declined")
+			require.Empty(t, result.CompensateErrors)
+			report, err := Replay(store, c.ExecutionID, s)
+			require.NoError(t, err)
+			require.Empty(t, report.Divergences)
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, int32(20), atomic.LoadInt32(&compensated))
+	require.Len(t, s.Steps(), 2)
+}
+
+func BenchmarkPlay(b *testing.B) {
+	s := NewSaga("order")
+	for i := 0; i < 10; i++ {
+		if err := s.AddStep(&Step{
+			Name:           fmt.Sprint("step", i),
+			Func:           func(context.Context) (int, error) { return 1, nil },
+			CompensateFunc: func(context.Context, int) error { return nil },
+		}); err != nil {
+			b.Fatal(err)
+		}
+	}
+	store := New()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := NewCoordinator(context.Background(), context.Background(), s, store).Play().ExecutionError; err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkAbort(b *testing.B) {
+	s := NewSaga("batch")
+	for i := 0; i < 1000; i++ {
+		if err := s.AddStep(&Step{
+			Name:           fmt.Sprint("item", i),
+			Func:           func(context.Context) (int, error) { return 1, nil },
+			CompensateFunc: func(context.Context, int) error { return nil },
+		}); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if err := s.AddStep(&Step{Name: "commit", Func: func(context.Context) error { return errors.New("failed") }, CompensateFunc: (&mock{}).f}); err != nil {
+		b.Fatal(err)
+	}
+	store := New()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if result := NewCoordinator(context.Background(), context.Background(), s, store).Play(); result.ExecutionError == nil {
+			b.Fatal("execution hasn't failed")
+		}
+	}
+}
+
+type storeCall struct {
+	method      string
+	err         error
+	payloadSize int
+}
+
+type testMetrics struct {
+	mu    sync.Mutex
+	calls []storeCall
+}
+
+func (m *testMetrics) ObserveStoreCall(method string, latency time.Duration, err error, payloadSize int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, storeCall{method: method, err: err, payloadSize: payloadSize})
+}
+
+func TestLogSync(t *testing.T) {
+	play := func(sync LogSync) (batches, appends, written int) {
+		metrics := &testMetrics{}
+		store := Instrumented(New(), metrics)
+		s := NewSaga("order")
+		s.LogSync = sync
+		require.NoError(t, s.AddStep(&Step{Name: "reserve", Func: func(ctx context.Context) error {
+			if err := SetData(ctx, "reservation", "r1"); err != nil {
+				return err
+			}
+			return SetData(ctx, "warehouse", "w1")
+		}, CompensateFunc: (&mock{}).f}))
+		require.NoError(t, s.AddStep(&Step{Name: "charge", Func: (&mock{}).f, CompensateFunc: (&mock{}).f}))
+		hooks := Hooks{AfterStep: func(executionID string, result StepResult) {
+			// the exec log of the step is written before hooks only by SyncEachLog and SyncStrict
+			if result.Name == "reserve" {
+				// nothing may be written yet with SyncPipelined
+				logs, _ := store.GetAllLogsByExecutionID(executionID)
+				written = len(logs)
+			}
+		}}
+		c := NewExecutionCoordinator(context.Background(), context.Background(), s, store, WithHooks(hooks))
+		require.NoError(t, c.Play().ExecutionError)
+		logs, err := store.GetAllLogsByExecutionID(c.ExecutionID)
+		require.NoError(t, err)
+		require.Len(t, logs, 6)
+		for _, call := range metrics.calls {
+			switch call.method {
+			case "WithinTx":
+				batches++
+			case "AppendLog", "AppendLogAt":
+				appends++
+			}
+		}
+		return batches, appends, written
+	}
+
+	batches, appends, written := play(SyncEachLog)
+	require.Equal(t, 0, batches)
+	require.Equal(t, 6, appends)
+	require.Equal(t, 4, written)
+	batches, appends, written = play(SyncAtSteps)
+	require.Equal(t, 4, batches)
+	require.Equal(t, 6, appends)
+	require.Equal(t, 1, written)
+	batches, _, written = play(SyncStrict)
+	require.Equal(t, 4, batches)
+	require.Equal(t, 4, written)
+	batches, appends, _ = play(SyncPipelined)
+	require.Equal(t, 4, batches)
+	require.Equal(t, 6, appends)
+
+	// the exec log of reserve is written while charge is called
+	store := &gatedStore{Store: New(), gate: make(chan struct{})}
+	s := NewSaga("order")
+	s.LogSync = SyncPipelined
+	require.NoError(t, s.AddStep(&Step{Name: "reserve", Func: (&mock{}).f, CompensateFunc: (&mock{}).f}))
+	require.NoError(t, s.AddStep(&Step{Name: "charge", Func: func(context.Context) error {
+		close(store.gate)
+		return nil
+	}, CompensateFunc: (&mock{}).f}))
+	c := NewCoordinator(context.Background(), context.Background(), s, store)
+	require.NoError(t, c.Play().ExecutionError)
+	logs, err := store.GetAllLogsByExecutionID(c.ExecutionID)
+	require.NoError(t, err)
+	require.Len(t, logs, 4)
+	require.Equal(t, LogTypeSagaComplete, logs[3].Type)
+}
+
+// rollbackStore rolls back transactions appending logs of the type.
+type rollbackStore struct {
+	Store
+	typ string
+}
+
+func (s *rollbackStore) WithinTx(fn func(tx Store) error) error {
+	return WithinTx(s.Store, func(tx Store) error {
+		watched := &typeWatcher{Store: tx, typ: s.typ}
+		if err := fn(watched); err != nil {
+			return err
+		}
+		if watched.seen {
+			return errors.New("rollback")
+		}
+		return nil
+	})
+}
+
+type typeWatcher struct {
+	Store
+	typ  string
+	seen bool
+}
+
+func (s *typeWatcher) AppendLog(l *Log) error {
+	s.seen = s.seen || l.Type == s.typ
+	return s.Store.AppendLog(l)
+}
+
+func (s *typeWatcher) AppendLogAt(l *Log, expected int) error {
+	s.seen = s.seen || l.Type == s.typ
+	return AppendLogAt(s.Store, l, expected)
+}
+
+func TestLogSyncWithinTx(t *testing.T) {
+	s := NewSaga("order")
+	s.LogSync = SyncAtSteps
+	require.NoError(t, s.AddStep(&Step{Name: "reserve", Func: (&mock{}).f, CompensateFunc: (&mock{}).f}))
+	require.NoError(t, s.AddStep(&Step{Name: "charge", Func: func(context.Context) error { return errors.New("declined") },
+		CompensateFunc: (&mock{}).f, Options: &StepOptions{Retry: &RetryPolicy{MaxAttempts: 2, Backoff: time.Minute}}}))
+
+	// the failed exec log of charge is committed only along with its retry
+	backend := New()
+	c := NewCoordinator(context.Background(), context.Background(), s, &rollbackStore{Store: backend, typ: LogTypeSagaStepRetryScheduled})
+	require.Panics(t, func() { c.Play() })
+	logs, err := backend.GetAllLogsByExecutionID(c.ExecutionID)
+	require.NoError(t, err)
+	for _, l := range logs {
+		require.Nil(t, l.StepError, "the failed exec log is committed without its retry")
+	}
+
+	c = NewCoordinator(context.Background(), context.Background(), s, backend)
+	result := c.Play()
+	require.True(t, result.Delayed)
+	logs, err = backend.GetAllLogsByExecutionID(c.ExecutionID)
+	require.NoError(t, err)
+	require.Equal(t, LogTypeSagaStepExec, logs[len(logs)-2].Type)
+	require.NotNil(t, logs[len(logs)-2].StepError)
+	require.Equal(t, LogTypeSagaStepRetryScheduled, logs[len(logs)-1].Type)
+}
+
+type gatedStore struct {
+	Store
+	gate chan struct{}
+}
+
+func (s *gatedStore) AppendLog(l *Log) error {
+	if l.Type == LogTypeSagaStepExec && *l.StepName == "reserve" {
+		select {
+		case <-s.gate:
+		case <-time.After(time.Second):
+			return errors.New("exec log isn't written in the background")
+		}
+	}
+	return s.Store.AppendLog(l)
+}
+
+func TestInstrumented(t *testing.T) {
+	metrics := &testMetrics{}
+	store := Instrumented(New(), metrics)
+	step := 0
+	require.NoError(t, store.AppendLog(&Log{ExecutionID: "1", Type: LogTypeSagaStepExec, StepNumber: &step, StepPayload: []byte(`[1]`)}))
+	_, err := store.GetAllLogsByExecutionID("1")
+	require.NoError(t, err)
+	_, err = store.GetAllLogsByExecutionID("2")
+	require.Equal(t, ErrNoLogs, err)
+	require.NoError(t, WithinTx(store, func(tx Store) error {
+		return tx.AppendLog(&Log{ExecutionID: "1", Type: LogTypeSagaComplete})
+	}))
+
+	require.Equal(t, []storeCall{
+		{method: "AppendLog", payloadSize: 3},
+		{method: "GetAllLogsByExecutionID", payloadSize: 3},
+		{method: "GetAllLogsByExecutionID", err: ErrNoLogs},
+		{method: "AppendLog"},
+		{method: "WithinTx"},
+	}, metrics.calls)
+}
+
+type flakyStore struct {
+	Store
+	mu  sync.Mutex
+	err error
+}
+
+func (s *flakyStore) AppendLog(log *Log) error {
+	s.mu.Lock()
+	err := s.err
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return s.Store.AppendLog(log)
+}
+
+func TestReplicatingStore(t *testing.T) {
+	secondary := &flakyStore{Store: New(), err: errors.New("unreachable")}
+	clock := &testClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	store := NewReplicatingStore(New(), secondary)
+	store.RetryInterval = time.Millisecond
+	store.Clock = clock
+	errs := make(chan error, 100)
+	store.OnError = func(err error) {
+		select {
+		case errs <- err:
+		default:
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go store.Run(ctx)
+
+	s := NewSaga("replicated")
+	require.NoError(t, s.AddStep(&Step{Name: "first", Func: (&mock{}).f, CompensateFunc: (&mock{}).f}))
+	c := NewCoordinator(context.Background(), context.Background(), s, store)
+	require.NoError(t, c.Play().ExecutionError)
+	require.EqualError(t, <-errs, "unreachable")
+	clock.now = clock.now.Add(time.Minute)
+	lag := store.Lag()
+	require.True(t, lag.Pending > 0)
+	require.Equal(t, time.Minute, lag.Oldest)
+	_, err := secondary.GetAllLogsByExecutionID(c.ExecutionID)
+	require.Equal(t, ErrNoLogs, err)
+
+	// the secondary catches up when it's reachable again
+	secondary.mu.Lock()
+	secondary.err = nil
+	secondary.mu.Unlock()
+	deadline := time.Now().Add(5 * time.Second)
+	for store.Lag().Pending > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	require.Equal(t, 0, store.Lag().Pending)
+	primaryLogs, err := store.GetAllLogsByExecutionID(c.ExecutionID)
+	require.NoError(t, err)
+	secondaryLogs, err := secondary.GetAllLogsByExecutionID(c.ExecutionID)
+	require.NoError(t, err)
+	require.Equal(t, primaryLogs, secondaryLogs)
+}
+
+func TestAppendConflict(t *testing.T) {
+	s := NewSaga("approval")
+	require.NoError(t, s.AddStep(&Step{Name: "first", Func: (&mock{}).f, CompensateFunc: (&mock{}).f, Options: &StepOptions{RequireApproval: true}}))
+	store := New()
+	c := NewCoordinator(context.Background(), context.Background(), s, store)
+	require.True(t, c.Play().Paused)
+
+	// both coordinators believe they own the execution, the one that appends later loses
+	stale := NewExecutionCoordinator(context.Background(), context.Background(), s, store, WithExecutionID(c.ExecutionID))
+	_, err := stale.loadProgress()
+	require.NoError(t, err)
+	_, err = NewExecutionCoordinator(context.Background(), context.Background(), s, store, WithExecutionID(c.ExecutionID)).Approve()
+	require.NoError(t, err)
+	logs, err := store.GetAllLogsByExecutionID(c.ExecutionID)
+	require.NoError(t, err)
+
+	defer func() {
+		conflict, ok := recover().(*ConflictError)
+		require.True(t, ok)
+		require.Equal(t, len(logs), conflict.Actual)
+		after, err := store.GetAllLogsByExecutionID(c.ExecutionID)
+		require.NoError(t, err)
+		require.Equal(t, logs, after)
+	}()
+	step := 0
+	stale.appendLog(&Log{Type: LogTypeSagaStepApproved, StepNumber: &step})
+}
+
+func TestExportImport(t *testing.T) {
+	s := NewSaga("order")
+	require.NoError(t, s.AddStep(&Step{Name: "first", Func: func(context.Context) (string, error) { return "payload", nil },
+		CompensateFunc: func(context.Context, string) error { return nil }}))
+	require.NoError(t, s.AddStep(&Step{Name: "second", Func: (&mock{err: errors.New("failed")}).f, CompensateFunc: (&mock{}).f}))
+	source := New()
+	var ids []string
+	for i := 0; i < 3; i++ {
+		c := NewCoordinator(context.Background(), context.Background(), s, source)
+		c.Play()
+		ids = append(ids, c.ExecutionID)
+	}
+	other := NewSaga("other")
+	require.NoError(t, other.AddStep(&Step{Name: "first", Func: (&mock{}).f, CompensateFunc: (&mock{}).f}))
+	NewCoordinator(context.Background(), context.Background(), other, source).Play()
+
+	var buf bytes.Buffer
+	exported, err := Export(source, ExecutionFilter{Name: "order"}, &buf)
+	require.NoError(t, err)
+	require.Equal(t, 3, exported)
+
+	target := New()
+	imported, err := Import(target, bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, 3, imported)
+	for _, id := range ids {
+		expected, err := source.GetAllLogsByExecutionID(id)
+		require.NoError(t, err)
+		actual, err := target.GetAllLogsByExecutionID(id)
+		require.NoError(t, err)
+		require.Len(t, actual, len(expected))
+		for i := range expected {
+			require.True(t, expected[i].Time.Equal(actual[i].Time))
+			e, a := *expected[i], *actual[i]
+			e.Time, a.Time = time.Time{}, time.Time{}
+			require.Equal(t, e, a)
+		}
+	}
+
+	_, err = Import(target, bytes.NewReader(buf.Bytes()))
+	require.True(t, errors.Is(err, ErrExecutionExists), err)
+}
+
+func TestContextStore(t *testing.T) {
+	store := New()
+	cs := WithContext(store)
+	require.True(t, BindContext(cs, context.Background()) == Store(store))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	bound := BindContext(struct{ ContextStore }{cs}, ctx)
+	s := NewSaga("order")
+	require.NoError(t, s.AddStep(&Step{Name: "first", Func: (&mock{}).f, CompensateFunc: (&mock{}).f}))
+	c := NewCoordinator(context.Background(), context.Background(), s, bound)
+	require.NoError(t, c.Play().ExecutionError)
+	logs, err := cs.GetAllLogsByExecutionID(ctx, c.ExecutionID)
+	require.NoError(t, err)
+	require.Len(t, logs, 3)
+
+	cancel()
+	_, err = bound.GetAllLogsByExecutionID(c.ExecutionID)
+	require.Equal(t, context.Canceled, err)
+	require.Equal(t, context.Canceled, bound.AppendLog(&Log{ExecutionID: c.ExecutionID, Type: LogTypeSagaComplete}))
+}
+
+func TestResultSteps(t *testing.T) {
+	clock := &testClock{now: time.Now()}
+	s := NewSaga("order")
+	require.NoError(t, s.AddStep(&Step{Name: "first", Func: func(context.Context) error { clock.now = clock.now.Add(time.Second); return nil },
+		CompensateFunc: (&mock{}).f}))
+	require.NoError(t, s.AddStep(&Step{Name: "second", Func: (&mock{err: errors.New("failed")}).f, CompensateFunc: (&mock{}).f,
+		Options: &StepOptions{Retry: &RetryPolicy{MaxAttempts: 2, Backoff: time.Minute}}}))
+	require.NoError(t, s.AddStep(&Step{Name: "third", Func: (&mock{}).f, CompensateFunc: (&mock{}).f}))
+	store := New()
+	c := NewCoordinator(context.Background(), context.Background(), s, store)
+	c.Clock = clock
+
+	result := c.Play()
+	require.True(t, result.Delayed)
+	require.Equal(t, []StepResult{
+		{Name: "first", Status: "succeeded", Attempts: 1, Duration: time.Second},
+		{Name: "second", Status: "failed", Attempts: 1, Error: errors.New("failed")},
+		{Name: "third", Status: "pending"},
+	}, result.Steps)
+
+	clock.now = clock.now.Add(time.Minute)
+	resumed := NewExecutionCoordinator(context.Background(), context.Background(), s, store, WithExecutionID(c.ExecutionID))
+	resumed.Clock = clock
+	result, err := resumed.Resume()
+	require.NoError(t, err)
+	require.Error(t, result.ExecutionError)
+	require.Equal(t, []StepResult{
+		{Name: "first", Status: "succeeded", Attempts: 1, Duration: time.Second, Compensated: true, CompensationAttempts: 1},
+		{Name: "second", Status: "failed", Attempts: 2, Error: errors.New("failed"), Compensated: true, CompensationAttempts: 1},
+		{Name: "third", Status: "pending"},
+	}, result.Steps)
+}
+
+func TestState(t *testing.T) {
+	s := NewSaga("approval")
+	require.NoError(t, s.AddStep(&Step{Name: "first", Func: (&mock{}).f, CompensateFunc: (&mock{}).f, Options: &StepOptions{RequireApproval: true}}))
+	require.NoError(t, s.AddStep(&Step{Name: "second", Func: (&mock{err: errors.New("failed")}).f, CompensateFunc: (&mock{}).f}))
+	store := New()
+	c := NewCoordinator(context.Background(), context.Background(), s, store)
+	require.Equal(t, StatePending, c.State())
+	require.True(t, c.Play().Paused)
+	require.Equal(t, StatePaused, c.State())
+
+	approver := NewExecutionCoordinator(context.Background(), context.Background(), s, store, WithExecutionID(c.ExecutionID))
+	_, err := approver.Approve()
+	require.NoError(t, err)
+	require.Equal(t, StateCompensated, approver.State())
+
+	logs, err := store.GetAllLogsByExecutionID(c.ExecutionID)
+	require.NoError(t, err)
+	var states []State
+	for _, l := range logs {
+		if len(states) == 0 || states[len(states)-1] != l.State {
+			states = append(states, l.State)
+		}
+	}
+	require.Equal(t, []State{StateRunning, StatePaused, StateRunning, StateFailed, StateCompensating, StateCompensated}, states)
+}
+
+func TestResultJSON(t *testing.T) {
+	result := &Result{
+		ExecutionError:   errors.New("failed"),
+		CompensateErrors: []error{errors.New("refund failed")},
+		Deferred:         true,
+		Steps: []StepResult{
+			{Name: "first", Status: "succeeded", Attempts: 1, Duration: 1500 * time.Millisecond, Compensated: true},
+			{Name: "second", Status: "failed", Attempts: 2, Error: errors.New("failed")},
+		},
+	}
+	data, err := json.Marshal(result)
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"executionError": "failed",
+		"compensateErrors": ["refund failed"],
+		"deferred": true,
+		"steps": [
+			{"name": "first", "status": "succeeded", "attempts": 1, "durationMs": 1500, "compensated": true},
+			{"name": "second", "status": "failed", "attempts": 2, "durationMs": 0, "error": "failed"}
+		]
+	}`, string(data))
+
+	var decoded Result
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	require.Equal(t, result, &decoded)
+}
+
+func TestOnComplete(t *testing.T) {
+	s := NewSaga("order")
+	require.NoError(t, s.AddStep(&Step{Name: "first", Func: (&mock{}).f, CompensateFunc: (&mock{}).f}))
+	require.NoError(t, s.AddStep(&Step{Name: "second", Func: (&mock{err: errors.New("failed")}).f, CompensateFunc: (&mock{}).f,
+		Options: &StepOptions{Retry: &RetryPolicy{MaxAttempts: 2, Backoff: time.Minute}}}))
+	store := New()
+	clock := &testClock{now: time.Now()}
+	var results []*Result
+	newCoordinator := func(opts ...Option) *ExecutionCoordinator {
+		c := NewExecutionCoordinator(context.Background(), context.Background(), s, store, opts...)
+		c.Clock = clock
+		c.OnComplete(func(result *Result) { results = append(results, result) })
+		return c
+	}
+
+	c := newCoordinator()
+	require.True(t, c.Play().Delayed)
+	require.Empty(t, results)
+
+	clock.now = clock.now.Add(time.Minute)
+	result, err := newCoordinator(WithExecutionID(c.ExecutionID)).Resume()
+	require.NoError(t, err)
+	require.Equal(t, []*Result{result}, results)
+
+	_, err = newCoordinator(WithExecutionID(c.ExecutionID)).Resume()
+	require.Equal(t, ErrExecutionCompleted, err)
+	require.Len(t, results, 1)
+}
+
+func TestPlayAsync(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	third, compensate := &mock{}, &mock{}
+	s := NewSaga("order")
+	require.NoError(t, s.AddStep(&Step{Name: "first", Func: (&mock{}).f, CompensateFunc: compensate.f}))
+	require.NoError(t, s.AddStep(&Step{Name: "second", Func: func(context.Context) error {
+		close(started)
+		<-release
+		return nil
+	}, CompensateFunc: compensate.f}))
+	require.NoError(t, s.AddStep(&Step{Name: "third", Func: third.f, CompensateFunc: (&mock{}).f}))
+	store := New()
+
+	h := NewCoordinator(context.Background(), context.Background(), s, store).PlayAsync()
+	<-started
+	status, err := h.Status()
+	require.NoError(t, err)
+	require.Equal(t, "running", status.State)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	_, err = h.Wait(ctx)
+	require.Equal(t, context.DeadlineExceeded, err)
+
+	h.Cancel()
+	close(release)
+	result, err := h.Wait(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, ErrCanceled, result.ExecutionError)
+	require.Equal(t, 0, third.callCounter)
+	require.Equal(t, 2, compensate.callCounter)
+	status, err = h.Status()
+	require.NoError(t, err)
+	require.Equal(t, "compensated", status.State)
+
+	// the handle of a duplicate has its ID
+	first := NewExecutionCoordinator(context.Background(), context.Background(), s, store, WithDedupKey("order-123", time.Hour))
+	release = make(chan struct{})
+	close(release)
+	started = make(chan struct{})
+	require.NoError(t, first.Play().ExecutionError)
+	h = NewExecutionCoordinator(context.Background(), context.Background(), s, store, WithDedupKey("order-123", time.Hour)).PlayAsync()
+	require.Equal(t, first.ExecutionID, h.ExecutionID)
+	result, err = h.Wait(context.Background())
+	require.NoError(t, err)
+	require.True(t, result.Duplicate)
+
+	// steps after the pivot aren't canceled
+	started, release = make(chan struct{}), make(chan struct{})
+	var packErr error
+	ship := &mock{}
+	s = NewSaga("order")
+	require.NoError(t, s.AddStep(&Step{Name: "charge", Func: (&mock{}).f, CompensateFunc: compensate.f, Pivot: true}))
+	require.NoError(t, s.AddStep(&Step{Name: "pack", Func: func(ctx context.Context) error {
+		close(started)
+		<-release
+		packErr = ctx.Err()
+		return nil
+	}, Retriable: true}))
+	require.NoError(t, s.AddStep(&Step{Name: "ship", Func: ship.f, Retriable: true}))
+	h = NewCoordinator(context.Background(), context.Background(), s, store).PlayAsync()
+	<-started
+	h.Cancel()
+	close(release)
+	result, err = h.Wait(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, result.ExecutionError)
+	require.NoError(t, packErr)
+	require.Equal(t, 1, ship.callCounter)
+}
+
+func TestStreamStepResults(t *testing.T) {
+	s := NewSaga("order")
+	require.NoError(t, s.AddStep(&Step{Name: "first", Func: (&mock{}).f, CompensateFunc: (&mock{}).f}))
+	require.NoError(t, s.AddStep(&Step{Name: "second", Func: (&mock{err: errors.New("failed")}).f, CompensateFunc: (&mock{}).f}))
+	c := NewCoordinator(context.Background(), context.Background(), s, New())
+	results := c.StreamStepResults(context.Background())
+	require.Error(t, c.Play().ExecutionError)
+
+	var streamed []StepResult
+	for result := range results {
+		streamed = append(streamed, result)
+	}
+	require.Len(t, streamed, 4)
+	require.Equal(t, "first", streamed[0].Name)
+	require.Equal(t, "succeeded", streamed[0].Status)
+	require.Equal(t, "second", streamed[1].Name)
+	require.Equal(t, "failed", streamed[1].Status)
+	require.True(t, streamed[2].Compensated)
+	require.True(t, streamed[3].Compensated)
+}
+
+func TestPostmortem(t *testing.T) {
+	s := NewSaga("order")
+	require.NoError(t, s.AddStep(&Step{Name: "reserve", Func: (&mock{}).f, CompensateFunc: (&mock{}).f}))
+	require.NoError(t, s.AddStep(&Step{Name: "charge", Func: (&mock{}).f, CompensateFunc: (&mock{err: errors.New("refund failed")}).f}))
+	require.NoError(t, s.AddStep(&Step{Name: "ship", Func: (&mock{err: errors.New("out of stock")}).f, CompensateFunc: (&mock{}).f}))
+	store := New()
+	c := NewCoordinator(context.Background(), context.Background(), s, store)
+	require.Error(t, c.Play().ExecutionError)
+
+	postmortem, err := NewPostmortem(store, c.ExecutionID)
+	require.NoError(t, err)
+	require.Len(t, postmortem.Ran, 3)
+	require.Len(t, postmortem.Failed, 1)
+	require.Equal(t, "ship", postmortem.Failed[0].Name)
+	require.Len(t, postmortem.Undone, 2)
+	require.Len(t, postmortem.Manual, 1)
+	require.Equal(t, "charge", postmortem.Manual[0].Step.Name)
+	require.Equal(t, "compensation failed: refund failed", postmortem.Manual[0].Reason)
+
+	var markdown, html bytes.Buffer
+	require.NoError(t, postmortem.WriteMarkdown(&markdown))
+	require.Contains(t, markdown.String(), "## What remains manual\n\n- charge: compensation failed: refund failed\n")
+	require.NoError(t, postmortem.WriteHTML(&html))
+	require.Contains(t, html.String(), "<li>charge: compensation failed: refund failed</li>")
+}
+
+func TestResultCompensationOutcome(t *testing.T) {
+	refund := &mock{err: errors.New("refund failed")}
+	s := NewSaga("order")
+	require.NoError(t, s.AddStep(&Step{Name: "charge", Func: (&mock{}).f, CompensateFunc: refund.f,
+		Options: &StepOptions{Compensation: Guaranteed, CompensationRetry: &RetryPolicy{Backoff: time.Minute}}}))
+	require.NoError(t, s.AddStep(&Step{Name: "ship", Func: (&mock{err: errors.New("out of stock")}).f, CompensateFunc: (&mock{}).f}))
+	store := New()
+	clock := &testClock{now: time.Now()}
+	c := NewCoordinator(context.Background(), context.Background(), s, store)
+	c.Clock = clock
+
+	result := c.Play()
+	require.True(t, result.Delayed)
+	require.False(t, result.Steps[0].Compensated)
+	require.Equal(t, 1, result.Steps[0].CompensationAttempts)
+	require.Equal(t, errors.New("refund failed"), result.Steps[0].CompensationError)
+
+	refund.err = nil
+	clock.now = clock.now.Add(time.Minute)
+	resumed := NewExecutionCoordinator(context.Background(), context.Background(), s, store, WithExecutionID(c.ExecutionID))
+	resumed.Clock = clock
+	result, err := resumed.Resume()
+	require.NoError(t, err)
+	require.True(t, result.Steps[0].Compensated)
+	require.Equal(t, 2, result.Steps[0].CompensationAttempts)
+	require.NoError(t, result.Steps[0].CompensationError)
+}
+
+func TestDataBag(t *testing.T) {
+	s := NewSaga("order")
+	require.NoError(t, s.AddStep(&Step{Name: "first", Func: func(ctx context.Context) error {
+		return SetData(ctx, "orderID", 42)
+	}, CompensateFunc: (&mock{}).f}))
+	require.NoError(t, s.AddStep(&Step{Name: "second", Func: func(ctx context.Context) error {
+		var orderID int
+		if err := GetData(ctx, "orderID", &orderID); err != nil {
+			return err
+		}
+		require.True(t, errors.Is(GetData(ctx, "missing", &orderID), ErrNoData))
+		return SetData(ctx, "invoice", fmt.Sprintf("INV-%d", orderID))
+	}, CompensateFunc: (&mock{}).f}))
+	store := New()
+	c := NewCoordinator(context.Background(), context.Background(), s, store)
+	result := c.Play()
+	require.NoError(t, result.ExecutionError)
+	require.Equal(t, map[string]json.RawMessage{"orderID": json.RawMessage("42"), "invoice": json.RawMessage(`"INV-42"`)}, result.Data)
+
+	logs, err := store.GetAllLogsByExecutionID(c.ExecutionID)
+	require.NoError(t, err)
+	complete := logs[len(logs)-1]
+	require.Equal(t, LogTypeSagaComplete, complete.Type)
+	require.JSONEq(t, `{"orderID": 42, "invoice": "INV-42"}`, string(complete.StepPayload))
+	require.True(t, errors.Is(GetData(context.Background(), "orderID", new(int)), ErrNotInStep))
+}
+
+func TestGetResult(t *testing.T) {
+	s := NewSaga("order")
+	require.NoError(t, s.AddStep(&Step{Name: "first", Func: func(ctx context.Context) error { return SetData(ctx, "total", 10) },
+		CompensateFunc: (&mock{err: errors.New("refund failed")}).f}))
+	require.NoError(t, s.AddStep(&Step{Name: "second", Func: (&mock{}).f, CompensateFunc: (&mock{}).f}))
+	store := New()
+	clock := &testClock{now: time.Now()}
+
+	c := NewCoordinator(context.Background(), context.Background(), s, store)
+	c.Clock = clock
+	played := c.Play()
+	result, err := GetResult(store, c.ExecutionID)
+	require.NoError(t, err)
+	require.Equal(t, played, result)
+
+	failing := NewSaga("failing")
+	require.NoError(t, failing.AddStep(s.Steps()[0]))
+	require.NoError(t, failing.AddStep(&Step{Name: "second", Func: (&mock{err: errors.New("failed")}).f, CompensateFunc: (&mock{}).f}))
+	c = NewCoordinator(context.Background(), context.Background(), failing, store)
+	c.Clock = clock
+	played = c.Play()
+	require.Len(t, played.CompensateErrors, 1)
+	result, err = GetResult(store, c.ExecutionID)
+	require.NoError(t, err)
+	require.Equal(t, played.ExecutionError, result.ExecutionError)
+	require.Equal(t, played.CompensateErrors[0].Error(), result.CompensateErrors[0].Error())
+	require.Equal(t, played.Steps, result.Steps)
+	require.Equal(t, played.Data, result.Data)
+	// the live execution, statuses and results share the fold of logs
+	logs, err := store.GetAllLogsByExecutionID(c.ExecutionID)
+	require.NoError(t, err)
+	require.Equal(t, played.Steps, foldProgress(failing, logs).steps)
+	status, err := GetStatus(store, c.ExecutionID)
+	require.NoError(t, err)
+	require.Equal(t, string(logs[len(logs)-1].State), status.State)
+
+	_, err = GetResult(store, "missing")
+	require.Equal(t, ErrNoLogs, err)
+}
+
+type testLogger struct{ lines []string }
+
+func (l *testLogger) Printf(format string, v ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(format, v...))
+}
+
+func TestCoordinatorOptions(t *testing.T) {
+	s := NewSaga("order")
+	require.NoError(t, s.AddStep(&Step{Name: "first", Func: (&mock{}).f, CompensateFunc: (&mock{}).f}))
+	require.NoError(t, s.AddStep(&Step{Name: "second", Func: (&mock{err: errors.New("failed")}).f, CompensateFunc: (&mock{}).f}))
+	store := New()
+	clock := &testClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	logger := &testLogger{}
+	var events []string
+	c := NewExecutionCoordinator(context.Background(), context.Background(), s, nil,
+		WithStore(store),
+		WithClock(clock),
+		WithLogger(logger),
+		WithHooks(Hooks{
+			BeforeStep:      func(_ string, step *Step) { events = append(events, "before "+step.Name) },
+			AfterStep:       func(_ string, result StepResult) { events = append(events, "after "+result.Name+" "+result.Status) },
+			AfterCompensate: func(_ string, result StepResult) { events = append(events, "compensated "+result.Name) },
+		}))
+	require.Error(t, c.Play().ExecutionError)
+
+	require.Equal(t, []string{"before first", "after first succeeded", "before second", "after second failed",
+		"compensated second", "compensated first"}, events)
+	require.Equal(t, []string{fmt.Sprintf("saga order: execution %s: step second failed: failed", c.ExecutionID)}, logger.lines)
+	logs, err := store.GetAllLogsByExecutionID(c.ExecutionID)
+	require.NoError(t, err)
+	require.Equal(t, clock.now, logs[0].Time)
+}
+
+func TestStepOptionsValidation(t *testing.T) {
+	for _, options := range []*StepOptions{
+		{CompensationTimeout: -time.Second},
+		{NoCompensation: true},
+		{Critical: true, Retry: &RetryPolicy{Backoff: time.Second}},
+		{Tags: map[string]string{"": "payments"}},
+	} {
+		require.Error(t, NewSaga("s").AddStep(&Step{Name: "first", Func: (&mock{}).f, CompensateFunc: (&mock{}).f, Options: options}))
+	}
+	require.NoError(t, NewSaga("s").AddStep(&Step{Name: "read", Func: (&mock{}).f, Options: &StepOptions{NoCompensation: true}}))
+
+	s := NewSaga("s")
+	require.NoError(t, s.AddStep(&Step{Name: "pivot", Func: (&mock{}).f, CompensateFunc: (&mock{}).f, Pivot: true}))
+	require.Error(t, s.AddStep(&Step{Name: "after", Func: (&mock{}).f, CompensateFunc: (&mock{}).f, Options: &StepOptions{Critical: true}}))
+}
+
+func TestCriticalStep(t *testing.T) {
+	failing := &mock{err: errors.New("failed")}
+	s := NewSaga("order")
+	s.CompensationGrace = time.Hour
+	require.NoError(t, s.AddStep(&Step{Name: "first", Func: (&mock{}).f, CompensateFunc: (&mock{}).f}))
+	require.NoError(t, s.AddStep(&Step{Name: "second", Func: failing.f, CompensateFunc: (&mock{}).f, Options: &StepOptions{Critical: true}}))
+	result := NewCoordinator(context.Background(), context.Background(), s, New()).Play()
+	require.False(t, result.Deferred)
+	require.True(t, result.Steps[0].Compensated)
+}
+
+func TestNoCompensationAndCondition(t *testing.T) {
+	read, skipped := &mock{}, &mock{}
+	s := NewSaga("order")
+	require.NoError(t, s.AddStep(&Step{Name: "read", Func: func(ctx context.Context) error {
+		read.callCounter++
+		return SetData(ctx, "express", false)
+	}, Options: &StepOptions{NoCompensation: true}}))
+	require.NoError(t, s.AddStep(&Step{Name: "express", Func: skipped.f, CompensateFunc: (&mock{}).f, Options: &StepOptions{
+		Condition: func(ctx context.Context) bool {
+			var express bool
+			return GetData(ctx, "express", &express) == nil && express
+		},
+	}}))
+	require.NoError(t, s.AddStep(&Step{Name: "ship", Func: (&mock{err: errors.New("failed")}).f, CompensateFunc: (&mock{}).f}))
+	result := NewCoordinator(context.Background(), context.Background(), s, New()).Play()
+	require.Error(t, result.ExecutionError)
+	require.Equal(t, 1, read.callCounter)
+	require.Equal(t, 0, skipped.callCounter)
+	require.Equal(t, "skipped", result.Steps[1].Status)
+	require.False(t, result.Steps[0].Compensated)
+	require.False(t, result.Steps[1].Compensated)
+	require.True(t, result.Steps[2].Compensated)
+}
+
+func TestCompensationTimeout(t *testing.T) {
+	s := NewSaga("order")
+	require.NoError(t, s.AddStep(&Step{Name: "first", Func: (&mock{}).f, CompensateFunc: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}, Options: &StepOptions{CompensationTimeout: 10 * time.Millisecond}}))
+	require.NoError(t, s.AddStep(&Step{Name: "second", Func: (&mock{err: errors.New("failed")}).f, CompensateFunc: (&mock{}).f}))
+	result := NewCoordinator(context.Background(), context.Background(), s, New()).Play()
+	require.Len(t, result.CompensateErrors, 1)
+	require.True(t, errors.Is(result.CompensateErrors[0], ErrCompensationTimeout), result.CompensateErrors[0])
+}
+
+type ctxKey struct{}
+
+func TestCompensationContext(t *testing.T) {
+	var compensateErrs []error
+	var values []interface{}
+	compensate := func(ctx context.Context) error {
+		compensateErrs = append(compensateErrs, ctx.Err())
+		values = append(values, ctx.Value(ctxKey{}))
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), ctxKey{}, "trace"))
+	s := NewSaga("order")
+	require.NoError(t, s.AddStep(&Step{Name: "first", Func: (&mock{}).f, CompensateFunc: compensate}))
+	require.NoError(t, s.AddStep(&Step{Name: "second", Func: func(context.Context) error { cancel(); return ctx.Err() }, CompensateFunc: compensate}))
+
+	// compensations get the canceled context of steps passed as both contexts unless it's detached
+	NewCoordinator(ctx, ctx, s, New()).Play()
+	require.Equal(t, []error{context.Canceled, context.Canceled}, compensateErrs)
+
+	compensateErrs, values = nil, nil
+	ctx, cancel = context.WithCancel(context.WithValue(context.Background(), ctxKey{}, "trace"))
+	NewExecutionCoordinator(ctx, ctx, s, New(), WithDetachedCompensation()).Play()
+	require.Equal(t, []error{nil, nil}, compensateErrs)
+	require.Equal(t, []interface{}{"trace", "trace"}, values)
+
+	compensateErrs, values = nil, nil
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	NewExecutionCoordinator(ctx, ctx, s, New(), WithCompensationContext(context.WithValue(context.Background(), ctxKey{}, "other"))).Play()
+	require.Equal(t, []error{nil, nil}, compensateErrs)
+	require.Equal(t, []interface{}{"other", "other"}, values)
+}
+
+func TestSagaDefaults(t *testing.T) {
+	retry := &RetryPolicy{MaxAttempts: 3, Backoff: time.Second}
+	s := NewSaga("order")
+	s.SetDefaults(StepOptions{Timeout: time.Minute, Retry: retry, CompensationTimeout: time.Second, Tags: map[string]string{"team": "orders", "tier": "1"}})
+	require.NoError(t, s.AddStep(&Step{Name: "first", Func: (&mock{}).f, CompensateFunc: (&mock{}).f}))
+	own := &Step{Name: "second", Func: (&mock{}).f, Options: &StepOptions{NoCompensation: true, Critical: true, Timeout: time.Second,
+		Tags: map[string]string{"team": "payments"}}}
+	require.NoError(t, s.AddStep(own))
+
+	first, second := s.Steps()[0].Options, s.Steps()[1].Options
+	require.Equal(t, time.Minute, first.Timeout)
+	require.Equal(t, retry, first.Retry)
+	require.Equal(t, time.Second, first.CompensationTimeout)
+	require.Equal(t, map[string]string{"team": "orders", "tier": "1"}, first.Tags)
+
+	require.Equal(t, time.Second, second.Timeout)
+	require.Nil(t, second.Retry)
+	require.Zero(t, second.CompensationTimeout)
+	require.Equal(t, map[string]string{"team": "payments", "tier": "1"}, second.Tags)
+	// the step passed to AddStep isn't changed
+	require.Equal(t, map[string]string{"team": "payments"}, own.Options.Tags)
+}
+
+func TestMiddleware(t *testing.T) {
+	s := NewSaga("order")
+	require.NoError(t, s.AddStep(&Step{Name: "first", Func: func(context.Context) (string, error) { return "payload", nil },
+		CompensateFunc: func(_ context.Context, payload string) error {
+			require.Equal(t, "payload", payload)
+			return nil
+		}}))
+	require.NoError(t, s.AddStep(&Step{Name: "forbidden", Func: func(context.Context) (int, error) { panic("must not be called") },
+		CompensateFunc: func(_ context.Context, n int) error {
+			require.Equal(t, 0, n)
+			return nil
+		}}))
+	c := NewCoordinator(context.Background(), context.Background(), s, New())
+	var calls []string
+	c.Use(func(next StepFunc) StepFunc {
+		return func(ctx context.Context, invocation *Invocation) error {
+			name := invocation.Step.Name
+			if invocation.Compensation {
+				name = "compensate " + name
+			}
+			calls = append(calls, name)
+			return next(ctx, invocation)
+		}
+	})
+	c.Use(func(next StepFunc) StepFunc {
+		return func(ctx context.Context, invocation *Invocation) error {
+			if invocation.Step.Name == "forbidden" && !invocation.Compensation {
+				return errors.New("forbidden")
+			}
+			return next(ctx, invocation)
+		}
+	})
+	result := c.Play()
+	require.Equal(t, errors.New("forbidden"), result.ExecutionError)
+	require.Empty(t, result.CompensateErrors)
+	require.Equal(t, []string{"first", "forbidden", "compensate forbidden", "compensate first"}, calls)
+
+	// hedged calls run concurrently, outputs are those of the call that succeeded
+	var attempts int32
+	var compensated string
+	s = NewSaga("order")
+	require.NoError(t, s.AddStep(&Step{Name: "quote", Func: func(context.Context) (string, error) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			return "slow", errors.New("timed out")
+		}
+		return "fast", nil
+	}, CompensateFunc: func(_ context.Context, quote string) error { compensated = quote; return nil }}))
+	require.NoError(t, s.AddStep(&Step{Name: "fail", Func: (&mock{err: errors.New("failed")}).f, CompensateFunc: (&mock{}).f}))
+	c = NewCoordinator(context.Background(), context.Background(), s, New())
+	c.Use(func(next StepFunc) StepFunc {
+		return func(ctx context.Context, invocation *Invocation) error {
+			if invocation.Compensation || invocation.Step.Name != "quote" {
+				return next(ctx, invocation)
+			}
+			errs := make(chan error, 4)
+			for i := 0; i < 4; i++ {
+				go func() { errs <- next(ctx, invocation) }()
+			}
+			err := <-errs
+			for i := 1; i < 4; i++ {
+				if e := <-errs; e == nil {
+					err = nil
+				}
+			}
+			return err
+		}
+	})
+	c.Play()
+	require.Equal(t, "fast", compensated)
+}
+
+func TestFinally(t *testing.T) {
+	refund := &mock{err: errors.New("refund failed")}
+	var finalized []*Result
+	s := NewSaga("order")
+	s.Finally(func(ctx context.Context, result *Result) error {
+		finalized = append(finalized, result)
+		return errors.New("notify failed")
+	})
+	require.NoError(t, s.AddStep(&Step{Name: "charge", Func: (&mock{}).f, CompensateFunc: refund.f,
+		Options: &StepOptions{Compensation: Guaranteed, CompensationRetry: &RetryPolicy{MaxAttempts: 1, Backoff: time.Minute}}}))
+	require.NoError(t, s.AddStep(&Step{Name: "ship", Func: (&mock{err: errors.New("out of stock")}).f, CompensateFunc: (&mock{}).f}))
+	store := New()
+
+	c := NewCoordinator(context.Background(), context.Background(), s, store)
+	result := c.Play()
+	require.True(t, result.DeadLettered)
+	require.Equal(t, errors.New("notify failed"), result.FinallyError)
+	require.Len(t, finalized, 1)
+
+	// the finalizer isn't run again when the dead-lettered execution is compensated later
+	refund.err = nil
+	result, err := NewExecutionCoordinator(context.Background(), context.Background(), s, store, WithExecutionID(c.ExecutionID)).Compensate()
+	require.NoError(t, err)
+	require.False(t, result.DeadLettered)
+	require.Len(t, finalized, 1)
+
+	logs, err := store.GetLogs(c.ExecutionID, []string{LogTypeSagaFinalized}, time.Time{}, time.Time{})
+	require.NoError(t, err)
+	require.Len(t, logs, 1)
+	require.Equal(t, "notify failed", *logs[0].StepError)
+	stored, err := GetResult(store, c.ExecutionID)
+	require.NoError(t, err)
+	require.Equal(t, errors.New("notify failed"), stored.FinallyError)
+
+	succeeding := NewSaga("succeeding")
+	succeeding.Finally(func(ctx context.Context, result *Result) error {
+		finalized = append(finalized, result)
+		return nil
+	})
+	require.NoError(t, succeeding.AddStep(&Step{Name: "first", Func: (&mock{}).f, CompensateFunc: (&mock{}).f}))
+	result = NewCoordinator(context.Background(), context.Background(), succeeding, store).Play()
+	require.NoError(t, result.ExecutionError)
+	require.NoError(t, result.FinallyError)
+	require.Len(t, finalized, 2)
+}
+
+func TestAddSteps(t *testing.T) {
+	var nilFunc func(context.Context) error
+	s := NewSaga("order")
+	err := s.AddSteps(
+		&Step{Name: "first", Func: (&mock{}).f, CompensateFunc: (&mock{}).f},
+		&Step{Name: "second", Func: (&mock{}).f},
+		nil,
+		&Step{Name: "fourth", Func: "not a func", CompensateFunc: (&mock{}).f},
+		&Step{Name: "fifth", CompensateFunc: (&mock{}).f},
+		&Step{Name: "sixth", Func: nilFunc, CompensateFunc: nilFunc},
+		&Step{Name: "seventh", Func: (&mock{}).f, CompensateFunc: nilFunc},
+	)
+	var stepsErr StepsError
+	require.True(t, errors.As(err, &stepsErr))
+	require.Len(t, stepsErr, 6)
+	require.Equal(t, []int{1, 2, 3, 4, 5, 6}, []int{stepsErr[0].Index, stepsErr[1].Index, stepsErr[2].Index,
+		stepsErr[3].Index, stepsErr[4].Index, stepsErr[5].Index})
+	require.Equal(t, "second", stepsErr[0].Name)
+	require.Equal(t, "fourth", stepsErr[2].Name)
+	require.Contains(t, err.Error(), "step 1 second: func field is not a func, but nil")
+	require.Contains(t, err.Error(), "step 4 fifth: func field is not a func, but nil")
+	require.Contains(t, err.Error(), "step 5 sixth: func field is a nil func")
+	require.Contains(t, err.Error(), "step 6 seventh: compensate func field is a nil func")
+	require.Empty(t, s.Steps())
+	_, ok := s.StepNumber("first")
+	require.False(t, ok)
+
+	require.NoError(t, s.AddSteps(
+		&Step{Name: "first", Func: (&mock{}).f, CompensateFunc: (&mock{}).f},
+		&Step{Name: "second", Func: (&mock{}).f, CompensateFunc: (&mock{}).f, Pivot: true},
+	))
+	require.Len(t, s.Steps(), 2)
+	step, ok := s.StepNumber("second")
+	require.True(t, ok)
+	require.Equal(t, 1, step)
+	require.Equal(t, 1, s.pivot())
+	require.EqualError(t, s.AddStep(&Step{Name: "third", Func: (&mock{}).f, CompensateFunc: (&mock{}).f, Pivot: true}),
+		"saga already has pivot step second")
+}
+
+type payments interface {
+	Charge(amount int) (string, error)
+	Refund(chargeID string) error
+}
+
+type fakePayments struct {
+	refunded []string
+}
+
+func (p *fakePayments) Charge(amount int) (string, error) { return "ch_1", nil }
+
+func (p *fakePayments) Refund(chargeID string) error {
+	p.refunded = append(p.refunded, chargeID)
+	return nil
+}
+
+type orderHandler struct {
+	_        struct{} `saga:"Read,Charge,Ship"`
+	Payments payments `inject:""`
+	Amount   int      `inject:""`
+}
+
+func (h *orderHandler) Read(context.Context) error { return nil }
+
+func (h *orderHandler) ReadOptions() *StepOptions { return &StepOptions{NoCompensation: true} }
+
+func (h *orderHandler) Charge(context.Context) (string, error) { return h.Payments.Charge(h.Amount) }
+
+func (h *orderHandler) CompensateCharge(_ context.Context, chargeID string) error {
+	return h.Payments.Refund(chargeID)
+}
+
+func (h *orderHandler) Ship(context.Context) error { return errors.New("out of stock") }
+
+func (h *orderHandler) CompensateShip(context.Context) error { return nil }
+
+func TestAddStruct(t *testing.T) {
+	p := &fakePayments{}
+	s := NewSaga("order")
+	require.NoError(t, s.AddStruct(&orderHandler{}, NewInjector(p, 100)))
+	require.Len(t, s.Steps(), 3)
+	require.Equal(t, "Charge", s.Steps()[1].Name)
+
+	result := NewCoordinator(context.Background(), context.Background(), s, New()).Play()
+	require.Equal(t, errors.New("out of stock"), result.ExecutionError)
+	require.Equal(t, []string{"ch_1"}, p.refunded)
+
+	require.Error(t, NewSaga("order").AddStruct(&orderHandler{}, NewInjector(p)))
+	require.Error(t, NewSaga("order").AddStruct(orderHandler{}, nil))
+}
+
+func TestSuppressLogs(t *testing.T) {
+	require.Error(t, NewSaga("s").AddStep(&Step{Name: "poll", Func: (&mock{}).f, CompensateFunc: (&mock{}).f, Options: &StepOptions{SuppressLogs: true}}))
+	require.Error(t, NewSaga("s").AddStep(&Step{Name: "poll", Func: (&mock{}).f, Options: &StepOptions{NoCompensation: true, LogSampleRate: 0.5}}))
+
+	poll := &mock{}
+	s := NewSaga("order")
+	require.NoError(t, s.AddStep(&Step{Name: "poll", Func: poll.f, Options: &StepOptions{NoCompensation: true, SuppressLogs: true}}))
+	require.NoError(t, s.AddStep(&Step{Name: "sampled", Func: (&mock{}).f, Options: &StepOptions{NoCompensation: true, SuppressLogs: true, LogSampleRate: 1}}))
+	require.NoError(t, s.AddStep(&Step{Name: "ship", Func: (&mock{err: errors.New("failed")}).f, CompensateFunc: (&mock{}).f}))
+	store := New()
+	c := NewCoordinator(context.Background(), context.Background(), s, store)
+	result := c.Play()
+	require.Error(t, result.ExecutionError)
+	require.Equal(t, "succeeded", result.Steps[0].Status)
+
+	logs, err := store.GetLogs(c.ExecutionID, []string{LogTypeSagaStepExec}, time.Time{}, time.Time{})
+	require.NoError(t, err)
+	require.Len(t, logs, 2)
+	require.Equal(t, "sampled", *logs[0].StepName)
+	require.Equal(t, "ship", *logs[1].StepName)
+}
+
+func TestDeterministicExecution(t *testing.T) {
+	play := func() []*Log {
+		clock := &testClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+		s := NewSaga("order")
+		require.NoError(t, s.AddStep(&Step{Name: "reserve", Func: (&mock{}).f, CompensateFunc: (&mock{}).f}))
+		store := New()
+		c := NewExecutionCoordinator(context.Background(), context.Background(), s, store,
+			WithClock(clock),
+			WithIDGenerator(UUIDv7Generator{Clock: clock}))
+		require.NoError(t, c.Play().ExecutionError)
+		logs, err := store.GetAllLogsByExecutionID(c.ExecutionID)
+		require.NoError(t, err)
+		return logs
+	}
+	first, second := play(), play()
+	require.Equal(t, len(first), len(second))
+	for i := range first {
+		require.Equal(t, first[i].Time, second[i].Time)
+		require.Equal(t, first[i].StepDuration, second[i].StepDuration)
+	}
+	// IDs are random after the timestamp of the clock
+	require.Equal(t, first[0].ExecutionID[:13], second[0].ExecutionID[:13])
+	require.NotEqual(t, first[0].ExecutionID, second[0].ExecutionID)
+
+	clock := &testClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	health := NewHealth(New())
+	health.Clock = clock
+	health.Heartbeat("timers", time.Minute)
+	require.True(t, health.Liveness().Healthy)
+	clock.now = clock.now.Add(2 * time.Minute)
+	require.False(t, health.Liveness().Healthy)
+}
+
+func TestAlerter(t *testing.T) {
+	var alerts []*Alert
+	alerter := AlerterFunc(func(alert *Alert) { alerts = append(alerts, alert) })
+	s := NewSaga("order")
+	require.NoError(t, s.AddStep(&Step{Name: "charge", Func: (&mock{}).f, CompensateFunc: (&mock{err: errors.New("refund failed")}).f,
+		Options: &StepOptions{Compensation: Guaranteed, CompensationRetry: &RetryPolicy{MaxAttempts: 1, Backoff: time.Minute}, Tags: map[string]string{"team": "payments"}}}))
+	require.NoError(t, s.AddStep(&Step{Name: "ship", Func: (&mock{err: errors.New("out of stock")}).f, CompensateFunc: (&mock{}).f}))
+
+	c := NewExecutionCoordinator(context.Background(), context.Background(), s, New(), WithAlerter(alerter))
+	require.True(t, c.Play().DeadLettered)
+	require.Len(t, alerts, 2)
+	require.Equal(t, AlertCompensationFailed, alerts[0].Kind)
+	require.Equal(t, "charge", alerts[0].StepName)
+	require.Equal(t, "refund failed", alerts[0].Error)
+	require.Equal(t, map[string]string{"team": "payments"}, alerts[0].Tags)
+	require.Equal(t, AlertDeadLettered, alerts[1].Kind)
+	require.Equal(t, c.ExecutionID, alerts[1].ExecutionID)
+
+	var buf bytes.Buffer
+	WriterAlerter(&buf).Alert(alerts[0])
+	require.Contains(t, buf.String(), "saga alert compensation-failed: saga order, execution "+c.ExecutionID+", step charge: refund failed")
+}
+
+func TestEventBus(t *testing.T) {
+	bus := NewEventBus()
+	var all, terminal []string
+	unsubscribe := bus.Subscribe(EventSinkFunc(func(event *CloudEvent) { all = append(all, event.Type) }))
+	bus.Subscribe(EventSinkFunc(func(event *CloudEvent) { terminal = append(terminal, event.Subject) }), EventTypeCompleted, EventTypeCompensated)
+
+	s := NewSaga("order")
+	require.NoError(t, s.AddStep(&Step{Name: "reserve", Func: (&mock{}).f, CompensateFunc: (&mock{}).f}))
+	require.NoError(t, s.AddStep(&Step{Name: "charge", Func: (&mock{err: errors.New("declined")}).f, CompensateFunc: (&mock{}).f}))
+	c := NewExecutionCoordinator(context.Background(), context.Background(), s, New(), WithEventBus(bus, "/orders"))
+	require.Error(t, c.Play().ExecutionError)
+	require.Equal(t, []string{EventTypeStarted, EventTypeStepExecuted, EventTypeStepExecuted, EventTypeAborted, EventTypeCompensated}, all)
+	require.Equal(t, []string{c.ExecutionID}, terminal)
+
+	unsubscribe()
+	unsubscribe()
+	ok := NewSaga("order")
+	require.NoError(t, ok.AddStep(&Step{Name: "reserve", Func: (&mock{}).f, CompensateFunc: (&mock{}).f}))
+	next := NewExecutionCoordinator(context.Background(), context.Background(), ok, New(), WithEventBus(bus, "/orders"))
+	require.NoError(t, next.Play().ExecutionError)
+	require.Len(t, all, 5)
+	require.Equal(t, []string{c.ExecutionID, next.ExecutionID}, terminal)
+}
+
+func TestRelay(t *testing.T) {
+	store := New()
+	s := NewSaga("order")
+	require.NoError(t, s.AddStep(&Step{Name: "reserve", Func: (&mock{}).f, CompensateFunc: (&mock{}).f}))
+	first := NewCoordinator(context.Background(), context.Background(), s, store)
+	require.NoError(t, first.Play().ExecutionError)
+
+	published := make(chan *CloudEvent, 10)
+	var failed int32
+	publisher := PublisherFunc(func(ctx context.Context, event *CloudEvent) error {
+		if atomic.AddInt32(&failed, 1) == 1 {
+			return errors.New("broker is unavailable")
+		}
+		published <- event
+		return nil
+	})
+	checkpoints := NewMemoryCheckpoints()
+	relay := NewRelay(store, publisher, checkpoints)
+	relay.Source = "/orders"
+	relay.RetryInterval = time.Millisecond
+	errs := make(chan error, 10)
+	relay.OnError = func(executionID string, err error) { errs <- err }
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		relay.Run(ctx)
+		close(done)
+	}()
+
+	receive := func(executionID string) []string {
+		var types []string
+		for i := 0; i < 3; i++ {
+			select {
+			case event := <-published:
+				require.Equal(t, executionID, event.Subject)
+				require.Equal(t, fmt.Sprintf("%s/%d", executionID, i), event.ID)
+				types = append(types, event.Type)
+			case <-time.After(time.Second):
+				t.Fatal("event isn't published")
+			}
+		}
+		return types
+	}
+	require.Equal(t, []string{EventTypeStarted, EventTypeStepExecuted, EventTypeCompleted}, receive(first.ExecutionID))
+	require.Contains(t, (<-errs).Error(), "broker is unavailable")
+
+	second := NewCoordinator(context.Background(), context.Background(), s, store)
+	require.NoError(t, second.Play().ExecutionError)
+	require.Equal(t, []string{EventTypeStarted, EventTypeStepExecuted, EventTypeCompleted}, receive(second.ExecutionID))
+	cancel()
+	<-done
+
+	relayed, err := checkpoints.Checkpoint(first.ExecutionID)
+	require.NoError(t, err)
+	require.Equal(t, 3, relayed)
+	relay.relay(context.Background(), first.ExecutionID)
+	require.Len(t, published, 0)
+}
+
+type reserveStep struct {
+	reserved, released int
+	err                error
+}
+
+func (s *reserveStep) Execute(ctx context.Context) error {
+	s.reserved++
+	return s.err
+}
+
+func (s *reserveStep) Compensate(ctx context.Context) error {
+	s.released++
+	return nil
+}
+
+func TestExecutor(t *testing.T) {
+	reserve := &reserveStep{}
+	charge := &reserveStep{err: errors.New("declined")}
+	s := NewSaga("order")
+	require.NoError(t, s.AddStep(&Step{Name: "reserve", Func: reserve, CompensateFunc: reserve}))
+	require.NoError(t, s.AddStep(&Step{Name: "charge", Func: charge, CompensateFunc: charge}))
+	require.Error(t, s.AddStep(&Step{Name: "ship", Func: reserve, CompensateFunc: func(context.Context, int) error { return nil }}))
+
+	var invocations []string
+	c := NewCoordinator(context.Background(), context.Background(), s, New())
+	c.Use(func(next StepFunc) StepFunc {
+		return func(ctx context.Context, invocation *Invocation) error {
+			invocations = append(invocations, invocation.Step.Name)
+			return next(ctx, invocation)
+		}
+	})
+	result := c.Play()
+	require.EqualError(t, result.ExecutionError, "declined")
+	require.Equal(t, 1, reserve.reserved)
+	require.Equal(t, 1, reserve.released)
+	require.Equal(t, 1, charge.released)
+	require.Equal(t, []string{"reserve", "charge", "charge", "reserve"}, invocations)
+}
+
+type orderInput struct {
+	OrderID string `json:"orderId"`
+}
+
+func TestInput(t *testing.T) {
+	var inputs []orderInput
+	reserve := func(ctx context.Context) error {
+		var input orderInput
+		if err := Input(ctx, &input); err != nil {
+			return err
+		}
+		inputs = append(inputs, input)
+		return nil
+	}
+	s := NewSaga("order")
+	require.NoError(t, s.AddStep(&Step{Name: "reserve", Func: reserve, CompensateFunc: (&mock{}).f}))
+	require.NoError(t, s.AddStep(&Step{Name: "approve", Func: reserve, CompensateFunc: (&mock{}).f, Options: &StepOptions{RequireApproval: true}}))
+	store := New()
+	c := NewExecutionCoordinator(context.Background(), context.Background(), s, store, WithInput(orderInput{OrderID: "42"}))
+	require.True(t, c.Play().Paused)
+
+	// the input is read from the Store after Resume
+	result, err := NewExecutionCoordinator(context.Background(), context.Background(), s, store, WithExecutionID(c.ExecutionID)).Approve()
+	require.NoError(t, err)
+	require.NoError(t, result.ExecutionError)
+	require.Equal(t, []orderInput{{OrderID: "42"}, {OrderID: "42"}}, inputs)
+
+	result = NewCoordinator(context.Background(), context.Background(), s, New()).Play()
+	require.True(t, errors.Is(result.ExecutionError, ErrNoInput))
+	require.Equal(t, ErrNotInStep, Input(context.Background(), &orderInput{}))
+}
+
+func TestTrigger(t *testing.T) {
+	var mu sync.Mutex
+	var orders []string
+	s := NewSaga("order")
+	require.NoError(t, s.AddStep(&Step{Name: "reserve", Func: func(ctx context.Context) error {
+		var input orderInput
+		if err := Input(ctx, &input); err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		orders = append(orders, input.OrderID)
+		return nil
+	}, CompensateFunc: (&mock{}).f}))
+	store := New()
+	trigger := NewTrigger(store)
+	trigger.Route("orders", s, func(msg *TriggerMessage) (interface{}, error) {
+		if len(msg.Value) == 0 {
+			return nil, errors.New("empty message")
+		}
+		return orderInput{OrderID: string(msg.Value)}, nil
+	})
+	require.Equal(t, []string{"orders"}, trigger.Topics())
+
+	msg := &TriggerMessage{ID: "orders/0/1", Topic: "orders", Value: []byte("42")}
+	h, err := trigger.Start(msg)
+	require.NoError(t, err)
+	require.Equal(t, "orders/0/1", h.ExecutionID)
+	result, err := h.Wait(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, result.ExecutionError)
+
+	// redelivery doesn't start a duplicate
+	h, err = trigger.Start(msg)
+	require.NoError(t, err)
+	_, err = h.Wait(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []string{"42"}, orders)
+	status, err := GetStatus(store, "orders/0/1")
+	require.NoError(t, err)
+	require.Equal(t, string(StateCompleted), status.State)
+
+	_, err = trigger.Start(&TriggerMessage{ID: "payments/0/1", Topic: "payments"})
+	require.True(t, errors.Is(err, ErrNoRoute))
+	_, err = trigger.Start(&TriggerMessage{ID: "orders/0/2", Topic: "orders"})
+	require.True(t, errors.Is(err, ErrInvalidMessage))
+	require.EqualError(t, err, "invalid message orders/0/2: empty message")
+}
+
+func TestInvocationFromContext(t *testing.T) {
+	var invocation *Invocation
+	s := NewSaga("order")
+	require.NoError(t, s.AddStep(&Step{Name: "reserve", Func: (&mock{}).f, CompensateFunc: (&mock{}).f}))
+	require.NoError(t, s.AddStep(&Step{Name: "charge", Func: func(ctx context.Context) error {
+		var ok bool
+		invocation, ok = InvocationFromContext(ctx)
+		require.True(t, ok)
+		return nil
+	}, CompensateFunc: (&mock{}).f}))
+	c := NewCoordinator(context.Background(), context.Background(), s, New())
+	require.NoError(t, c.Play().ExecutionError)
+	require.Equal(t, c.ExecutionID, invocation.ExecutionID)
+	require.Equal(t, 1, invocation.StepNumber)
+	require.Equal(t, "charge", invocation.Step.Name)
+	require.False(t, invocation.Compensation)
+
+	// compensations know their invocations as well
+	s = NewSaga("order")
+	require.NoError(t, s.AddStep(&Step{Name: "reserve", Func: (&mock{}).f, CompensateFunc: func(ctx context.Context) error {
+		invocation, _ = InvocationFromContext(ctx)
+		return nil
+	}}))
+	require.NoError(t, s.AddStep(&Step{Name: "charge", Func: (&mock{err: errors.New("declined")}).f, CompensateFunc: (&mock{}).f}))
+	c = NewCoordinator(context.Background(), context.Background(), s, New())
+	require.Error(t, c.Play().ExecutionError)
+	require.Equal(t, c.ExecutionID, invocation.ExecutionID)
+	require.Equal(t, 0, invocation.StepNumber)
+	require.True(t, invocation.Compensation)
+
+	_, ok := InvocationFromContext(context.Background())
+	require.False(t, ok)
+}
+
+func TestHistoryInterchange(t *testing.T) {
+	s := NewSaga("order")
+	require.NoError(t, s.AddStep(&Step{Name: "first", Func: func(context.Context) (string, error) { return "payload", nil },
+		CompensateFunc: func(context.Context, string) error { return nil }}))
+	require.NoError(t, s.AddStep(&Step{Name: "second", Func: (&mock{err: errors.New("failed")}).f, CompensateFunc: (&mock{}).f}))
+	key := []byte("key")
+	source := HashChained(New(), key)
+	c := NewCoordinator(context.Background(), context.Background(), s, source)
+	c.Play()
+
+	var buf bytes.Buffer
+	exported, err := ExportHistory(source, ExecutionFilter{}, &buf)
+	require.NoError(t, err)
+	require.Equal(t, 1, exported)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	var header HistoryHeader
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &header))
+	require.Equal(t, HistoryFormat, header.Format)
+	require.Equal(t, HistoryVersion, header.Version)
+	var record map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[2]), &record))
+	require.Equal(t, c.ExecutionID, record["executionId"])
+	require.Equal(t, float64(1), record["sequence"])
+	require.Equal(t, LogTypeSagaStepExec, record["type"])
+	require.Equal(t, []interface{}{"payload"}, record["payload"])
+
+	target := New()
+	imported, err := ImportHistory(target, bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, 1, imported)
+	expected, err := source.GetAllLogsByExecutionID(c.ExecutionID)
+	require.NoError(t, err)
+	actual, err := target.GetAllLogsByExecutionID(c.ExecutionID)
+	require.NoError(t, err)
+	require.Len(t, actual, len(expected))
+	for i := range expected {
+		require.True(t, expected[i].Time.Equal(actual[i].Time))
+		e, a := *expected[i], *actual[i]
+		e.Time, a.Time = time.Time{}, time.Time{}
+		require.Equal(t, e, a)
+	}
+	require.NoError(t, VerifyChain(target, c.ExecutionID, key))
+
+	binary := &Log{ExecutionID: "1", Type: LogTypeSagaStepExec, StepPayload: []byte{0, 1, 2}}
+	require.Equal(t, binary.StepPayload, NewHistoryRecord(binary, 0).Log().StepPayload)
+
+	_, err = ImportHistory(New(), strings.NewReader(`{"format":"go-saga/history","version":2}`+"\n"))
+	require.True(t, errors.Is(err, ErrUnsupportedHistory))
+	_, err = ImportHistory(New(), bytes.NewReader(buf.Bytes()[len(lines[0])+1:]))
+	require.True(t, errors.Is(err, ErrUnsupportedHistory))
+}
@@ -0,0 +1,102 @@
+package sagatest
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	saga "github.com/itimofeev/go-saga"
+)
+
+// Clock is a fake saga.Clock that only moves when told to. Funcs scheduled by AfterFunc, e.g. timeouts
+// of steps, are called by Advance and Set once the clock reaches their time.
+type Clock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*timer
+}
+
+func NewClock(now time.Time) *Clock {
+	return &Clock{now: now}
+}
+
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by d.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+	c.fire()
+}
+
+// Set moves the clock to now.
+func (c *Clock) Set(now time.Time) {
+	c.mu.Lock()
+	c.now = now
+	c.mu.Unlock()
+	c.fire()
+}
+
+// AfterFunc schedules f to be called by Advance or Set once d has passed on the clock.
+func (c *Clock) AfterFunc(d time.Duration, f func()) saga.Timer {
+	t := &timer{clock: c, f: f}
+	t.Reset(d)
+	return t
+}
+
+// fire calls funcs of due timers in order of their time, outside of the lock, so they can use the clock.
+func (c *Clock) fire() {
+	c.mu.Lock()
+	var due []*timer
+	pending := c.timers[:0]
+	for _, t := range c.timers {
+		if t.at.After(c.now) {
+			pending = append(pending, t)
+		} else {
+			due = append(due, t)
+		}
+	}
+	c.timers = pending
+	c.mu.Unlock()
+	sort.SliceStable(due, func(i, j int) bool { return due[i].at.Before(due[j].at) })
+	for _, t := range due {
+		t.f()
+	}
+}
+
+type timer struct {
+	clock *Clock
+	at    time.Time
+	f     func()
+}
+
+// remove removes the timer from the clock, it returns true if the timer was pending. c.mu is held.
+func (t *timer) remove() bool {
+	for i, pending := range t.clock.timers {
+		if pending == t {
+			t.clock.timers = append(t.clock.timers[:i], t.clock.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (t *timer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	return t.remove()
+}
+
+func (t *timer) Reset(d time.Duration) bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	pending := t.remove()
+	t.at = t.clock.now.Add(d)
+	t.clock.timers = append(t.clock.timers, t)
+	return pending
+}
@@ -0,0 +1,89 @@
+package sagatest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	saga "github.com/itimofeev/go-saga"
+)
+
+var ErrInjected = errors.New("failure injected by sagatest")
+
+// CheckCompensations runs the saga failing each step in turn, alone and together with each
+// compensation that should be called then, and checks in a subtest for each combination that
+// every executed step is compensated exactly once in reverse order and errors are reported.
+// Steps of the saga are called for real, except the failing ones, so they should use fakes.
+// Steps after the pivot aren't failed, as their failures are retried instead of compensated.
+func CheckCompensations(t *testing.T, s *saga.Saga) {
+	steps := s.Steps()
+	for failed := range steps {
+		checkFailure(t, s, failed, -1)
+		for compensation := failed; compensation >= 0; compensation-- {
+			checkFailure(t, s, failed, compensation)
+		}
+		if steps[failed].Pivot {
+			break
+		}
+	}
+}
+
+func checkFailure(t *testing.T, s *saga.Saga, failed, compensation int) {
+	steps := s.Steps()
+	name := "fail " + steps[failed].Name
+	if compensation >= 0 {
+		name += fmt.Sprintf(", fail %s", CompensationName(steps[compensation].Name))
+	}
+
+	t.Run(name, func(t *testing.T) {
+		failing := mapSteps(s, func(i int, step *saga.Step) {
+			if i == failed {
+				step.Func = failFunc(step.Func)
+			}
+			if i == compensation {
+				step.CompensateFunc = failFunc(step.CompensateFunc)
+			}
+		})
+		recorded, recorder := Record(failing)
+
+		store := NewStore()
+		c := saga.NewCoordinator(context.Background(), context.Background(), recorded, store)
+		result := c.Play()
+		if result.ExecutionError != ErrInjected {
+			t.Errorf("expected execution error %v, but was %v", ErrInjected, result.ExecutionError)
+		}
+		var expectedErrors []error
+		if compensation >= 0 {
+			expectedErrors = []error{ErrInjected}
+		}
+		if !reflect.DeepEqual(result.CompensateErrors, expectedErrors) {
+			t.Errorf("expected compensation errors %v, but was %v", expectedErrors, result.CompensateErrors)
+		}
+
+		var expectedCalls, expectedCompensations []string
+		for i := 0; i <= failed; i++ {
+			expectedCalls = append(expectedCalls, steps[i].Name)
+		}
+		for i := failed; i >= 0; i-- {
+			expectedCalls = append(expectedCalls, CompensationName(steps[i].Name))
+			expectedCompensations = append(expectedCompensations, steps[i].Name)
+		}
+		AssertRecorded(t, recorder, expectedCalls...)
+		AssertCompensationOrder(t, store, c.ExecutionID, expectedCompensations...)
+		AssertState(t, store, c.ExecutionID, "compensated")
+	})
+}
+
+// failFunc returns func of the same type as f that returns zero values and ErrInjected.
+func failFunc(f interface{}) interface{} {
+	funcType := reflect.TypeOf(f)
+	return reflect.MakeFunc(funcType, func([]reflect.Value) []reflect.Value {
+		results := make([]reflect.Value, 0, funcType.NumOut())
+		for i := 0; i < funcType.NumOut()-1; i++ {
+			results = append(results, reflect.Zero(funcType.Out(i)))
+		}
+		return append(results, reflect.ValueOf(&ErrInjected).Elem())
+	}).Interface()
+}
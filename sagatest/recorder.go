@@ -0,0 +1,100 @@
+package sagatest
+
+import (
+	"reflect"
+	"sync"
+
+	saga "github.com/itimofeev/go-saga"
+)
+
+// Call is a recorded invocation of a step func or compensation.
+type Call struct {
+	Step         string
+	Compensation bool
+	// Args are arguments of the call except context
+	Args []interface{}
+	// Results are returned values except error
+	Results []interface{}
+	Err     error
+}
+
+// Name returns name of the step, or CompensationName of it for compensations.
+func (c *Call) Name() string {
+	if c.Compensation {
+		return CompensationName(c.Step)
+	}
+	return c.Step
+}
+
+// Recorder captures invocations of funcs of a saga wrapped by Record.
+type Recorder struct {
+	mu    sync.Mutex
+	calls []*Call
+}
+
+// Record returns a copy of the saga whose step funcs and compensations are recorded by the returned Recorder.
+func Record(s *saga.Saga) (*saga.Saga, *Recorder) {
+	r := &Recorder{}
+	recorded := mapSteps(s, func(i int, step *saga.Step) {
+		step.Func = r.wrap(step.Name, false, step.Func)
+		step.CompensateFunc = r.wrap(step.Name, true, step.CompensateFunc)
+	})
+	return recorded, r
+}
+
+// mapSteps returns copy of the saga with steps changed by f.
+func mapSteps(s *saga.Saga, f func(i int, step *saga.Step)) *saga.Saga {
+	res := saga.NewSaga(s.Name)
+	res.TenantID = s.TenantID
+	for i, step := range s.Steps() {
+		copied := *step
+		f(i, &copied)
+		// the step is already validated by the original saga
+		_ = res.AddStep(&copied)
+	}
+	return res
+}
+
+// Calls returns recorded calls in order of invocation.
+func (r *Recorder) Calls() []*Call {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*Call(nil), r.calls...)
+}
+
+// Names returns names of recorded calls in order of invocation, see Call.Name.
+func (r *Recorder) Names() []string {
+	var res []string
+	for _, c := range r.Calls() {
+		res = append(res, c.Name())
+	}
+	return res
+}
+
+func (r *Recorder) wrap(stepName string, compensation bool, f interface{}) interface{} {
+	if f == nil {
+		// compensation of a retriable step or of a step with CompensateSaga
+		return nil
+	}
+	funcValue := reflect.ValueOf(f)
+	return reflect.MakeFunc(funcValue.Type(), func(args []reflect.Value) []reflect.Value {
+		c := &Call{Step: stepName, Compensation: compensation}
+		for _, arg := range args[1:] {
+			c.Args = append(c.Args, arg.Interface())
+		}
+		r.mu.Lock()
+		r.calls = append(r.calls, c)
+		r.mu.Unlock()
+
+		results := funcValue.Call(args)
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		for _, res := range results[:len(results)-1] {
+			c.Results = append(c.Results, res.Interface())
+		}
+		if err := results[len(results)-1]; !err.IsNil() {
+			c.Err = err.Interface().(error)
+		}
+		return results
+	}).Interface()
+}
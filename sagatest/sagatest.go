@@ -0,0 +1,71 @@
+// Package sagatest provides helpers for testing sagas: a fake clock, step funcs with
+// scripted outcomes, a recorder of calls, an inspectable Store and assertions.
+//
+//	script := sagatest.NewScript()
+//	script.Func("charge").FailOn(1, errors.New("declined"))
+//	s := saga.NewSaga("order")
+//	s.AddStep(script.Step("reserve"))
+//	s.AddStep(script.Step("charge"))
+//
+//	store := sagatest.NewStore()
+//	c := saga.NewCoordinator(ctx, ctx, s, store)
+//	c.Play()
+//	sagatest.AssertCompensationOrder(t, store, c.ExecutionID, "charge", "reserve")
+package sagatest
+
+import (
+	"reflect"
+	"testing"
+
+	saga "github.com/itimofeev/go-saga"
+)
+
+// AssertCompensationOrder checks that steps of the execution were compensated exactly in that order.
+func AssertCompensationOrder(t testing.TB, store *Store, executionID string, stepNames ...string) {
+	t.Helper()
+	if actual := store.Compensated(executionID); !equalNames(actual, stepNames) {
+		t.Errorf("expected compensation of %v, but was %v", stepNames, actual)
+	}
+}
+
+// AssertNotCompensated checks that no step of the execution were compensated.
+func AssertNotCompensated(t testing.TB, store *Store, executionID string) {
+	t.Helper()
+	AssertCompensationOrder(t, store, executionID)
+}
+
+// AssertCalls checks that funcs of the script were called exactly in that order.
+func AssertCalls(t testing.TB, script *Script, names ...string) {
+	t.Helper()
+	if actual := script.Calls(); !equalNames(actual, names) {
+		t.Errorf("expected calls %v, but was %v", names, actual)
+	}
+}
+
+// AssertRecorded checks that funcs of the recorded saga were called exactly in that order, see Call.Name.
+func AssertRecorded(t testing.TB, recorder *Recorder, names ...string) {
+	t.Helper()
+	if actual := recorder.Names(); !equalNames(actual, names) {
+		t.Errorf("expected calls %v, but was %v", names, actual)
+	}
+}
+
+// AssertState checks state of the execution, see saga.Status.
+func AssertState(t testing.TB, store saga.Store, executionID string, state string) {
+	t.Helper()
+	status, err := saga.GetStatus(store, executionID)
+	if err != nil {
+		t.Errorf("can't get status of %s: %v", executionID, err)
+		return
+	}
+	if status.State != state {
+		t.Errorf("expected %s to be %s, but was %s", executionID, state, status.State)
+	}
+}
+
+func equalNames(actual, expected []string) bool {
+	if len(actual) == 0 && len(expected) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(actual, expected)
+}
@@ -0,0 +1,138 @@
+package sagatest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	saga "github.com/itimofeev/go-saga"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScriptedSaga(t *testing.T) {
+	script := NewScript()
+	script.Func("charge").FailOn(1, errors.New("declined"))
+
+	clock := NewClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	script.Func("reserve").Takes(clock, time.Second)
+
+	s := saga.NewSaga("order")
+	require.NoError(t, s.AddStep(script.Step("reserve")))
+	require.NoError(t, s.AddStep(script.Step("charge")))
+
+	store := NewStore()
+	c := saga.NewCoordinator(context.Background(), context.Background(), s, store)
+	c.Clock = clock
+	require.EqualError(t, c.Play().ExecutionError, "declined")
+
+	AssertCalls(t, script, "reserve", "charge", "charge.compensate", "reserve.compensate")
+	AssertCompensationOrder(t, store, c.ExecutionID, "charge", "reserve")
+	AssertState(t, store, c.ExecutionID, "compensated")
+	require.Equal(t, 1, script.Func("charge").Attempts())
+
+	logs := store.Logs(c.ExecutionID)
+	require.Equal(t, time.Second, logs[1].StepDuration)
+	require.Equal(t, clock.Now(), logs[len(logs)-1].Time)
+	require.Equal(t, saga.LogTypeSagaComplete, store.Types(c.ExecutionID)[len(logs)-1])
+
+	// the second attempt of charge succeeds
+	c = saga.NewCoordinator(context.Background(), context.Background(), s, store)
+	require.NoError(t, c.Play().ExecutionError)
+	AssertNotCompensated(t, store, c.ExecutionID)
+	AssertState(t, store, c.ExecutionID, "completed")
+	require.Len(t, store.Appended(), len(logs)+4)
+}
+
+func TestClockTimeouts(t *testing.T) {
+	clock := NewClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	s := saga.NewSaga("slow")
+	require.NoError(t, s.AddStep(&saga.Step{
+		Name: "slow",
+		Func: func(ctx context.Context) error {
+			clock.Advance(time.Minute)
+			return ctx.Err()
+		},
+		CompensateFunc: func(context.Context) error { return nil },
+		Options:        &saga.StepOptions{SoftTimeout: 10 * time.Second, Timeout: 30 * time.Second},
+	}))
+
+	var escalations []*saga.Escalation
+	c := saga.NewExecutionCoordinator(context.Background(), context.Background(), s, NewStore(),
+		saga.WithClock(clock), saga.WithEscalator(func(e *saga.Escalation) time.Duration {
+			escalations = append(escalations, e)
+			return 0
+		}))
+	result := c.Play()
+	require.True(t, errors.Is(result.ExecutionError, saga.ErrStepTimeout), result.ExecutionError)
+	require.Len(t, escalations, 1)
+	require.Equal(t, time.Minute, escalations[0].Elapsed)
+	require.Equal(t, time.Date(2020, 1, 1, 0, 0, 30, 0, time.UTC), escalations[0].Deadline)
+
+	// timers of completed steps don't fire
+	clock.Advance(time.Hour)
+	require.Len(t, escalations, 1)
+}
+
+func TestRecorder(t *testing.T) {
+	s := saga.NewSaga("order")
+	require.NoError(t, s.AddStep(&saga.Step{
+		Name:           "reserve",
+		Func:           func(context.Context) (string, int, error) { return "item", 2, nil },
+		CompensateFunc: func(context.Context, string, int) error { return nil },
+	}))
+	require.NoError(t, s.AddStep(&saga.Step{
+		Name:           "charge",
+		Func:           func(context.Context) error { return errors.New("declined") },
+		CompensateFunc: func(context.Context) error { return errors.New("refund failed") },
+	}))
+
+	recorded, recorder := Record(s)
+	c := saga.NewCoordinator(context.Background(), context.Background(), recorded, NewStore())
+	c.Play()
+
+	AssertRecorded(t, recorder, "reserve", "charge", "charge.compensate", "reserve.compensate")
+	calls := recorder.Calls()
+	require.Equal(t, []interface{}{"item", 2}, calls[0].Results)
+	require.Nil(t, calls[0].Args)
+	require.EqualError(t, calls[1].Err, "declined")
+	require.EqualError(t, calls[2].Err, "refund failed")
+	require.True(t, calls[3].Compensation)
+	require.Equal(t, []interface{}{"item", 2}, calls[3].Args)
+}
+
+func TestCheckCompensations(t *testing.T) {
+	s := saga.NewSaga("order")
+	require.NoError(t, s.AddStep(NewScript().Step("reserve")))
+	require.NoError(t, s.AddStep(&saga.Step{
+		Name:           "charge",
+		Func:           func(context.Context) (int, error) { return 42, nil },
+		CompensateFunc: func(context.Context, int) error { return nil },
+		Pivot:          true,
+	}))
+	require.NoError(t, s.AddStep(&saga.Step{
+		Name:      "notify",
+		Func:      func(context.Context) error { return nil },
+		Retriable: true,
+	}))
+	CheckCompensations(t, s)
+}
+
+func TestFailingStore(t *testing.T) {
+	s := saga.NewSaga("order")
+	require.NoError(t, s.AddStep(NewScript().Step("reserve")))
+
+	unavailable := errors.New("store is unavailable")
+	store := NewStore().FailOn(MethodAppendLog, 2, unavailable)
+	c := saga.NewCoordinator(context.Background(), context.Background(), s, store)
+	require.Panics(t, func() { c.Play() })
+	require.Equal(t, []string{saga.LogTypeStartSaga}, store.Types(c.ExecutionID))
+	require.Equal(t, 2, store.Calls(MethodAppendLog))
+
+	store.FailAlways(MethodGetAllLogsByExecutionID, unavailable)
+	_, err := saga.GetStatus(store, c.ExecutionID)
+	require.Equal(t, unavailable, err)
+
+	store.FailAlways(MethodGetAllLogsByExecutionID, nil)
+	AssertState(t, store, c.ExecutionID, "running")
+}
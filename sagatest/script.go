@@ -0,0 +1,120 @@
+package sagatest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	saga "github.com/itimofeev/go-saga"
+)
+
+// Script provides step and compensation funcs with scripted outcomes and records
+// all their calls in order.
+type Script struct {
+	mu    sync.Mutex
+	calls []string
+	funcs map[string]*Func
+}
+
+func NewScript() *Script {
+	return &Script{funcs: make(map[string]*Func)}
+}
+
+// CompensationName is the name of the func used as compensation of step created by Script.Step.
+func CompensationName(stepName string) string {
+	return stepName + ".compensate"
+}
+
+// Step returns step using Func(name) as the step and Func(CompensationName(name)) as its compensation.
+func (s *Script) Step(name string) *saga.Step {
+	return &saga.Step{
+		Name:           name,
+		Func:           s.Func(name).Call,
+		CompensateFunc: s.Func(CompensationName(name)).Call,
+	}
+}
+
+// Func returns func with the name, creating it on the first call. It succeeds unless scripted otherwise.
+func (s *Script) Func(name string) *Func {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, ok := s.funcs[name]
+	if !ok {
+		f = &Func{name: name, script: s, failOn: make(map[int]error)}
+		s.funcs[name] = f
+	}
+	return f
+}
+
+// Calls returns names of called funcs in order of calls.
+func (s *Script) Calls() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.calls...)
+}
+
+func (s *Script) record(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = append(s.calls, name)
+}
+
+// Func is a step or compensation func with scripted outcomes of its attempts.
+type Func struct {
+	name   string
+	script *Script
+
+	mu       sync.Mutex
+	attempts int
+	failOn   map[int]error
+	always   error
+	delay    time.Duration
+	clock    *Clock
+}
+
+// FailOn makes attempt (starting from 1) return err.
+func (f *Func) FailOn(attempt int, err error) *Func {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failOn[attempt] = err
+	return f
+}
+
+// FailAlways makes all attempts return err.
+func (f *Func) FailAlways(err error) *Func {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.always = err
+	return f
+}
+
+// Takes makes each attempt advance clock by d, as if the func took that long.
+func (f *Func) Takes(clock *Clock, d time.Duration) *Func {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.clock, f.delay = clock, d
+	return f
+}
+
+// Attempts returns the number of calls of the func.
+func (f *Func) Attempts() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.attempts
+}
+
+// Call has signature of a step func without return values, so it can be used both as Func and as CompensateFunc.
+func (f *Func) Call(context.Context) error {
+	f.script.record(f.name)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.attempts++
+	if f.clock != nil {
+		f.clock.Advance(f.delay)
+	}
+	if err, ok := f.failOn[f.attempts]; ok {
+		return err
+	}
+	return f.always
+}
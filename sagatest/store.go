@@ -0,0 +1,182 @@
+package sagatest
+
+import (
+	"sync"
+	"time"
+
+	saga "github.com/itimofeev/go-saga"
+)
+
+// Method is a method of saga.Store that can be scripted to fail, see Store.FailOn.
+type Method string
+
+const (
+	MethodAppendLog               Method = "AppendLog"
+	MethodGetAllLogsByExecutionID Method = "GetAllLogsByExecutionID"
+	MethodGetStepLogsToCompensate Method = "GetStepLogsToCompensate"
+	MethodGetLogs                 Method = "GetLogs"
+	MethodGetLogsPage             Method = "GetLogsPage"
+	MethodListExecutions          Method = "ListExecutions"
+	MethodDeleteByMetadata        Method = "DeleteByMetadata"
+)
+
+// Store is an in-memory saga.Store that also keeps all appended logs in order for inspection.
+// Its methods can be scripted to fail, e.g. to test what happens when persistence is unavailable.
+type Store struct {
+	saga.Store
+
+	mu       sync.Mutex
+	appended []*saga.Log
+	calls    map[Method]int
+	failOn   map[Method]map[int]error
+	always   map[Method]error
+}
+
+func NewStore() *Store {
+	return &Store{
+		Store:  saga.New(),
+		calls:  make(map[Method]int),
+		failOn: make(map[Method]map[int]error),
+		always: make(map[Method]error),
+	}
+}
+
+// FailOn makes the call number call (starting from 1) of the method return err.
+func (s *Store) FailOn(method Method, call int, err error) *Store {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failOn[method] == nil {
+		s.failOn[method] = make(map[int]error)
+	}
+	s.failOn[method][call] = err
+	return s
+}
+
+// FailAlways makes all calls of the method return err, nil err makes them succeed again.
+func (s *Store) FailAlways(method Method, err error) *Store {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.always[method] = err
+	return s
+}
+
+// Calls returns the number of calls of the method, including failed ones.
+func (s *Store) Calls(method Method) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[method]
+}
+
+func (s *Store) call(method Method) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls[method]++
+	if err, ok := s.failOn[method][s.calls[method]]; ok {
+		return err
+	}
+	return s.always[method]
+}
+
+func (s *Store) AppendLog(log *saga.Log) error {
+	if err := s.call(MethodAppendLog); err != nil {
+		return err
+	}
+	if err := s.Store.AppendLog(log); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.appended = append(s.appended, log)
+	return nil
+}
+
+func (s *Store) GetAllLogsByExecutionID(executionID string) ([]*saga.Log, error) {
+	if err := s.call(MethodGetAllLogsByExecutionID); err != nil {
+		return nil, err
+	}
+	return s.Store.GetAllLogsByExecutionID(executionID)
+}
+
+func (s *Store) GetStepLogsToCompensate(executionID string) ([]*saga.Log, error) {
+	if err := s.call(MethodGetStepLogsToCompensate); err != nil {
+		return nil, err
+	}
+	return s.Store.GetStepLogsToCompensate(executionID)
+}
+
+func (s *Store) GetLogs(executionID string, types []string, from, to time.Time) ([]*saga.Log, error) {
+	if err := s.call(MethodGetLogs); err != nil {
+		return nil, err
+	}
+	return s.Store.GetLogs(executionID, types, from, to)
+}
+
+func (s *Store) GetLogsPage(executionID string, page saga.Page) ([]*saga.Log, string, error) {
+	if err := s.call(MethodGetLogsPage); err != nil {
+		return nil, "", err
+	}
+	return s.Store.GetLogsPage(executionID, page)
+}
+
+func (s *Store) ListExecutions(filter saga.ExecutionFilter, page saga.Page) ([]*saga.Status, string, error) {
+	if err := s.call(MethodListExecutions); err != nil {
+		return nil, "", err
+	}
+	return s.Store.ListExecutions(filter, page)
+}
+
+func (s *Store) DeleteByMetadata(key, value string) (int, error) {
+	if err := s.call(MethodDeleteByMetadata); err != nil {
+		return 0, err
+	}
+	return s.Store.DeleteByMetadata(key, value)
+}
+
+func (s *Store) DeleteTenantByMetadata(tenantID, key, value string) (int, error) {
+	if err := s.call(MethodDeleteByMetadata); err != nil {
+		return 0, err
+	}
+	return saga.DeleteTenantByMetadata(s.Store, tenantID, key, value)
+}
+
+func (s *Store) IsReadOnly() bool {
+	return saga.IsReadOnly(s.Store)
+}
+
+// Appended returns all logs of all executions in order of appending.
+func (s *Store) Appended() []*saga.Log {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*saga.Log(nil), s.appended...)
+}
+
+// Logs returns logs of the execution, nil if there are none.
+func (s *Store) Logs(executionID string) []*saga.Log {
+	var res []*saga.Log
+	for _, l := range s.Appended() {
+		if l.ExecutionID == executionID {
+			res = append(res, l)
+		}
+	}
+	return res
+}
+
+// Types returns types of logs of the execution in order.
+func (s *Store) Types(executionID string) []string {
+	var res []string
+	for _, l := range s.Logs(executionID) {
+		res = append(res, l.Type)
+	}
+	return res
+}
+
+// Compensated returns names of compensated steps of the execution in order of compensation.
+func (s *Store) Compensated(executionID string) []string {
+	var res []string
+	for _, l := range s.Logs(executionID) {
+		if l.Type == saga.LogTypeSagaStepCompensate {
+			res = append(res, *l.StepName)
+		}
+	}
+	return res
+}
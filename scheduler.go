@@ -0,0 +1,171 @@
+package saga
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// OverlapPolicy is what Scheduler does when it's time to start a saga, but its previous
+// execution is still running.
+type OverlapPolicy int
+
+const (
+	// OverlapSkip skips the start
+	OverlapSkip OverlapPolicy = iota
+	// OverlapQueue starts the saga again after the previous execution ends
+	OverlapQueue
+	// OverlapCancelPrevious cancels context of steps of the previous execution, so it's compensated,
+	// and starts the saga after that
+	OverlapCancelPrevious
+)
+
+// Scheduler starts sagas on cron schedules, see ParseCron. Each start is a new execution in the Store.
+type Scheduler struct {
+	// FuncsCtx and CompensateFuncsCtx are parents of contexts of started executions
+	FuncsCtx           context.Context
+	CompensateFuncsCtx context.Context
+	// Clock is used to check schedules, it must be set before adding sagas
+	Clock Clock
+
+	store Store
+
+	mu      sync.Mutex
+	entries []*scheduleEntry
+	wg      sync.WaitGroup
+}
+
+type scheduleEntry struct {
+	schedule *CronSchedule
+	saga     *Saga
+	policy   OverlapPolicy
+	next     time.Time
+
+	running bool
+	queued  int
+	cancel  context.CancelFunc
+}
+
+func NewScheduler(store Store) *Scheduler {
+	return &Scheduler{
+		FuncsCtx:           context.Background(),
+		CompensateFuncsCtx: context.Background(),
+		Clock:              SystemClock,
+		store:              store,
+	}
+}
+
+// Add schedules the saga by cron expression.
+func (s *Scheduler) Add(expr string, saga *Saga, policy OverlapPolicy) error {
+	schedule, err := ParseCron(expr)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, &scheduleEntry{
+		schedule: schedule,
+		saga:     saga,
+		policy:   policy,
+		next:     schedule.Next(s.Clock.Now()),
+	})
+	return nil
+}
+
+// Run starts sagas on their schedules until ctx is done, then it cancels running executions
+// and waits for them to end.
+func (s *Scheduler) Run(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer func() {
+		cancel()
+		s.mu.Lock()
+		for _, e := range s.entries {
+			if e.running {
+				e.cancel()
+			}
+		}
+		s.mu.Unlock()
+		s.wg.Wait()
+	}()
+	for {
+		now := s.Clock.Now()
+		wait := time.Minute
+		if next := s.fire(ctx, now); !next.IsZero() && next.Sub(now) < wait {
+			wait = next.Sub(now)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// fire starts sagas due at now and returns the time of the next start.
+func (s *Scheduler) fire(ctx context.Context, now time.Time) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var next time.Time
+	for _, e := range s.entries {
+		if e.next.IsZero() {
+			continue
+		}
+		if !e.next.After(now) {
+			s.launch(ctx, e)
+			e.next = e.schedule.Next(now)
+		}
+		if next.IsZero() || e.next.Before(next) {
+			next = e.next
+		}
+	}
+	return next
+}
+
+func (s *Scheduler) launch(ctx context.Context, e *scheduleEntry) {
+	if !e.running {
+		s.start(ctx, e)
+		return
+	}
+	switch e.policy {
+	case OverlapQueue:
+		e.queued++
+	case OverlapCancelPrevious:
+		e.cancel()
+		e.queued = 1
+	}
+}
+
+// start plays the saga in background, s.mu must be locked.
+func (s *Scheduler) start(ctx context.Context, e *scheduleEntry) {
+	funcsCtx, cancel := context.WithCancel(s.FuncsCtx)
+	e.running, e.cancel = true, cancel
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.play(funcsCtx, e.saga)
+		cancel()
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		e.running = false
+		if e.queued > 0 && ctx.Err() == nil {
+			e.queued--
+			s.start(ctx, e)
+		}
+	}()
+}
+
+func (s *Scheduler) play(funcsCtx context.Context, saga *Saga) {
+	defer func() {
+		// errors of the Store mustn't stop the scheduler
+		if p := recover(); p != nil {
+			log.Println("scheduled execution of", saga.Name, "failed:", p)
+		}
+	}()
+	c := NewCoordinator(funcsCtx, s.CompensateFuncsCtx, saga, s.store)
+	c.Clock = s.Clock
+	c.Play()
+}
@@ -0,0 +1,54 @@
+package saga
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	ErrNoSecretsProvider = errors.New("no secrets provider, see WithSecrets")
+	ErrSecretNotFound    = errors.New("secret not found")
+)
+
+// SecretsProvider resolves secrets by name, e.g. from Vault or a cloud secrets manager.
+type SecretsProvider interface {
+	Secret(ctx context.Context, name string) (string, error)
+}
+
+// SecretsProviderFunc is an adapter to use ordinary functions as SecretsProvider.
+type SecretsProviderFunc func(ctx context.Context, name string) (string, error)
+
+func (f SecretsProviderFunc) Secret(ctx context.Context, name string) (string, error) {
+	return f(ctx, name)
+}
+
+// StaticSecrets is SecretsProvider with fixed secrets, e.g. for tests.
+type StaticSecrets map[string]string
+
+func (s StaticSecrets) Secret(_ context.Context, name string) (string, error) {
+	secret, ok := s[name]
+	if !ok {
+		return "", ErrSecretNotFound
+	}
+	return secret, nil
+}
+
+// WithSecrets makes the provider available to steps and compensations by Secret, so their
+// closures don't capture credentials that could end up in payloads.
+func WithSecrets(provider SecretsProvider) Option {
+	return func(c *ExecutionCoordinator) {
+		c.funcsCtx = context.WithValue(c.funcsCtx, secretsKey{}, provider)
+		c.compensateFuncsCtx = context.WithValue(c.compensateFuncsCtx, secretsKey{}, provider)
+	}
+}
+
+type secretsKey struct{}
+
+// Secret resolves the secret by SecretsProvider set by WithSecrets, ctx is the context passed to a step or compensation.
+func Secret(ctx context.Context, name string) (string, error) {
+	provider, ok := ctx.Value(secretsKey{}).(SecretsProvider)
+	if !ok {
+		return "", ErrNoSecretsProvider
+	}
+	return provider.Secret(ctx, name)
+}
@@ -0,0 +1,42 @@
+package saga
+
+// State is the state of an execution. An execution starts pending and is running from its first log,
+// it may be paused, delayed or failed on the way and returns to running when it continues. A failed one is
+// either retried or compensating, compensating may become dead-lettered until it's compensated again.
+// Completed and compensated are final.
+type State string
+
+const (
+	StatePending      State = "pending"
+	StateRunning      State = "running"
+	StatePaused       State = "paused"
+	StateDelayed      State = "delayed"
+	StateFailed       State = "failed"
+	StateCompensating State = "compensating"
+	StateDeadLettered State = "dead-lettered"
+	StateCompleted    State = "completed"
+	StateCompensated  State = "compensated"
+)
+
+// State returns the state of the execution known to the coordinator, StatePending before it's played or
+// resumed. Each log records the state it has moved the execution to in Log.State.
+func (c *ExecutionCoordinator) State() State {
+	c.seqMu.Lock()
+	defer c.seqMu.Unlock()
+	if c.progress == nil {
+		return StatePending
+	}
+	return c.progress.state()
+}
+
+func (c *ExecutionCoordinator) aborted() bool {
+	c.seqMu.Lock()
+	defer c.seqMu.Unlock()
+	return c.progress != nil && c.progress.aborted
+}
+
+func (c *ExecutionCoordinator) deadLettered() bool {
+	c.seqMu.Lock()
+	defer c.seqMu.Unlock()
+	return c.progress != nil && c.progress.deadLettered
+}
@@ -0,0 +1,64 @@
+package saga
+
+import "time"
+
+// Status is a summary of an execution built from its logs.
+type Status struct {
+	ExecutionID string `json:"executionId"`
+	Name        string `json:"name"`
+	TenantID    string `json:"tenantId,omitempty"`
+	// Metadata is set by WithMetadata
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// State is one of running, paused, delayed, failed, compensating, dead-lettered, completed, compensated, see State
+	State string `json:"state"`
+	// CurrentStep is the number of the last step that was executed, paused or compensated
+	CurrentStep *int `json:"currentStep,omitempty"`
+	// Attempts is the number of executions of each step by step number
+	Attempts map[int]int `json:"attempts"`
+	// Errors are errors returned by steps in order of occurrence
+	Errors      []string   `json:"errors,omitempty"`
+	StartedAt   time.Time  `json:"startedAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
+	// FireAt is the time of the timer of a parked execution, it has to be resumed then, see TimerLoop.
+	// It's the end of the delay of a delayed execution (StepOptions.Delay), the deadline of a paused
+	// one (StepOptions.ApprovalTimeout), the next attempt (RetryPolicy) or the end of the grace period
+	// (Saga.CompensationGrace) of a failed one, or the next attempt of guaranteed compensations
+	// of a compensating one
+	FireAt *time.Time `json:"fireAt,omitempty"`
+}
+
+// GetStatus folds logs of the execution into its Status.
+func GetStatus(store Store, executionID string) (*Status, error) {
+	logs, err := store.GetAllLogsByExecutionID(executionID)
+	if err != nil {
+		return nil, err
+	}
+	if len(logs) == 0 {
+		return nil, ErrNoLogs
+	}
+	return newStatus(executionID, foldProgress(nil, logs)), nil
+}
+
+func newStatus(executionID string, p *progress) *Status {
+	status := &Status{
+		ExecutionID: executionID,
+		Name:        p.name,
+		TenantID:    p.tenantID,
+		Metadata:    p.metadata,
+		State:       string(p.state()),
+		CurrentStep: p.currentStep,
+		Attempts:    p.attempts,
+		Errors:      p.errors,
+		StartedAt:   p.start,
+		UpdatedAt:   p.updated,
+	}
+	if p.completed {
+		end := p.end
+		status.CompletedAt = &end
+	}
+	if fireAt := p.timer(); !fireAt.IsZero() {
+		status.FireAt = &fireAt
+	}
+	return status
+}
@@ -0,0 +1,175 @@
+package saga
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// StepResult is the outcome of a step of an execution, see Result.Steps.
+type StepResult struct {
+	Name string
+	// Status is one of pending, paused, delayed, skipped, succeeded, failed
+	Status string
+	// Attempts is the number of executions of the step, Duration is their total duration
+	Attempts int
+	Duration time.Duration
+	// Error is the error of the last attempt if it has failed
+	Error error
+	// Compensated is set if compensation of the step has run and succeeded. CompensationAttempts is the number
+	// of its attempts, CompensationError is the error of the last one if it has failed
+	Compensated          bool
+	CompensationAttempts int
+	CompensationError    error
+}
+
+func newStepResults(saga *Saga) []StepResult {
+	steps := make([]StepResult, len(saga.steps))
+	for i, step := range saga.steps {
+		steps[i] = StepResult{Name: step.Name, Status: "pending"}
+	}
+	return steps
+}
+
+// applyStepLog updates results of steps by the log, logs of steps unknown to the saga are ignored.
+func applyStepLog(steps []StepResult, l *Log) {
+	if l.StepNumber == nil || *l.StepNumber < 0 || *l.StepNumber >= len(steps) {
+		return
+	}
+	step := &steps[*l.StepNumber]
+	switch l.Type {
+	case LogTypeSagaStepExec:
+		step.Attempts++
+		step.Duration += l.StepDuration
+		if l.StepError != nil {
+			step.Status = "failed"
+			step.Error = errors.New(*l.StepError)
+		} else {
+			step.Status = "succeeded"
+			step.Error = nil
+		}
+	case LogTypeSagaStepSkipped:
+		step.Status = "skipped"
+	case LogTypeSagaStepPaused:
+		step.Status = "paused"
+	case LogTypeSagaStepApproved:
+		step.Status = "pending"
+	case LogTypeSagaStepDelayed:
+		step.Status = "delayed"
+	case LogTypeSagaStepCompensate:
+		step.Compensated = true
+		step.CompensationAttempts++
+		step.CompensationError = nil
+	case LogTypeSagaStepCompensateFailed:
+		step.Compensated = false
+		if l.StepError != nil {
+			step.CompensationError = errors.New(*l.StepError)
+		}
+	}
+}
+
+// stepResults returns a copy of results of steps known to the coordinator.
+func (c *ExecutionCoordinator) stepResults() []StepResult {
+	c.seqMu.Lock()
+	defer c.seqMu.Unlock()
+	if c.progress == nil {
+		return nil
+	}
+	return append([]StepResult(nil), c.progress.steps...)
+}
+
+// streamStepResult streams the result of the step of the appended log if it has finished or has been compensated,
+// it's called with seqMu held after the log is applied to the progress.
+func (c *ExecutionCoordinator) streamStepResult(l *Log) {
+	if c.progress == nil || l.StepNumber == nil || *l.StepNumber < 0 || *l.StepNumber >= len(c.progress.steps) {
+		return
+	}
+	switch l.Type {
+	case LogTypeSagaStepExec, LogTypeSagaStepCompensate, LogTypeSagaStepCompensateFailed:
+		for _, s := range c.streams {
+			s.push(c.progress.steps[*l.StepNumber])
+		}
+	}
+}
+
+// StreamStepResults returns channel of results of steps sent as each step or its compensation finishes,
+// e.g. to update progress bars of long executions. Slow readers don't block the execution, the channel
+// is closed when ctx is done or when the operation of the coordinator running the execution returns.
+func (c *ExecutionCoordinator) StreamStepResults(ctx context.Context) <-chan StepResult {
+	s := &stepStream{notify: make(chan struct{}, 1)}
+	c.seqMu.Lock()
+	c.streams = append(c.streams, s)
+	c.seqMu.Unlock()
+
+	ch := make(chan StepResult)
+	go func() {
+		defer close(ch)
+		s.run(ctx, ch)
+	}()
+	return ch
+}
+
+// closeStreams closes streams of step results after the remaining results are sent.
+func (c *ExecutionCoordinator) closeStreams() {
+	c.seqMu.Lock()
+	streams := c.streams
+	c.streams = nil
+	c.seqMu.Unlock()
+	for _, s := range streams {
+		s.close()
+	}
+}
+
+// stepStream queues results of steps for StreamStepResults the same way as watcher queues logs.
+type stepStream struct {
+	mu     sync.Mutex
+	queue  []StepResult
+	closed bool
+	notify chan struct{}
+}
+
+func (s *stepStream) push(result StepResult) {
+	s.mu.Lock()
+	s.queue = append(s.queue, result)
+	s.mu.Unlock()
+	s.wake()
+}
+
+func (s *stepStream) close() {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	s.wake()
+}
+
+func (s *stepStream) wake() {
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (s *stepStream) run(ctx context.Context, ch chan<- StepResult) {
+	for {
+		s.mu.Lock()
+		queue, closed := s.queue, s.closed
+		s.queue = nil
+		s.mu.Unlock()
+		for _, result := range queue {
+			select {
+			case ch <- result:
+			case <-ctx.Done():
+				return
+			}
+		}
+		if closed {
+			return
+		}
+		select {
+		case <-s.notify:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
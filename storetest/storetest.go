@@ -0,0 +1,489 @@
+// Package storetest checks that an implementation of saga.Store behaves like the in-memory one,
+// so executions run, resume and are listed the same way on top of it:
+//
+//	func TestConformance(t *testing.T) {
+//		storetest.RunConformance(t, func() saga.Store { return mystore.New(db) })
+//	}
+package storetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	saga "github.com/itimofeev/go-saga"
+)
+
+// RunConformance runs subtests checking the Store returned by newStore, a new empty Store is
+// created for each subtest.
+func RunConformance(t *testing.T, newStore func() saga.Store) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, store saga.Store)
+	}{
+		{"AppendOrder", testAppendOrder},
+		{"ExecutionsAreSeparate", testExecutionsAreSeparate},
+		{"MissingExecution", testMissingExecution},
+		{"StepLogsToCompensate", testStepLogsToCompensate},
+		{"ConcurrentAppends", testConcurrentAppends},
+		{"LogsPage", testLogsPage},
+		{"GetLogs", testGetLogs},
+		{"ListExecutions", testListExecutions},
+		{"ListExecutionsPage", testListExecutionsPage},
+		{"DeleteByMetadata", testDeleteByMetadata},
+		{"Watch", testWatch},
+		{"AppendLogAt", testAppendLogAt},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newStore())
+		})
+	}
+}
+
+var start = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func newLog(executionID, logType string, step int) *saga.Log {
+	l := &saga.Log{
+		ExecutionID: executionID,
+		Name:        "saga",
+		Type:        logType,
+		Time:        start.Add(time.Duration(step+1) * time.Second),
+	}
+	if logType != saga.LogTypeStartSaga && logType != saga.LogTypeSagaComplete {
+		stepName := fmt.Sprintf("step%d", step)
+		l.StepNumber = &step
+		l.StepName = &stepName
+	}
+	return l
+}
+
+func appendLogs(t *testing.T, store saga.Store, logs ...*saga.Log) {
+	t.Helper()
+	for _, l := range logs {
+		if err := store.AppendLog(l); err != nil {
+			t.Fatalf("AppendLog: %v", err)
+		}
+	}
+}
+
+// appendExecution appends logs of an execution that executed the number of steps and,
+// if failed, compensated them.
+func appendExecution(t *testing.T, store saga.Store, executionID string, steps int, failed, completed bool) {
+	t.Helper()
+	appendLogs(t, store, newLog(executionID, saga.LogTypeStartSaga, -1))
+	for i := 0; i < steps; i++ {
+		l := newLog(executionID, saga.LogTypeSagaStepExec, i)
+		l.StepPayload = []byte(fmt.Sprintf(`[%d]`, i))
+		if failed && i == steps-1 {
+			stepErr := "failed"
+			l.StepError = &stepErr
+		}
+		appendLogs(t, store, l)
+	}
+	if failed {
+		appendLogs(t, store, newLog(executionID, saga.LogTypeSagaAbort, steps))
+		for i := steps - 1; i >= 0; i-- {
+			appendLogs(t, store, newLog(executionID, saga.LogTypeSagaStepCompensate, i))
+		}
+	}
+	if completed {
+		appendLogs(t, store, newLog(executionID, saga.LogTypeSagaComplete, -1))
+	}
+}
+
+func getLogs(t *testing.T, store saga.Store, executionID string) []*saga.Log {
+	t.Helper()
+	logs, err := store.GetAllLogsByExecutionID(executionID)
+	if err != nil {
+		t.Fatalf("GetAllLogsByExecutionID(%s): %v", executionID, err)
+	}
+	return logs
+}
+
+func checkLogs(t *testing.T, expected, actual []*saga.Log) {
+	t.Helper()
+	if len(actual) != len(expected) {
+		t.Fatalf("expected %d logs, but got %d", len(expected), len(actual))
+	}
+	for i := range expected {
+		e, a := *expected[i], *actual[i]
+		if !e.Time.Equal(a.Time) {
+			t.Errorf("log %d: expected time %s, but got %s", i, e.Time, a.Time)
+		}
+		e.Time, a.Time = time.Time{}, time.Time{}
+		if len(e.StepPayload) == 0 && len(a.StepPayload) == 0 {
+			e.StepPayload, a.StepPayload = nil, nil
+		}
+		if !reflect.DeepEqual(e, a) {
+			t.Errorf("log %d: expected %+v, but got %+v", i, e, a)
+		}
+	}
+}
+
+func testAppendOrder(t *testing.T, store saga.Store) {
+	expected := []*saga.Log{newLog("e1", saga.LogTypeStartSaga, -1)}
+	for i := 0; i < 3; i++ {
+		l := newLog("e1", saga.LogTypeSagaStepExec, i)
+		l.TenantID = "tenant"
+		l.StepPayload = []byte(`["payload",1]`)
+		l.StepDuration = time.Duration(i) * time.Millisecond
+		expected = append(expected, l)
+	}
+	stepErr := "failed"
+	expected[3].StepError = &stepErr
+	expected = append(expected, newLog("e1", saga.LogTypeSagaComplete, -1))
+
+	appendLogs(t, store, expected...)
+	checkLogs(t, expected, getLogs(t, store, "e1"))
+}
+
+func testExecutionsAreSeparate(t *testing.T, store saga.Store) {
+	first := []*saga.Log{newLog("e1", saga.LogTypeStartSaga, -1), newLog("e1", saga.LogTypeSagaStepExec, 0)}
+	second := []*saga.Log{newLog("e2", saga.LogTypeStartSaga, -1)}
+	appendLogs(t, store, first[0], second[0], first[1])
+
+	checkLogs(t, first, getLogs(t, store, "e1"))
+	checkLogs(t, second, getLogs(t, store, "e2"))
+}
+
+func testMissingExecution(t *testing.T, store saga.Store) {
+	appendExecution(t, store, "e1", 1, false, true)
+
+	if _, err := store.GetAllLogsByExecutionID("missing"); !errors.Is(err, saga.ErrNoLogs) {
+		t.Errorf("GetAllLogsByExecutionID: expected %v, but got %v", saga.ErrNoLogs, err)
+	}
+	if _, err := store.GetStepLogsToCompensate("missing"); !errors.Is(err, saga.ErrNoLogs) {
+		t.Errorf("GetStepLogsToCompensate: expected %v, but got %v", saga.ErrNoLogs, err)
+	}
+	if _, _, err := store.GetLogsPage("missing", saga.Page{}); !errors.Is(err, saga.ErrNoLogs) {
+		t.Errorf("GetLogsPage: expected %v, but got %v", saga.ErrNoLogs, err)
+	}
+}
+
+func testStepLogsToCompensate(t *testing.T, store saga.Store) {
+	appendExecution(t, store, "e1", 3, true, false)
+
+	logs, err := store.GetStepLogsToCompensate("e1")
+	if err != nil {
+		t.Fatalf("GetStepLogsToCompensate: %v", err)
+	}
+	var steps []int
+	for _, l := range logs {
+		if l.Type != saga.LogTypeSagaStepExec {
+			t.Errorf("expected only %s logs, but got %s", saga.LogTypeSagaStepExec, l.Type)
+			continue
+		}
+		steps = append(steps, *l.StepNumber)
+	}
+	if !reflect.DeepEqual(steps, []int{2, 1, 0}) {
+		t.Errorf("expected exec logs of steps [2 1 0] in reverse order, but got %v", steps)
+	}
+}
+
+func testConcurrentAppends(t *testing.T, store saga.Store) {
+	const writers, logsPerWriter = 10, 20
+	var wg sync.WaitGroup
+	errs := make(chan error, writers*logsPerWriter*2)
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			own := fmt.Sprintf("own%d", w)
+			for i := 0; i < logsPerWriter; i++ {
+				errs <- store.AppendLog(newLog(own, saga.LogTypeSagaStepExec, i))
+				shared := newLog("shared", saga.LogTypeSagaStepExec, i)
+				shared.StepPayload = []byte(fmt.Sprintf(`[%d]`, w))
+				errs <- store.AppendLog(shared)
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("AppendLog: %v", err)
+		}
+	}
+
+	for w := 0; w < writers; w++ {
+		if logs := getLogs(t, store, fmt.Sprintf("own%d", w)); len(logs) != logsPerWriter {
+			t.Errorf("expected %d logs of writer %d, but got %d", logsPerWriter, w, len(logs))
+		}
+	}
+	shared := getLogs(t, store, "shared")
+	if len(shared) != writers*logsPerWriter {
+		t.Fatalf("expected %d shared logs, but got %d", writers*logsPerWriter, len(shared))
+	}
+	// logs of each writer must keep their order
+	next := make(map[string]int)
+	for _, l := range shared {
+		writer := string(l.StepPayload)
+		if *l.StepNumber != next[writer] {
+			t.Fatalf("expected log %d of writer %s, but got %d", next[writer], writer, *l.StepNumber)
+		}
+		next[writer]++
+	}
+}
+
+func testGetLogs(t *testing.T, store saga.Store) {
+	appendExecution(t, store, "e1", 3, true, true)
+	appendExecution(t, store, "e2", 3, true, true)
+	all := getLogs(t, store, "e1")
+
+	tests := []struct {
+		types    []string
+		from, to time.Time
+		expected []*saga.Log
+	}{
+		{nil, time.Time{}, time.Time{}, all},
+		{[]string{saga.LogTypeSagaAbort}, time.Time{}, time.Time{}, all[4:5]},
+		{[]string{saga.LogTypeSagaStepCompensate, saga.LogTypeSagaAbort}, time.Time{}, time.Time{}, all[4:8]},
+		{[]string{saga.LogTypeSagaStepExec}, start.Add(2 * time.Second), start.Add(3 * time.Second), all[2:3]},
+		{nil, start.Add(4 * time.Second), time.Time{}, all[4:5]},
+	}
+	for i, tt := range tests {
+		logs, err := store.GetLogs("e1", tt.types, tt.from, tt.to)
+		if err != nil {
+			t.Fatalf("GetLogs %d: %v", i, err)
+		}
+		checkLogs(t, tt.expected, logs)
+	}
+	if _, err := store.GetLogs("missing", nil, time.Time{}, time.Time{}); !errors.Is(err, saga.ErrNoLogs) {
+		t.Errorf("expected ErrNoLogs for missing execution, but got %v", err)
+	}
+}
+
+func testLogsPage(t *testing.T, store saga.Store) {
+	appendExecution(t, store, "e1", 3, true, true)
+	expected := getLogs(t, store, "e1")
+
+	var actual []*saga.Log
+	page := saga.Page{Limit: 2}
+	for i := 0; ; i++ {
+		if i > len(expected) {
+			t.Fatalf("pages don't end")
+		}
+		logs, next, err := store.GetLogsPage("e1", page)
+		if err != nil {
+			t.Fatalf("GetLogsPage: %v", err)
+		}
+		if len(logs) > page.Limit {
+			t.Fatalf("expected at most %d logs, but got %d", page.Limit, len(logs))
+		}
+		actual = append(actual, logs...)
+		if next == "" {
+			break
+		}
+		page.Cursor = next
+	}
+	checkLogs(t, expected, actual)
+
+	all, next, err := store.GetLogsPage("e1", saga.Page{})
+	if err != nil || next != "" {
+		t.Fatalf("GetLogsPage without limit: %v, next cursor %q", err, next)
+	}
+	checkLogs(t, expected, all)
+
+	if _, _, err := store.GetLogsPage("e1", saga.Page{Cursor: "invalid cursor"}); !errors.Is(err, saga.ErrInvalidCursor) {
+		t.Errorf("expected %v, but got %v", saga.ErrInvalidCursor, err)
+	}
+}
+
+func testListExecutions(t *testing.T, store saga.Store) {
+	appendExecution(t, store, "running", 1, false, false)
+	appendExecution(t, store, "completed", 2, false, true)
+	appendExecution(t, store, "compensated", 2, true, true)
+	other := newLog("other", saga.LogTypeStartSaga, 10)
+	other.Name = "other"
+	other.TenantID = "tenant"
+	appendLogs(t, store, other)
+
+	tests := []struct {
+		filter   saga.ExecutionFilter
+		expected []string
+	}{
+		{saga.ExecutionFilter{}, []string{"compensated", "completed", "other", "running"}},
+		{saga.ExecutionFilter{Name: "saga"}, []string{"compensated", "completed", "running"}},
+		{saga.ExecutionFilter{TenantID: "tenant"}, []string{"other"}},
+		{saga.ExecutionFilter{States: []string{"completed", "compensated"}}, []string{"compensated", "completed"}},
+		{saga.ExecutionFilter{Incomplete: true}, []string{"other", "running"}},
+		{saga.ExecutionFilter{From: start, To: start.Add(time.Second)}, []string{"compensated", "completed", "running"}},
+		{saga.ExecutionFilter{From: start.Add(time.Second)}, []string{"other"}},
+	}
+	for _, tt := range tests {
+		statuses, _, err := store.ListExecutions(tt.filter, saga.Page{})
+		if err != nil {
+			t.Fatalf("ListExecutions(%+v): %v", tt.filter, err)
+		}
+		if actual := executionIDs(statuses); !reflect.DeepEqual(actual, tt.expected) {
+			t.Errorf("ListExecutions(%+v): expected %v, but got %v", tt.filter, tt.expected, actual)
+		}
+	}
+
+	statuses, _, err := store.ListExecutions(saga.ExecutionFilter{Name: "saga", States: []string{"compensated"}}, saga.Page{})
+	if err != nil || len(statuses) != 1 {
+		t.Fatalf("ListExecutions: %v, %d executions", err, len(statuses))
+	}
+	if st := statuses[0]; st.CompletedAt == nil || st.Attempts[1] != 1 || !st.StartedAt.Equal(start) {
+		t.Errorf("unexpected status of compensated execution %+v", st)
+	}
+}
+
+func testListExecutionsPage(t *testing.T, store saga.Store) {
+	var expected []string
+	for i := 0; i < 7; i++ {
+		id := fmt.Sprintf("e%d", i)
+		appendExecution(t, store, id, 1, false, i%2 == 0)
+		if i%2 == 0 {
+			expected = append(expected, id)
+		}
+	}
+
+	var actual []*saga.Status
+	page := saga.Page{Limit: 2}
+	for i := 0; ; i++ {
+		if i > 7 {
+			t.Fatalf("pages don't end")
+		}
+		statuses, next, err := store.ListExecutions(saga.ExecutionFilter{States: []string{"completed"}}, page)
+		if err != nil {
+			t.Fatalf("ListExecutions: %v", err)
+		}
+		if len(statuses) > page.Limit {
+			t.Fatalf("expected at most %d executions, but got %d", page.Limit, len(statuses))
+		}
+		actual = append(actual, statuses...)
+		if next == "" {
+			break
+		}
+		page.Cursor = next
+	}
+	if ids := executionIDs(actual); !reflect.DeepEqual(ids, expected) {
+		t.Errorf("expected executions %v, but got %v", expected, ids)
+	}
+
+	if _, _, err := store.ListExecutions(saga.ExecutionFilter{}, saga.Page{Cursor: "invalid cursor"}); !errors.Is(err, saga.ErrInvalidCursor) {
+		t.Errorf("expected %v, but got %v", saga.ErrInvalidCursor, err)
+	}
+}
+
+// executionIDs returns sorted IDs of executions, stores may list executions in any order.
+func executionIDs(statuses []*saga.Status) []string {
+	var res []string
+	for _, st := range statuses {
+		res = append(res, st.ExecutionID)
+	}
+	sort.Strings(res)
+	return res
+}
+
+func testDeleteByMetadata(t *testing.T, store saga.Store) {
+	for i, customer := range []string{"c1", "c2", "c1", ""} {
+		executionID := fmt.Sprintf("e%d", i)
+		logs := []*saga.Log{newLog(executionID, saga.LogTypeStartSaga, -1), newLog(executionID, saga.LogTypeSagaStepExec, 0)}
+		if customer != "" {
+			for _, l := range logs {
+				l.Metadata = map[string]string{"customer": customer}
+			}
+		}
+		appendLogs(t, store, logs...)
+	}
+
+	statuses, _, err := store.ListExecutions(saga.ExecutionFilter{Metadata: map[string]string{"customer": "c1"}}, saga.Page{})
+	if err != nil {
+		t.Fatalf("ListExecutions: %v", err)
+	}
+	if ids := executionIDs(statuses); !reflect.DeepEqual(ids, []string{"e0", "e2"}) {
+		t.Errorf("expected executions [e0 e2] of the customer, but got %v", ids)
+	}
+
+	deleted, err := store.DeleteByMetadata("customer", "c1")
+	if err != nil || deleted != 2 {
+		t.Fatalf("DeleteByMetadata: expected 2 deleted executions, but got %d, %v", deleted, err)
+	}
+	for _, executionID := range []string{"e0", "e2"} {
+		if _, err := store.GetAllLogsByExecutionID(executionID); !errors.Is(err, saga.ErrNoLogs) {
+			t.Errorf("expected %s to be deleted, but got %v", executionID, err)
+		}
+	}
+	if logs := getLogs(t, store, "e1"); len(logs) != 2 {
+		t.Errorf("expected logs of other executions to stay, but got %d", len(logs))
+	}
+	statuses, _, err = store.ListExecutions(saga.ExecutionFilter{}, saga.Page{})
+	if err != nil {
+		t.Fatalf("ListExecutions: %v", err)
+	}
+	if ids := executionIDs(statuses); !reflect.DeepEqual(ids, []string{"e1", "e3"}) {
+		t.Errorf("expected executions [e1 e3] to stay, but got %v", ids)
+	}
+	if deleted, err := store.DeleteByMetadata("customer", ""); err != nil || deleted != 0 {
+		t.Errorf("expected executions without metadata to stay, but %d were deleted, %v", deleted, err)
+	}
+}
+
+func testWatch(t *testing.T, store saga.Store) {
+	appendExecution(t, store, "before", 1, false, true)
+	ctx, cancel := context.WithCancel(context.Background())
+	aborts := store.Watch(ctx, saga.LogFilter{Types: []string{saga.LogTypeSagaAbort}})
+	e1 := store.Watch(ctx, saga.LogFilter{ExecutionID: "e1"})
+
+	appendExecution(t, store, "e1", 2, true, true)
+	appendExecution(t, store, "e2", 1, true, false)
+	receive := func(ch <-chan *saga.Log) *saga.Log {
+		t.Helper()
+		select {
+		case l := <-ch:
+			return l
+		case <-time.After(5 * time.Second):
+			t.Fatal("no log received")
+			return nil
+		}
+	}
+	for _, executionID := range []string{"e1", "e2"} {
+		if l := receive(aborts); l.ExecutionID != executionID || l.Type != saga.LogTypeSagaAbort {
+			t.Errorf("expected abort of %s, but got %s of %s", executionID, l.Type, l.ExecutionID)
+		}
+	}
+	expected := getLogs(t, store, "e1")
+	actual := make([]*saga.Log, 0, len(expected))
+	for range expected {
+		actual = append(actual, receive(e1))
+	}
+	checkLogs(t, expected, actual)
+
+	cancel()
+	for range aborts {
+	}
+	for range e1 {
+	}
+}
+
+func testAppendLogAt(t *testing.T, store saga.Store) {
+	if _, ok := store.(saga.SequencedStore); !ok {
+		t.Skip("store isn't a SequencedStore")
+	}
+	if err := saga.AppendLogAt(store, newLog("e1", saga.LogTypeStartSaga, -1), 0); err != nil {
+		t.Fatalf("AppendLogAt: %v", err)
+	}
+	if err := saga.AppendLogAt(store, newLog("e1", saga.LogTypeSagaStepExec, 0), 1); err != nil {
+		t.Fatalf("AppendLogAt: %v", err)
+	}
+	err := saga.AppendLogAt(store, newLog("e1", saga.LogTypeSagaStepExec, 1), 1)
+	var conflict *saga.ConflictError
+	if !errors.As(err, &conflict) || conflict.Expected != 1 || conflict.Actual != 2 {
+		t.Errorf("expected conflict with 2 logs, but got %v", err)
+	}
+	if !errors.Is(err, saga.ErrConflict) {
+		t.Errorf("expected ErrConflict, but got %v", err)
+	}
+	if logs := getLogs(t, store, "e1"); len(logs) != 2 {
+		t.Errorf("expected 2 logs after conflict, but got %d", len(logs))
+	}
+}
@@ -0,0 +1,56 @@
+package storetest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	saga "github.com/itimofeev/go-saga"
+	"github.com/itimofeev/go-saga/sagatest"
+)
+
+func TestMemoryStore(t *testing.T) {
+	RunConformance(t, saga.New)
+}
+
+func TestSagatestStore(t *testing.T) {
+	RunConformance(t, func() saga.Store { return sagatest.NewStore() })
+}
+
+func TestEncryptedStore(t *testing.T) {
+	keys, err := saga.NewAESKeyProvider([]byte("0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	RunConformance(t, func() saga.Store { return saga.Encrypted(saga.New(), keys) })
+}
+
+func TestNamespacedStore(t *testing.T) {
+	shared := saga.New()
+	// executions of another namespace in the same backend must be invisible
+	other := saga.Namespaced(shared, "other")
+	namespaces := 0
+	RunConformance(t, func() saga.Store {
+		namespaces++
+		if err := other.AppendLog(&saga.Log{ExecutionID: "e1", Type: saga.LogTypeStartSaga, Metadata: map[string]string{"customer": "c1"}}); err != nil {
+			t.Fatal(err)
+		}
+		return saga.Namespaced(shared, fmt.Sprintf("app%d", namespaces))
+	})
+}
+
+type nopMetrics struct{}
+
+func (nopMetrics) ObserveStoreCall(string, time.Duration, error, int) {}
+
+func TestInstrumentedStore(t *testing.T) {
+	RunConformance(t, func() saga.Store { return saga.Instrumented(saga.New(), nopMetrics{}) })
+}
+
+func TestContextStore(t *testing.T) {
+	RunConformance(t, func() saga.Store {
+		// BindContext unwraps the adapter, so wrap it once more
+		return saga.BindContext(struct{ saga.ContextStore }{saga.WithContext(saga.New())}, context.Background())
+	})
+}
@@ -0,0 +1,163 @@
+package saga
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrTenantMismatch          = errors.New("log belongs to another tenant")
+	ErrTenantDeleteUnsupported = errors.New("store doesn't delete executions of a tenant")
+)
+
+// TenantDeleter is implemented by stores that delete executions of a tenant atomically, ForTenant stores
+// require it for DeleteByMetadata. The in-memory store and decorators of this package implement it.
+type TenantDeleter interface {
+	// DeleteTenantByMetadata deletes all logs of executions of the tenant having the metadata and returns
+	// the number of deleted executions, executions of other tenants aren't considered
+	DeleteTenantByMetadata(tenantID, key, value string) (int, error)
+}
+
+// DeleteTenantByMetadata deletes executions of the tenant having the metadata, stores that don't implement
+// TenantDeleter fail with ErrTenantDeleteUnsupported.
+func DeleteTenantByMetadata(store Store, tenantID, key, value string) (int, error) {
+	if deleter, ok := store.(TenantDeleter); ok {
+		return deleter.DeleteTenantByMetadata(tenantID, key, value)
+	}
+	return 0, ErrTenantDeleteUnsupported
+}
+
+// ForTenant returns Store that only reads and writes executions of the tenant.
+// Executions of other tenants look as if they don't exist.
+func ForTenant(store Store, tenantID string) Store {
+	checkOK(tenantID != "", "tenantID must not be empty")
+	return &tenantStore{store: store, tenantID: tenantID}
+}
+
+type tenantStore struct {
+	store    Store
+	tenantID string
+}
+
+func (s *tenantStore) AppendLog(log *Log) error {
+	if err := s.checkAppend(log); err != nil {
+		return err
+	}
+	return s.store.AppendLog(log)
+}
+
+func (s *tenantStore) AppendLogAt(log *Log, expected int) error {
+	if err := s.checkAppend(log); err != nil {
+		return err
+	}
+	return AppendLogAt(s.store, log, expected)
+}
+
+// checkAppend returns ErrTenantMismatch if the log or its existing execution belongs to another tenant,
+// so executions of other tenants can't be appended to by their IDs.
+func (s *tenantStore) checkAppend(log *Log) error {
+	if log.TenantID != s.tenantID {
+		return ErrTenantMismatch
+	}
+	tenantID, err := s.tenantOf(log.ExecutionID)
+	if errors.Is(err, ErrNoLogs) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if tenantID != s.tenantID {
+		return ErrTenantMismatch
+	}
+	return nil
+}
+
+func (s *tenantStore) GetAllLogsByExecutionID(executionID string) ([]*Log, error) {
+	logs, err := s.store.GetAllLogsByExecutionID(executionID)
+	if err != nil {
+		return nil, err
+	}
+	if len(logs) == 0 || logs[0].TenantID != s.tenantID {
+		return nil, ErrNoLogs
+	}
+	return logs, nil
+}
+
+func (s *tenantStore) GetStepLogsToCompensate(executionID string) ([]*Log, error) {
+	if err := s.checkExecution(executionID); err != nil {
+		return nil, err
+	}
+	return s.store.GetStepLogsToCompensate(executionID)
+}
+
+func (s *tenantStore) GetLogs(executionID string, types []string, from, to time.Time) ([]*Log, error) {
+	if err := s.checkExecution(executionID); err != nil {
+		return nil, err
+	}
+	return s.store.GetLogs(executionID, types, from, to)
+}
+
+func (s *tenantStore) GetLogsPage(executionID string, page Page) ([]*Log, string, error) {
+	if err := s.checkExecution(executionID); err != nil {
+		return nil, "", err
+	}
+	return s.store.GetLogsPage(executionID, page)
+}
+
+func (s *tenantStore) ListExecutions(filter ExecutionFilter, page Page) ([]*Status, string, error) {
+	if filter.TenantID != "" && filter.TenantID != s.tenantID {
+		return nil, "", nil
+	}
+	filter.TenantID = s.tenantID
+	return s.store.ListExecutions(filter, page)
+}
+
+// DeleteByMetadata deletes executions of the tenant having the metadata, executions of other tenants
+// with the same metadata are kept. The store must implement TenantDeleter, see DeleteTenantByMetadata.
+func (s *tenantStore) DeleteByMetadata(key, value string) (int, error) {
+	return DeleteTenantByMetadata(s.store, s.tenantID, key, value)
+}
+
+func (s *tenantStore) DeleteTenantByMetadata(tenantID, key, value string) (int, error) {
+	if tenantID != s.tenantID {
+		return 0, nil
+	}
+	return DeleteTenantByMetadata(s.store, tenantID, key, value)
+}
+
+func (s *tenantStore) IsReadOnly() bool {
+	return IsReadOnly(s.store)
+}
+
+func (s *tenantStore) Watch(ctx context.Context, filter LogFilter) <-chan *Log {
+	if filter.TenantID != "" && filter.TenantID != s.tenantID {
+		return closedLogs()
+	}
+	filter.TenantID = s.tenantID
+	return s.store.Watch(ctx, filter)
+}
+
+// checkExecution returns ErrNoLogs if the execution belongs to another tenant.
+func (s *tenantStore) checkExecution(executionID string) error {
+	tenantID, err := s.tenantOf(executionID)
+	if err != nil {
+		return err
+	}
+	if tenantID != s.tenantID {
+		return ErrNoLogs
+	}
+	return nil
+}
+
+// tenantOf returns the tenant of the execution, ErrNoLogs if it doesn't exist.
+func (s *tenantStore) tenantOf(executionID string) (string, error) {
+	logs, _, err := s.store.GetLogsPage(executionID, Page{Limit: 1})
+	if err != nil {
+		return "", err
+	}
+	if len(logs) == 0 {
+		return "", ErrNoLogs
+	}
+	return logs[0].TenantID, nil
+}
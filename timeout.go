@@ -0,0 +1,167 @@
+package saga
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var ErrStepTimeout = errors.New("step timed out")
+
+// TimeoutPolicy is whether a timed out step is compensated: its side effect may or may not have happened.
+type TimeoutPolicy int
+
+const (
+	// AlwaysCompensate compensates timed out steps as failed ones, it's the default
+	AlwaysCompensate TimeoutPolicy = iota
+	// NeverCompensate skips compensation of timed out steps
+	NeverCompensate
+	// VerifyThenCompensate compensates timed out steps only if StepOptions.Probe reports that
+	// the side effect has happened or fails, its error is returned in Result.CompensateErrors then
+	VerifyThenCompensate
+)
+
+// Probe checks whether the side effect of a timed out step has happened, it's called with
+// the compensation context.
+type Probe func(ctx context.Context) (bool, error)
+
+// Escalation describes a step running longer than its StepOptions.SoftTimeout.
+type Escalation struct {
+	ExecutionID string
+	SagaName    string
+	StepNumber  int
+	StepName    string
+	// Tags are StepOptions.Tags of the step
+	Tags map[string]string
+	// Elapsed is the time since the start of the step
+	Elapsed time.Duration
+	// Deadline is the hard deadline of the step, zero if it has no StepOptions.Timeout
+	Deadline time.Time
+}
+
+// Escalator is called for steps running longer than their soft timeout, e.g. to page operators.
+// It returns duration to extend the hard timeout of the step by, zero to keep it.
+type Escalator func(e *Escalation) time.Duration
+
+// WithEscalator sets Escalator called for steps exceeding their StepOptions.SoftTimeout.
+func WithEscalator(escalator Escalator) Option {
+	return func(c *ExecutionCoordinator) {
+		c.escalator = escalator
+	}
+}
+
+// stepTimer enforces timeouts of a running step.
+type stepTimer struct {
+	mu       sync.Mutex
+	clock    Clock
+	start    time.Time
+	deadline time.Time
+	timedOut bool
+	hard     Timer
+	soft     Timer
+	cancel   context.CancelFunc
+}
+
+// startStepTimer returns context of step i canceled at its hard timeout and the timer of the step,
+// nil if the step has no timeouts.
+func (c *ExecutionCoordinator) startStepTimer(i int) (context.Context, *stepTimer) {
+	options := c.saga.steps[i].Options
+	if options == nil || options.Timeout <= 0 && (options.SoftTimeout <= 0 || c.escalator == nil) {
+		return c.stepCtx(i), nil
+	}
+	ctx, cancel := context.WithCancel(c.stepCtx(i))
+	t := &stepTimer{clock: c.Clock, start: c.Clock.Now(), cancel: cancel}
+	if options.Timeout > 0 {
+		t.deadline = t.start.Add(options.Timeout)
+		t.hard = afterFunc(c.Clock, options.Timeout, func() {
+			t.mu.Lock()
+			t.timedOut = true
+			t.mu.Unlock()
+			cancel()
+		})
+	}
+	if options.SoftTimeout > 0 && c.escalator != nil {
+		t.soft = afterFunc(c.Clock, options.SoftTimeout, func() {
+			t.mu.Lock()
+			deadline := t.deadline
+			t.mu.Unlock()
+			extension := c.escalator(&Escalation{
+				ExecutionID: c.ExecutionID,
+				SagaName:    c.saga.Name,
+				StepNumber:  i,
+				StepName:    c.saga.steps[i].Name,
+				Tags:        options.Tags,
+				Elapsed:     c.Clock.Now().Sub(t.start),
+				Deadline:    deadline,
+			})
+			t.extend(extension)
+		})
+	}
+	return ctx, t
+}
+
+func (t *stepTimer) extend(extension time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if extension <= 0 || t.hard == nil || t.timedOut || !t.hard.Stop() {
+		return
+	}
+	t.deadline = t.deadline.Add(extension)
+	t.hard.Reset(t.deadline.Sub(t.clock.Now()))
+}
+
+// compensateTimedOut returns true if the step of the exec log has to be compensated,
+// i.e. it hasn't timed out or its TimeoutPolicy requires compensation.
+func (c *ExecutionCoordinator) compensateTimedOut(stepLog *Log) bool {
+	if !stepLog.StepTimedOut {
+		return true
+	}
+	options := c.saga.steps[*stepLog.StepNumber].Options
+	if options == nil {
+		return true
+	}
+	switch options.OnTimeout {
+	case NeverCompensate:
+		return false
+	case VerifyThenCompensate:
+		happened, err := options.Probe(c.compensateFuncsCtx)
+		if err != nil {
+			c.compensateErrors = append(c.compensateErrors, err)
+			return true
+		}
+		return happened
+	}
+	return true
+}
+
+// expired returns true if the step has run past its hard timeout.
+func (t *stepTimer) expired() bool {
+	if t == nil {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.timedOut
+}
+
+// stop stops the timer when the step returns, it replaces the error of a timed out step with ErrStepTimeout.
+func (t *stepTimer) stop(err error) error {
+	if t == nil {
+		return err
+	}
+	if t.soft != nil {
+		t.soft.Stop()
+	}
+	t.cancel()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.hard != nil {
+		t.hard.Stop()
+	}
+	if t.timedOut && err != nil {
+		return fmt.Errorf("%w after %s: %v", ErrStepTimeout, t.clock.Now().Sub(t.start).Round(time.Millisecond), err)
+	}
+	return err
+}
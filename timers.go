@@ -0,0 +1,141 @@
+package saga
+
+import (
+	"context"
+	"time"
+)
+
+// TimerLoop resumes parked executions when their timers fire: delays of steps, approval deadlines,
+// retries of failed steps and grace periods of compensation. Timers are Status.FireAt of executions
+// in the Store, so they survive restarts and any number of loops can share the Store: a loop claims
+// the due timer by a LogTypeSagaTimerClaimed log appended at the next sequence of the execution
+// before resuming it, so it's resumed by one of them. It requires a SequencedStore, e.g. the in-memory one,
+// other stores can't reject concurrent claims.
+//
+// The loop polls the Store each Interval for timers due within Horizon in batches and keeps them
+// in a hierarchical timer wheel, which fires them with Resolution precision, so hundreds of thousands
+// of pending timers cost neither a goroutine each nor a Store query per tick.
+type TimerLoop struct {
+	// Interval between polls of the Store
+	Interval time.Duration
+	// Horizon is how far ahead timers are loaded, it should be longer than Interval
+	Horizon time.Duration
+	// Resolution is the tick of the timer wheel
+	Resolution time.Duration
+	// BatchSize is the page size of polls
+	BatchSize int
+	// Lease is how long a claimed timer belongs to the loop, it should be longer than resumes take.
+	// The timer fires again after the lease if the execution hasn't been parked with another timer by then
+	Lease time.Duration
+	// Heartbeat is beaten after each poll if it's set, see Health.Heartbeat
+	Heartbeat *Heartbeat
+	// OnError receives errors of the Store and of resumed executions if it's set
+	OnError func(executionID string, err error)
+
+	admin *Admin
+	wheel *timerWheel
+}
+
+// NewTimerLoop returns TimerLoop resuming executions by the admin, its sagas must include
+// definitions of all executions with timers. Resumes are attributed to ActorTimers.
+func NewTimerLoop(admin *Admin) *TimerLoop {
+	return &TimerLoop{
+		Interval:   10 * time.Second,
+		Horizon:    time.Minute,
+		Resolution: 100 * time.Millisecond,
+		BatchSize:  1000,
+		Lease:      5 * time.Minute,
+		admin:      admin,
+	}
+}
+
+// ActorTimers is the actor of resumes by TimerLoop, see Attribution.
+const ActorTimers = "timers"
+
+// Run fires timers until ctx is done.
+func (l *TimerLoop) Run(ctx context.Context) {
+	ticker := time.NewTicker(l.Resolution)
+	defer ticker.Stop()
+	var polled time.Time
+	for {
+		now := l.admin.Clock.Now()
+		if now.Sub(polled) >= l.Interval {
+			l.poll(now)
+			polled = now
+		}
+		l.fire(ctx, now)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Fire polls the Store and resumes executions with timers due by now, it returns the number of resumed ones.
+func (l *TimerLoop) Fire(ctx context.Context) int {
+	now := l.admin.Clock.Now()
+	l.poll(now)
+	return l.fire(ctx, now)
+}
+
+// poll loads timers due within the horizon into the wheel.
+func (l *TimerLoop) poll(now time.Time) {
+	if l.wheel == nil {
+		l.wheel = newTimerWheel(l.Resolution, now)
+	}
+	filter := ExecutionFilter{Incomplete: true, DueBy: now.Add(l.Horizon)}
+	page := Page{Limit: l.BatchSize}
+	for {
+		statuses, next, err := l.admin.ListExecutions(filter, page)
+		if err != nil {
+			l.error("", err)
+			break
+		}
+		for _, status := range statuses {
+			l.wheel.add(status.ExecutionID, *status.FireAt)
+		}
+		if next == "" {
+			break
+		}
+		page.Cursor = next
+	}
+	if l.Heartbeat != nil {
+		l.Heartbeat.Beat()
+	}
+}
+
+// fire resumes executions with expired timers in the wheel if they are still due.
+func (l *TimerLoop) fire(ctx context.Context, now time.Time) int {
+	if l.wheel == nil {
+		return 0
+	}
+	ctx = WithActor(ctx, ActorTimers)
+	resumed := 0
+	for _, executionID := range l.wheel.advance(now) {
+		// the execution may have been resumed or claimed since the poll
+		claimed, fireAt, err := l.admin.claimTimer(executionID, now, l.Lease)
+		if err != nil {
+			l.error(executionID, err)
+			continue
+		}
+		if !claimed {
+			if !fireAt.IsZero() {
+				l.wheel.add(executionID, fireAt)
+			}
+			continue
+		}
+		if _, err := l.admin.Resume(ctx, executionID); err != nil {
+			l.error(executionID, err)
+			continue
+		}
+		resumed++
+	}
+	return resumed
+}
+
+func (l *TimerLoop) error(executionID string, err error) {
+	if l.OnError != nil {
+		l.OnError(executionID, err)
+	}
+}
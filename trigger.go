@@ -0,0 +1,126 @@
+package saga
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+var (
+	ErrNoRoute = errors.New("no saga is routed for topic")
+	// ErrInvalidMessage wraps errors of TriggerMapper
+	ErrInvalidMessage = errors.New("invalid message")
+)
+
+// TriggerMessage is a message of a broker that starts an execution, e.g. a Kafka record or an SQS message.
+type TriggerMessage struct {
+	// ID identifies the message in the broker and is the ID of the started execution, redeliveries
+	// of the message must have the same ID, e.g. topic, partition and offset of a Kafka record
+	ID string
+	// Topic selects the saga started by the message, see Trigger.Route
+	Topic   string
+	Key     []byte
+	Value   []byte
+	Headers map[string]string
+}
+
+// TriggerMapper returns the input of the execution started by the message, see Input.
+type TriggerMapper func(msg *TriggerMessage) (interface{}, error)
+
+// Trigger starts executions of sagas routed by topics of messages. It's the core of consumers of brokers,
+// which acknowledge messages once Start returns: the start is written to the Store then, so starts are
+// at-least-once, and redeliveries resume the execution of the message instead of starting a duplicate.
+type Trigger struct {
+	// FuncsCtx and CompensateFuncsCtx are passed to coordinators of started executions
+	FuncsCtx           context.Context
+	CompensateFuncsCtx context.Context
+
+	store Store
+
+	mu     sync.RWMutex
+	routes map[string]*triggerRoute
+}
+
+type triggerRoute struct {
+	saga   *Saga
+	mapper TriggerMapper
+	opts   []Option
+}
+
+func NewTrigger(store Store) *Trigger {
+	return &Trigger{
+		FuncsCtx:           context.Background(),
+		CompensateFuncsCtx: context.Background(),
+		store:              store,
+		routes:             make(map[string]*triggerRoute),
+	}
+}
+
+// Route starts executions of the saga for messages of the topic with inputs returned by mapper,
+// opts configure their coordinators.
+func (t *Trigger) Route(topic string, saga *Saga, mapper TriggerMapper, opts ...Option) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.routes[topic] = &triggerRoute{saga: saga, mapper: mapper, opts: opts}
+}
+
+// Topics returns sorted topics of the routes, e.g. to subscribe to them.
+func (t *Trigger) Topics() []string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	topics := make([]string, 0, len(t.routes))
+	for topic := range t.routes {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	return topics
+}
+
+// Start starts the execution of the message and returns when its start is written to the Store,
+// the execution is played in the background. If the message is a redelivery, the execution started
+// by it earlier is resumed or, if it's completed, left as is. Messages of unknown topics fail with ErrNoRoute
+// and the ones rejected by the mapper with ErrInvalidMessage, retries of such messages don't start them.
+func (t *Trigger) Start(msg *TriggerMessage) (*ExecutionHandle, error) {
+	checkOK(msg.ID != "", "message ID must not be empty")
+	t.mu.RLock()
+	route := t.routes[msg.Topic]
+	t.mu.RUnlock()
+	if route == nil {
+		return nil, fmt.Errorf("%w %s", ErrNoRoute, msg.Topic)
+	}
+	input, err := route.mapper(msg)
+	if err != nil {
+		return nil, fmt.Errorf("%w %s: %v", ErrInvalidMessage, msg.ID, err)
+	}
+	raw, err := json.Marshal(input)
+	if err != nil {
+		return nil, fmt.Errorf("%w %s: %v", ErrInvalidMessage, msg.ID, err)
+	}
+
+	opts := append(append([]Option(nil), route.opts...), WithExecutionID(msg.ID), WithInput(json.RawMessage(raw)))
+	c := NewExecutionCoordinator(t.FuncsCtx, t.CompensateFuncsCtx, route.saga, t.store, opts...)
+	started := make(chan struct{})
+	var once sync.Once
+	c.onStart = func() { once.Do(func() { close(started) }) }
+	if _, err := GetStatus(t.store, msg.ID); err == nil {
+		c.onStart()
+	} else if !errors.Is(err, ErrNoLogs) {
+		return nil, err
+	}
+
+	h := c.async(func() *Result { return resumeOrPlay(c) })
+	select {
+	case <-started:
+		return h, nil
+	case <-h.done:
+		select {
+		case <-started:
+			return h, nil
+		default:
+			return nil, h.result.ExecutionError
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package saga
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Transactional is implemented by stores that can append several logs atomically, e.g. SQL stores,
+// so the coordinator never leaves logs of a step execution half-written: the exec log of a failed step
+// is appended in one transaction with the decision what's next, e.g. LogTypeSagaStepRetryScheduled.
+type Transactional interface {
+	// WithinTx calls fn with Store whose appended logs are committed if fn returns nil and rolled back
+	// otherwise. Reads of logs by tx include logs appended by tx, and WithinTx of tx appends logs within the
+	// same transaction.
+	WithinTx(fn func(tx Store) error) error
+}
+
+// WithinTx calls fn within a transaction of the store if it's Transactional, otherwise fn appends
+// logs to the store directly. Decorators such as HashChained aren't Transactional.
+func WithinTx(store Store, fn func(tx Store) error) error {
+	if transactional, ok := store.(Transactional); ok {
+		return transactional.WithinTx(fn)
+	}
+	return fn(store)
+}
+
+// withinTx appends logs of fn within a transaction of the Store of the coordinator. Logs buffered by
+// Saga.LogSync aren't synced meanwhile, they are committed with the transaction.
+func (c *ExecutionCoordinator) withinTx(fn func()) {
+	store, inTx := c.logStore, c.inTx
+	defer func() { c.logStore, c.inTx = store, inTx }()
+	c.seqMu.Lock()
+	c.waitWrite()
+	c.seqMu.Unlock()
+	err := WithinTx(store, func(tx Store) error {
+		c.logStore, c.inTx = tx, true
+		fn()
+		if inTx {
+			// the outer transaction commits the buffer
+			return nil
+		}
+		c.seqMu.Lock()
+		defer c.seqMu.Unlock()
+		buffer := c.buffer
+		c.buffer = nil
+		return appendBuffer(tx, buffer)
+	})
+	var conflict *ConflictError
+	if errors.As(err, &conflict) {
+		panic(conflict)
+	}
+	checkErr(err, "WithinTx()")
+}
+
+// memoryTx buffers logs appended within a transaction of the memory store. Reads include copies of buffered logs,
+// nested transactions append to the same buffer and roll back only their own logs.
+type memoryTx struct {
+	store *store
+	logs  []*Log
+	// expected are sequences of logs appended by AppendLogAt, -1 for AppendLog
+	expected []int
+}
+
+func (s *store) WithinTx(fn func(tx Store) error) error {
+	tx := &memoryTx{store: s}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	// sequences are checked on commit, so the transaction is appended entirely or not at all
+	return s.append(tx.logs, tx.expected)
+}
+
+func (tx *memoryTx) WithinTx(fn func(tx Store) error) error {
+	n := len(tx.logs)
+	if err := fn(tx); err != nil {
+		tx.logs, tx.expected = tx.logs[:n], tx.expected[:n]
+		return err
+	}
+	return nil
+}
+
+func (tx *memoryTx) AppendLog(log *Log) error {
+	return tx.AppendLogAt(log, -1)
+}
+
+func (tx *memoryTx) AppendLogAt(log *Log, expected int) error {
+	tx.logs = append(tx.logs, copyLog(log))
+	tx.expected = append(tx.expected, expected)
+	return nil
+}
+
+// executionLogs returns copies of logs of the execution in the store followed by buffered ones, false if there are none.
+func (tx *memoryTx) executionLogs(executionID string) ([]*Log, bool) {
+	logs, ok := tx.store.logs(executionID)
+	for _, log := range tx.logs {
+		if log.ExecutionID == executionID {
+			logs = append(logs, copyLog(log))
+			ok = true
+		}
+	}
+	return logs, ok
+}
+
+func (tx *memoryTx) GetAllLogsByExecutionID(executionID string) ([]*Log, error) {
+	if logs, ok := tx.executionLogs(executionID); ok {
+		return logs, nil
+	}
+	return nil, ErrNoLogs
+}
+
+func (tx *memoryTx) GetStepLogsToCompensate(executionID string) ([]*Log, error) {
+	logs, ok := tx.executionLogs(executionID)
+	if !ok {
+		return nil, ErrNoLogs
+	}
+	return stepLogsToCompensate(logs), nil
+}
+
+func (tx *memoryTx) GetLogs(executionID string, types []string, from, to time.Time) ([]*Log, error) {
+	logs, ok := tx.executionLogs(executionID)
+	if !ok {
+		return nil, ErrNoLogs
+	}
+	return filterLogs(logs, LogFilter{Types: types, From: from, To: to}), nil
+}
+
+func (tx *memoryTx) GetLogsPage(executionID string, page Page) ([]*Log, string, error) {
+	logs, ok := tx.executionLogs(executionID)
+	if !ok {
+		return nil, "", ErrNoLogs
+	}
+	return logsPage(logs, page)
+}
+
+// ListExecutions lists executions of the store followed by ones started by the transaction.
+func (tx *memoryTx) ListExecutions(filter ExecutionFilter, page Page) ([]*Status, string, error) {
+	tx.store.orderMu.RLock()
+	order := append([]string(nil), tx.store.order...)
+	tx.store.orderMu.RUnlock()
+	known := make(map[string]bool, len(order))
+	for _, executionID := range order {
+		known[executionID] = true
+	}
+	for _, log := range tx.logs {
+		if !known[log.ExecutionID] {
+			known[log.ExecutionID] = true
+			order = append(order, log.ExecutionID)
+		}
+	}
+	return pageExecutions(order, tx.executionLogs, filter, page)
+}
+
+// DeleteByMetadata deletes logs of the store immediately, deletes aren't part of the transaction.
+func (tx *memoryTx) DeleteByMetadata(key, value string) (int, error) {
+	return tx.store.DeleteByMetadata(key, value)
+}
+
+// DeleteTenantByMetadata deletes logs of the store immediately, deletes aren't part of the transaction.
+func (tx *memoryTx) DeleteTenantByMetadata(tenantID, key, value string) (int, error) {
+	return tx.store.DeleteTenantByMetadata(tenantID, key, value)
+}
+
+// Watch watches the store, logs appended by the transaction are sent once it's committed.
+func (tx *memoryTx) Watch(ctx context.Context, filter LogFilter) <-chan *Log {
+	return tx.store.Watch(ctx, filter)
+}
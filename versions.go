@@ -0,0 +1,72 @@
+package saga
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrVersionConflict = errors.New("resource was changed by someone else")
+	ErrNoVersion       = errors.New("no version recorded for resource")
+	ErrNotInStep       = errors.New("context isn't a context of a step")
+)
+
+// recordedVersion is the payload of LogTypeSagaVersionRecorded logs.
+type recordedVersion struct {
+	Resource string `json:"resource"`
+	Version  string `json:"version"`
+}
+
+type stepKey struct{}
+
+// stepScope is the step being executed, it's put into context of step funcs.
+type stepScope struct {
+	c    *ExecutionCoordinator
+	step int
+}
+
+// RecordVersion records version of the resource read by the step, e.g. ETag or row version, so later
+// steps can check by VerifyVersion that nobody has changed it in between. Versions are written to the Store,
+// so they are checked after Resume too. ctx is the context passed to a step.
+func RecordVersion(ctx context.Context, resource, version string) error {
+	scope, ok := ctx.Value(stepKey{}).(*stepScope)
+	if !ok {
+		return ErrNotInStep
+	}
+	payload, err := json.Marshal(&recordedVersion{Resource: resource, Version: version})
+	if err != nil {
+		return err
+	}
+	c := scope.c
+	c.appendLog(&Log{
+		Type:        LogTypeSagaVersionRecorded,
+		StepNumber:  &scope.step,
+		StepName:    &c.saga.steps[scope.step].Name,
+		StepPayload: payload,
+	})
+	return nil
+}
+
+// VerifyVersion returns ErrVersionConflict if the current version of the resource differs from the one
+// recorded by RecordVersion. Steps return it to compensate the execution instead of overwriting changes
+// made since the resource was read.
+func VerifyVersion(ctx context.Context, resource, current string) error {
+	scope, ok := ctx.Value(stepKey{}).(*stepScope)
+	if !ok {
+		return ErrNotInStep
+	}
+	p, err := scope.c.loadProgress()
+	if err != nil {
+		return err
+	}
+	recorded, ok := p.versions[resource]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNoVersion, resource)
+	}
+	if recorded != current {
+		return fmt.Errorf("%w: %s had version %s, but it's %s now", ErrVersionConflict, resource, recorded, current)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package saga
+
+import (
+	"context"
+	"sync"
+)
+
+// watcher queues logs appended to the memory store for a Watch channel, so appends never wait for readers.
+type watcher struct {
+	filter LogFilter
+	mu     sync.Mutex
+	queue  []*Log
+	notify chan struct{}
+}
+
+func (s *store) Watch(ctx context.Context, filter LogFilter) <-chan *Log {
+	w := &watcher{filter: filter, notify: make(chan struct{}, 1)}
+	s.watchMu.Lock()
+	s.watchers[w] = struct{}{}
+	s.watchMu.Unlock()
+
+	ch := make(chan *Log)
+	go func() {
+		defer close(ch)
+		defer func() {
+			s.watchMu.Lock()
+			delete(s.watchers, w)
+			s.watchMu.Unlock()
+		}()
+		w.run(ctx, ch)
+	}()
+	return ch
+}
+
+func (w *watcher) push(l *Log) {
+	if !w.filter.Match(l) {
+		return
+	}
+	w.mu.Lock()
+	w.queue = append(w.queue, l)
+	w.mu.Unlock()
+	select {
+	case w.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (w *watcher) run(ctx context.Context, ch chan<- *Log) {
+	for {
+		w.mu.Lock()
+		queue := w.queue
+		w.queue = nil
+		w.mu.Unlock()
+		for _, l := range queue {
+			select {
+			case ch <- l:
+			case <-ctx.Done():
+				return
+			}
+		}
+		select {
+		case <-w.notify:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// mapLogs returns channel of logs of in mapped by f, logs for which f returns nil are skipped.
+// It's used by decorators of Store.Watch.
+func mapLogs(ctx context.Context, in <-chan *Log, f func(l *Log) *Log) <-chan *Log {
+	out := make(chan *Log)
+	go func() {
+		defer close(out)
+		for l := range in {
+			if l = f(l); l == nil {
+				continue
+			}
+			select {
+			case out <- l:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// closedLogs returns channel of no logs.
+func closedLogs() <-chan *Log {
+	ch := make(chan *Log)
+	close(ch)
+	return ch
+}
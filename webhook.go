@@ -0,0 +1,110 @@
+package saga
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultWebhookRetry retries deliveries of webhooks of WebhookNotifier.
+var DefaultWebhookRetry = RetryPolicy{MaxAttempts: 5, Backoff: time.Second, MaxBackoff: time.Minute}
+
+// WebhookNotifier is EventSink that POSTs Status of executions to the URLs when they reach a terminal state:
+// completed, compensated or dead-lettered. Bodies are signed like callbacks of CallbackHandler, with
+// CallbackTimestampHeader and CallbackSignatureHeader, so receivers verify them by SignCallback.
+// Failed deliveries are retried by Retry in background, each URL independently.
+type WebhookNotifier struct {
+	Client *http.Client
+	Retry  RetryPolicy
+	Clock  Clock
+	// OnError is called when a delivery has failed all attempts
+	OnError func(url string, status *Status, err error)
+
+	store  Store
+	secret []byte
+	urls   []string
+	wg     sync.WaitGroup
+}
+
+// NewWebhookNotifier returns notifier that reads statuses of executions from the store, the one passed to CloudEvents.
+func NewWebhookNotifier(store Store, secret []byte, urls ...string) *WebhookNotifier {
+	checkOK(len(secret) > 0, "secret must not be empty")
+	return &WebhookNotifier{
+		Client:  http.DefaultClient,
+		Retry:   DefaultWebhookRetry,
+		Clock:   SystemClock,
+		OnError: func(string, *Status, error) {},
+		store:   store,
+		secret:  secret,
+		urls:    urls,
+	}
+}
+
+func (n *WebhookNotifier) Emit(event *CloudEvent) {
+	switch event.Type {
+	case EventTypeCompleted, EventTypeCompensated, EventTypeDeadLettered:
+	default:
+		return
+	}
+	status, err := GetStatus(n.store, event.Subject)
+	if err != nil {
+		for _, url := range n.urls {
+			n.OnError(url, &Status{ExecutionID: event.Subject}, err)
+		}
+		return
+	}
+	body, err := json.Marshal(status)
+	checkErr(err, "json.Marshal(Status)")
+	for _, url := range n.urls {
+		n.wg.Add(1)
+		go func(url string) {
+			defer n.wg.Done()
+			if err := n.deliver(url, body); err != nil {
+				n.OnError(url, status, err)
+			}
+		}(url)
+	}
+}
+
+// Wait blocks until pending deliveries succeed or fail all attempts.
+func (n *WebhookNotifier) Wait() {
+	n.wg.Wait()
+}
+
+func (n *WebhookNotifier) deliver(url string, body []byte) error {
+	for attempts := 1; ; attempts++ {
+		err := n.post(url, body)
+		if err == nil || n.Retry.MaxAttempts > 0 && attempts >= n.Retry.MaxAttempts {
+			return err
+		}
+		time.Sleep(n.Retry.backoff(attempts))
+	}
+}
+
+// post sends the body signed at the time of the attempt, so retries aren't rejected as replays.
+func (n *WebhookNotifier) post(url string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	now := n.Clock.Now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(CallbackTimestampHeader, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(CallbackSignatureHeader, SignCallback(n.secret, now, body))
+	resp, err := n.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s: unexpected status %s", url, resp.Status)
+	}
+	return nil
+}
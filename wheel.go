@@ -0,0 +1,108 @@
+package saga
+
+import "time"
+
+const (
+	wheelBits   = 6
+	wheelSlots  = 1 << wheelBits
+	wheelLevels = 4
+)
+
+// timerWheel is a hierarchical timer wheel keeping timers of executions by ID. Level 0 has a slot
+// per tick, each next level has a slot per revolution of the previous one. Timers are placed to
+// the lowest level that spans them and cascade down as time advances, so adding and firing
+// timers costs O(1) regardless of their number.
+type timerWheel struct {
+	tick    time.Duration
+	current int64
+	levels  [wheelLevels][wheelSlots]map[string]int64
+	// timers are expiration ticks by ID, entries in slots that don't match them are stale
+	timers  map[string]int64
+	expired []wheelEntry
+}
+
+type wheelEntry struct {
+	id         string
+	expiration int64
+}
+
+func newTimerWheel(tick time.Duration, now time.Time) *timerWheel {
+	w := &timerWheel{tick: tick, timers: make(map[string]int64)}
+	w.current = w.ticks(now)
+	return w
+}
+
+func (w *timerWheel) ticks(t time.Time) int64 {
+	return t.UnixNano() / int64(w.tick)
+}
+
+func (w *timerWheel) len() int {
+	return len(w.timers)
+}
+
+// add sets the timer of the execution, it replaces its previous timer.
+func (w *timerWheel) add(id string, at time.Time) {
+	// rounded up, so timers never fire early
+	expiration := (at.UnixNano() + int64(w.tick) - 1) / int64(w.tick)
+	if previous, ok := w.timers[id]; ok && previous == expiration {
+		return
+	}
+	w.timers[id] = expiration
+	w.place(id, expiration)
+}
+
+func (w *timerWheel) place(id string, expiration int64) {
+	delta := expiration - w.current
+	if delta <= 0 {
+		w.expired = append(w.expired, wheelEntry{id, expiration})
+		return
+	}
+	level := 0
+	for level < wheelLevels-1 && delta >= 1<<(wheelBits*(level+1)) {
+		level++
+	}
+	slot := (expiration >> (wheelBits * level)) & (wheelSlots - 1)
+	if w.levels[level][slot] == nil {
+		w.levels[level][slot] = make(map[string]int64)
+	}
+	w.levels[level][slot][id] = expiration
+}
+
+// advance moves the wheel to now and returns IDs of executions with expired timers.
+func (w *timerWheel) advance(now time.Time) []string {
+	target := w.ticks(now)
+	for w.current < target {
+		if len(w.timers) == 0 {
+			// nothing to cascade or fire
+			w.current = target
+			break
+		}
+		w.current++
+		for level := 1; level < wheelLevels; level++ {
+			if w.current&(1<<(wheelBits*level)-1) != 0 {
+				break
+			}
+			slot := (w.current >> (wheelBits * level)) & (wheelSlots - 1)
+			entries := w.levels[level][slot]
+			w.levels[level][slot] = nil
+			for id, expiration := range entries {
+				w.place(id, expiration)
+			}
+		}
+		slot := w.current & (wheelSlots - 1)
+		for id, expiration := range w.levels[0][slot] {
+			w.expired = append(w.expired, wheelEntry{id, expiration})
+		}
+		w.levels[0][slot] = nil
+	}
+
+	var due []string
+	for _, e := range w.expired {
+		if expiration, ok := w.timers[e.id]; ok && expiration == e.expiration {
+			due = append(due, e.id)
+			delete(w.timers, e.id)
+		}
+	}
+	w.expired = nil
+	return due
+}
@@ -0,0 +1,73 @@
+package saga
+
+import (
+	"errors"
+	"time"
+)
+
+const day = 24 * time.Hour
+
+// TimeWindow is a daily period of time, e.g. 02:00-04:00 UTC, see StepOptions.Window.
+type TimeWindow struct {
+	// Start and End are offsets from midnight, End before Start means the window spans midnight
+	Start time.Duration
+	End   time.Duration
+	// Location of the window, UTC if it's nil
+	Location *time.Location
+}
+
+func (w *TimeWindow) check() error {
+	if w.Start < 0 || w.Start >= day || w.End < 0 || w.End >= day || w.Start == w.End {
+		return errors.New("window must start and end at different times of day")
+	}
+	return nil
+}
+
+// Next returns t if the window is open at t, otherwise the time it opens next.
+func (w *TimeWindow) Next(t time.Time) time.Time {
+	location := w.Location
+	if location == nil {
+		location = time.UTC
+	}
+	length := (w.End - w.Start + day) % day
+	local := t.In(location)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, location)
+	// the window opened yesterday may still be open
+	for days := -1; ; days++ {
+		start := midnight.AddDate(0, 0, days).Add(w.Start)
+		if start.After(t) {
+			return start.In(t.Location())
+		}
+		if t.Before(start.Add(length)) {
+			return t
+		}
+	}
+}
+
+// waitWindow checks that the window of the step is open, it returns true if the execution is
+// parked until the window opens. The timer is written to the Store as a delay of the step.
+func (c *ExecutionCoordinator) waitWindow(i int) bool {
+	options := c.saga.steps[i].Options
+	if options == nil || options.Window == nil {
+		return false
+	}
+	now := c.Clock.Now()
+	opens := options.Window.Next(now)
+	if !opens.After(now) {
+		return false
+	}
+	c.delayed = true
+	p, err := c.loadProgress()
+	checkErr(err, "c.loadProgress()")
+	if fireAt, ok := p.delays[i]; ok && fireAt.Equal(opens) {
+		// the execution is resumed before the window opens
+		return true
+	}
+	c.appendLog(&Log{
+		Type:         LogTypeSagaStepDelayed,
+		StepNumber:   &i,
+		StepName:     &c.saga.steps[i].Name,
+		StepDuration: opens.Sub(now),
+	})
+	return true
+}